@@ -38,11 +38,11 @@ func main() {
 	}
 
 	dispatcher := jobs.NewDispatcher(redisClient, "trade-intents", cfg.RequestTTL, log.With().Str("component", "dispatcher").Logger())
-	authz := auth.NewAuthenticator(cfg.APIToken)
+	authz := auth.NewAuthenticator(cfg.APIToken, cfg.ServiceToken)
 	limiter := middleware.NewRateLimiter(cfg.RateLimitRPS)
 
-	taClient := ta.New(cfg.TAServiceURL)
-	server := httpapi.NewServer(authz, limiter, dispatcher, taClient, log.With().Str("component", "api").Logger())
+	taClient := ta.New(cfg.TAServiceURL, cfg.TAAdminToken)
+	server := httpapi.NewServer(authz, limiter, dispatcher, taClient, cfg.AdminChats, log.With().Str("component", "api").Logger())
 
 	srv := &http.Server{
 		Addr:    cfg.HTTPAddr,
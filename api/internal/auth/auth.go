@@ -14,25 +14,43 @@ const (
     CtxKeyPrincipal ContextKey = "principal"
 )
 
+// Principals a request can authenticate as.
+const (
+    // PrincipalBot holds the shared API token.
+    PrincipalBot = "bot"
+    // PrincipalService is the TA service posting strategy trade intents.
+    PrincipalService = "ta-service"
+)
+
 // Authenticator validates bearer tokens.
 type Authenticator struct {
-    apiToken string
+    apiToken     string
+    serviceToken string
 }
 
-// NewAuthenticator creates a new Authenticator instance.
-func NewAuthenticator(token string) *Authenticator {
-    return &Authenticator{apiToken: token}
+// NewAuthenticator creates a new Authenticator instance. An empty
+// serviceToken disables the service principal.
+func NewAuthenticator(token, serviceToken string) *Authenticator {
+    return &Authenticator{apiToken: token, serviceToken: serviceToken}
 }
 
 // Middleware checks bearer token and attaches a principal to the context.
 func (a *Authenticator) Middleware(next http.Handler) http.Handler {
     return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
         token := extractBearer(r.Header.Get("Authorization"))
-        if token == "" || token != a.apiToken {
+        var principal string
+        switch {
+        case token == "":
+        case token == a.apiToken:
+            principal = PrincipalBot
+        case token == a.serviceToken:
+            principal = PrincipalService
+        }
+        if principal == "" {
             http.Error(w, "unauthorized", http.StatusUnauthorized)
             return
         }
-        ctx := context.WithValue(r.Context(), CtxKeyPrincipal, "bot")
+        ctx := context.WithValue(r.Context(), CtxKeyPrincipal, principal)
         next.ServeHTTP(w, r.WithContext(ctx))
     })
 }
@@ -15,10 +15,13 @@ type Config struct {
 	RedisURL      string        `mapstructure:"redis_url"`
 	NATSServerURL string        `mapstructure:"nats_url"`
 	APIToken      string        `mapstructure:"api_token"`
+	ServiceToken  string        `mapstructure:"service_token"`
 	AllowedChats  []int64       `mapstructure:"allowed_chats"`
+	AdminChats    []int64       `mapstructure:"admin_chats"`
 	RateLimitRPS  int           `mapstructure:"rate_limit_rps"`
 	RequestTTL    time.Duration `mapstructure:"request_ttl"`
 	TAServiceURL  string        `mapstructure:"ta_service_url"`
+	TAAdminToken  string        `mapstructure:"ta_admin_token"`
 }
 
 // Load reads configuration from env and optional config file.
@@ -58,6 +61,10 @@ func Load() (Config, error) {
 		return Config{}, fmt.Errorf("api_token must be set")
 	}
 
+	if cfg.ServiceToken == cfg.APIToken {
+		return Config{}, fmt.Errorf("service_token must differ from api_token")
+	}
+
 	if cfg.TAServiceURL == "" {
 		return Config{}, fmt.Errorf("ta_service_url must be set")
 	}
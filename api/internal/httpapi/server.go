@@ -2,7 +2,10 @@ package httpapi
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -26,6 +29,10 @@ type TradeRequest struct {
 	PaperTrading bool    `json:"paper_trading"`
 	Interval     string  `json:"interval,omitempty"`
 	Force        bool    `json:"force,omitempty"`
+	ChatID       int64   `json:"chat_id,omitempty"`
+	// Strategy skips the chat's auto-trade filter in exec, so it is only
+	// kept on intents from the TA service's strategy runner.
+	Strategy string `json:"strategy,omitempty"`
 }
 
 // ActionRequest is a generic action payload.
@@ -39,16 +46,21 @@ type Server struct {
 	dispatcher *jobs.Dispatcher
 	logger     zerolog.Logger
 	taClient   *ta.Client
+	adminChats map[int64]bool
 }
 
-// NewServer builds the HTTP router.
-func NewServer(authz *auth.Authenticator, limiter *middleware.RateLimiter, dispatcher *jobs.Dispatcher, taClient *ta.Client, logger zerolog.Logger) *Server {
+// NewServer builds the HTTP router. adminChats may remove any chat's watched
+// symbols.
+func NewServer(authz *auth.Authenticator, limiter *middleware.RateLimiter, dispatcher *jobs.Dispatcher, taClient *ta.Client, adminChats []int64, logger zerolog.Logger) *Server {
 	r := chi.NewRouter()
 	r.Use(cors.AllowAll().Handler)
 	r.Use(limiter.Middleware)
 	r.Use(authz.Middleware)
 
-	srv := &Server{router: r, dispatcher: dispatcher, taClient: taClient, logger: logger}
+	srv := &Server{router: r, dispatcher: dispatcher, taClient: taClient, adminChats: make(map[int64]bool, len(adminChats)), logger: logger}
+	for _, id := range adminChats {
+		srv.adminChats[id] = true
+	}
 	r.Get("/healthz", srv.health)
 	r.Get("/readyz", srv.ready)
 	r.Post("/v1/trades", srv.createTrade)
@@ -56,6 +68,24 @@ func NewServer(authz *auth.Authenticator, limiter *middleware.RateLimiter, dispa
 	r.Get("/v1/ta/rsi/{pair}/{interval}", srv.rsi)
 	r.Get("/v1/ta/macd/{pair}/{interval}", srv.macd)
 	r.Get("/v1/ta/signals/{pair}/{interval}", srv.signals)
+	r.Get("/v1/ta/{name}/{pair}/{interval}/series", srv.series)
+	r.Get("/v1/ta/watch", srv.listWatched)
+	r.Post("/v1/ta/watch", srv.watch)
+	r.Delete("/v1/ta/watch/{pair}/{interval}", srv.unwatch)
+	r.Post("/v1/filters/validate", srv.validateFilter)
+	r.Get("/v1/strategies", srv.listStrategies)
+	r.Post("/v1/strategies", srv.createStrategy)
+	r.Put("/v1/strategies/filter", srv.setChatFilter)
+	r.Delete("/v1/strategies/filter", srv.clearChatFilter)
+	r.Patch("/v1/strategies/{id}", srv.updateStrategy)
+	r.Delete("/v1/strategies/{id}", srv.deleteStrategy)
+	r.Get("/v1/alerts", srv.listAlerts)
+	r.Post("/v1/alerts", srv.createAlert)
+	r.Delete("/v1/alerts/{id}", srv.deleteAlert)
+	r.Get("/v1/alerts/events", srv.alertEvents)
+	r.Post("/v1/alerts/events/ack", srv.ackAlertEvents)
+	r.Post("/v1/backtests", srv.submitBacktest)
+	r.Get("/v1/backtests/{id}", srv.getBacktest)
 
 	return srv
 }
@@ -88,6 +118,9 @@ func (s *Server) createTrade(w http.ResponseWriter, r *http.Request) {
 	}
 
 	principal := r.Context().Value(auth.CtxKeyPrincipal).(string)
+	if principal != auth.PrincipalService {
+		req.Strategy = ""
+	}
 	intentID, err := s.dispatcher.Publish(r.Context(), principal, req)
 	if err != nil {
 		s.logger.Error().Err(err).Msg("failed to dispatch trade")
@@ -155,6 +188,304 @@ func (s *Server) signals(w http.ResponseWriter, r *http.Request) {
 	s.writeJSON(w, result)
 }
 
+func (s *Server) series(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	pair := chi.URLParam(r, "pair")
+	interval := chi.URLParam(r, "interval")
+	query := url.Values{}
+	for _, key := range []string{"from", "to", "limit"} {
+		if v := r.URL.Query().Get(key); v != "" {
+			query.Set(key, v)
+		}
+	}
+	result, err := s.taClient.FetchSeries(name, pair, interval, query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	s.writeJSON(w, result)
+}
+
+func (s *Server) listWatched(w http.ResponseWriter, _ *http.Request) {
+	result, err := s.taClient.ListWatched()
+	if err != nil {
+		s.taError(w, err)
+		return
+	}
+	s.writeJSON(w, map[string]interface{}{"symbols": result})
+}
+
+func (s *Server) watch(w http.ResponseWriter, r *http.Request) {
+	var req ta.WatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if req.Pair == "" || req.Interval == "" {
+		http.Error(w, "pair and interval required", http.StatusBadRequest)
+		return
+	}
+	result, err := s.taClient.Watch(req)
+	if err != nil {
+		s.taError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// unwatch removes a symbol for chat_id, which must have added it unless it is
+// an admin chat.
+func (s *Server) unwatch(w http.ResponseWriter, r *http.Request) {
+	chatID, err := strconv.ParseInt(r.URL.Query().Get("chat_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid chat_id", http.StatusBadRequest)
+		return
+	}
+	owner := strconv.FormatInt(chatID, 10)
+	if s.adminChats[chatID] {
+		owner = ""
+	}
+	if err := s.taClient.Unwatch(chi.URLParam(r, "pair"), chi.URLParam(r, "interval"), owner); err != nil {
+		s.taError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) validateFilter(w http.ResponseWriter, r *http.Request) {
+	var req ta.FilterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	result, err := s.taClient.ValidateFilter(req)
+	if err != nil {
+		s.taError(w, err)
+		return
+	}
+	s.writeJSON(w, result)
+}
+
+func (s *Server) listAlerts(w http.ResponseWriter, r *http.Request) {
+	chatID, err := strconv.ParseInt(r.URL.Query().Get("chat_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid chat_id", http.StatusBadRequest)
+		return
+	}
+	result, err := s.taClient.ListAlerts(chatID)
+	if err != nil {
+		s.taError(w, err)
+		return
+	}
+	s.writeJSON(w, map[string]interface{}{"alerts": result})
+}
+
+func (s *Server) createAlert(w http.ResponseWriter, r *http.Request) {
+	var req ta.Alert
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	result, err := s.taClient.CreateAlert(req)
+	if err != nil {
+		s.taError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+func (s *Server) deleteAlert(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid alert id", http.StatusBadRequest)
+		return
+	}
+	chatID, err := strconv.ParseInt(r.URL.Query().Get("chat_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid chat_id", http.StatusBadRequest)
+		return
+	}
+	if err := s.taClient.DeleteAlert(chatID, id); err != nil {
+		s.taError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) alertEvents(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	result, err := s.taClient.PendingAlertEvents(limit)
+	if err != nil {
+		s.taError(w, err)
+		return
+	}
+	s.writeJSON(w, map[string]interface{}{"events": result})
+}
+
+func (s *Server) ackAlertEvents(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		IDs []int64 `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if err := s.taClient.AckAlertEvents(req.IDs); err != nil {
+		s.taError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) listStrategies(w http.ResponseWriter, r *http.Request) {
+	chatID, err := strconv.ParseInt(r.URL.Query().Get("chat_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid chat_id", http.StatusBadRequest)
+		return
+	}
+	result, err := s.taClient.ListStrategies(chatID)
+	if err != nil {
+		s.taError(w, err)
+		return
+	}
+	s.writeJSON(w, result)
+}
+
+func (s *Server) createStrategy(w http.ResponseWriter, r *http.Request) {
+	var req ta.Strategy
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	result, err := s.taClient.CreateStrategy(req)
+	if err != nil {
+		s.taError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+func (s *Server) updateStrategy(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid strategy id", http.StatusBadRequest)
+		return
+	}
+	chatID, err := strconv.ParseInt(r.URL.Query().Get("chat_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid chat_id", http.StatusBadRequest)
+		return
+	}
+	var req ta.StrategyMode
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	result, err := s.taClient.UpdateStrategy(chatID, id, req)
+	if err != nil {
+		s.taError(w, err)
+		return
+	}
+	s.writeJSON(w, result)
+}
+
+func (s *Server) deleteStrategy(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid strategy id", http.StatusBadRequest)
+		return
+	}
+	chatID, err := strconv.ParseInt(r.URL.Query().Get("chat_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid chat_id", http.StatusBadRequest)
+		return
+	}
+	if err := s.taClient.DeleteStrategy(chatID, id); err != nil {
+		s.taError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) setChatFilter(w http.ResponseWriter, r *http.Request) {
+	var req ta.ChatFilter
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	result, err := s.taClient.SetChatFilter(req)
+	if err != nil {
+		s.taError(w, err)
+		return
+	}
+	s.writeJSON(w, result)
+}
+
+func (s *Server) clearChatFilter(w http.ResponseWriter, r *http.Request) {
+	chatID, err := strconv.ParseInt(r.URL.Query().Get("chat_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid chat_id", http.StatusBadRequest)
+		return
+	}
+	if err := s.taClient.ClearChatFilter(chatID); err != nil {
+		s.taError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) submitBacktest(w http.ResponseWriter, r *http.Request) {
+	var req ta.BacktestRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	result, err := s.taClient.SubmitBacktest(req)
+	if err != nil {
+		s.taError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+func (s *Server) getBacktest(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid backtest id", http.StatusBadRequest)
+		return
+	}
+	chatID, err := strconv.ParseInt(r.URL.Query().Get("chat_id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid chat_id", http.StatusBadRequest)
+		return
+	}
+	result, err := s.taClient.GetBacktest(chatID, id)
+	if err != nil {
+		s.taError(w, err)
+		return
+	}
+	s.writeJSON(w, result)
+}
+
+// taError forwards client errors from the TA service and maps everything
+// else to 502.
+func (s *Server) taError(w http.ResponseWriter, err error) {
+	var statusErr *ta.StatusError
+	if errors.As(err, &statusErr) && statusErr.Status >= 400 && statusErr.Status < 500 && statusErr.Status != http.StatusUnauthorized {
+		http.Error(w, statusErr.Message, statusErr.Status)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadGateway)
+}
+
 func (s *Server) writeJSON(w http.ResponseWriter, payload interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	_ = json.NewEncoder(w).Encode(payload)
@@ -1,27 +1,45 @@
 package ta
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 )
 
 // Client queries the TA service for indicator data.
 type Client struct {
-	baseURL string
-	client  *http.Client
+	baseURL    string
+	adminToken string
+	client     *http.Client
 }
 
-// New creates a client.
-func New(baseURL string) *Client {
+// New creates a client. adminToken authorises the TA admin endpoints.
+func New(baseURL, adminToken string) *Client {
 	return &Client{
-		baseURL: strings.TrimRight(baseURL, "/"),
-		client:  &http.Client{Timeout: 5 * time.Second},
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		adminToken: adminToken,
+		client:     &http.Client{Timeout: 15 * time.Second},
 	}
 }
 
+// StatusError is a non-2xx TA service response.
+type StatusError struct {
+	Status  int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("ta service status %d", e.Status)
+	}
+	return fmt.Sprintf("ta service status %d: %s", e.Status, e.Message)
+}
+
 // IndicatorResponse describes a single value indicator.
 type IndicatorResponse struct {
 	Value float64 `json:"value"`
@@ -60,18 +78,316 @@ func (c *Client) FetchSignals(pair, interval string) (SignalsResponse, error) {
 	return resp, err
 }
 
+// SeriesResponse is an indicator history aligned to candle start times.
+type SeriesResponse struct {
+	Name       string               `json:"name"`
+	Pair       string               `json:"pair"`
+	Interval   string               `json:"interval"`
+	Timestamps []time.Time          `json:"timestamps"`
+	Values     []float64            `json:"values"`
+	Components map[string][]float64 `json:"components,omitempty"`
+}
+
+// FetchSeries returns the indicator history, forwarding from/to/limit filters.
+func (c *Client) FetchSeries(name, pair, interval string, query url.Values) (SeriesResponse, error) {
+	var resp SeriesResponse
+	target := fmt.Sprintf("%s/v1/indicators/%s/%s/%s/series", c.baseURL, url.PathEscape(name), url.PathEscape(pair), url.PathEscape(interval))
+	if encoded := query.Encode(); encoded != "" {
+		target += "?" + encoded
+	}
+	err := c.get(target, &resp)
+	return resp, err
+}
+
+// WatchedSymbol is a pair/interval tracked by the TA service.
+type WatchedSymbol struct {
+	Exchange  string    `json:"exchange"`
+	Pair      string    `json:"pair"`
+	Interval  string    `json:"interval"`
+	AddedBy   string    `json:"added_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WatchRequest adds a pair/interval to the TA watchlist.
+type WatchRequest struct {
+	Pair     string `json:"pair"`
+	Interval string `json:"interval"`
+	AddedBy  string `json:"added_by,omitempty"`
+}
+
+// ListWatched returns the tracked symbols.
+func (c *Client) ListWatched() ([]WatchedSymbol, error) {
+	var resp struct {
+		Symbols []WatchedSymbol `json:"symbols"`
+	}
+	err := c.do(http.MethodGet, c.baseURL+"/v1/admin/symbols", nil, &resp)
+	return resp.Symbols, err
+}
+
+// Watch starts tracking a pair/interval.
+func (c *Client) Watch(req WatchRequest) (WatchedSymbol, error) {
+	var resp WatchedSymbol
+	err := c.do(http.MethodPost, c.baseURL+"/v1/admin/symbols", req, &resp)
+	return resp, err
+}
+
+// Unwatch stops tracking a pair/interval. A non-empty owner must match the
+// symbol's added_by.
+func (c *Client) Unwatch(pair, interval, owner string) error {
+	target := fmt.Sprintf("%s/v1/admin/symbols/%s/%s", c.baseURL, url.PathEscape(pair), url.PathEscape(interval))
+	if owner != "" {
+		target += "?" + url.Values{"owner": {owner}}.Encode()
+	}
+	return c.do(http.MethodDelete, target, nil, nil)
+}
+
+// FilterRequest is an auto-trade filter to validate.
+type FilterRequest struct {
+	Expression string `json:"expression"`
+	Interval   string `json:"interval,omitempty"`
+}
+
+// FilterValidation is the TA service verdict on a filter expression.
+type FilterValidation struct {
+	Valid     bool     `json:"valid"`
+	Canonical string   `json:"canonical,omitempty"`
+	Intervals []string `json:"intervals,omitempty"`
+	Refs      []string `json:"refs,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	Position  int      `json:"position,omitempty"`
+}
+
+// ValidateFilter parses and type-checks a filter expression.
+func (c *Client) ValidateFilter(req FilterRequest) (FilterValidation, error) {
+	var resp FilterValidation
+	err := c.do(http.MethodPost, c.baseURL+"/v1/filters/validate", req, &resp)
+	return resp, err
+}
+
+// Alert is a per-chat condition evaluated by the TA service on closed candles.
+type Alert struct {
+	ID        int64      `json:"id,omitempty"`
+	ChatID    int64      `json:"chat_id"`
+	Kind      string     `json:"kind"`
+	Exchange  string     `json:"exchange,omitempty"`
+	Pair      string     `json:"pair"`
+	Interval  string     `json:"interval"`
+	Indicator string     `json:"indicator,omitempty"`
+	Direction string     `json:"direction,omitempty"`
+	Threshold float64    `json:"threshold,omitempty"`
+	Lookback  int        `json:"lookback,omitempty"`
+	Recurring bool       `json:"recurring"`
+	Active    bool       `json:"active"`
+	LastFired *time.Time `json:"last_fired,omitempty"`
+	CreatedAt time.Time  `json:"created_at,omitempty"`
+}
+
+// AlertEvent is a fired alert awaiting delivery.
+type AlertEvent struct {
+	ID      int64     `json:"id"`
+	AlertID int64     `json:"alert_id"`
+	ChatID  int64     `json:"chat_id"`
+	Message string    `json:"message"`
+	FiredAt time.Time `json:"fired_at"`
+}
+
+// ListAlerts returns a chat's alerts.
+func (c *Client) ListAlerts(chatID int64) ([]Alert, error) {
+	var resp struct {
+		Alerts []Alert `json:"alerts"`
+	}
+	err := c.do(http.MethodGet, fmt.Sprintf("%s/v1/alerts?chat_id=%d", c.baseURL, chatID), nil, &resp)
+	return resp.Alerts, err
+}
+
+// CreateAlert stores a new alert.
+func (c *Client) CreateAlert(alert Alert) (Alert, error) {
+	var resp Alert
+	err := c.do(http.MethodPost, c.baseURL+"/v1/alerts", alert, &resp)
+	return resp, err
+}
+
+// DeleteAlert removes a chat's alert.
+func (c *Client) DeleteAlert(chatID, id int64) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("%s/v1/alerts/%d?chat_id=%d", c.baseURL, id, chatID), nil, nil)
+}
+
+// PendingAlertEvents returns fired alerts not yet delivered.
+func (c *Client) PendingAlertEvents(limit int) ([]AlertEvent, error) {
+	var resp struct {
+		Events []AlertEvent `json:"events"`
+	}
+	err := c.do(http.MethodGet, fmt.Sprintf("%s/v1/alerts/events?limit=%d", c.baseURL, limit), nil, &resp)
+	return resp.Events, err
+}
+
+// AckAlertEvents marks events delivered.
+func (c *Client) AckAlertEvents(ids []int64) error {
+	return c.do(http.MethodPost, c.baseURL+"/v1/alerts/events/ack", map[string][]int64{"ids": ids}, nil)
+}
+
+// Strategy is a chat's auto-trade rule set run by the TA service.
+type Strategy struct {
+	ID              int64      `json:"id,omitempty"`
+	ChatID          int64      `json:"chat_id"`
+	Name            string     `json:"name"`
+	Pair            string     `json:"pair"`
+	Interval        string     `json:"interval"`
+	Entry           string     `json:"entry"`
+	Exit            string     `json:"exit,omitempty"`
+	Size            float64    `json:"size"`
+	SlippageBps     int        `json:"slippage_bps,omitempty"`
+	CooldownSeconds int        `json:"cooldown_seconds,omitempty"`
+	MaxPositions    int        `json:"max_positions,omitempty"`
+	DryRun          bool       `json:"dry_run"`
+	PaperTrading    bool       `json:"paper_trading"`
+	Enabled         bool       `json:"enabled"`
+	OpenPositions   int        `json:"open_positions"`
+	LastTrade       *time.Time `json:"last_trade,omitempty"`
+	CreatedAt       time.Time  `json:"created_at,omitempty"`
+}
+
+// StrategyMode pauses, resumes or switches a strategy between dry runs and
+// live intents; nil fields are left unchanged.
+type StrategyMode struct {
+	Enabled *bool `json:"enabled,omitempty"`
+	DryRun  *bool `json:"dry_run,omitempty"`
+}
+
+// ChatFilter gates every strategy entry of a chat.
+type ChatFilter struct {
+	ChatID     int64     `json:"chat_id"`
+	Expression string    `json:"expression"`
+	Interval   string    `json:"interval"`
+	UpdatedAt  time.Time `json:"updated_at,omitempty"`
+}
+
+// StrategyList is a chat's strategies and auto-trade filter.
+type StrategyList struct {
+	Strategies []Strategy  `json:"strategies"`
+	Filter     *ChatFilter `json:"filter,omitempty"`
+}
+
+// ListStrategies returns a chat's strategies and filter.
+func (c *Client) ListStrategies(chatID int64) (StrategyList, error) {
+	var resp StrategyList
+	err := c.do(http.MethodGet, fmt.Sprintf("%s/v1/strategies?chat_id=%d", c.baseURL, chatID), nil, &resp)
+	return resp, err
+}
+
+// CreateStrategy stores a new strategy.
+func (c *Client) CreateStrategy(st Strategy) (Strategy, error) {
+	var resp Strategy
+	err := c.do(http.MethodPost, c.baseURL+"/v1/strategies", st, &resp)
+	return resp, err
+}
+
+// UpdateStrategy changes whether a strategy runs and whether it only notifies.
+func (c *Client) UpdateStrategy(chatID, id int64, mode StrategyMode) (Strategy, error) {
+	var resp Strategy
+	err := c.do(http.MethodPatch, fmt.Sprintf("%s/v1/strategies/%d?chat_id=%d", c.baseURL, id, chatID), mode, &resp)
+	return resp, err
+}
+
+// DeleteStrategy removes a chat's strategy.
+func (c *Client) DeleteStrategy(chatID, id int64) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("%s/v1/strategies/%d?chat_id=%d", c.baseURL, id, chatID), nil, nil)
+}
+
+// SetChatFilter stores a chat's auto-trade filter.
+func (c *Client) SetChatFilter(f ChatFilter) (ChatFilter, error) {
+	var resp ChatFilter
+	err := c.do(http.MethodPut, c.baseURL+"/v1/strategies/filter", f, &resp)
+	return resp, err
+}
+
+// ClearChatFilter removes a chat's auto-trade filter.
+func (c *Client) ClearChatFilter(chatID int64) error {
+	return c.do(http.MethodDelete, fmt.Sprintf("%s/v1/strategies/filter?chat_id=%d", c.baseURL, chatID), nil, nil)
+}
+
+// BacktestRequest queues a backtest of a strategy on a pair's stored
+// candles, over From to To or the Range, such as "90d", before now.
+type BacktestRequest struct {
+	ChatID   int64              `json:"chat_id,omitempty"`
+	Strategy string             `json:"strategy"`
+	Params   map[string]float64 `json:"params,omitempty"`
+	Pair     string             `json:"pair"`
+	Interval string             `json:"interval"`
+	From     *time.Time         `json:"from,omitempty"`
+	To       *time.Time         `json:"to,omitempty"`
+	Range    string             `json:"range,omitempty"`
+	Cash     float64            `json:"cash,omitempty"`
+}
+
+// Backtest is a queued, running or finished backtest job. Result holds the
+// report metrics once Status is done.
+type Backtest struct {
+	ID         int64              `json:"id"`
+	ChatID     int64              `json:"chat_id,omitempty"`
+	Strategy   string             `json:"strategy"`
+	Params     map[string]float64 `json:"params"`
+	Pair       string             `json:"pair"`
+	Interval   string             `json:"interval"`
+	From       time.Time          `json:"from"`
+	To         time.Time          `json:"to"`
+	Cash       float64            `json:"cash"`
+	Status     string             `json:"status"`
+	Error      string             `json:"error,omitempty"`
+	Result     json.RawMessage    `json:"result,omitempty"`
+	CreatedAt  time.Time          `json:"created_at"`
+	StartedAt  *time.Time         `json:"started_at,omitempty"`
+	FinishedAt *time.Time         `json:"finished_at,omitempty"`
+}
+
+// SubmitBacktest queues a backtest job.
+func (c *Client) SubmitBacktest(req BacktestRequest) (Backtest, error) {
+	var resp Backtest
+	err := c.do(http.MethodPost, c.baseURL+"/v1/backtests", req, &resp)
+	return resp, err
+}
+
+// GetBacktest returns a chat's backtest job.
+func (c *Client) GetBacktest(chatID, id int64) (Backtest, error) {
+	var resp Backtest
+	err := c.do(http.MethodGet, fmt.Sprintf("%s/v1/backtests/%d?chat_id=%d", c.baseURL, id, chatID), nil, &resp)
+	return resp, err
+}
+
 func (c *Client) get(url string, out interface{}) error {
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	return c.do(http.MethodGet, url, nil, out)
+}
+
+func (c *Client) do(method, url string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, url, reader)
 	if err != nil {
 		return err
 	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.adminToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.adminToken)
+	}
 	res, err := c.client.Do(req)
 	if err != nil {
 		return err
 	}
 	defer res.Body.Close()
 	if res.StatusCode >= 300 {
-		return fmt.Errorf("ta service status %d", res.StatusCode)
+		msg, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return &StatusError{Status: res.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	if out == nil || res.StatusCode == http.StatusNoContent {
+		return nil
 	}
 	return json.NewDecoder(res.Body).Decode(out)
 }
@@ -36,6 +36,9 @@ func main() {
     telemetry.StartHealthServer(ctx, cfg.HealthAddr, logger)
 
     router := handlers.NewRouter(handlers.NewHTTPAPIClient(cfg.APIBaseURL, cfg.APIToken, logger), logger)
+    if cfg.AlertPoll > 0 {
+        go router.RunAlertDelivery(ctx, bot, cfg.AlertPoll)
+    }
 
     updateCfg := tgbotapi.NewUpdate(0)
     updateCfg.Timeout = 60
@@ -3,6 +3,7 @@ package config
 import (
     "fmt"
     "strings"
+    "time"
 
     "github.com/spf13/viper"
 )
@@ -14,6 +15,7 @@ type Config struct {
     APIToken        string   `mapstructure:"api_token"`
     CommandPrefixes []string `mapstructure:"command_prefixes"`
     HealthAddr      string   `mapstructure:"health_addr"`
+    AlertPoll       time.Duration `mapstructure:"alert_poll"`
 }
 
 // Load returns a Config using viper to merge env + yaml files.
@@ -25,6 +27,7 @@ func Load() (Config, error) {
 
     v.SetDefault("health_addr", ":9091")
     v.SetDefault("command_prefixes", []string{"/"})
+    v.SetDefault("alert_poll", "5s")
 
     v.SetConfigName("bot")
     v.SetConfigType("yaml")
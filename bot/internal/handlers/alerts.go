@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const alertUsage = "Usage:\n" +
+	"/alert add <pair> <interval> price <above|below> <value> [recurring]\n" +
+	"/alert add <pair> <interval> <indicator> <above|below> <value> [recurring]\n" +
+	"/alert add <pair> <interval> move [up|down|any] <pct>% [candles] [recurring]\n" +
+	"/alert add <pair> <interval> bollinger [above|below|any] [recurring]\n" +
+	"/alert list\n" +
+	"/alert rm <id>"
+
+// Alert mirrors the TA alert payload.
+type Alert struct {
+	ID        int64      `json:"id,omitempty"`
+	ChatID    int64      `json:"chat_id"`
+	Kind      string     `json:"kind"`
+	Pair      string     `json:"pair"`
+	Interval  string     `json:"interval"`
+	Indicator string     `json:"indicator,omitempty"`
+	Direction string     `json:"direction,omitempty"`
+	Threshold float64    `json:"threshold,omitempty"`
+	Lookback  int        `json:"lookback,omitempty"`
+	Recurring bool       `json:"recurring"`
+	Active    bool       `json:"active"`
+	LastFired *time.Time `json:"last_fired,omitempty"`
+}
+
+// AlertEvent is a fired alert awaiting delivery.
+type AlertEvent struct {
+	ID      int64  `json:"id"`
+	ChatID  int64  `json:"chat_id"`
+	Message string `json:"message"`
+}
+
+func (c *HTTPAPIClient) CreateAlert(ctx context.Context, alert Alert) (Alert, error) {
+	var created Alert
+	err := c.send(ctx, http.MethodPost, "/v1/alerts", alert, &created)
+	return created, err
+}
+
+func (c *HTTPAPIClient) ListAlerts(ctx context.Context, chatID int64) ([]Alert, error) {
+	var resp struct {
+		Alerts []Alert `json:"alerts"`
+	}
+	if err := c.get(ctx, "/v1/alerts?chat_id="+strconv.FormatInt(chatID, 10), &resp); err != nil {
+		return nil, err
+	}
+	return resp.Alerts, nil
+}
+
+func (c *HTTPAPIClient) DeleteAlert(ctx context.Context, chatID, id int64) error {
+	return c.send(ctx, http.MethodDelete, fmt.Sprintf("/v1/alerts/%d?chat_id=%d", id, chatID), nil, nil)
+}
+
+func (c *HTTPAPIClient) PendingAlertEvents(ctx context.Context) ([]AlertEvent, error) {
+	var resp struct {
+		Events []AlertEvent `json:"events"`
+	}
+	if err := c.get(ctx, "/v1/alerts/events", &resp); err != nil {
+		return nil, err
+	}
+	return resp.Events, nil
+}
+
+func (c *HTTPAPIClient) AckAlertEvents(ctx context.Context, ids []int64) error {
+	return c.send(ctx, http.MethodPost, "/v1/alerts/events/ack", map[string][]int64{"ids": ids}, nil)
+}
+
+func (r *Router) handleAlert(ctx context.Context, bot *tgbotapi.BotAPI, msg *tgbotapi.Message) {
+	parts := strings.Fields(msg.CommandArguments())
+	if len(parts) == 0 {
+		r.reply(ctx, bot, msg.Chat.ID, alertUsage)
+		return
+	}
+	switch strings.ToLower(parts[0]) {
+	case "add":
+		alert, err := parseAlert(parts[1:])
+		if err != nil {
+			r.reply(ctx, bot, msg.Chat.ID, err.Error()+"\n\n"+alertUsage)
+			return
+		}
+		alert.ChatID = msg.Chat.ID
+		created, err := r.api.CreateAlert(ctx, alert)
+		if err != nil {
+			r.reply(ctx, bot, msg.Chat.ID, "Failed to add alert: "+err.Error())
+			return
+		}
+		r.reply(ctx, bot, msg.Chat.ID, fmt.Sprintf("Alert #%d set: %s", created.ID, describeAlert(created)))
+	case "list", "ls":
+		list, err := r.api.ListAlerts(ctx, msg.Chat.ID)
+		if err != nil {
+			r.reply(ctx, bot, msg.Chat.ID, "Alerts unavailable: "+err.Error())
+			return
+		}
+		if len(list) == 0 {
+			r.reply(ctx, bot, msg.Chat.ID, "No alerts. Use /alert add.")
+			return
+		}
+		var b strings.Builder
+		b.WriteString("Alerts:\n")
+		for _, a := range list {
+			status := ""
+			if !a.Active {
+				status = " (fired)"
+			}
+			b.WriteString(fmt.Sprintf("• #%d %s%s\n", a.ID, describeAlert(a), status))
+		}
+		r.reply(ctx, bot, msg.Chat.ID, b.String())
+	case "rm", "remove", "del":
+		if len(parts) < 2 {
+			r.reply(ctx, bot, msg.Chat.ID, "Usage: /alert rm <id>")
+			return
+		}
+		id, err := strconv.ParseInt(strings.TrimPrefix(parts[1], "#"), 10, 64)
+		if err != nil {
+			r.reply(ctx, bot, msg.Chat.ID, "invalid alert id")
+			return
+		}
+		if err := r.api.DeleteAlert(ctx, msg.Chat.ID, id); err != nil {
+			r.reply(ctx, bot, msg.Chat.ID, "Failed to remove alert: "+err.Error())
+			return
+		}
+		r.reply(ctx, bot, msg.Chat.ID, fmt.Sprintf("Alert #%d removed", id))
+	default:
+		r.reply(ctx, bot, msg.Chat.ID, alertUsage)
+	}
+}
+
+// parseAlert parses "<pair> <interval> <condition...> [recurring]".
+func parseAlert(args []string) (Alert, error) {
+	var alert Alert
+	if n := len(args); n > 0 && (strings.EqualFold(args[n-1], "recurring") || strings.EqualFold(args[n-1], "once")) {
+		alert.Recurring = strings.EqualFold(args[n-1], "recurring")
+		args = args[:n-1]
+	}
+	if len(args) < 3 {
+		return alert, fmt.Errorf("missing condition")
+	}
+	alert.Pair = strings.ToUpper(args[0])
+	alert.Interval = args[1]
+	subject := strings.ToLower(args[2])
+	rest := args[3:]
+	switch subject {
+	case "move":
+		alert.Kind = "move"
+		if len(rest) > 0 {
+			if dir, ok := moveDirection(rest[0]); ok {
+				alert.Direction = dir
+				rest = rest[1:]
+			}
+		}
+		if len(rest) == 0 {
+			return alert, fmt.Errorf("move needs a percentage")
+		}
+		pct, err := parseFloat(strings.TrimSuffix(rest[0], "%"))
+		if err != nil || pct <= 0 {
+			return alert, fmt.Errorf("invalid percentage %q", rest[0])
+		}
+		alert.Threshold = pct
+		if len(rest) > 1 {
+			bars, err := strconv.Atoi(rest[1])
+			if err != nil || bars <= 0 {
+				return alert, fmt.Errorf("invalid candle count %q", rest[1])
+			}
+			alert.Lookback = bars
+		}
+	case "bollinger", "bb":
+		alert.Kind = "bollinger"
+		if len(rest) > 0 {
+			dir, ok := moveDirection(rest[0])
+			if !ok {
+				return alert, fmt.Errorf("invalid direction %q", rest[0])
+			}
+			alert.Direction = dir
+		}
+	default:
+		alert.Kind = "indicator"
+		alert.Indicator = subject
+		if subject == "price" {
+			alert.Kind = "price"
+			alert.Indicator = ""
+		}
+		if len(rest) < 2 {
+			return alert, fmt.Errorf("%s needs a direction and a value", subject)
+		}
+		switch strings.ToLower(rest[0]) {
+		case "above", ">", ">=":
+			alert.Direction = "above"
+		case "below", "<", "<=":
+			alert.Direction = "below"
+		default:
+			return alert, fmt.Errorf("invalid direction %q", rest[0])
+		}
+		value, err := parseFloat(rest[1])
+		if err != nil {
+			return alert, fmt.Errorf("invalid value %q", rest[1])
+		}
+		alert.Threshold = value
+	}
+	return alert, nil
+}
+
+func moveDirection(v string) (string, bool) {
+	switch strings.ToLower(v) {
+	case "up", "above":
+		return "above", true
+	case "down", "below":
+		return "below", true
+	case "any":
+		return "any", true
+	}
+	return "", false
+}
+
+func describeAlert(a Alert) string {
+	var cond string
+	switch a.Kind {
+	case "price":
+		cond = fmt.Sprintf("price %s %g", a.Direction, a.Threshold)
+	case "indicator":
+		cond = fmt.Sprintf("`%s` %s %g", a.Indicator, a.Direction, a.Threshold)
+	case "move":
+		cond = fmt.Sprintf("move %s %g%% over %d candles", a.Direction, a.Threshold, a.Lookback)
+	case "bollinger":
+		cond = fmt.Sprintf("bollinger breakout %s", a.Direction)
+	default:
+		cond = a.Kind
+	}
+	mode := "once"
+	if a.Recurring {
+		mode = "recurring"
+	}
+	return fmt.Sprintf("%s %s %s (%s)", a.Pair, a.Interval, cond, mode)
+}
+
+// RunAlertDelivery polls fired alerts and sends them to their chats until ctx
+// ends. Events are acknowledged only after Telegram accepts them, so an
+// outage delays rather than drops alerts.
+func (r *Router) RunAlertDelivery(ctx context.Context, bot *tgbotapi.BotAPI, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		events, err := r.api.PendingAlertEvents(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				r.logger.Warn().Err(err).Msg("failed to poll alert events")
+			}
+			continue
+		}
+		var delivered []int64
+		for _, ev := range events {
+			if _, err := bot.Send(tgbotapi.NewMessage(ev.ChatID, ev.Message)); err != nil {
+				r.logger.Error().Err(err).Int64("chat", ev.ChatID).Int64("event", ev.ID).Msg("failed to deliver alert")
+				// Chats that blocked the bot or no longer exist never
+				// succeed; drop those events instead of retrying forever.
+				var tgErr *tgbotapi.Error
+				if !errors.As(err, &tgErr) || (tgErr.Code != http.StatusBadRequest && tgErr.Code != http.StatusForbidden) {
+					continue
+				}
+			}
+			delivered = append(delivered, ev.ID)
+		}
+		if len(delivered) == 0 {
+			continue
+		}
+		if err := r.api.AckAlertEvents(ctx, delivered); err != nil {
+			r.logger.Warn().Err(err).Msg("failed to acknowledge alert events")
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const backtestUsage = "Usage:\n" +
+	"/backtest <rsi|macd|bollinger|ema> <pair> <interval> <range> [name=value ...]\n" +
+	"/backtest <id>\n" +
+	"e.g. /backtest rsi ETHUSDT 1h 90d oversold=25"
+
+// How long /backtest waits for a job before leaving it to /backtest <id>.
+const (
+	backtestPoll = 3 * time.Second
+	backtestWait = 5 * time.Minute
+)
+
+// Backtest mirrors the TA backtest job payload.
+type Backtest struct {
+	ID       int64              `json:"id,omitempty"`
+	ChatID   int64              `json:"chat_id,omitempty"`
+	Strategy string             `json:"strategy"`
+	Params   map[string]float64 `json:"params,omitempty"`
+	Pair     string             `json:"pair"`
+	Interval string             `json:"interval"`
+	Range    string             `json:"range,omitempty"`
+	From     *time.Time         `json:"from,omitempty"`
+	To       *time.Time         `json:"to,omitempty"`
+	Cash     float64            `json:"cash,omitempty"`
+	Status   string             `json:"status,omitempty"`
+	Error    string             `json:"error,omitempty"`
+	Result   *struct {
+		Metrics BacktestMetrics `json:"metrics"`
+	} `json:"result,omitempty"`
+}
+
+// BacktestMetrics are the summary metrics of a finished backtest.
+type BacktestMetrics struct {
+	StartEquity  float64  `json:"start_equity"`
+	FinalEquity  float64  `json:"final_equity"`
+	TotalReturn  float64  `json:"total_return"`
+	CAGR         float64  `json:"cagr"`
+	Sharpe       float64  `json:"sharpe"`
+	Sortino      float64  `json:"sortino"`
+	MaxDrawdown  float64  `json:"max_drawdown"`
+	Trades       int      `json:"trades"`
+	WinRate      float64  `json:"win_rate"`
+	ProfitFactor *float64 `json:"profit_factor,omitempty"`
+	Exposure     float64  `json:"exposure"`
+	Fees         float64  `json:"fees"`
+}
+
+func (c *HTTPAPIClient) SubmitBacktest(ctx context.Context, bt Backtest) (Backtest, error) {
+	var created Backtest
+	err := c.send(ctx, http.MethodPost, "/v1/backtests", bt, &created)
+	return created, err
+}
+
+func (c *HTTPAPIClient) GetBacktest(ctx context.Context, chatID, id int64) (Backtest, error) {
+	var bt Backtest
+	err := c.get(ctx, "/v1/backtests/"+strconv.FormatInt(id, 10)+"?chat_id="+strconv.FormatInt(chatID, 10), &bt)
+	return bt, err
+}
+
+func (r *Router) handleBacktest(ctx context.Context, bot *tgbotapi.BotAPI, msg *tgbotapi.Message) {
+	parts := strings.Fields(msg.CommandArguments())
+	if len(parts) == 1 {
+		id, err := strconv.ParseInt(strings.TrimPrefix(parts[0], "#"), 10, 64)
+		if err != nil {
+			r.reply(ctx, bot, msg.Chat.ID, backtestUsage)
+			return
+		}
+		bt, err := r.api.GetBacktest(ctx, msg.Chat.ID, id)
+		if err != nil {
+			r.reply(ctx, bot, msg.Chat.ID, "Backtest unavailable: "+err.Error())
+			return
+		}
+		r.reply(ctx, bot, msg.Chat.ID, describeBacktest(bt))
+		return
+	}
+	bt, err := parseBacktest(parts)
+	if err != nil {
+		r.reply(ctx, bot, msg.Chat.ID, err.Error()+"\n\n"+backtestUsage)
+		return
+	}
+	bt.ChatID = msg.Chat.ID
+	queued, err := r.api.SubmitBacktest(ctx, bt)
+	if err != nil {
+		r.reply(ctx, bot, msg.Chat.ID, "Failed to queue backtest:\n```\n"+err.Error()+"\n```")
+		return
+	}
+	r.reply(ctx, bot, msg.Chat.ID, fmt.Sprintf("Backtest #%d queued: %s %s %s over %s. Results follow when it finishes.",
+		queued.ID, queued.Strategy, queued.Pair, queued.Interval, bt.Range))
+
+	done, err := r.awaitBacktest(ctx, msg.Chat.ID, queued.ID)
+	switch {
+	case err != nil:
+		r.reply(ctx, bot, msg.Chat.ID, fmt.Sprintf("Backtest #%d status unavailable: %s\nCheck later with /backtest %d", queued.ID, err.Error(), queued.ID))
+	case done.Status != "done" && done.Status != "failed":
+		r.reply(ctx, bot, msg.Chat.ID, fmt.Sprintf("Backtest #%d is still %s. Check later with /backtest %d", queued.ID, done.Status, queued.ID))
+	default:
+		r.reply(ctx, bot, msg.Chat.ID, describeBacktest(done))
+	}
+}
+
+// awaitBacktest polls a job until it finishes or backtestWait passes.
+func (r *Router) awaitBacktest(ctx context.Context, chatID, id int64) (Backtest, error) {
+	ctx, cancel := context.WithTimeout(ctx, backtestWait)
+	defer cancel()
+	ticker := time.NewTicker(backtestPoll)
+	defer ticker.Stop()
+	var last Backtest
+	for {
+		select {
+		case <-ctx.Done():
+			return last, nil
+		case <-ticker.C:
+		}
+		bt, err := r.api.GetBacktest(ctx, chatID, id)
+		if err != nil {
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return last, nil
+			}
+			return last, err
+		}
+		if last = bt; bt.Status == "done" || bt.Status == "failed" {
+			return bt, nil
+		}
+	}
+}
+
+// parseBacktest parses "<strategy> <pair> <interval> <range> [name=value ...]".
+func parseBacktest(args []string) (Backtest, error) {
+	if len(args) < 4 {
+		return Backtest{}, errors.New("strategy, pair, interval and range required")
+	}
+	bt := Backtest{Strategy: strings.ToLower(args[0]), Pair: strings.ToUpper(args[1]), Interval: args[2], Range: strings.ToLower(args[3])}
+	for _, arg := range args[4:] {
+		name, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			return bt, fmt.Errorf("parameter %q: want name=value", arg)
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return bt, fmt.Errorf("parameter %q: not a number", arg)
+		}
+		if bt.Params == nil {
+			bt.Params = make(map[string]float64)
+		}
+		bt.Params[strings.ToLower(name)] = v
+	}
+	return bt, nil
+}
+
+func describeBacktest(bt Backtest) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Backtest #%d %s %s %s", bt.ID, bt.Strategy, bt.Pair, bt.Interval))
+	if bt.From != nil && bt.To != nil {
+		b.WriteString(fmt.Sprintf(", %s → %s", bt.From.Format("2006-01-02"), bt.To.Format("2006-01-02")))
+	}
+	if len(bt.Params) > 0 {
+		names := make([]string, 0, len(bt.Params))
+		for name := range bt.Params {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for i, name := range names {
+			names[i] = fmt.Sprintf("%s=%g", name, bt.Params[name])
+		}
+		b.WriteString("\n`" + strings.Join(names, " ") + "`")
+	}
+	switch {
+	case bt.Status == "failed":
+		b.WriteString("\nFailed:\n```\n" + bt.Error + "\n```")
+		return b.String()
+	case bt.Status != "done" || bt.Result == nil:
+		b.WriteString("\nStatus: " + bt.Status)
+		return b.String()
+	}
+	m := bt.Result.Metrics
+	b.WriteString(fmt.Sprintf("\nReturn: %+.2f%% (CAGR %+.2f%%)", m.TotalReturn*100, m.CAGR*100))
+	b.WriteString(fmt.Sprintf("\nSharpe %.2f, Sortino %.2f", m.Sharpe, m.Sortino))
+	b.WriteString(fmt.Sprintf("\nMax drawdown: %.2f%%", m.MaxDrawdown*100))
+	b.WriteString(fmt.Sprintf("\nTrades: %d, win rate %.1f%%", m.Trades, m.WinRate*100))
+	if m.ProfitFactor != nil {
+		b.WriteString(fmt.Sprintf(", profit factor %.2f", *m.ProfitFactor))
+	}
+	b.WriteString(fmt.Sprintf("\nExposure: %.1f%%", m.Exposure*100))
+	b.WriteString(fmt.Sprintf("\nEquity: %.2f → %.2f (fees %.2f)", m.StartEquity, m.FinalEquity, m.Fees))
+	return b.String()
+}
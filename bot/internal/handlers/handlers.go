@@ -5,7 +5,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -21,7 +24,22 @@ type APIClient interface {
 	FetchRSI(ctx context.Context, pair, interval string) (float64, error)
 	FetchMACD(ctx context.Context, pair, interval string) (MACDResponse, error)
 	FetchSignals(ctx context.Context, pair, interval string) (map[string]float64, error)
-	SetAutoTradeFilter(ctx context.Context, expression, interval string, enabled bool) error
+	SetAutoTradeFilter(ctx context.Context, chatID int64, expression, interval string, enabled bool) error
+	ValidateFilter(ctx context.Context, expression, interval string) (FilterValidation, error)
+	CreateStrategy(ctx context.Context, st Strategy) (Strategy, error)
+	ListStrategies(ctx context.Context, chatID int64) (StrategyList, error)
+	UpdateStrategy(ctx context.Context, chatID, id int64, enabled, dryRun *bool) (Strategy, error)
+	DeleteStrategy(ctx context.Context, chatID, id int64) error
+	WatchSymbol(ctx context.Context, pair, interval, addedBy string) error
+	UnwatchSymbol(ctx context.Context, chatID int64, pair, interval string) error
+	ListWatched(ctx context.Context) ([]WatchedSymbol, error)
+	CreateAlert(ctx context.Context, alert Alert) (Alert, error)
+	ListAlerts(ctx context.Context, chatID int64) ([]Alert, error)
+	DeleteAlert(ctx context.Context, chatID, id int64) error
+	PendingAlertEvents(ctx context.Context) ([]AlertEvent, error)
+	AckAlertEvents(ctx context.Context, ids []int64) error
+	SubmitBacktest(ctx context.Context, bt Backtest) (Backtest, error)
+	GetBacktest(ctx context.Context, chatID, id int64) (Backtest, error)
 }
 
 // TradeIntent mirrors the API payload for trade execution requests.
@@ -45,6 +63,23 @@ type MACDResponse struct {
 	Histogram float64 `json:"histogram"`
 }
 
+// WatchedSymbol is a pair/interval streamed by the TA service.
+type WatchedSymbol struct {
+	Exchange string `json:"exchange"`
+	Pair     string `json:"pair"`
+	Interval string `json:"interval"`
+	AddedBy  string `json:"added_by,omitempty"`
+}
+
+// FilterValidation is the TA verdict on an auto-trade filter expression.
+type FilterValidation struct {
+	Valid     bool     `json:"valid"`
+	Canonical string   `json:"canonical,omitempty"`
+	Intervals []string `json:"intervals,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	Position  int      `json:"position,omitempty"`
+}
+
 // HTTPAPIClient implements APIClient using net/http.
 type HTTPAPIClient struct {
 	baseURL string
@@ -140,7 +175,17 @@ func (c *HTTPAPIClient) FetchSignals(ctx context.Context, pair, interval string)
 	return resp.Signals, nil
 }
 
-func (c *HTTPAPIClient) SetAutoTradeFilter(ctx context.Context, expression, interval string, enabled bool) error {
+// SetAutoTradeFilter stores the chat filter the strategy runner applies to
+// entries and forwards it to exec, which gates manual trades on it.
+func (c *HTTPAPIClient) SetAutoTradeFilter(ctx context.Context, chatID int64, expression, interval string, enabled bool) error {
+	if enabled {
+		filter := map[string]interface{}{"chat_id": chatID, "expression": expression, "interval": interval}
+		if err := c.send(ctx, http.MethodPut, "/v1/strategies/filter", filter, nil); err != nil {
+			return err
+		}
+	} else if err := c.send(ctx, http.MethodDelete, fmt.Sprintf("/v1/strategies/filter?chat_id=%d", chatID), nil, nil); err != nil {
+		return err
+	}
 	payload := map[string]interface{}{
 		"expression": expression,
 		"interval":   interval,
@@ -153,6 +198,70 @@ func (c *HTTPAPIClient) SetAutoTradeFilter(ctx context.Context, expression, inte
 	return c.SendAction(ctx, "set-autotrade-filter", string(body))
 }
 
+func (c *HTTPAPIClient) ValidateFilter(ctx context.Context, expression, interval string) (FilterValidation, error) {
+	var resp FilterValidation
+	payload := map[string]string{"expression": expression, "interval": interval}
+	err := c.send(ctx, http.MethodPost, "/v1/filters/validate", payload, &resp)
+	return resp, err
+}
+
+func (c *HTTPAPIClient) WatchSymbol(ctx context.Context, pair, interval, addedBy string) error {
+	payload := map[string]string{"pair": pair, "interval": interval, "added_by": addedBy}
+	return c.send(ctx, http.MethodPost, "/v1/ta/watch", payload, nil)
+}
+
+func (c *HTTPAPIClient) UnwatchSymbol(ctx context.Context, chatID int64, pair, interval string) error {
+	path := fmt.Sprintf("/v1/ta/watch/%s/%s?chat_id=%d", url.PathEscape(pair), url.PathEscape(interval), chatID)
+	return c.send(ctx, http.MethodDelete, path, nil, nil)
+}
+
+func (c *HTTPAPIClient) ListWatched(ctx context.Context) ([]WatchedSymbol, error) {
+	var resp struct {
+		Symbols []WatchedSymbol `json:"symbols"`
+	}
+	if err := c.get(ctx, "/v1/ta/watch", &resp); err != nil {
+		return nil, err
+	}
+	return resp.Symbols, nil
+}
+
+// send issues a JSON request, decoding the response into out when set, and
+// surfaces the API error message on failure.
+func (c *HTTPAPIClient) send(ctx context.Context, method, path string, payload, out interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		if text := strings.TrimSpace(string(msg)); text != "" {
+			return fmt.Errorf("%s", text)
+		}
+		return fmt.Errorf("api returned status %d", resp.StatusCode)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
 func (c *HTTPAPIClient) get(ctx context.Context, path string, out interface{}) error {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
 	if err != nil {
@@ -199,7 +308,7 @@ func (r *Router) HandleUpdate(ctx context.Context, bot *tgbotapi.BotAPI, update
 	case "start":
 		r.reply(ctx, bot, msg.Chat.ID, "Welcome to tg-crypto-trader. Use /buy or /sell to execute trades.")
 	case "help":
-		r.reply(ctx, bot, msg.Chat.ID, "Commands:\n/buy <pair> <size> <slippage%>\n/sell <pair> <size> <slippage%>\n/forcebuy <pair> <size> <slippage%>\n/rsi <pair> <interval>\n/macd <pair> <interval>\n/signals <pair> <interval>\n/autotrade <on|off> [expr] [interval]\n/mode <paper|live>\n/portfolio")
+		r.reply(ctx, bot, msg.Chat.ID, "Commands:\n/buy <pair> <size> <slippage%>\n/sell <pair> <size> <slippage%>\n/forcebuy <pair> <size> <slippage%>\n/rsi <pair> <interval>\n/macd <pair> <interval>\n/signals <pair> <interval>\n/autotrade <on|off> [expr] [interval]\n/watch <add|remove|list> [pair] [interval]\n/alert <add|list|rm> ...\n/strategy <add|list|rm|pause|resume|live|dry> ...\n/backtest <strategy> <pair> <interval> <range>\n/mode <paper|live>\n/portfolio")
 	case "buy", "sell":
 		r.handleTrade(ctx, bot, msg)
 	case "forcebuy":
@@ -214,8 +323,16 @@ func (r *Router) HandleUpdate(ctx context.Context, bot *tgbotapi.BotAPI, update
 		r.handleMACD(ctx, bot, msg)
 	case "signals":
 		r.handleSignals(ctx, bot, msg)
+	case "strategy", "strategies":
+		r.handleStrategy(ctx, bot, msg)
 	case "autotrade":
 		r.handleAutoTrade(ctx, bot, msg)
+	case "watch":
+		r.handleWatch(ctx, bot, msg)
+	case "alert", "alerts":
+		r.handleAlert(ctx, bot, msg)
+	case "backtest":
+		r.handleBacktest(ctx, bot, msg)
 	default:
 		r.reply(ctx, bot, msg.Chat.ID, "Unknown command. Use /help.")
 	}
@@ -376,31 +493,105 @@ func (r *Router) handleAutoTrade(ctx context.Context, bot *tgbotapi.BotAPI, msg
 	mode := strings.ToLower(parts[0])
 	switch mode {
 	case "off":
-		if err := r.api.SetAutoTradeFilter(ctx, "", "", false); err != nil {
+		if err := r.api.SetAutoTradeFilter(ctx, msg.Chat.ID, "", "", false); err != nil {
 			r.reply(ctx, bot, msg.Chat.ID, "Failed to disable auto-trade: "+err.Error())
 			return
 		}
 		r.reply(ctx, bot, msg.Chat.ID, "Auto-trade filters disabled")
 	case "on":
 		if len(parts) < 2 {
-			r.reply(ctx, bot, msg.Chat.ID, "Usage: /autotrade on <expression> [interval]")
+			r.reply(ctx, bot, msg.Chat.ID, "Usage: /autotrade on <expression> [interval]\nExample: `/autotrade on rsi@1h < 30 and macd_histogram@5m crosses_above 0`")
 			return
 		}
-		expression := strings.ToLower(parts[1])
+		// A trailing bare interval sets the default for identifiers without
+		// @interval; it can never end a valid expression.
 		interval := "1m"
-		if len(parts) >= 3 {
-			interval = parts[2]
+		if n := len(parts); n > 2 && intervalPattern.MatchString(parts[n-1]) {
+			interval = parts[n-1]
+			parts = parts[:n-1]
 		}
-		if err := r.api.SetAutoTradeFilter(ctx, expression, interval, true); err != nil {
+		expression := strings.Join(parts[1:], " ")
+		verdict, err := r.api.ValidateFilter(ctx, expression, interval)
+		if err != nil {
+			r.reply(ctx, bot, msg.Chat.ID, "Failed to validate filter: "+err.Error())
+			return
+		}
+		if !verdict.Valid {
+			r.reply(ctx, bot, msg.Chat.ID, "Invalid filter:\n```\n"+verdict.Error+"\n"+pointAt(expression, verdict.Position)+"\n```")
+			return
+		}
+		if err := r.api.SetAutoTradeFilter(ctx, msg.Chat.ID, expression, interval, true); err != nil {
 			r.reply(ctx, bot, msg.Chat.ID, "Failed to enable auto-trade: "+err.Error())
 			return
 		}
-		r.reply(ctx, bot, msg.Chat.ID, fmt.Sprintf("Auto-trade filter enabled: %s @ %s", expression, interval))
+		r.reply(ctx, bot, msg.Chat.ID, fmt.Sprintf("Auto-trade filter enabled: `%s` (default interval %s)", verdict.Canonical, interval))
 	default:
 		r.reply(ctx, bot, msg.Chat.ID, "Usage: /autotrade <on|off> [expression] [interval]")
 	}
 }
 
+// intervalPattern matches kline intervals such as 5m, 1h or 1M.
+var intervalPattern = regexp.MustCompile(`^[0-9]+[smhdwM]$`)
+
+// pointAt renders expression with a caret under the 1-based column pos.
+func pointAt(expression string, pos int) string {
+	if pos < 1 || pos > len(expression)+1 {
+		return expression
+	}
+	return expression + "\n" + strings.Repeat(" ", pos-1) + "^"
+}
+
+func (r *Router) handleWatch(ctx context.Context, bot *tgbotapi.BotAPI, msg *tgbotapi.Message) {
+	const usage = "Usage: /watch <add|remove|list> [pair] [interval]"
+	sub, rest, _ := strings.Cut(strings.TrimSpace(msg.CommandArguments()), " ")
+	switch strings.ToLower(sub) {
+	case "list":
+		symbols, err := r.api.ListWatched(ctx)
+		if err != nil {
+			r.reply(ctx, bot, msg.Chat.ID, "Watchlist unavailable: "+err.Error())
+			return
+		}
+		if len(symbols) == 0 {
+			r.reply(ctx, bot, msg.Chat.ID, "No symbols watched")
+			return
+		}
+		var b strings.Builder
+		b.WriteString("Watched symbols:\n")
+		for _, s := range symbols {
+			b.WriteString(fmt.Sprintf("• %s %s", s.Pair, s.Interval))
+			if s.AddedBy == "config" {
+				b.WriteString(" (config)")
+			}
+			b.WriteString("\n")
+		}
+		r.reply(ctx, bot, msg.Chat.ID, b.String())
+	case "add":
+		pair, interval, ok := parsePairInterval(rest)
+		if !ok {
+			r.reply(ctx, bot, msg.Chat.ID, usage)
+			return
+		}
+		if err := r.api.WatchSymbol(ctx, pair, interval, strconv.FormatInt(msg.Chat.ID, 10)); err != nil {
+			r.reply(ctx, bot, msg.Chat.ID, "Failed to watch: "+err.Error())
+			return
+		}
+		r.reply(ctx, bot, msg.Chat.ID, fmt.Sprintf("Watching %s %s; history is backfilling", pair, interval))
+	case "remove", "rm":
+		pair, interval, ok := parsePairInterval(rest)
+		if !ok {
+			r.reply(ctx, bot, msg.Chat.ID, usage)
+			return
+		}
+		if err := r.api.UnwatchSymbol(ctx, msg.Chat.ID, pair, interval); err != nil {
+			r.reply(ctx, bot, msg.Chat.ID, "Failed to unwatch: "+err.Error())
+			return
+		}
+		r.reply(ctx, bot, msg.Chat.ID, fmt.Sprintf("Stopped watching %s %s", pair, interval))
+	default:
+		r.reply(ctx, bot, msg.Chat.ID, usage)
+	}
+}
+
 func (r *Router) reply(ctx context.Context, bot *tgbotapi.BotAPI, chatID int64, message string) {
 	msg := tgbotapi.NewMessage(chatID, message)
 	msg.ParseMode = "Markdown"
@@ -0,0 +1,269 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const strategyUsage = "Usage:\n" +
+	"/strategy add <name> <pair> <interval> <size> entry <expr> [exit <expr>] [cooldown <4h>] [max <n>] [slippage <pct>%] [live] [real]\n" +
+	"/strategy list\n" +
+	"/strategy <pause|resume|live|dry|rm> <id>\n" +
+	"New strategies only notify (dry run) until switched to live; live intents are paper trades unless `real` is given."
+
+// Strategy mirrors the TA strategy payload.
+type Strategy struct {
+	ID              int64      `json:"id,omitempty"`
+	ChatID          int64      `json:"chat_id"`
+	Name            string     `json:"name"`
+	Pair            string     `json:"pair"`
+	Interval        string     `json:"interval"`
+	Entry           string     `json:"entry"`
+	Exit            string     `json:"exit,omitempty"`
+	Size            float64    `json:"size"`
+	SlippageBps     int        `json:"slippage_bps,omitempty"`
+	CooldownSeconds int        `json:"cooldown_seconds,omitempty"`
+	MaxPositions    int        `json:"max_positions,omitempty"`
+	DryRun          bool       `json:"dry_run"`
+	PaperTrading    bool       `json:"paper_trading"`
+	Enabled         bool       `json:"enabled"`
+	OpenPositions   int        `json:"open_positions"`
+	LastTrade       *time.Time `json:"last_trade,omitempty"`
+}
+
+// StrategyList is a chat's strategies and auto-trade filter.
+type StrategyList struct {
+	Strategies []Strategy `json:"strategies"`
+	Filter     *struct {
+		Expression string `json:"expression"`
+		Interval   string `json:"interval"`
+	} `json:"filter,omitempty"`
+}
+
+func (c *HTTPAPIClient) CreateStrategy(ctx context.Context, st Strategy) (Strategy, error) {
+	var created Strategy
+	err := c.send(ctx, http.MethodPost, "/v1/strategies", st, &created)
+	return created, err
+}
+
+func (c *HTTPAPIClient) ListStrategies(ctx context.Context, chatID int64) (StrategyList, error) {
+	var resp StrategyList
+	err := c.get(ctx, "/v1/strategies?chat_id="+strconv.FormatInt(chatID, 10), &resp)
+	return resp, err
+}
+
+func (c *HTTPAPIClient) UpdateStrategy(ctx context.Context, chatID, id int64, enabled, dryRun *bool) (Strategy, error) {
+	var updated Strategy
+	payload := map[string]*bool{"enabled": enabled, "dry_run": dryRun}
+	err := c.send(ctx, http.MethodPatch, fmt.Sprintf("/v1/strategies/%d?chat_id=%d", id, chatID), payload, &updated)
+	return updated, err
+}
+
+func (c *HTTPAPIClient) DeleteStrategy(ctx context.Context, chatID, id int64) error {
+	return c.send(ctx, http.MethodDelete, fmt.Sprintf("/v1/strategies/%d?chat_id=%d", id, chatID), nil, nil)
+}
+
+func (r *Router) handleStrategy(ctx context.Context, bot *tgbotapi.BotAPI, msg *tgbotapi.Message) {
+	parts := strings.Fields(msg.CommandArguments())
+	if len(parts) == 0 {
+		r.reply(ctx, bot, msg.Chat.ID, strategyUsage)
+		return
+	}
+	sub := strings.ToLower(parts[0])
+	switch sub {
+	case "add":
+		st, err := parseStrategy(parts[1:])
+		if err != nil {
+			r.reply(ctx, bot, msg.Chat.ID, err.Error()+"\n\n"+strategyUsage)
+			return
+		}
+		st.ChatID = msg.Chat.ID
+		created, err := r.api.CreateStrategy(ctx, st)
+		if err != nil {
+			r.reply(ctx, bot, msg.Chat.ID, "Failed to add strategy:\n```\n"+err.Error()+"\n```")
+			return
+		}
+		r.reply(ctx, bot, msg.Chat.ID, fmt.Sprintf("Strategy #%d added:\n%s", created.ID, describeStrategy(created)))
+	case "list", "ls":
+		list, err := r.api.ListStrategies(ctx, msg.Chat.ID)
+		if err != nil {
+			r.reply(ctx, bot, msg.Chat.ID, "Strategies unavailable: "+err.Error())
+			return
+		}
+		var b strings.Builder
+		if list.Filter != nil {
+			b.WriteString(fmt.Sprintf("Auto-trade filter: `%s` (default interval %s)\n", list.Filter.Expression, list.Filter.Interval))
+		}
+		if len(list.Strategies) == 0 {
+			b.WriteString("No strategies. Use /strategy add.")
+		}
+		for _, st := range list.Strategies {
+			b.WriteString(fmt.Sprintf("#%d %s\n", st.ID, describeStrategy(st)))
+		}
+		r.reply(ctx, bot, msg.Chat.ID, b.String())
+	case "rm", "remove", "del", "pause", "resume", "live", "dry":
+		if len(parts) < 2 {
+			r.reply(ctx, bot, msg.Chat.ID, fmt.Sprintf("Usage: /strategy %s <id>", sub))
+			return
+		}
+		id, err := strconv.ParseInt(strings.TrimPrefix(parts[1], "#"), 10, 64)
+		if err != nil {
+			r.reply(ctx, bot, msg.Chat.ID, "invalid strategy id")
+			return
+		}
+		if sub == "rm" || sub == "remove" || sub == "del" {
+			if err := r.api.DeleteStrategy(ctx, msg.Chat.ID, id); err != nil {
+				r.reply(ctx, bot, msg.Chat.ID, "Failed to remove strategy: "+err.Error())
+				return
+			}
+			r.reply(ctx, bot, msg.Chat.ID, fmt.Sprintf("Strategy #%d removed", id))
+			return
+		}
+		var enabled, dryRun *bool
+		on, off := true, false
+		switch sub {
+		case "pause":
+			enabled = &off
+		case "resume":
+			enabled = &on
+		case "live":
+			dryRun = &off
+		case "dry":
+			dryRun = &on
+		}
+		updated, err := r.api.UpdateStrategy(ctx, msg.Chat.ID, id, enabled, dryRun)
+		if err != nil {
+			r.reply(ctx, bot, msg.Chat.ID, "Failed to update strategy:\n```\n"+err.Error()+"\n```")
+			return
+		}
+		r.reply(ctx, bot, msg.Chat.ID, fmt.Sprintf("Strategy #%d updated:\n%s", updated.ID, describeStrategy(updated)))
+	default:
+		r.reply(ctx, bot, msg.Chat.ID, strategyUsage)
+	}
+}
+
+// strategyKeywords start the optional clauses of /strategy add; none of them
+// is a filter identifier, so expressions can be split on them.
+var strategyKeywords = map[string]bool{
+	"entry": true, "exit": true, "cooldown": true, "max": true, "slippage": true, "live": true, "real": true,
+}
+
+// parseStrategy parses "<name> <pair> <interval> <size> entry <expr> ...".
+func parseStrategy(args []string) (Strategy, error) {
+	st := Strategy{DryRun: true, PaperTrading: true}
+	if len(args) < 4 {
+		return st, fmt.Errorf("missing name, pair, interval or size")
+	}
+	st.Name = strings.ToLower(args[0])
+	st.Pair = strings.ToUpper(args[1])
+	st.Interval = args[2]
+	size, err := parseFloat(args[3])
+	if err != nil || size <= 0 {
+		return st, fmt.Errorf("invalid size %q", args[3])
+	}
+	st.Size = size
+	clauses := make(map[string][]string)
+	var current string
+	for _, arg := range args[4:] {
+		if word := strings.ToLower(arg); strategyKeywords[word] {
+			if _, dup := clauses[word]; dup {
+				return st, fmt.Errorf("%s given twice", word)
+			}
+			current = word
+			clauses[word] = []string{}
+			continue
+		}
+		if current == "" {
+			return st, fmt.Errorf("unexpected %q; start the rule with entry", arg)
+		}
+		clauses[current] = append(clauses[current], arg)
+	}
+	st.Entry = strings.Join(clauses["entry"], " ")
+	st.Exit = strings.Join(clauses["exit"], " ")
+	if st.Entry == "" {
+		return st, fmt.Errorf("entry rule required")
+	}
+	if _, ok := clauses["exit"]; ok && st.Exit == "" {
+		return st, fmt.Errorf("exit needs a rule")
+	}
+	if v, ok := clauses["cooldown"]; ok {
+		if len(v) != 1 {
+			return st, fmt.Errorf("cooldown takes one duration, e.g. 4h")
+		}
+		d, err := parseCooldown(v[0])
+		if err != nil {
+			return st, err
+		}
+		st.CooldownSeconds = int(d / time.Second)
+	}
+	if v, ok := clauses["max"]; ok {
+		n, err := strconv.Atoi(strings.Join(v, ""))
+		if err != nil || n <= 0 {
+			return st, fmt.Errorf("max takes a positive number of positions")
+		}
+		st.MaxPositions = n
+	}
+	if v, ok := clauses["slippage"]; ok {
+		pct, err := parseFloat(strings.TrimSuffix(strings.Join(v, ""), "%"))
+		if err != nil || pct <= 0 {
+			return st, fmt.Errorf("invalid slippage")
+		}
+		st.SlippageBps = int(pct * 100)
+	}
+	for _, flag := range []string{"live", "real"} {
+		if v, ok := clauses[flag]; ok && len(v) > 0 {
+			return st, fmt.Errorf("%s takes no value", flag)
+		}
+	}
+	_, live := clauses["live"]
+	_, realFunds := clauses["real"]
+	st.DryRun = !live
+	st.PaperTrading = !realFunds
+	return st, nil
+}
+
+// parseCooldown accepts Go durations plus whole days such as 2d.
+func parseCooldown(v string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(v, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err == nil && n >= 0 {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid cooldown %q", v)
+	}
+	return d, nil
+}
+
+func describeStrategy(st Strategy) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("`%s`: %s %s size %g\n", st.Name, st.Pair, st.Interval, st.Size))
+	b.WriteString(fmt.Sprintf("  entry `%s`\n", st.Entry))
+	if st.Exit != "" {
+		b.WriteString(fmt.Sprintf("  exit `%s`\n", st.Exit))
+	}
+	mode := "dry run"
+	if !st.DryRun {
+		mode = "live, paper"
+		if !st.PaperTrading {
+			mode = "live, real"
+		}
+	}
+	if !st.Enabled {
+		mode += ", paused"
+	}
+	cooldown := "none"
+	if st.CooldownSeconds > 0 {
+		cooldown = (time.Duration(st.CooldownSeconds) * time.Second).String()
+	}
+	b.WriteString(fmt.Sprintf("  %s; positions %d/%d; cooldown %s", mode, st.OpenPositions, st.MaxPositions, cooldown))
+	return b.String()
+}
@@ -1,6 +1,15 @@
 package cex
 
-import "context"
+import (
+    "context"
+    "errors"
+    "fmt"
+    "regexp"
+    "strings"
+)
+
+// ErrInvalidOrder is returned for order requests an exchange would reject.
+var ErrInvalidOrder = errors.New("invalid order")
 
 // Ticker represents a market ticker update.
 type Ticker struct {
@@ -9,17 +18,178 @@ type Ticker struct {
     Timestamp int64
 }
 
-// OrderRequest describes a request to place an order.
+// OrderType selects how an order executes.
+type OrderType string
+
+// Order types.
+const (
+    // Market fills immediately at the best available prices.
+    Market OrderType = "MARKET"
+    // Limit rests at Price until filled or cancelled.
+    Limit OrderType = "LIMIT"
+    // StopLossLimit places a limit order at Price once the market trades
+    // through StopPrice against the position.
+    StopLossLimit OrderType = "STOP_LOSS_LIMIT"
+    // TakeProfitLimit places a limit order at Price once the market trades
+    // through StopPrice in the position's favour.
+    TakeProfitLimit OrderType = "TAKE_PROFIT_LIMIT"
+    // OCO pairs a limit order at Price with a stop-limit order triggered at
+    // StopPrice and priced at StopLimitPrice; when one fills the other is
+    // cancelled.
+    OCO OrderType = "OCO"
+)
+
+// TimeInForce sets how long a limit order stays on the book.
+type TimeInForce string
+
+// Times in force.
+const (
+    // GoodTillCancel rests until filled or cancelled.
+    GoodTillCancel TimeInForce = "GTC"
+    // ImmediateOrCancel fills what it can at once and cancels the rest.
+    ImmediateOrCancel TimeInForce = "IOC"
+    // FillOrKill fills entirely at once or not at all.
+    FillOrKill TimeInForce = "FOK"
+)
+
+// clientOrderIDPattern is the client order ID format exchanges accept.
+var clientOrderIDPattern = regexp.MustCompile(`^[.A-Za-z0-9:/_-]{1,36}$`)
+
+// OrderRequest describes a request to place an order. Size is in the base
+// asset; market orders may give QuoteSize instead to spend or receive that
+// much of the quote asset.
 type OrderRequest struct {
     Symbol string
     Side   string
+    Type   OrderType
     Size   float64
-    Price  float64
+    // QuoteSize replaces Size on market orders.
+    QuoteSize float64
+    // Price is the limit price; for OCO orders, the limit leg's.
+    Price float64
+    // StopPrice triggers stop-loss, take-profit and OCO stop legs.
+    StopPrice float64
+    // StopLimitPrice is the limit price of an OCO order's stop leg.
+    StopLimitPrice float64
+    // TimeInForce applies to limit orders and OCO stop legs; defaults to
+    // GoodTillCancel.
+    TimeInForce TimeInForce
+    // ClientOrderID identifies the order, or the OCO list, to the caller;
+    // connectors generate one when it is empty.
+    ClientOrderID string
+}
+
+// Normalize upper-cases the symbol and side, fills in the default type and
+// time in force, and checks that the request carries exactly the fields its
+// type needs.
+func (r OrderRequest) Normalize() (OrderRequest, error) {
+    r.Symbol = strings.ToUpper(strings.TrimSpace(r.Symbol))
+    r.Side = strings.ToUpper(strings.TrimSpace(r.Side))
+    r.Type = OrderType(strings.ToUpper(string(r.Type)))
+    r.TimeInForce = TimeInForce(strings.ToUpper(string(r.TimeInForce)))
+    if r.Type == "" {
+        r.Type = Market
+    }
+    invalid := func(format string, args ...interface{}) (OrderRequest, error) {
+        return r, fmt.Errorf("%w: %s", ErrInvalidOrder, fmt.Sprintf(format, args...))
+    }
+    if r.Symbol == "" {
+        return invalid("symbol required")
+    }
+    if r.Side != "BUY" && r.Side != "SELL" {
+        return invalid("side %q: want BUY or SELL", r.Side)
+    }
+    if r.ClientOrderID != "" && !clientOrderIDPattern.MatchString(r.ClientOrderID) {
+        return invalid("client order id %q: want up to 36 letters, digits or .:/_-", r.ClientOrderID)
+    }
+    if r.Size < 0 || r.QuoteSize < 0 || r.Price < 0 || r.StopPrice < 0 || r.StopLimitPrice < 0 {
+        return invalid("sizes and prices must not be negative")
+    }
+    if r.Type == Market {
+        if r.TimeInForce != "" {
+            return invalid("market orders take no time in force")
+        }
+        if r.Price > 0 || r.StopPrice > 0 || r.StopLimitPrice > 0 {
+            return invalid("market orders take no price")
+        }
+        if (r.Size > 0) == (r.QuoteSize > 0) {
+            return invalid("market orders need either a size or a quote size")
+        }
+        return r, nil
+    }
+
+    switch r.TimeInForce {
+    case "":
+        r.TimeInForce = GoodTillCancel
+    case GoodTillCancel, ImmediateOrCancel, FillOrKill:
+    default:
+        return invalid("time in force %q: want GTC, IOC or FOK", r.TimeInForce)
+    }
+    if r.QuoteSize > 0 {
+        return invalid("only market orders take a quote size")
+    }
+    if r.Size == 0 || r.Price == 0 {
+        return invalid("%s orders need a size and a price", r.Type)
+    }
+    switch r.Type {
+    case Limit:
+        if r.StopPrice > 0 || r.StopLimitPrice > 0 {
+            return invalid("limit orders take no stop price")
+        }
+    case StopLossLimit, TakeProfitLimit:
+        if r.StopPrice == 0 {
+            return invalid("%s orders need a stop price", r.Type)
+        }
+        if r.StopLimitPrice > 0 {
+            return invalid("%s orders take their limit as the price", r.Type)
+        }
+    case OCO:
+        if r.StopPrice == 0 || r.StopLimitPrice == 0 {
+            return invalid("OCO orders need a stop price and a stop limit price")
+        }
+        // The limit leg takes profit and the stop leg limits the loss.
+        if r.Side == "SELL" && r.Price <= r.StopPrice {
+            return invalid("OCO sells need the limit price above the stop price")
+        }
+        if r.Side == "BUY" && r.Price >= r.StopPrice {
+            return invalid("OCO buys need the limit price below the stop price")
+        }
+    default:
+        return invalid("unknown order type %q", r.Type)
+    }
+    return r, nil
+}
+
+// CancelRequest identifies a resting order to cancel.
+type CancelRequest struct {
+    Symbol string
+    // OrderID is the ID PlaceOrder returned, or the order's client order ID.
+    OrderID string
+    // Type is the order's type; cancelling an OCO order cancels both legs.
+    Type OrderType
+}
+
+// Normalize upper-cases the symbol and type and checks that the order is
+// identified.
+func (r CancelRequest) Normalize() (CancelRequest, error) {
+    r.Symbol = strings.ToUpper(strings.TrimSpace(r.Symbol))
+    r.OrderID = strings.TrimSpace(r.OrderID)
+    r.Type = OrderType(strings.ToUpper(string(r.Type)))
+    if r.Symbol == "" {
+        return r, fmt.Errorf("%w: symbol required", ErrInvalidOrder)
+    }
+    if r.OrderID == "" {
+        return r, fmt.Errorf("%w: order id required", ErrInvalidOrder)
+    }
+    return r, nil
 }
 
-// Connector defines the interface exchanges must implement.
+// Connector defines the interface exchanges must implement. PlaceOrder
+// accepts the types OrderTypes lists and returns the exchange's order ID, or
+// the order list ID for OCO orders; CancelOrder takes that ID back.
 type Connector interface {
     SubscribeTickers(ctx context.Context, symbols []string) (<-chan Ticker, error)
+    OrderTypes() []OrderType
     PlaceOrder(ctx context.Context, req OrderRequest) (string, error)
-    CancelOrder(ctx context.Context, orderID string) error
+    CancelOrder(ctx context.Context, req CancelRequest) error
 }
@@ -2,12 +2,15 @@ package binance
 
 import (
     "context"
+    "crypto/rand"
+    "encoding/hex"
     "encoding/json"
     "fmt"
     "net/http"
     "net/url"
     "strconv"
     "strings"
+    "sync"
     "time"
 
     "github.com/gorilla/websocket"
@@ -16,30 +19,83 @@ import (
     "github.com/example/tg-crypto-trader/connectors/cex"
 )
 
-// Client implements the Binance Spot TESTNET API integration.
+// Default endpoints of the Binance Spot testnet.
+const (
+    TestnetRESTURL = "https://testnet.binance.vision"
+    TestnetWSURL   = "wss://testnet.binance.vision/ws"
+)
+
+// Options configure a Client.
+type Options struct {
+    // RESTURL and WSURL default to the testnet.
+    RESTURL string
+    WSURL   string
+    // Live places real orders through /api/v3/order. Otherwise orders go to
+    // /api/v3/order/test, which validates them without reaching the
+    // matching engine.
+    Live bool
+    // RecvWindow is how long after its timestamp Binance accepts a signed
+    // request; defaults to 5s.
+    RecvWindow time.Duration
+    HTTPClient *http.Client
+}
+
+var _ cex.Connector = (*Client)(nil)
+
+// Client implements the Binance Spot API integration.
 type Client struct {
     apiKey     string
     secret     string
     restURL    string
     wsURL      string
+    live       bool
+    recvWindow time.Duration
     httpClient *http.Client
     logger     zerolog.Logger
+    now        func() time.Time
+
+    mu     sync.Mutex
+    offset time.Duration
+    synced bool
 }
 
-// NewClient returns a configured Binance testnet client.
+// NewClient returns a Binance testnet client that only test-places orders.
 func NewClient(apiKey, secret string, logger zerolog.Logger) *Client {
+    return NewClientWithOptions(apiKey, secret, Options{}, logger)
+}
+
+// NewClientWithOptions returns a configured Binance client.
+func NewClientWithOptions(apiKey, secret string, opts Options, logger zerolog.Logger) *Client {
+    if opts.RESTURL == "" {
+        opts.RESTURL = TestnetRESTURL
+    }
+    if opts.WSURL == "" {
+        opts.WSURL = TestnetWSURL
+    }
+    if opts.RecvWindow <= 0 {
+        opts.RecvWindow = 5 * time.Second
+    }
+    if opts.HTTPClient == nil {
+        opts.HTTPClient = &http.Client{Timeout: 5 * time.Second}
+    }
     return &Client{
-        apiKey:  apiKey,
-        secret:  secret,
-        restURL: "https://testnet.binance.vision",
-        wsURL:   "wss://testnet.binance.vision/ws",
-        httpClient: &http.Client{
-            Timeout: 5 * time.Second,
-        },
-        logger: logger,
+        apiKey:     apiKey,
+        secret:     secret,
+        restURL:    strings.TrimRight(opts.RESTURL, "/"),
+        wsURL:      opts.WSURL,
+        live:       opts.Live,
+        recvWindow: opts.RecvWindow,
+        httpClient: opts.HTTPClient,
+        logger:     logger,
+        now:        time.Now,
     }
 }
 
+// Live reports whether orders reach the matching engine.
+func (c *Client) Live() bool {
+    return c.live
+}
+
 // SubscribeTickers subscribes to live ticker updates.
 func (c *Client) SubscribeTickers(ctx context.Context, symbols []string) (<-chan cex.Ticker, error) {
     stream := strings.ToLower(strings.Join(symbols, "@ticker/")) + "@ticker"
@@ -80,36 +136,288 @@ func (c *Client) SubscribeTickers(ctx context.Context, symbols []string) (<-chan
     return out, nil
 }
 
-// PlaceOrder submits a market order on the testnet.
+// Fill is one trade that filled part of an order.
+type Fill struct {
+    TradeID         int64
+    Price           float64
+    Qty             float64
+    Commission      float64
+    CommissionAsset string
+}
+
+// OrderResponse is Binance's FULL order response. OCO orders set ListID
+// and carry their two legs in Orders. Test orders only echo the request.
+type OrderResponse struct {
+    Symbol        string
+    OrderID       int64
+    ListID        int64
+    ClientOrderID string
+    TransactTime  time.Time
+    Status        string
+    Side          string
+    Type          string
+    TimeInForce   string
+    Price         float64
+    StopPrice     float64
+    OrigQty       float64
+    ExecutedQty   float64
+    QuoteQty      float64
+    Fills         []Fill
+    Orders        []OrderResponse
+    Test          bool
+}
+
+// AvgPrice is the quantity-weighted price of the fills.
+func (o OrderResponse) AvgPrice() float64 {
+    if o.ExecutedQty == 0 {
+        return 0
+    }
+    return o.QuoteQty / o.ExecutedQty
+}
+
+// OrderTypes lists the order types PlaceOrder accepts.
+func (c *Client) OrderTypes() []cex.OrderType {
+    return []cex.OrderType{cex.Market, cex.Limit, cex.StopLossLimit, cex.TakeProfitLimit, cex.OCO}
+}
+
+// PlaceOrder submits an order and returns the exchange order ID, the order
+// list ID for OCO orders, or a "test-" ID when orders are only test-placed.
 func (c *Client) PlaceOrder(ctx context.Context, req cex.OrderRequest) (string, error) {
-    endpoint := "/api/v3/order/test"
+    resp, err := c.SubmitOrder(ctx, req)
+    if err != nil {
+        return "", err
+    }
+    switch {
+    case resp.Test:
+        return "test-" + resp.ClientOrderID, nil
+    case resp.Type == string(cex.OCO):
+        return strconv.FormatInt(resp.ListID, 10), nil
+    default:
+        return strconv.FormatInt(resp.OrderID, 10), nil
+    }
+}
+
+// SubmitOrder places an order and parses the response and fills.
+func (c *Client) SubmitOrder(ctx context.Context, req cex.OrderRequest) (OrderResponse, error) {
+    req, err := req.Normalize()
+    if err != nil {
+        return OrderResponse{}, err
+    }
+    if req.ClientOrderID == "" {
+        if req.ClientOrderID, err = newClientOrderID(); err != nil {
+            return OrderResponse{}, err
+        }
+    }
+    if req.Type == cex.OCO {
+        return c.submitOCO(ctx, req)
+    }
+
     params := url.Values{}
     params.Set("symbol", req.Symbol)
-    params.Set("side", strings.ToUpper(req.Side))
-    params.Set("type", "MARKET")
-    params.Set("quantity", fmt.Sprintf("%f", req.Size))
-    reqURL := fmt.Sprintf("%s%s?%s", c.restURL, endpoint, params.Encode())
+    params.Set("side", req.Side)
+    params.Set("type", string(req.Type))
+    params.Set("newClientOrderId", req.ClientOrderID)
+    params.Set("newOrderRespType", "FULL")
+    if req.QuoteSize > 0 {
+        params.Set("quoteOrderQty", formatDecimal(req.QuoteSize))
+    } else {
+        params.Set("quantity", formatDecimal(req.Size))
+    }
+    if req.Type != cex.Market {
+        params.Set("timeInForce", string(req.TimeInForce))
+        params.Set("price", formatDecimal(req.Price))
+    }
+    if req.StopPrice > 0 {
+        params.Set("stopPrice", formatDecimal(req.StopPrice))
+    }
 
-    httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL, nil)
+    if !c.live {
+        if err := c.signed(ctx, http.MethodPost, "/api/v3/order/test", params, nil); err != nil {
+            return OrderResponse{}, err
+        }
+        return OrderResponse{Symbol: req.Symbol, ClientOrderID: req.ClientOrderID, Side: req.Side, Type: string(req.Type),
+            TimeInForce: string(req.TimeInForce), Price: req.Price, StopPrice: req.StopPrice, OrigQty: req.Size, Test: true}, nil
+    }
+    var raw orderJSON
+    if err := c.signed(ctx, http.MethodPost, "/api/v3/order", params, &raw); err != nil {
+        return OrderResponse{}, err
+    }
+    resp, err := raw.parse()
     if err != nil {
+        return resp, err
+    }
+    c.logger.Info().Str("symbol", resp.Symbol).Int64("order_id", resp.OrderID).Str("type", resp.Type).Str("status", resp.Status).
+        Float64("executed_qty", resp.ExecutedQty).Float64("avg_price", resp.AvgPrice()).Msg("binance order placed")
+    return resp, nil
+}
+
+// submitOCO places a limit order and a stop-limit order as one list.
+// Binance has no test endpoint for OCO orders, so test mode refuses them.
+func (c *Client) submitOCO(ctx context.Context, req cex.OrderRequest) (OrderResponse, error) {
+    if !c.live {
+        return OrderResponse{}, fmt.Errorf("%w: Binance cannot test-place OCO orders; enable live orders", cex.ErrInvalidOrder)
+    }
+    params := url.Values{}
+    params.Set("symbol", req.Symbol)
+    params.Set("side", req.Side)
+    params.Set("quantity", formatDecimal(req.Size))
+    params.Set("price", formatDecimal(req.Price))
+    params.Set("stopPrice", formatDecimal(req.StopPrice))
+    params.Set("stopLimitPrice", formatDecimal(req.StopLimitPrice))
+    params.Set("stopLimitTimeInForce", string(req.TimeInForce))
+    params.Set("listClientOrderId", req.ClientOrderID)
+    params.Set("newOrderRespType", "FULL")
+
+    var raw struct {
+        OrderListID       int64       `json:"orderListId"`
+        ListClientOrderID string      `json:"listClientOrderId"`
+        ListOrderStatus   string      `json:"listOrderStatus"`
+        TransactionTime   int64       `json:"transactionTime"`
+        Symbol            string      `json:"symbol"`
+        OrderReports      []orderJSON `json:"orderReports"`
+    }
+    if err := c.signed(ctx, http.MethodPost, "/api/v3/order/oco", params, &raw); err != nil {
+        return OrderResponse{}, err
+    }
+    resp := OrderResponse{Symbol: raw.Symbol, ListID: raw.OrderListID, ClientOrderID: raw.ListClientOrderID,
+        TransactTime: time.UnixMilli(raw.TransactionTime).UTC(), Status: raw.ListOrderStatus, Side: req.Side,
+        Type: string(cex.OCO), TimeInForce: string(req.TimeInForce), Price: req.Price, StopPrice: req.StopPrice, OrigQty: req.Size}
+    for _, report := range raw.OrderReports {
+        leg, err := report.parse()
+        if err != nil {
+            return resp, err
+        }
+        resp.ExecutedQty += leg.ExecutedQty
+        resp.QuoteQty += leg.QuoteQty
+        resp.Fills = append(resp.Fills, leg.Fills...)
+        resp.Orders = append(resp.Orders, leg)
+    }
+    c.logger.Info().Str("symbol", resp.Symbol).Int64("order_list_id", resp.ListID).Str("status", resp.Status).
+        Msg("binance OCO order placed")
+    return resp, nil
+}
+
+// orderJSON is the wire form of an order response; Binance sends decimals
+// as strings.
+type orderJSON struct {
+    Symbol        string `json:"symbol"`
+    OrderID       int64  `json:"orderId"`
+    OrderListID   int64  `json:"orderListId"`
+    ClientOrderID string `json:"clientOrderId"`
+    TransactTime  int64  `json:"transactTime"`
+    Status        string `json:"status"`
+    Side          string `json:"side"`
+    Type          string `json:"type"`
+    TimeInForce   string `json:"timeInForce"`
+    Price         string `json:"price"`
+    StopPrice     string `json:"stopPrice"`
+    OrigQty       string `json:"origQty"`
+    ExecutedQty   string `json:"executedQty"`
+    QuoteQty      string `json:"cummulativeQuoteQty"`
+    Fills         []struct {
+        TradeID         int64  `json:"tradeId"`
+        Price           string `json:"price"`
+        Qty             string `json:"qty"`
+        Commission      string `json:"commission"`
+        CommissionAsset string `json:"commissionAsset"`
+    } `json:"fills"`
+}
+
+func (o orderJSON) parse() (OrderResponse, error) {
+    resp := OrderResponse{Symbol: o.Symbol, OrderID: o.OrderID, ListID: o.OrderListID, ClientOrderID: o.ClientOrderID,
+        TransactTime: time.UnixMilli(o.TransactTime).UTC(), Status: o.Status, Side: o.Side, Type: o.Type, TimeInForce: o.TimeInForce}
+    var err error
+    for _, f := range []struct {
+        dst *float64
+        raw string
+    }{{&resp.Price, o.Price}, {&resp.StopPrice, o.StopPrice}, {&resp.OrigQty, o.OrigQty}, {&resp.ExecutedQty, o.ExecutedQty}, {&resp.QuoteQty, o.QuoteQty}} {
+        if *f.dst, err = parseDecimal(f.raw); err != nil {
+            return resp, fmt.Errorf("binance order %d: %w", o.OrderID, err)
+        }
+    }
+    for _, raw := range o.Fills {
+        fill := Fill{TradeID: raw.TradeID, CommissionAsset: raw.CommissionAsset}
+        for _, f := range []struct {
+            dst *float64
+            raw string
+        }{{&fill.Price, raw.Price}, {&fill.Qty, raw.Qty}, {&fill.Commission, raw.Commission}} {
+            if *f.dst, err = parseDecimal(f.raw); err != nil {
+                return resp, fmt.Errorf("binance order %d fill %d: %w", o.OrderID, raw.TradeID, err)
+            }
+        }
+        resp.Fills = append(resp.Fills, fill)
+    }
+    return resp, nil
+}
+
+func parseDecimal(v string) (float64, error) {
+    if v == "" {
+        return 0, nil
+    }
+    return strconv.ParseFloat(v, 64)
+}
+
+// formatDecimal writes v in plain notation with the fewest digits that
+// round-trip; %f would cut quantities to 6 decimals.
+func formatDecimal(v float64) string {
+    return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// newClientOrderID returns a random ID within Binance's 36 character limit.
+func newClientOrderID() (string, error) {
+    b := make([]byte, 12)
+    if _, err := rand.Read(b); err != nil {
         return "", err
     }
-    httpReq.Header.Set("X-MBX-APIKEY", c.apiKey)
+    return "tgct-" + hex.EncodeToString(b), nil
+}
 
-    resp, err := c.httpClient.Do(httpReq)
+// CancelOrder cancels a resting order, or both legs of an OCO order. IDs
+// PlaceOrder returned in test mode never reached the book, so there is
+// nothing to cancel.
+func (c *Client) CancelOrder(ctx context.Context, req cex.CancelRequest) error {
+    req, err := req.Normalize()
     if err != nil {
-        return "", err
+        return err
     }
-    defer resp.Body.Close()
+    if strings.HasPrefix(req.OrderID, "test-") {
+        c.logger.Info().Str("order_id", req.OrderID).Msg("test order has nothing to cancel")
+        return nil
+    }
+    // Numeric IDs are the exchange's; anything else is a client order ID.
+    _, numErr := strconv.ParseInt(req.OrderID, 10, 64)
+    numeric := numErr == nil
+    params := url.Values{}
+    params.Set("symbol", req.Symbol)
 
-    if resp.StatusCode >= 300 {
-        return "", fmt.Errorf("binance rejected order: %d", resp.StatusCode)
+    if req.Type == cex.OCO {
+        if numeric {
+            params.Set("orderListId", req.OrderID)
+        } else {
+            params.Set("listClientOrderId", req.OrderID)
+        }
+        var raw struct {
+            OrderListID     int64  `json:"orderListId"`
+            ListOrderStatus string `json:"listOrderStatus"`
+        }
+        if err := c.signed(ctx, http.MethodDelete, "/api/v3/orderList", params, &raw); err != nil {
+            return err
+        }
+        c.logger.Info().Str("symbol", req.Symbol).Int64("order_list_id", raw.OrderListID).Str("status", raw.ListOrderStatus).
+            Msg("binance OCO order cancelled")
+        return nil
     }
-    return "test-order", nil
-}
 
-// CancelOrder is a stub for the testnet.
-func (c *Client) CancelOrder(ctx context.Context, orderID string) error {
-    c.logger.Info().Str("order_id", orderID).Msg("cancel order noop on testnet")
+    if numeric {
+        params.Set("orderId", req.OrderID)
+    } else {
+        params.Set("origClientOrderId", req.OrderID)
+    }
+    var raw orderJSON
+    if err := c.signed(ctx, http.MethodDelete, "/api/v3/order", params, &raw); err != nil {
+        return err
+    }
+    c.logger.Info().Str("symbol", req.Symbol).Int64("order_id", raw.OrderID).Str("status", raw.Status).
+        Str("executed_qty", raw.ExecutedQty).Msg("binance order cancelled")
     return nil
 }
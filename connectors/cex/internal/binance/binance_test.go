@@ -0,0 +1,452 @@
+package binance
+
+import (
+    "context"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "net/http"
+    "net/http/httptest"
+    "strconv"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/rs/zerolog"
+
+    "github.com/example/tg-crypto-trader/connectors/cex"
+)
+
+const (
+    testKey    = "test-key"
+    testSecret = "test-secret"
+)
+
+// fakeBinance is a local stand-in for the Binance REST API that checks
+// signatures and timestamps the way the exchange does.
+type fakeBinance struct {
+    t      *testing.T
+    server *httptest.Server
+    // clock is the exchange's time; the client's clock is the real one.
+    clock func() time.Time
+
+    mu       sync.Mutex
+    requests []*http.Request
+    // rejectNext answers the next signed request with -1021.
+    rejectNext bool
+    timeCalls  int
+}
+
+func newFakeBinance(t *testing.T, skew time.Duration) *fakeBinance {
+    f := &fakeBinance{t: t, clock: func() time.Time { return time.Now().Add(skew) }}
+    mux := http.NewServeMux()
+    mux.HandleFunc("/api/v3/time", func(w http.ResponseWriter, r *http.Request) {
+        f.mu.Lock()
+        f.timeCalls++
+        f.mu.Unlock()
+        fmt.Fprintf(w, `{"serverTime":%d}`, f.clock().UnixMilli())
+    })
+    mux.HandleFunc("/api/v3/order/test", f.signed(func(w http.ResponseWriter, r *http.Request) {
+        fmt.Fprint(w, `{}`)
+    }))
+    mux.HandleFunc("/api/v3/order", f.signed(func(w http.ResponseWriter, r *http.Request) {
+        q := r.URL.Query()
+        if r.Method == http.MethodDelete {
+            if q.Get("orderId") != "29" && q.Get("origClientOrderId") != "my-limit-1" {
+                w.WriteHeader(http.StatusBadRequest)
+                fmt.Fprint(w, `{"code":-2011,"msg":"Unknown order sent."}`)
+                return
+            }
+            fmt.Fprintf(w, `{"symbol":%q,"origClientOrderId":"my-limit-1","orderId":29,"orderListId":-1,"clientOrderId":"cancel-1",
+                "price":"3500.00000000","origQty":"2.00000000","executedQty":"0.50000000","cummulativeQuoteQty":"1750.00000000",
+                "status":"CANCELED","timeInForce":"GTC","type":"LIMIT","side":"BUY"}`, q.Get("symbol"))
+            return
+        }
+        if q.Get("symbol") == "FAILUSDT" {
+            w.WriteHeader(http.StatusBadRequest)
+            fmt.Fprint(w, `{"code":-2010,"msg":"Account has insufficient balance for requested action."}`)
+            return
+        }
+        typ := q.Get("type")
+        if typ != "MARKET" {
+            // Resting orders come back unfilled.
+            fmt.Fprintf(w, `{"symbol":%q,"orderId":29,"orderListId":-1,"clientOrderId":%q,"transactTime":1507725176595,
+                "price":%q,"stopPrice":%q,"origQty":%q,"executedQty":"0.00000000","cummulativeQuoteQty":"0.00000000",
+                "status":"NEW","timeInForce":%q,"type":%q,"side":%q,"fills":[]}`,
+                q.Get("symbol"), q.Get("newClientOrderId"), q.Get("price"), q.Get("stopPrice"), q.Get("quantity"),
+                q.Get("timeInForce"), typ, q.Get("side"))
+            return
+        }
+        fmt.Fprintf(w, `{"symbol":%q,"orderId":28,"orderListId":-1,"clientOrderId":%q,"transactTime":1507725176595,
+            "price":"0.00000000","origQty":"1.50000000","executedQty":"1.50000000","cummulativeQuoteQty":"6000.50000000","status":"FILLED",
+            "timeInForce":"GTC","type":"MARKET","side":%q,"fills":[
+            {"price":"4000.00000000","qty":"1.00000000","commission":"4.00000000","commissionAsset":"USDT","tradeId":56},
+            {"price":"4001.00000000","qty":"0.50000000","commission":"2.00050000","commissionAsset":"USDT","tradeId":57}]}`,
+            q.Get("symbol"), q.Get("newClientOrderId"), q.Get("side"))
+    }))
+    mux.HandleFunc("/api/v3/order/oco", f.signed(func(w http.ResponseWriter, r *http.Request) {
+        q := r.URL.Query()
+        fmt.Fprintf(w, `{"orderListId":7,"contingencyType":"OCO","listStatusType":"EXEC_STARTED","listOrderStatus":"EXECUTING",
+            "listClientOrderId":%q,"transactionTime":1563417480525,"symbol":%q,"orderReports":[
+            {"symbol":%[2]q,"orderId":30,"orderListId":7,"clientOrderId":"leg-stop","transactTime":1563417480525,"price":%q,
+             "origQty":%q,"executedQty":"0.00000000","cummulativeQuoteQty":"0.00000000","status":"NEW","timeInForce":%q,
+             "type":"STOP_LOSS_LIMIT","side":%q,"stopPrice":%q},
+            {"symbol":%[2]q,"orderId":31,"orderListId":7,"clientOrderId":"leg-limit","transactTime":1563417480525,"price":%[8]q,
+             "origQty":%[4]q,"executedQty":"0.00000000","cummulativeQuoteQty":"0.00000000","status":"NEW","timeInForce":"GTC",
+             "type":"LIMIT_MAKER","side":%[6]q}]}`,
+            q.Get("listClientOrderId"), q.Get("symbol"), q.Get("stopLimitPrice"), q.Get("quantity"),
+            q.Get("stopLimitTimeInForce"), q.Get("side"), q.Get("stopPrice"), q.Get("price"))
+    }))
+    mux.HandleFunc("/api/v3/orderList", f.signed(func(w http.ResponseWriter, r *http.Request) {
+        q := r.URL.Query()
+        if r.Method != http.MethodDelete || (q.Get("orderListId") != "7" && q.Get("listClientOrderId") != "exit-1") {
+            w.WriteHeader(http.StatusBadRequest)
+            fmt.Fprint(w, `{"code":-2011,"msg":"Unknown order list sent."}`)
+            return
+        }
+        fmt.Fprintf(w, `{"orderListId":7,"contingencyType":"OCO","listStatusType":"ALL_DONE","listOrderStatus":"ALL_DONE",
+            "listClientOrderId":"exit-1","transactionTime":1563417480525,"symbol":%q}`, q.Get("symbol"))
+    }))
+    f.server = httptest.NewServer(mux)
+    t.Cleanup(f.server.Close)
+    return f
+}
+
+// signed verifies the API key, signature and timestamp window.
+func (f *fakeBinance) signed(next http.HandlerFunc) http.HandlerFunc {
+    return func(w http.ResponseWriter, r *http.Request) {
+        f.mu.Lock()
+        f.requests = append(f.requests, r)
+        reject := f.rejectNext
+        f.rejectNext = false
+        f.mu.Unlock()
+
+        fail := func(code int, msg string) {
+            w.WriteHeader(http.StatusBadRequest)
+            fmt.Fprintf(w, `{"code":%d,"msg":%q}`, code, msg)
+        }
+        if r.Header.Get("X-MBX-APIKEY") != testKey {
+            fail(-2014, "API-key format invalid.")
+            return
+        }
+        raw := r.URL.RawQuery
+        idx := strings.LastIndex(raw, "&signature=")
+        if idx < 0 {
+            fail(-1102, "Mandatory parameter 'signature' was not sent.")
+            return
+        }
+        mac := hmac.New(sha256.New, []byte(testSecret))
+        mac.Write([]byte(raw[:idx]))
+        if raw[idx+len("&signature="):] != hex.EncodeToString(mac.Sum(nil)) {
+            fail(-1022, "Signature for this request is not valid.")
+            return
+        }
+        q := r.URL.Query()
+        ts, err := strconv.ParseInt(q.Get("timestamp"), 10, 64)
+        if err != nil {
+            fail(-1102, "Mandatory parameter 'timestamp' was not sent.")
+            return
+        }
+        window, _ := strconv.ParseInt(q.Get("recvWindow"), 10, 64)
+        if window == 0 {
+            window = 5000
+        }
+        now := f.clock().UnixMilli()
+        if reject || ts > now+1000 || now-ts > window {
+            fail(codeTimestampOutOfWindow, "Timestamp for this request is outside of the recvWindow.")
+            return
+        }
+        next(w, r)
+    }
+}
+
+func (f *fakeBinance) client(live bool) *Client {
+    return NewClientWithOptions(testKey, testSecret, Options{RESTURL: f.server.URL, Live: live, RecvWindow: 3 * time.Second}, zerolog.Nop())
+}
+
+func TestLiveOrder(t *testing.T) {
+    // The exchange clock runs a minute ahead; unsynced requests would fail.
+    f := newFakeBinance(t, time.Minute)
+    c := f.client(true)
+
+    resp, err := c.SubmitOrder(context.Background(), cex.OrderRequest{Symbol: "ethusdt", Side: "buy", Size: 1.5})
+    if err != nil {
+        t.Fatalf("submit: %v", err)
+    }
+    if off := c.TimeOffset(); off < 59*time.Second || off > 61*time.Second {
+        t.Fatalf("time offset %v, want about a minute", off)
+    }
+    if resp.OrderID != 28 || resp.Status != "FILLED" || resp.Symbol != "ETHUSDT" || resp.Side != "BUY" || resp.Test {
+        t.Fatalf("unexpected response %+v", resp)
+    }
+    if !strings.HasPrefix(resp.ClientOrderID, "tgct-") || len(resp.ClientOrderID) > 36 {
+        t.Fatalf("client order id %q", resp.ClientOrderID)
+    }
+    if resp.ExecutedQty != 1.5 || resp.QuoteQty != 6000.5 || len(resp.Fills) != 2 {
+        t.Fatalf("unexpected quantities %+v", resp)
+    }
+    if got := resp.AvgPrice(); got < 4000.33 || got > 4000.34 {
+        t.Fatalf("avg price %v", got)
+    }
+    if fill := resp.Fills[1]; fill.TradeID != 57 || fill.Price != 4001 || fill.Qty != 0.5 || fill.Commission != 2.0005 || fill.CommissionAsset != "USDT" {
+        t.Fatalf("unexpected fill %+v", fill)
+    }
+
+    f.mu.Lock()
+    q := f.requests[0].URL.Query()
+    path := f.requests[0].URL.Path
+    f.mu.Unlock()
+    if path != "/api/v3/order" {
+        t.Fatalf("live order sent to %s", path)
+    }
+    for k, want := range map[string]string{"type": "MARKET", "quantity": "1.5", "recvWindow": "3000", "newOrderRespType": "FULL"} {
+        if got := q.Get(k); got != want {
+            t.Errorf("%s = %q, want %q", k, got, want)
+        }
+    }
+
+    id, err := c.PlaceOrder(context.Background(), cex.OrderRequest{Symbol: "ETHUSDT", Side: "SELL", Size: 0.00000001})
+    if err != nil || id != "28" {
+        t.Fatalf("place order = %q, %v", id, err)
+    }
+    f.mu.Lock()
+    qty := f.requests[1].URL.Query().Get("quantity")
+    f.mu.Unlock()
+    if qty != "0.00000001" {
+        t.Fatalf("quantity sent as %q", qty)
+    }
+}
+
+func TestTestOrder(t *testing.T) {
+    f := newFakeBinance(t, 0)
+    c := f.client(false)
+    id, err := c.PlaceOrder(context.Background(), cex.OrderRequest{Symbol: "BTCUSDT", Side: "BUY", Size: 0.01})
+    if err != nil {
+        t.Fatalf("place: %v", err)
+    }
+    if !strings.HasPrefix(id, "test-tgct-") {
+        t.Fatalf("test order id %q", id)
+    }
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    if len(f.requests) != 1 || f.requests[0].URL.Path != "/api/v3/order/test" {
+        t.Fatalf("test order should only hit /api/v3/order/test, got %d requests", len(f.requests))
+    }
+}
+
+func TestResyncAndErrors(t *testing.T) {
+    f := newFakeBinance(t, 0)
+    c := f.client(true)
+    ctx := context.Background()
+    if err := c.SyncTime(ctx); err != nil {
+        t.Fatalf("sync: %v", err)
+    }
+
+    f.mu.Lock()
+    f.rejectNext = true
+    f.mu.Unlock()
+    if _, err := c.PlaceOrder(ctx, cex.OrderRequest{Symbol: "ETHUSDT", Side: "BUY", Size: 1}); err != nil {
+        t.Fatalf("order after a rejected timestamp should resync and retry: %v", err)
+    }
+    f.mu.Lock()
+    calls := f.timeCalls
+    f.mu.Unlock()
+    if calls != 2 {
+        t.Fatalf("server time fetched %d times, want 2", calls)
+    }
+
+    _, err := c.PlaceOrder(ctx, cex.OrderRequest{Symbol: "FAILUSDT", Side: "BUY", Size: 1})
+    var apiErr *APIError
+    if !errors.As(err, &apiErr) || apiErr.Code != -2010 || apiErr.Status != http.StatusBadRequest || !strings.Contains(apiErr.Message, "insufficient balance") {
+        t.Fatalf("expected insufficient balance error, got %v", err)
+    }
+
+    bad := NewClientWithOptions(testKey, "wrong-secret", Options{RESTURL: f.server.URL, Live: true}, zerolog.Nop())
+    if _, err := bad.PlaceOrder(ctx, cex.OrderRequest{Symbol: "ETHUSDT", Side: "BUY", Size: 1}); !errors.As(err, &apiErr) || apiErr.Code != -1022 {
+        t.Fatalf("expected signature error, got %v", err)
+    }
+    if _, err := c.PlaceOrder(ctx, cex.OrderRequest{Symbol: "ETHUSDT", Side: "HOLD", Size: 1}); err == nil {
+        t.Fatal("expected invalid side to be rejected")
+    }
+}
+
+func TestOrderTypes(t *testing.T) {
+    f := newFakeBinance(t, 0)
+    c := f.client(true)
+    ctx := context.Background()
+
+    cases := []struct {
+        name string
+        req  cex.OrderRequest
+        want map[string]string
+    }{
+        {"quote market", cex.OrderRequest{Symbol: "ETHUSDT", Side: "BUY", QuoteSize: 250.5},
+            map[string]string{"type": "MARKET", "quoteOrderQty": "250.5", "quantity": "", "timeInForce": ""}},
+        {"limit", cex.OrderRequest{Symbol: "ETHUSDT", Side: "BUY", Type: cex.Limit, Size: 2, Price: 3500, ClientOrderID: "my-limit-1"},
+            map[string]string{"type": "LIMIT", "quantity": "2", "price": "3500", "timeInForce": "GTC", "newClientOrderId": "my-limit-1", "stopPrice": ""}},
+        {"stop loss", cex.OrderRequest{Symbol: "ETHUSDT", Side: "SELL", Type: "stop_loss_limit", Size: 1, Price: 2990, StopPrice: 3000, TimeInForce: cex.ImmediateOrCancel},
+            map[string]string{"type": "STOP_LOSS_LIMIT", "price": "2990", "stopPrice": "3000", "timeInForce": "IOC"}},
+        {"take profit", cex.OrderRequest{Symbol: "ETHUSDT", Side: "SELL", Type: cex.TakeProfitLimit, Size: 1, Price: 4010, StopPrice: 4000, TimeInForce: cex.FillOrKill},
+            map[string]string{"type": "TAKE_PROFIT_LIMIT", "price": "4010", "stopPrice": "4000", "timeInForce": "FOK"}},
+    }
+    for i, tc := range cases {
+        resp, err := c.SubmitOrder(ctx, tc.req)
+        if err != nil {
+            t.Fatalf("%s: %v", tc.name, err)
+        }
+        if resp.Type != tc.want["type"] {
+            t.Errorf("%s: response type %q", tc.name, resp.Type)
+        }
+        f.mu.Lock()
+        q := f.requests[i].URL.Query()
+        f.mu.Unlock()
+        for k, want := range tc.want {
+            if got := q.Get(k); got != want {
+                t.Errorf("%s: %s = %q, want %q", tc.name, k, got, want)
+            }
+        }
+    }
+
+    resp, err := c.SubmitOrder(ctx, cex.OrderRequest{Symbol: "ETHUSDT", Side: "SELL", Type: cex.Limit, Size: 2, Price: 3500.25, ClientOrderID: "my-limit-2"})
+    if err != nil {
+        t.Fatalf("limit: %v", err)
+    }
+    if resp.Status != "NEW" || resp.Price != 3500.25 || resp.OrigQty != 2 || resp.ExecutedQty != 0 || resp.ClientOrderID != "my-limit-2" || resp.TimeInForce != "GTC" {
+        t.Fatalf("unexpected limit response %+v", resp)
+    }
+}
+
+func TestOCOOrder(t *testing.T) {
+    f := newFakeBinance(t, 0)
+    ctx := context.Background()
+    req := cex.OrderRequest{Symbol: "ETHUSDT", Side: "SELL", Type: cex.OCO, Size: 1.25, Price: 4200, StopPrice: 3800, StopLimitPrice: 3790, ClientOrderID: "exit-1"}
+
+    if _, err := f.client(false).PlaceOrder(ctx, req); !errors.Is(err, cex.ErrInvalidOrder) {
+        t.Fatalf("test-mode OCO should be refused, got %v", err)
+    }
+
+    c := f.client(true)
+    id, err := c.PlaceOrder(ctx, req)
+    if err != nil || id != "7" {
+        t.Fatalf("place OCO = %q, %v", id, err)
+    }
+    f.mu.Lock()
+    q := f.requests[0].URL.Query()
+    path := f.requests[0].URL.Path
+    f.mu.Unlock()
+    if path != "/api/v3/order/oco" {
+        t.Fatalf("OCO order sent to %s", path)
+    }
+    for k, want := range map[string]string{"side": "SELL", "quantity": "1.25", "price": "4200", "stopPrice": "3800",
+        "stopLimitPrice": "3790", "stopLimitTimeInForce": "GTC", "listClientOrderId": "exit-1", "newOrderRespType": "FULL"} {
+        if got := q.Get(k); got != want {
+            t.Errorf("%s = %q, want %q", k, got, want)
+        }
+    }
+
+    resp, err := c.SubmitOrder(ctx, req)
+    if err != nil {
+        t.Fatalf("submit OCO: %v", err)
+    }
+    if resp.ListID != 7 || resp.Type != "OCO" || resp.Status != "EXECUTING" || resp.ClientOrderID != "exit-1" || len(resp.Orders) != 2 {
+        t.Fatalf("unexpected OCO response %+v", resp)
+    }
+    if stop := resp.Orders[0]; stop.OrderID != 30 || stop.Type != "STOP_LOSS_LIMIT" || stop.Price != 3790 || stop.StopPrice != 3800 || stop.ListID != 7 {
+        t.Fatalf("unexpected stop leg %+v", stop)
+    }
+    if limit := resp.Orders[1]; limit.OrderID != 31 || limit.Type != "LIMIT_MAKER" || limit.Price != 4200 || limit.OrigQty != 1.25 {
+        t.Fatalf("unexpected limit leg %+v", limit)
+    }
+}
+
+func TestCancelOrder(t *testing.T) {
+    f := newFakeBinance(t, 0)
+    c := f.client(true)
+    ctx := context.Background()
+
+    for _, tc := range []struct {
+        req  cex.CancelRequest
+        path string
+        want map[string]string
+    }{
+        {cex.CancelRequest{Symbol: "ethusdt", OrderID: "29", Type: cex.Limit}, "/api/v3/order",
+            map[string]string{"symbol": "ETHUSDT", "orderId": "29", "origClientOrderId": ""}},
+        {cex.CancelRequest{Symbol: "ETHUSDT", OrderID: "my-limit-1"}, "/api/v3/order",
+            map[string]string{"orderId": "", "origClientOrderId": "my-limit-1"}},
+        {cex.CancelRequest{Symbol: "ETHUSDT", OrderID: "7", Type: "oco"}, "/api/v3/orderList",
+            map[string]string{"orderListId": "7", "listClientOrderId": ""}},
+        {cex.CancelRequest{Symbol: "ETHUSDT", OrderID: "exit-1", Type: cex.OCO}, "/api/v3/orderList",
+            map[string]string{"orderListId": "", "listClientOrderId": "exit-1"}},
+    } {
+        if err := c.CancelOrder(ctx, tc.req); err != nil {
+            t.Fatalf("cancel %+v: %v", tc.req, err)
+        }
+        f.mu.Lock()
+        r := f.requests[len(f.requests)-1]
+        f.mu.Unlock()
+        if r.Method != http.MethodDelete || r.URL.Path != tc.path {
+            t.Fatalf("cancel %+v sent %s %s", tc.req, r.Method, r.URL.Path)
+        }
+        q := r.URL.Query()
+        for k, want := range tc.want {
+            if got := q.Get(k); got != want {
+                t.Errorf("cancel %+v: %s = %q, want %q", tc.req, k, got, want)
+            }
+        }
+    }
+
+    var apiErr *APIError
+    if err := c.CancelOrder(ctx, cex.CancelRequest{Symbol: "ETHUSDT", OrderID: "30"}); !errors.As(err, &apiErr) || apiErr.Code != -2011 {
+        t.Fatalf("expected unknown order error, got %v", err)
+    }
+    if err := c.CancelOrder(ctx, cex.CancelRequest{OrderID: "29"}); !errors.Is(err, cex.ErrInvalidOrder) {
+        t.Fatalf("expected missing symbol to be rejected, got %v", err)
+    }
+
+    f.mu.Lock()
+    sent := len(f.requests)
+    f.mu.Unlock()
+    if err := f.client(false).CancelOrder(ctx, cex.CancelRequest{Symbol: "BTCUSDT", OrderID: "test-tgct-abc"}); err != nil {
+        t.Fatalf("cancel test order: %v", err)
+    }
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    if len(f.requests) != sent {
+        t.Fatalf("test order cancel reached the exchange")
+    }
+}
+
+func TestInvalidOrders(t *testing.T) {
+    f := newFakeBinance(t, 0)
+    c := f.client(true)
+    for name, req := range map[string]cex.OrderRequest{
+        "no symbol":            {Side: "BUY", Size: 1},
+        "bad side":             {Symbol: "ETHUSDT", Side: "HOLD", Size: 1},
+        "market both sizes":    {Symbol: "ETHUSDT", Side: "BUY", Size: 1, QuoteSize: 100},
+        "market no size":       {Symbol: "ETHUSDT", Side: "BUY"},
+        "market with price":    {Symbol: "ETHUSDT", Side: "BUY", Size: 1, Price: 3000},
+        "market with tif":      {Symbol: "ETHUSDT", Side: "BUY", Size: 1, TimeInForce: cex.ImmediateOrCancel},
+        "limit no price":       {Symbol: "ETHUSDT", Side: "BUY", Type: cex.Limit, Size: 1},
+        "limit quote size":     {Symbol: "ETHUSDT", Side: "BUY", Type: cex.Limit, QuoteSize: 100, Price: 3000},
+        "limit bad tif":        {Symbol: "ETHUSDT", Side: "BUY", Type: cex.Limit, Size: 1, Price: 3000, TimeInForce: "GTD"},
+        "stop no stop price":   {Symbol: "ETHUSDT", Side: "SELL", Type: cex.StopLossLimit, Size: 1, Price: 3000},
+        "oco no stop limit":    {Symbol: "ETHUSDT", Side: "SELL", Type: cex.OCO, Size: 1, Price: 4200, StopPrice: 3800},
+        "oco sell inverted":    {Symbol: "ETHUSDT", Side: "SELL", Type: cex.OCO, Size: 1, Price: 3700, StopPrice: 3800, StopLimitPrice: 3790},
+        "oco buy inverted":     {Symbol: "ETHUSDT", Side: "BUY", Type: cex.OCO, Size: 1, Price: 3900, StopPrice: 3800, StopLimitPrice: 3810},
+        "unknown type":         {Symbol: "ETHUSDT", Side: "BUY", Type: "TRAILING", Size: 1, Price: 3000},
+        "bad client order id":  {Symbol: "ETHUSDT", Side: "BUY", Size: 1, ClientOrderID: "has spaces"},
+        "long client order id": {Symbol: "ETHUSDT", Side: "BUY", Size: 1, ClientOrderID: strings.Repeat("x", 37)},
+    } {
+        if _, err := c.PlaceOrder(context.Background(), req); !errors.Is(err, cex.ErrInvalidOrder) {
+            t.Errorf("%s: expected ErrInvalidOrder, got %v", name, err)
+        }
+    }
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    if len(f.requests) != 0 {
+        t.Fatalf("invalid orders reached the exchange %d times", len(f.requests))
+    }
+}
@@ -0,0 +1,136 @@
+package binance
+
+import (
+    "context"
+    "crypto/hmac"
+    "crypto/sha256"
+    "encoding/hex"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "strconv"
+    "time"
+)
+
+// codeTimestampOutOfWindow is Binance's error for requests whose timestamp
+// falls outside recvWindow, usually because the local clock drifted.
+const codeTimestampOutOfWindow = -1021
+
+// APIError is an error response from the Binance REST API.
+type APIError struct {
+    Status  int
+    Code    int    `json:"code"`
+    Message string `json:"msg"`
+}
+
+func (e *APIError) Error() string {
+    return fmt.Sprintf("binance: %s (code %d, status %d)", e.Message, e.Code, e.Status)
+}
+
+// SyncTime measures the offset between the local clock and Binance server
+// time; signed requests add it to their timestamp.
+func (c *Client) SyncTime(ctx context.Context) error {
+    var resp struct {
+        ServerTime int64 `json:"serverTime"`
+    }
+    sent := c.now()
+    if err := c.do(ctx, http.MethodGet, "/api/v3/time", nil, false, &resp); err != nil {
+        return fmt.Errorf("sync time: %w", err)
+    }
+    // Assume the server stamped the response halfway through the round trip.
+    local := sent.Add(c.now().Sub(sent) / 2)
+    offset := time.UnixMilli(resp.ServerTime).Sub(local)
+    c.mu.Lock()
+    c.offset, c.synced = offset, true
+    c.mu.Unlock()
+    c.logger.Debug().Dur("offset", offset).Msg("binance server time synced")
+    return nil
+}
+
+// TimeOffset returns the last measured server time offset.
+func (c *Client) TimeOffset() time.Duration {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return c.offset
+}
+
+// signed sends a SIGNED request, syncing the server time first and once
+// more if Binance rejects the timestamp.
+func (c *Client) signed(ctx context.Context, method, path string, params url.Values, out interface{}) error {
+    c.mu.Lock()
+    synced := c.synced
+    c.mu.Unlock()
+    if !synced {
+        if err := c.SyncTime(ctx); err != nil {
+            return err
+        }
+    }
+    err := c.do(ctx, method, path, params, true, out)
+    var apiErr *APIError
+    if errors.As(err, &apiErr) && apiErr.Code == codeTimestampOutOfWindow {
+        c.logger.Warn().Err(err).Msg("binance timestamp rejected; resyncing server time")
+        if err := c.SyncTime(ctx); err != nil {
+            return err
+        }
+        err = c.do(ctx, method, path, params, true, out)
+    }
+    return err
+}
+
+// do sends a request. Signed requests carry timestamp, recvWindow and an
+// HMAC-SHA256 signature of the query string keyed by the API secret.
+func (c *Client) do(ctx context.Context, method, path string, params url.Values, signed bool, out interface{}) error {
+    query := url.Values{}
+    for k, v := range params {
+        query[k] = v
+    }
+    encoded := query.Encode()
+    if signed {
+        query.Set("timestamp", strconv.FormatInt(c.now().Add(c.TimeOffset()).UnixMilli(), 10))
+        if c.recvWindow > 0 {
+            query.Set("recvWindow", strconv.FormatInt(c.recvWindow.Milliseconds(), 10))
+        }
+        encoded = query.Encode()
+        encoded += "&signature=" + c.sign(encoded)
+    }
+    reqURL := c.restURL + path
+    if encoded != "" {
+        reqURL += "?" + encoded
+    }
+    req, err := http.NewRequestWithContext(ctx, method, reqURL, nil)
+    if err != nil {
+        return err
+    }
+    if c.apiKey != "" {
+        req.Header.Set("X-MBX-APIKEY", c.apiKey)
+    }
+    resp, err := c.httpClient.Do(req)
+    if err != nil {
+        return err
+    }
+    defer resp.Body.Close()
+    body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+    if err != nil {
+        return err
+    }
+    if resp.StatusCode >= 300 {
+        apiErr := &APIError{Status: resp.StatusCode}
+        if json.Unmarshal(body, apiErr) != nil || apiErr.Message == "" {
+            apiErr.Message = http.StatusText(resp.StatusCode)
+        }
+        return apiErr
+    }
+    if out == nil {
+        return nil
+    }
+    return json.Unmarshal(body, out)
+}
+
+func (c *Client) sign(query string) string {
+    mac := hmac.New(sha256.New, []byte(c.secret))
+    mac.Write([]byte(query))
+    return hex.EncodeToString(mac.Sum(nil))
+}
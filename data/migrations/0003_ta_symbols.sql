@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS ta_symbols (
+    exchange TEXT NOT NULL,
+    pair TEXT NOT NULL,
+    interval TEXT NOT NULL,
+    added_by TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (exchange, pair, interval)
+);
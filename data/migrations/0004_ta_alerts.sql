@@ -0,0 +1,30 @@
+CREATE TABLE IF NOT EXISTS ta_alerts (
+    id BIGSERIAL PRIMARY KEY,
+    chat_id BIGINT NOT NULL,
+    kind TEXT NOT NULL,
+    exchange TEXT NOT NULL,
+    pair TEXT NOT NULL,
+    interval TEXT NOT NULL,
+    indicator TEXT NOT NULL DEFAULT '',
+    direction TEXT NOT NULL,
+    threshold DOUBLE PRECISION NOT NULL DEFAULT 0,
+    lookback INT NOT NULL DEFAULT 0,
+    recurring BOOLEAN NOT NULL DEFAULT FALSE,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    armed BOOLEAN NOT NULL DEFAULT TRUE,
+    last_fired TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS ta_alerts_chat_idx ON ta_alerts (chat_id);
+
+CREATE TABLE IF NOT EXISTS ta_alert_events (
+    id BIGSERIAL PRIMARY KEY,
+    alert_id BIGINT NOT NULL,
+    chat_id BIGINT NOT NULL,
+    message TEXT NOT NULL,
+    fired_at TIMESTAMPTZ NOT NULL,
+    delivered_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS ta_alert_events_pending_idx ON ta_alert_events (id) WHERE delivered_at IS NULL;
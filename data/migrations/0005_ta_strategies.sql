@@ -0,0 +1,27 @@
+CREATE TABLE IF NOT EXISTS ta_strategies (
+    id BIGSERIAL PRIMARY KEY,
+    chat_id BIGINT NOT NULL,
+    name TEXT NOT NULL,
+    pair TEXT NOT NULL,
+    interval TEXT NOT NULL,
+    entry TEXT NOT NULL,
+    exit TEXT NOT NULL DEFAULT '',
+    size DOUBLE PRECISION NOT NULL,
+    slippage_bps INT NOT NULL,
+    cooldown_seconds INT NOT NULL DEFAULT 0,
+    max_positions INT NOT NULL DEFAULT 1,
+    dry_run BOOLEAN NOT NULL DEFAULT TRUE,
+    paper_trading BOOLEAN NOT NULL DEFAULT TRUE,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    open_positions INT NOT NULL DEFAULT 0,
+    last_trade TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (chat_id, name)
+);
+
+CREATE TABLE IF NOT EXISTS ta_chat_filters (
+    chat_id BIGINT PRIMARY KEY,
+    expression TEXT NOT NULL,
+    interval TEXT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
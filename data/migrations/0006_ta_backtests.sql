@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS ta_backtests (
+    id BIGSERIAL PRIMARY KEY,
+    chat_id BIGINT NOT NULL DEFAULT 0,
+    strategy TEXT NOT NULL,
+    params JSONB NOT NULL DEFAULT '{}',
+    pair TEXT NOT NULL,
+    interval TEXT NOT NULL,
+    range_from TIMESTAMPTZ NOT NULL,
+    range_to TIMESTAMPTZ NOT NULL,
+    cash DOUBLE PRECISION NOT NULL,
+    status TEXT NOT NULL DEFAULT 'queued',
+    error TEXT NOT NULL DEFAULT '',
+    result JSONB,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS ta_backtests_queued_idx ON ta_backtests (id) WHERE status = 'queued';
@@ -21,6 +21,7 @@ pub struct TradeRequest {
     pub paper_trading: bool,
     pub interval: Option<String>,
     pub force: Option<bool>,
+    pub strategy: Option<String>,
 }
 
 impl TradeRequest {
@@ -35,6 +36,12 @@ impl TradeRequest {
     pub fn force(&self) -> bool {
         self.force.unwrap_or(false)
     }
+
+    /// Strategy intents were already gated by the TA strategy runner. The API
+    /// only lets the TA service's token set `strategy`.
+    pub fn from_strategy(&self) -> bool {
+        self.strategy.as_deref().map_or(false, |s| !s.is_empty())
+    }
 }
 
 impl Intent {
@@ -193,7 +193,7 @@ async fn handle_trade(
     ta_client: &TAClient,
     registry: &filters::Registry,
 ) -> anyhow::Result<()> {
-    if !trade.force() {
+    if !trade.force() && !trade.from_strategy() {
         if let Some(filter) = registry.get(&intent.principal).await {
             let allowed = filter.evaluate(ta_client, &trade.token).await?;
             if !allowed {
@@ -2,6 +2,7 @@ package engine
 
 import (
     "errors"
+    "fmt"
     "sync"
     "time"
 
@@ -20,49 +21,59 @@ type Engine struct {
     cooldownState map[string]time.Time
     maxPortfolio  decimal.Decimal
     exposure      decimal.Decimal
+    now           func() time.Time
 }
 
 // New creates an Engine instance.
 func New(limits map[string]models.RiskLimits, maxPortfolio decimal.Decimal) *Engine {
+    return NewWithClock(limits, maxPortfolio, time.Now)
+}
+
+// NewWithClock creates an Engine whose cooldowns follow now instead of the
+// wall clock, e.g. a backtest's simulated time.
+func NewWithClock(limits map[string]models.RiskLimits, maxPortfolio decimal.Decimal, now func() time.Time) *Engine {
     return &Engine{
         limits:        limits,
         maxPortfolio:  maxPortfolio,
         cooldownState: make(map[string]time.Time),
         exposure:      decimal.Zero,
+        now:           now,
     }
 }
 
-// Evaluate validates a trade intent against configured limits.
+// Evaluate validates a trade intent against configured limits. Rejections
+// wrap ErrRiskRejected with the rule that failed.
 func (e *Engine) Evaluate(intent models.TradeIntent) error {
     e.mu.Lock()
     defer e.mu.Unlock()
 
     notional := intent.Size.Mul(intent.Price)
     if e.exposure.Add(notional).GreaterThan(e.maxPortfolio) {
-        return ErrRiskRejected
+        return fmt.Errorf("%w: portfolio exposure %s would exceed %s", ErrRiskRejected, e.exposure.Add(notional).StringFixed(2), e.maxPortfolio)
     }
 
     limit, ok := e.limits[intent.Token]
     if !ok {
-        return ErrRiskRejected
+        return fmt.Errorf("%w: no limits for %s", ErrRiskRejected, intent.Token)
     }
 
     if notional.GreaterThan(limit.MaxNotionalUSD) {
-        return ErrRiskRejected
+        return fmt.Errorf("%w: notional %s above the %s cap", ErrRiskRejected, notional.StringFixed(2), limit.MaxNotionalUSD)
     }
 
     if intent.MaxSlippageBps > limit.MaxSlippageBps {
-        return ErrRiskRejected
+        return fmt.Errorf("%w: slippage %d bps above the %d bps cap", ErrRiskRejected, intent.MaxSlippageBps, limit.MaxSlippageBps)
     }
 
+    now := e.now()
     if until, exists := e.cooldownState[intent.Token]; exists {
-        if time.Now().Before(until) {
-            return ErrRiskRejected
+        if now.Before(until) {
+            return fmt.Errorf("%w: %s cooling down until %s", ErrRiskRejected, intent.Token, until.UTC().Format(time.RFC3339))
         }
     }
 
     if limit.Cooldown > 0 {
-        e.cooldownState[intent.Token] = time.Now().Add(time.Duration(limit.Cooldown) * time.Second)
+        e.cooldownState[intent.Token] = now.Add(time.Duration(limit.Cooldown) * time.Second)
     }
 
     e.exposure = e.exposure.Add(notional)
@@ -1,6 +1,8 @@
 package engine
 
 import (
+    "errors"
+    "strings"
     "testing"
     "time"
 
@@ -45,3 +47,24 @@ func TestEngineEvaluate(t *testing.T) {
         t.Fatalf("expected slippage rejection")
     }
 }
+
+func TestEngineVirtualClock(t *testing.T) {
+    limits := map[string]models.RiskLimits{
+        "WETH": {MaxNotionalUSD: decimal.NewFromInt(5000), MaxSlippageBps: 75, Cooldown: 3600},
+    }
+    now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+    eng := NewWithClock(limits, decimal.NewFromInt(100000), func() time.Time { return now })
+    intent := models.TradeIntent{Token: "WETH", Size: decimal.NewFromInt(1), Price: decimal.NewFromInt(2000), MaxSlippageBps: 50}
+
+    if err := eng.Evaluate(intent); err != nil {
+        t.Fatalf("expected intent to pass: %v", err)
+    }
+    now = now.Add(59 * time.Minute)
+    if err := eng.Evaluate(intent); !errors.Is(err, ErrRiskRejected) || !strings.Contains(err.Error(), "cooling down") {
+        t.Fatalf("expected cooldown rejection, got %v", err)
+    }
+    now = now.Add(time.Minute)
+    if err := eng.Evaluate(intent); err != nil {
+        t.Fatalf("expected cooldown to expire on the virtual clock: %v", err)
+    }
+}
@@ -0,0 +1,156 @@
+// Package preset defines named risk presets and gates trades through the
+// risk engine with them. It is the risk module's public entry point for
+// other services, such as the TA backtester.
+package preset
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shopspring/decimal"
+
+	"github.com/example/tg-crypto-trader/risk/internal/engine"
+	"github.com/example/tg-crypto-trader/risk/internal/models"
+)
+
+// ErrRejected is wrapped by every rejection.
+var ErrRejected = engine.ErrRiskRejected
+
+// Limit is the policy for one token.
+type Limit struct {
+	MaxNotionalUSD  float64 `json:"max_notional_usd" yaml:"max_notional_usd"`
+	MaxSlippageBps  int     `json:"max_slippage_bps" yaml:"max_slippage_bps"`
+	CooldownSeconds int64   `json:"cooldown_seconds" yaml:"cooldown_seconds"`
+}
+
+// Preset is a named set of limits. Tokens without their own entry use
+// Default; a zero Default leaves them unlisted, and the engine rejects them.
+type Preset struct {
+	Name            string           `json:"name" yaml:"name"`
+	MaxPortfolioUSD float64          `json:"max_portfolio_usd" yaml:"max_portfolio_usd"`
+	Default         Limit            `json:"default" yaml:"default"`
+	Tokens          map[string]Limit `json:"tokens,omitempty" yaml:"tokens"`
+}
+
+var builtin = map[string]Preset{
+	"conservative": {
+		Name: "conservative", MaxPortfolioUSD: 5000,
+		Default: Limit{MaxNotionalUSD: 1000, MaxSlippageBps: 50, CooldownSeconds: 3600},
+	},
+	"balanced": {
+		Name: "balanced", MaxPortfolioUSD: 25000,
+		Default: Limit{MaxNotionalUSD: 5000, MaxSlippageBps: 100, CooldownSeconds: 900},
+	},
+	"aggressive": {
+		Name: "aggressive", MaxPortfolioUSD: 100000,
+		Default: Limit{MaxNotionalUSD: 25000, MaxSlippageBps: 300, CooldownSeconds: 60},
+	},
+}
+
+// Builtin returns a built-in preset by name.
+func Builtin(name string) (Preset, bool) {
+	p, ok := builtin[strings.ToLower(strings.TrimSpace(name))]
+	return p, ok
+}
+
+// Names lists the built-in presets.
+func Names() []string {
+	names := make([]string, 0, len(builtin))
+	for name := range builtin {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks the preset for negative or missing limits.
+func (p Preset) Validate() error {
+	if p.MaxPortfolioUSD <= 0 {
+		return errors.New("max_portfolio_usd must be positive")
+	}
+	check := func(name string, l Limit) error {
+		if l.MaxNotionalUSD < 0 || l.MaxSlippageBps < 0 || l.CooldownSeconds < 0 {
+			return fmt.Errorf("%s: limits must not be negative", name)
+		}
+		return nil
+	}
+	if err := check("default", p.Default); err != nil {
+		return err
+	}
+	for token, l := range p.Tokens {
+		if err := check(token, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Intent is a trade to vet. Size is in base units, Price in USD.
+type Intent struct {
+	Token       string
+	Side        string
+	Size        float64
+	Price       float64
+	SlippageBps int
+}
+
+// Gate runs intents through a risk engine configured from a preset.
+type Gate struct {
+	mu     sync.Mutex
+	preset Preset
+	limits map[string]models.RiskLimits
+	eng    *engine.Engine
+}
+
+// NewGate builds a gate whose cooldowns follow now; pass time.Now for live
+// trading or a simulated clock for backtests.
+func (p Preset) NewGate(now func() time.Time) *Gate {
+	g := &Gate{preset: p, limits: make(map[string]models.RiskLimits)}
+	for token, l := range p.Tokens {
+		g.limits[strings.ToUpper(token)] = l.model()
+	}
+	g.eng = engine.NewWithClock(g.limits, decimal.NewFromFloat(p.MaxPortfolioUSD), now)
+	return g
+}
+
+// Evaluate approves the intent and books its exposure, or returns an error
+// wrapping ErrRejected.
+func (g *Gate) Evaluate(in Intent) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	token := strings.ToUpper(in.Token)
+	if _, ok := g.limits[token]; !ok && g.preset.Default != (Limit{}) {
+		g.limits[token] = g.preset.Default.model()
+	}
+	return g.eng.Evaluate(in.model(token))
+}
+
+// Release frees the exposure of an approved intent once it settles or
+// will not fill.
+func (g *Gate) Release(in Intent) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.eng.Release(in.model(strings.ToUpper(in.Token)))
+}
+
+func (l Limit) model() models.RiskLimits {
+	return models.RiskLimits{
+		MaxNotionalUSD: decimal.NewFromFloat(l.MaxNotionalUSD),
+		MaxSlippageBps: l.MaxSlippageBps,
+		Cooldown:       l.CooldownSeconds,
+	}
+}
+
+func (in Intent) model(token string) models.TradeIntent {
+	return models.TradeIntent{
+		Token:          token,
+		Size:           decimal.NewFromFloat(in.Size),
+		Price:          decimal.NewFromFloat(in.Price),
+		Side:           in.Side,
+		MaxSlippageBps: in.SlippageBps,
+	}
+}
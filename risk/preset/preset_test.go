@@ -0,0 +1,46 @@
+package preset
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGate(t *testing.T) {
+	p, ok := Builtin("Conservative")
+	if !ok || p.Validate() != nil {
+		t.Fatalf("conservative preset missing or invalid: %+v", p)
+	}
+	p.Tokens = map[string]Limit{"btcusdt": {MaxNotionalUSD: 3000, MaxSlippageBps: 50}}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	g := p.NewGate(func() time.Time { return now })
+
+	big := Intent{Token: "ETHUSDT", Side: "buy", Size: 1, Price: 2000, SlippageBps: 10}
+	if err := g.Evaluate(big); !errors.Is(err, ErrRejected) {
+		t.Fatalf("expected the default 1000 USD cap to reject, got %v", err)
+	}
+	small := Intent{Token: "ETHUSDT", Side: "buy", Size: 0.25, Price: 2000, SlippageBps: 10}
+	if err := g.Evaluate(small); err != nil {
+		t.Fatalf("small intent: %v", err)
+	}
+	if err := g.Evaluate(small); !errors.Is(err, ErrRejected) {
+		t.Fatal("expected the cooldown to reject a second ETHUSDT trade")
+	}
+	now = now.Add(time.Hour)
+	if err := g.Evaluate(small); err != nil {
+		t.Fatalf("cooldown should follow the clock: %v", err)
+	}
+	// The token override lifts the cap but the portfolio limit still holds.
+	btc := Intent{Token: "BTCUSDT", Side: "buy", Size: 0.07, Price: 40000, SlippageBps: 10}
+	if err := g.Evaluate(btc); err != nil {
+		t.Fatalf("btc intent: %v", err)
+	}
+	if err := g.Evaluate(btc); !errors.Is(err, ErrRejected) {
+		t.Fatal("expected the portfolio limit to reject")
+	}
+	g.Release(btc)
+	g.Release(small)
+	if err := g.Evaluate(btc); err != nil {
+		t.Fatalf("released exposure should allow the trade again: %v", err)
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/backtest"
+	"github.com/example/tg-crypto-trader/ta-service/internal/storage"
+)
+
+// loadSeries reads the run's candles from its CSV files, or from ta_candles
+// when pairs are given. Candles before the run's from date only warm the
+// strategies up.
+func loadSeries(ctx context.Context, run runConfig, dbURL string) ([]backtest.Series, time.Time, error) {
+	from, to, err := run.dateRange()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	var series []backtest.Series
+	switch {
+	case len(run.Pairs) > 0 && len(run.files()) > 0:
+		return nil, time.Time{}, errors.New("give either CSV files or pairs to load from the database, not both")
+	case len(run.Pairs) > 0:
+		series, err = loadDB(ctx, run, dbURL, from, to)
+	case len(run.files()) > 0:
+		series, err = loadCSVs(run, to)
+	default:
+		return nil, time.Time{}, errors.New("csv file or pairs required")
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	for _, s := range series {
+		if len(s.Candles) == 0 {
+			return nil, time.Time{}, fmt.Errorf("no %s candles in range", s.Pair)
+		}
+	}
+	return series, from, nil
+}
+
+// loadDB loads every pair's closed candles from Postgres, reaching back
+// run.Warmup bars before from.
+func loadDB(ctx context.Context, run runConfig, dbURL string, from, to time.Time) ([]backtest.Series, error) {
+	if dbURL == "" {
+		return nil, errors.New("database URL required: set -db or TA_SERVICE_POSTGRES_URL")
+	}
+	store, err := storage.New(ctx, dbURL)
+	if err != nil {
+		return nil, err
+	}
+	defer store.Close()
+	return backtest.LoadStored(ctx, store, run.Exchange, run.Pairs, run.Interval, from, to, run.Warmup)
+}
+
+// loadCSVs reads every file, cut at to.
+func loadCSVs(run runConfig, to time.Time) ([]backtest.Series, error) {
+	out, err := backtest.LoadCSVFiles(run.files(), run.Interval)
+	if err != nil || to.IsZero() {
+		return out, err
+	}
+	return backtest.Slice(out, time.Time{}, to), nil
+}
+
+// dateRange parses the run's from and to dates.
+func (r runConfig) dateRange() (from, to time.Time, err error) {
+	if from, err = parseDate(r.From); err != nil {
+		return from, to, fmt.Errorf("from: %w", err)
+	}
+	if to, err = parseDate(r.To); err != nil {
+		return from, to, fmt.Errorf("to: %w", err)
+	}
+	if !to.IsZero() && !to.After(from) {
+		return from, to, errors.New("to must be after from")
+	}
+	return from, to, nil
+}
+
+// parseDate reads YYYY-MM-DD or RFC 3339; empty is the zero time.
+func parseDate(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return t, fmt.Errorf("invalid date %q: want YYYY-MM-DD or RFC 3339", v)
+	}
+	return t, nil
+}
+
+// stringsFlag collects repeatable or comma separated values.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string { return strings.Join(*s, ",") }
+
+func (s *stringsFlag) Set(v string) error {
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			*s = append(*s, part)
+		}
+	}
+	return nil
+}
@@ -1,110 +1,304 @@
 package main
 
 import (
-	"bufio"
-	"encoding/csv"
+	"context"
 	"flag"
+	"fmt"
 	"log"
-	"math"
 	"os"
 	"strconv"
-	"time"
+	"strings"
 
 	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v3"
 
-	"github.com/example/tg-crypto-trader/ta-service/internal/candles"
-	"github.com/example/tg-crypto-trader/ta-service/internal/indicators"
+	"github.com/example/tg-crypto-trader/ta-service/internal/backtest"
 )
 
+// runConfig is the YAML run file; flags given on the command line override it.
+type runConfig struct {
+	File         string           `yaml:"file"`
+	Files        []string         `yaml:"files"`
+	Exchange     string           `yaml:"exchange"`
+	Pairs        []string         `yaml:"pairs"`
+	Interval     string           `yaml:"interval"`
+	From         string           `yaml:"from"`
+	To           string           `yaml:"to"`
+	Warmup       int              `yaml:"warmup"`
+	Strategy     string           `yaml:"strategy"`
+	Params       backtest.Params  `yaml:"params"`
+	Cash         float64          `yaml:"cash"`
+	PositionSize float64          `yaml:"position_size"`
+	Fees         string           `yaml:"fees"`
+	Slippage     string           `yaml:"slippage"`
+	NextOpen     bool             `yaml:"next_open"`
+	Latency      int              `yaml:"latency"`
+	Risk         riskConfig       `yaml:"risk"`
+	MonteCarlo   monteCarloConfig `yaml:"monte_carlo"`
+	Sweep        sweepConfig      `yaml:"sweep"`
+}
+
+// files lists file and files together.
+func (r runConfig) files() []string {
+	if r.File == "" {
+		return r.Files
+	}
+	return append([]string{r.File}, r.Files...)
+}
+
 func main() {
-	file := flag.String("file", "", "path to CSV with timestamp,open,high,low,close,volume")
+	configPath := flag.String("config", "", "YAML run file with file, strategy, params and cash")
+	var files, pairs stringsFlag
+	flag.Var(&files, "file", "CSV with a header naming timestamp (ms), open, high, low, close, volume and optionally pair; repeatable")
+	flag.Var(&pairs, "pairs", "pairs to load from ta_candles instead of CSV, comma separated")
+	dbURL := flag.String("db", os.Getenv("TA_SERVICE_POSTGRES_URL"), "Postgres URL for -pairs")
+	exchange := flag.String("exchange", "binance", "exchange of the -pairs candles")
+	interval := flag.String("interval", "1h", "candle interval")
+	from := flag.String("from", "", "first traded candle, YYYY-MM-DD or RFC 3339; earlier candles only warm up")
+	to := flag.String("to", "", "end of the data, exclusive")
+	warmup := flag.Int("warmup", 200, "bars loaded from the database before -from to warm indicators up")
+	positionSize := flag.Float64("position-size", 0, "fraction of equity per buy; 0 splits it evenly across pairs")
+	strategyName := flag.String("strategy", "rsi", "strategy to run; see -list")
+	cash := flag.Float64("cash", backtest.DefaultConfig().Cash, "starting cash")
+	params := paramFlag{}
+	flag.Var(params, "param", "strategy parameter as name=value; repeatable or comma separated")
+	fees := flag.String("fees", "binance", "fee schedule: none, binance, binance-bnb, binance-vip, uniswap or MAKER%/TAKER%")
+	slippage := flag.String("slippage", "none", "slippage model: none, fixed:AMOUNT, pct:PERCENT or impact:COEF[:MAX%]")
+	nextOpen := flag.Bool("next-open", true, "fill market orders at the next bar's open instead of the signal bar's close")
+	latency := flag.Int("latency", 0, "order latency in bars")
+	risk := flag.String("risk", "", "route orders through the risk engine with a preset: conservative, balanced, aggressive or a YAML file")
+	riskSlippage := flag.Int("risk-slippage-bps", 50, "slippage tolerance in bps each order asks the risk engine for")
+	out := flag.String("out", "", "directory for report.json, trades.csv, equity.csv and report.html")
+	grid := gridFlag{}
+	flag.Var(grid, "grid", "sweep parameter as name=v1|v2|v3 or name=min:max:step; repeatable")
+	ranges := rangeFlag{}
+	flag.Var(ranges, "range", "random-search parameter as name=min:max; repeatable, needs -samples")
+	samples := flag.Int("samples", 0, "random parameter combinations to try instead of the full grid")
+	seed := flag.Int64("seed", 1, "random search and Monte Carlo seed")
+	objective := flag.String("objective", "sharpe", "sweep ranking: sharpe, sortino, return, cagr, calmar or drawdown")
+	workers := flag.Int("workers", 0, "parallel sweep runs; 0 uses every CPU")
+	top := flag.Int("top", 10, "sweep results to print")
+	wfIn := flag.Int("wf-in", 0, "walk-forward in-sample window in bars; enables walk-forward with -wf-out")
+	wfOut := flag.Int("wf-out", 0, "walk-forward out-of-sample window in bars")
+	mc := defaultMonteCarlo()
+	flag.IntVar(&mc.Runs, "mc", 0, "Monte Carlo runs over the result; 0 disables")
+	flag.StringVar(&mc.Method, "mc-method", mc.Method, "Monte Carlo method: shuffle or resample trades, or bootstrap bar returns")
+	flag.Float64Var(&mc.Ruin, "mc-ruin", mc.Ruin, "loss of starting equity that counts as ruin, as a fraction")
+	flag.Float64Var(&mc.Confidence, "mc-confidence", mc.Confidence, "confidence level of the reported intervals")
+	flag.IntVar(&mc.Block, "mc-block", mc.Block, "bootstrap block length in bars")
+	list := flag.Bool("list", false, "list strategies and their default parameters")
 	flag.Parse()
-	if *file == "" {
-		log.Fatal("csv file required")
+
+	if *list {
+		for _, f := range backtest.Factories() {
+			fmt.Printf("%-10s %s\n           %s\n", f.Name, f.Description, f.Defaults)
+		}
+		return
+	}
+
+	run := runConfig{Exchange: *exchange, Interval: *interval, Warmup: *warmup, Strategy: *strategyName, Cash: *cash, Fees: *fees, Slippage: *slippage, NextOpen: *nextOpen, Latency: *latency,
+		Risk: riskConfig{Preset: *risk, SlippageBps: *riskSlippage}, MonteCarlo: mc,
+		Sweep: sweepConfig{Seed: *seed, Objective: *objective, Workers: *workers, Top: *top}}
+	if *configPath != "" {
+		var err error
+		if run, err = loadRunConfig(*configPath); err != nil {
+			log.Fatalf("load config: %v", err)
+		}
+	}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "file":
+			run.File, run.Files = "", files
+		case "pairs":
+			run.Pairs = pairs
+		case "exchange":
+			run.Exchange = *exchange
+		case "interval":
+			run.Interval = *interval
+		case "from":
+			run.From = *from
+		case "to":
+			run.To = *to
+		case "warmup":
+			run.Warmup = *warmup
+		case "position-size":
+			run.PositionSize = *positionSize
+		case "strategy":
+			run.Strategy = *strategyName
+		case "cash":
+			run.Cash = *cash
+		case "fees":
+			run.Fees = *fees
+		case "slippage":
+			run.Slippage = *slippage
+		case "next-open":
+			run.NextOpen = *nextOpen
+		case "latency":
+			run.Latency = *latency
+		case "risk":
+			run.Risk.Preset = *risk
+		case "risk-slippage-bps":
+			run.Risk.SlippageBps = *riskSlippage
+		case "mc":
+			run.MonteCarlo.Runs = mc.Runs
+		case "mc-method":
+			run.MonteCarlo.Method = mc.Method
+		case "mc-ruin":
+			run.MonteCarlo.Ruin = mc.Ruin
+		case "mc-confidence":
+			run.MonteCarlo.Confidence = mc.Confidence
+		case "mc-block":
+			run.MonteCarlo.Block = mc.Block
+		case "samples":
+			run.Sweep.Samples = *samples
+		case "seed":
+			run.Sweep.Seed = *seed
+			run.MonteCarlo.Seed = *seed
+		case "objective":
+			run.Sweep.Objective = *objective
+		case "workers":
+			run.Sweep.Workers = *workers
+		case "top":
+			run.Sweep.Top = *top
+		case "wf-in":
+			run.Sweep.WalkForward.InSample = *wfIn
+		case "wf-out":
+			run.Sweep.WalkForward.OutOfSample = *wfOut
+		}
+	})
+	run.Sweep.merge(grid, ranges)
+	if run.Params == nil {
+		run.Params = backtest.Params{}
 	}
-	candlesData, err := loadCSV(*file)
+	for k, v := range params {
+		run.Params[k] = v
+	}
+
+	_, err := backtest.New(run.Strategy, run.Params)
+	if err != nil {
+		log.Fatalf("strategy: %v", err)
+	}
+	cfg := backtest.DefaultConfig()
+	cfg.Cash = run.Cash
+	if run.PositionSize < 0 || run.PositionSize > 1 {
+		log.Fatal("position size must be between 0 and 1")
+	}
+	cfg.PositionSize = run.PositionSize
+	if cfg.Fills, err = run.fillModel(); err != nil {
+		log.Fatalf("fill model: %v", err)
+	}
+	if cfg.NewGate, err = run.Risk.gateFactory(); err != nil {
+		log.Fatalf("risk: %v", err)
+	}
+	series, tradeFrom, err := loadSeries(context.Background(), run, *dbURL)
 	if err != nil {
-		log.Fatalf("load csv: %v", err)
+		log.Fatalf("load candles: %v", err)
+	}
+	cfg.TradeFrom = tradeFrom
+	if run.Sweep.enabled() {
+		if err := runSweep(run, series, cfg, *out); err != nil {
+			log.Fatalf("sweep: %v", err)
+		}
+		return
 	}
-	source := &sliceSource{candles: candlesData}
-	indicatorSvc := indicators.NewService(source)
 	logger := zerolog.New(os.Stdout).With().Timestamp().Logger()
 
-	cash := 10000.0
-	position := 0.0
-	entry := 0.0
-
-	for i := 50; i < len(candlesData); i++ {
-		source.cursor = i
-		rsi, err := indicatorSvc.RSI("BACKTEST", "1m")
-		if err != nil {
-			continue
-		}
-		price := candlesData[i].Close
-		if position == 0 && rsi.Value < 30 {
-			qty := math.Floor((cash/price)*1000) / 1000
-			if qty <= 0 {
-				continue
-			}
-			position = qty
-			cash -= qty * price
-			entry = price
-			logger.Info().Float64("price", price).Msg("BUY")
-		} else if position > 0 && (rsi.Value > 70 || price < entry*0.95) {
-			cash += position * price
-			logger.Info().Float64("price", price).Msg("SELL")
-			position = 0
+	res, err := backtest.RunPortfolio(series, func() (backtest.Strategy, error) { return backtest.New(run.Strategy, run.Params) }, cfg)
+	if err != nil {
+		log.Fatalf("run: %v", err)
+	}
+	for _, f := range res.Fills {
+		logger.Info().Str("pair", f.Pair).Time("at", f.Time).Float64("price", f.Price).Float64("qty", f.Qty).Float64("fee", f.Fee).Str("type", string(f.Type)).Str("reason", f.Reason).Msg(strings.ToUpper(string(f.Side)))
+	}
+	for _, r := range res.Rejected {
+		logger.Warn().Str("pair", r.Pair).Time("at", r.Time).Str("side", string(r.Side)).Float64("price", r.Price).Float64("qty", r.Qty).Str("reason", r.Reason).Msg("rejected by risk")
+	}
+	report := backtest.NewReport(res, strategyParams(run))
+	m := report.Metrics
+	logger.Info().
+		Str("strategy", res.Strategy).
+		Strs("pairs", res.Pairs).
+		Float64("final_cash", res.FinalCash).
+		Float64("total_return", m.TotalReturn).
+		Float64("cagr", m.CAGR).
+		Float64("sharpe", m.Sharpe).
+		Float64("sortino", m.Sortino).
+		Float64("max_drawdown", m.MaxDrawdown).
+		Dur("max_drawdown_duration", m.MaxDrawdownDuration).
+		Int("trades", m.Trades).
+		Float64("win_rate", m.WinRate).
+		Float64("exposure", m.Exposure).
+		Float64("fees", res.Fees).
+		Int("unfilled", res.Unfilled).
+		Int("rejected", len(res.Rejected)).
+		Msg("backtest complete")
+	if *out != "" {
+		if err := report.WriteFiles(*out); err != nil {
+			log.Fatalf("write report: %v", err)
 		}
+		logger.Info().Str("dir", *out).Msg("report written")
 	}
-	if position > 0 {
-		cash += position * candlesData[len(candlesData)-1].Close
+	if run.MonteCarlo.Runs > 0 {
+		if err := runMonteCarlo(run.MonteCarlo, report, *out); err != nil {
+			log.Fatalf("monte carlo: %v", err)
+		}
 	}
-	logger.Info().Float64("final_cash", cash).Msg("backtest complete")
 }
 
-type sliceSource struct {
-	candles []candles.Candle
-	cursor  int
+func loadRunConfig(path string) (runConfig, error) {
+	run := runConfig{Exchange: "binance", Interval: "1h", Warmup: 200, Strategy: "rsi", Cash: backtest.DefaultConfig().Cash, Fees: "binance", Slippage: "none", NextOpen: true,
+		Risk: riskConfig{SlippageBps: 50}, MonteCarlo: defaultMonteCarlo(), Sweep: sweepConfig{Seed: 1, Objective: "sharpe", Top: 10}}
+	f, err := os.Open(path)
+	if err != nil {
+		return run, err
+	}
+	defer f.Close()
+	dec := yaml.NewDecoder(f)
+	dec.KnownFields(true)
+	if err := dec.Decode(&run); err != nil {
+		return run, fmt.Errorf("%s: %w", path, err)
+	}
+	return run, nil
 }
 
-func (s *sliceSource) Candles(exchange, pair, interval string) []candles.Candle {
-	if s.cursor < len(s.candles) {
-		return append([]candles.Candle(nil), s.candles[:s.cursor+1]...)
-	}
-	return s.candles
+// strategyParams returns the run's parameters merged over the defaults.
+func strategyParams(r runConfig) backtest.Params {
+	f, _ := backtest.Lookup(r.Strategy)
+	params, _ := f.Resolve(r.Params)
+	return params
 }
 
-func loadCSV(path string) ([]candles.Candle, error) {
-	f, err := os.Open(path)
+func (r runConfig) fillModel() (backtest.FillModel, error) {
+	fees, err := backtest.ParseFees(r.Fees)
 	if err != nil {
-		return nil, err
+		return backtest.FillModel{}, err
 	}
-	defer f.Close()
-	reader := csv.NewReader(bufio.NewReader(f))
-	reader.FieldsPerRecord = 6
-	rows, err := reader.ReadAll()
+	slippage, err := backtest.ParseSlippage(r.Slippage)
 	if err != nil {
-		return nil, err
-	}
-	var out []candles.Candle
-	for _, row := range rows[1:] { // skip header
-		ts, _ := strconv.ParseInt(row[0], 10, 64)
-		open, _ := strconv.ParseFloat(row[1], 64)
-		high, _ := strconv.ParseFloat(row[2], 64)
-		low, _ := strconv.ParseFloat(row[3], 64)
-		closePrice, _ := strconv.ParseFloat(row[4], 64)
-		vol, _ := strconv.ParseFloat(row[5], 64)
-		out = append(out, candles.Candle{
-			Exchange: "backtest",
-			Pair:     "BACKTEST",
-			Interval: "1m",
-			Open:     open,
-			High:     high,
-			Low:      low,
-			Close:    closePrice,
-			Volume:   vol,
-			Start:    time.Unix(ts, 0),
-		})
-	}
-	return out, nil
+		return backtest.FillModel{}, err
+	}
+	if r.Latency < 0 {
+		return backtest.FillModel{}, fmt.Errorf("latency must not be negative")
+	}
+	return backtest.FillModel{Fees: fees, Slippage: slippage, NextBarOpen: r.NextOpen, LatencyBars: r.Latency}, nil
+}
+
+// paramFlag collects -param name=value pairs.
+type paramFlag backtest.Params
+
+func (p paramFlag) String() string { return backtest.Params(p).String() }
+
+func (p paramFlag) Set(v string) error {
+	for _, pair := range strings.Split(v, ",") {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("expected name=value, got %q", pair)
+		}
+		n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return fmt.Errorf("parameter %s: %w", name, err)
+		}
+		p[strings.ToLower(strings.TrimSpace(name))] = n
+	}
+	return nil
 }
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/backtest"
+)
+
+// monteCarloConfig is the monte_carlo section of the YAML run file.
+type monteCarloConfig struct {
+	Runs       int     `yaml:"runs"`
+	Method     string  `yaml:"method"`
+	Seed       int64   `yaml:"seed"`
+	Ruin       float64 `yaml:"ruin"`
+	Confidence float64 `yaml:"confidence"`
+	Block      int     `yaml:"block"`
+}
+
+func defaultMonteCarlo() monteCarloConfig {
+	d := backtest.DefaultMonteCarlo()
+	return monteCarloConfig{Method: d.Method, Seed: d.Seed, Ruin: d.Ruin, Confidence: d.Confidence, Block: d.Block}
+}
+
+// runMonteCarlo prints the analysis of report and writes montecarlo.json.
+func runMonteCarlo(mc monteCarloConfig, report backtest.Report, out string) error {
+	res, err := backtest.MonteCarlo(report, backtest.MonteCarloOptions{
+		Method: mc.Method, Runs: mc.Runs, Seed: mc.Seed, Ruin: mc.Ruin, Confidence: mc.Confidence, Block: mc.Block,
+	})
+	if err != nil {
+		return err
+	}
+	if err := res.WriteText(os.Stdout); err != nil {
+		return err
+	}
+	return writeJSON(out, "montecarlo.json", res)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/example/tg-crypto-trader/risk/preset"
+	"github.com/example/tg-crypto-trader/ta-service/internal/backtest"
+)
+
+// riskConfig is the risk section of the YAML run file.
+type riskConfig struct {
+	// Preset names a built-in preset or a YAML file holding one.
+	Preset string `yaml:"preset"`
+	// SlippageBps is the slippage tolerance every order asks for.
+	SlippageBps int `yaml:"slippage_bps"`
+}
+
+// gateFactory returns a fresh risk gate per run, or nil without a preset.
+func (r riskConfig) gateFactory() (func() backtest.Gate, error) {
+	if r.Preset == "" {
+		return nil, nil
+	}
+	p, err := loadPreset(r.Preset)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.Validate(); err != nil {
+		return nil, fmt.Errorf("risk preset %s: %w", r.Preset, err)
+	}
+	return func() backtest.Gate {
+		g := &riskGate{slippageBps: r.SlippageBps}
+		g.gate = p.NewGate(func() time.Time { return g.now })
+		return g
+	}, nil
+}
+
+func loadPreset(name string) (preset.Preset, error) {
+	if p, ok := preset.Builtin(name); ok {
+		return p, nil
+	}
+	if !strings.HasSuffix(name, ".yaml") && !strings.HasSuffix(name, ".yml") {
+		return preset.Preset{}, fmt.Errorf("unknown risk preset %q (have %s, or a YAML file)", name, strings.Join(preset.Names(), ", "))
+	}
+	raw, err := os.ReadFile(name)
+	if err != nil {
+		return preset.Preset{}, err
+	}
+	var p preset.Preset
+	if err := yaml.Unmarshal(raw, &p); err != nil {
+		return p, fmt.Errorf("%s: %w", name, err)
+	}
+	return p, nil
+}
+
+// riskGate runs backtest orders through the risk engine on the simulated
+// clock.
+type riskGate struct {
+	gate        *preset.Gate
+	now         time.Time
+	slippageBps int
+}
+
+func (g *riskGate) Check(at time.Time, pair string, side backtest.Side, qty, price float64) error {
+	g.now = at
+	return g.gate.Evaluate(g.intent(pair, side, qty, price))
+}
+
+func (g *riskGate) Release(pair string, side backtest.Side, qty, price float64) {
+	g.gate.Release(g.intent(pair, side, qty, price))
+}
+
+func (g *riskGate) intent(pair string, side backtest.Side, qty, price float64) preset.Intent {
+	return preset.Intent{Token: pair, Side: string(side), Size: qty, Price: price, SlippageBps: g.slippageBps}
+}
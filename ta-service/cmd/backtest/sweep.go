@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/backtest"
+)
+
+// sweepConfig is the sweep section of the YAML run file.
+type sweepConfig struct {
+	Grid        map[string][]float64      `yaml:"grid"`
+	Ranges      map[string]backtest.Range `yaml:"ranges"`
+	Samples     int                       `yaml:"samples"`
+	Seed        int64                     `yaml:"seed"`
+	Objective   string                    `yaml:"objective"`
+	Workers     int                       `yaml:"workers"`
+	Top         int                       `yaml:"top"`
+	WalkForward struct {
+		InSample    int `yaml:"in_sample"`
+		OutOfSample int `yaml:"out_of_sample"`
+	} `yaml:"walk_forward"`
+}
+
+func (s *sweepConfig) enabled() bool {
+	return len(s.Grid) > 0 || len(s.Ranges) > 0
+}
+
+// merge adds the -grid and -range flags over the file's values.
+func (s *sweepConfig) merge(grid gridFlag, ranges rangeFlag) {
+	for name, values := range grid {
+		if s.Grid == nil {
+			s.Grid = make(map[string][]float64)
+		}
+		s.Grid[name] = values
+	}
+	for name, r := range ranges {
+		if s.Ranges == nil {
+			s.Ranges = make(map[string]backtest.Range)
+		}
+		s.Ranges[name] = r
+	}
+}
+
+// runSweep ranks parameter combinations, or walks them forward when
+// windows are configured, and prints the results.
+func runSweep(run runConfig, data []backtest.Series, cfg backtest.Config, out string) error {
+	factory, ok := backtest.Lookup(run.Strategy)
+	if !ok {
+		return fmt.Errorf("%w %q", backtest.ErrUnknownStrategy, run.Strategy)
+	}
+	objective, err := backtest.ParseObjective(run.Sweep.Objective)
+	if err != nil {
+		return err
+	}
+	space := backtest.ParamSpace{Grid: run.Sweep.Grid, Ranges: run.Sweep.Ranges, Samples: run.Sweep.Samples, Seed: run.Sweep.Seed}
+	candidates, err := space.Candidates(factory)
+	if err != nil {
+		return err
+	}
+	// Parameters fixed with -param or params apply to every candidate.
+	for _, c := range candidates {
+		for k, v := range run.Params {
+			if _, swept := c[k]; !swept {
+				c[k] = v
+			}
+		}
+	}
+	opts := backtest.SweepOptions{Objective: objective, Workers: run.Sweep.Workers}
+
+	if wf := run.Sweep.WalkForward; wf.InSample > 0 || wf.OutOfSample > 0 {
+		res, err := backtest.WalkForward(factory, candidates, data, cfg, opts,
+			backtest.WalkForwardOptions{InSample: wf.InSample, OutOfSample: wf.OutOfSample})
+		if err != nil {
+			return err
+		}
+		printWalkForward(res)
+		return writeJSON(out, "walkforward.json", res)
+	}
+
+	trials := backtest.Sweep(factory, candidates, data, cfg, opts)
+	printTrials(trials, objective.Name, run.Sweep.Top)
+	return writeJSON(out, "sweep.json", trials)
+}
+
+func printTrials(trials []backtest.Trial, objective string, top int) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "rank\t%s\treturn\tmax dd\ttrades\twin rate\tparams\t\n", objective)
+	invalid := 0
+	for i, t := range trials {
+		if t.Err != "" {
+			invalid++
+			continue
+		}
+		if top > 0 && i >= top {
+			continue
+		}
+		m := t.Metrics
+		fmt.Fprintf(tw, "%d\t%.4g\t%.2f%%\t%.2f%%\t%d\t%.0f%%\t%s\t\n", i+1, t.Score, m.TotalReturn*100, m.MaxDrawdown*100, m.Trades, m.WinRate*100, t.Params)
+	}
+	tw.Flush()
+	fmt.Printf("%d combinations", len(trials))
+	if invalid > 0 {
+		fmt.Printf(", %d rejected by the strategy (e.g. %s)", invalid, trials[len(trials)-1].Err)
+	}
+	fmt.Println()
+}
+
+func printWalkForward(res backtest.WalkForwardResult) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "window\tout-of-sample from\tin-sample %s\tout-of-sample %s\treturn\tparams\t\n", res.Objective, res.Objective)
+	for i, w := range res.Windows {
+		fmt.Fprintf(tw, "%d\t%s\t%.4g\t%.4g\t%.2f%%\t%s\t\n", i+1, w.Split.UTC().Format("2006-01-02 15:04"), w.InSample, w.OutOfSample, w.Metrics.TotalReturn*100, w.Params)
+	}
+	tw.Flush()
+	fmt.Printf("mean %s: in-sample %.4g, out-of-sample %.4g, efficiency %.2f; compounded out-of-sample return %.2f%%\n",
+		res.Objective, res.InSampleMean, res.OutOfSampleMean, res.Efficiency, res.Return*100)
+}
+
+func writeJSON(dir, name string, v any) error {
+	if dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	raw, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, name), append(raw, '\n'), 0o644)
+}
+
+// gridFlag collects -grid specs.
+type gridFlag map[string][]float64
+
+func (g gridFlag) String() string { return fmt.Sprint(map[string][]float64(g)) }
+
+func (g gridFlag) Set(v string) error {
+	name, values, err := backtest.ParseGrid(v)
+	if err != nil {
+		return err
+	}
+	g[name] = values
+	return nil
+}
+
+// rangeFlag collects -range specs.
+type rangeFlag map[string]backtest.Range
+
+func (r rangeFlag) String() string {
+	parts := make([]string, 0, len(r))
+	for name, v := range r {
+		parts = append(parts, fmt.Sprintf("%s=%g:%g", name, v.Min, v.Max))
+	}
+	return strings.Join(parts, ",")
+}
+
+func (r rangeFlag) Set(v string) error {
+	name, rng, err := backtest.ParseRange(v)
+	if err != nil {
+		return err
+	}
+	r[name] = rng
+	return nil
+}
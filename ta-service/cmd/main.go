@@ -10,11 +10,17 @@ import (
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
+	"github.com/example/tg-crypto-trader/ta-service/internal/alerts"
+	"github.com/example/tg-crypto-trader/ta-service/internal/backtest"
 	"github.com/example/tg-crypto-trader/ta-service/internal/candles"
+	"github.com/example/tg-crypto-trader/ta-service/internal/cluster"
 	"github.com/example/tg-crypto-trader/ta-service/internal/config"
 	"github.com/example/tg-crypto-trader/ta-service/internal/indicators"
+	"github.com/example/tg-crypto-trader/ta-service/internal/replay"
 	"github.com/example/tg-crypto-trader/ta-service/internal/server"
 	"github.com/example/tg-crypto-trader/ta-service/internal/storage"
+	"github.com/example/tg-crypto-trader/ta-service/internal/strategy"
+	"github.com/example/tg-crypto-trader/ta-service/internal/telemetry"
 	"github.com/example/tg-crypto-trader/ta-service/internal/ws"
 )
 
@@ -28,24 +34,147 @@ func main() {
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
 	defer cancel()
 
+	telemetry.StartMetricsServer(ctx, cfg.MetricsAddr, log.With().Str("component", "ta-metrics").Logger())
+
 	store, err := storage.New(ctx, cfg.PostgresURL)
 	if err != nil {
 		log.Fatal().Err(err).Msg("connect postgres")
 	}
 	defer store.Close()
 
-	bridge, err := candles.NewRustBridge(cfg.UniswapPairs, log.With().Str("component", "uniswap-bridge").Logger())
-	if err != nil {
-		log.Warn().Err(err).Msg("uniswap bridge disabled")
+	writer := storage.NewWriter(store, storage.WriterOptions{
+		BatchSize:     cfg.PersistBatchSize,
+		FlushInterval: cfg.PersistFlush,
+		MaxPending:    cfg.PersistMaxPending,
+	}, log.With().Str("component", "candle-writer").Logger())
+	writer.Start(ctx)
+	defer writer.Close()
+
+	var bridge candles.UniswapBridge
+	if len(cfg.UniswapPairs) > 0 {
+		bridge, err = newUniswapBridge(cfg)
+		if err != nil {
+			log.Warn().Err(err).Msg("uniswap bridge disabled")
+			bridge = nil
+		} else {
+			defer bridge.Close()
+		}
 	}
 
-	candleSvc := candles.NewService(cfg, store, bridge, log.With().Str("component", "candles").Logger())
-	candleSvc.Start(ctx)
+	candleSvc := candles.NewService(cfg, store, writer, bridge, log.With().Str("component", "candles").Logger())
+
+	now := time.Now
+	var replayClock *replay.Clock
+	var replaySeries []backtest.Series
+	if cfg.Mode == config.ModeReplay {
+		replaySeries, replayClock, err = loadReplay(ctx, cfg, store)
+		if err != nil {
+			log.Fatal().Err(err).Msg("load replay")
+		}
+		now = replayClock.Now
+	} else {
+		candleSvc.Warm(ctx)
+	}
 
 	indicatorSvc := indicators.NewService(candleSvc)
 
+	alertEngine := alerts.NewEngine(store, candleSvc, indicatorSvc, alerts.Options{
+		MaxPerChat: cfg.AlertMaxPerChat,
+		Refresh:    cfg.AlertRefresh,
+		Now:        now,
+	}, log.With().Str("component", "alerts").Logger())
+	candleSvc.OnCandleClose(alertEngine.OnCandleClose)
+
+	var intents strategy.IntentSink
+	switch {
+	case cfg.Mode == config.ModeReplay:
+		// Replayed signals must never reach a live account.
+		log.Info().Msg("replay mode; strategies run in dry-run mode only")
+	case cfg.APIURL != "":
+		intents = strategy.NewAPIClient(cfg.APIURL, cfg.APIToken)
+	default:
+		log.Warn().Msg("TA_SERVICE_API_URL not set; strategies run in dry-run mode only")
+	}
+	runner := strategy.NewRunner(store, indicatorSvc, candleSvc, intents, strategy.Options{
+		MaxPerChat: cfg.StrategyMaxPerChat,
+		Refresh:    cfg.StrategyRefresh,
+		Now:        now,
+	}, log.With().Str("component", "strategy").Logger())
+	candleSvc.OnCandleClose(runner.OnCandleClose)
+
 	httpSrv := server.NewHTTP(indicatorSvc, candleSvc, log.With().Str("component", "http").Logger())
-	wsHub := ws.NewHub(indicatorSvc, candleSvc, log.With().Str("component", "ws").Logger())
+	if cfg.AdminToken == "" {
+		log.Warn().Msg("TA_SERVICE_ADMIN_TOKEN not set; admin, alert, strategy and backtest endpoints are disabled")
+	} else {
+		httpSrv.EnableAdmin(candleSvc, cfg.AdminToken)
+		httpSrv.EnableAlerts(alertEngine, cfg.AdminToken)
+		httpSrv.EnableStrategies(runner, cfg.AdminToken)
+	}
+
+	// Jobs are claimed through Postgres, so every replica can run them.
+	backtests := backtest.NewJobs(store, backtest.JobOptions{
+		Workers:   cfg.BacktestWorkers,
+		MaxQueued: cfg.BacktestMaxQueued,
+		MaxBars:   cfg.BacktestMaxBars,
+		Timeout:   cfg.BacktestTimeout,
+		Warmup:    cfg.BacktestWarmup,
+	}, log.With().Str("component", "backtests").Logger())
+	if cfg.AdminToken != "" {
+		httpSrv.EnableBacktests(backtests, cfg.AdminToken)
+	}
+	go backtests.Run(ctx)
+	wsHub := ws.NewHub(indicatorSvc, candleSvc, ws.Options{
+		Token:      cfg.WSToken,
+		SendBuffer: cfg.WSSendBuffer,
+		Policy:     ws.Policy(cfg.WSSlowPolicy),
+	}, log.With().Str("component", "ws").Logger())
+	alertEngine.OnFire(wsHub.PublishAlert)
+
+	switch {
+	case cfg.Mode == config.ModeReplay:
+		from, _, _ := cfg.ReplayRange()
+		player := replay.NewPlayer(replaySeries, candleSvc, replayClock, []replay.Worker{alertEngine, runner}, replay.Options{
+			Exchange: cfg.ReplayExchange,
+			From:     from,
+			Speed:    cfg.ReplaySpeed,
+		}, log.With().Str("component", "replay").Logger())
+		go alertEngine.Run(ctx)
+		go runner.Run(ctx)
+		go func() {
+			// The service stays up after the replay so its state can be inspected.
+			if err := player.Run(ctx); err != nil && ctx.Err() == nil {
+				log.Error().Err(err).Msg("replay failed")
+			}
+		}()
+	case cfg.Role == config.RoleCluster:
+		redisClient, err := cluster.NewRedisClient(cfg.RedisURL)
+		if err != nil {
+			log.Fatal().Err(err).Msg("parse redis url")
+		}
+		defer redisClient.Close()
+		clusterLog := log.With().Str("component", "cluster").Logger()
+		elector := cluster.NewElector(redisClient, cfg.LeaderLockKey, cfg.InstanceID, cfg.LeaderLockTTL, clusterLog)
+		publisher := cluster.NewPublisher(redisClient, cfg.CandleChannel, elector, clusterLog)
+		candleSvc.OnCandleClose(publisher.Publish)
+		go publisher.Run(ctx)
+		// Alerts fire on the collector; followers push them to their own clients.
+		alertPublisher := cluster.NewPublisher(redisClient, cfg.AlertChannel, elector, clusterLog)
+		alertEngine.OnFire(func(f alerts.Fired) { alertPublisher.Send(f) })
+		go alertPublisher.Run(ctx)
+		// Only the collector evaluates alerts and strategies so each fires once.
+		go elector.Run(ctx, func(leadCtx context.Context) {
+			candleSvc.Start(leadCtx)
+			go runner.Run(leadCtx)
+			alertEngine.Run(leadCtx)
+		}, func(followCtx context.Context) {
+			go cluster.SubscribeAlerts(followCtx, redisClient, cfg.AlertChannel, wsHub.PublishAlert, clusterLog)
+			cluster.Subscribe(followCtx, redisClient, cfg.CandleChannel, candleSvc.Ingest, clusterLog)
+		})
+	default:
+		candleSvc.Start(ctx)
+		go alertEngine.Run(ctx)
+		go runner.Run(ctx)
+	}
 
 	mux := http.NewServeMux()
 	mux.Handle("/", httpSrv.Router())
@@ -64,6 +193,7 @@ func main() {
 		defer cancel()
 		_ = srv.Shutdown(shutdownCtx)
 		wsHub.Broadcast(map[string]string{"status": "shutdown"})
+		wsHub.Close()
 	}()
 
 	log.Info().Str("addr", cfg.HTTPAddr).Msg("ta-service listening")
@@ -71,3 +201,48 @@ func main() {
 		log.Error().Err(err).Msg("http server error")
 	}
 }
+
+// loadReplay loads the candles to replay and a clock starting at the replay's
+// from date, or at the first candle without one.
+func loadReplay(ctx context.Context, cfg config.Config, store *storage.Store) ([]backtest.Series, *replay.Clock, error) {
+	from, to, err := cfg.ReplayRange()
+	if err != nil {
+		return nil, nil, err
+	}
+	series, err := replay.Load(ctx, store, replay.Source{
+		Files:    cfg.ReplayFiles,
+		Exchange: cfg.ReplayExchange,
+		Pairs:    cfg.ReplayPairs,
+		Interval: cfg.ReplayInterval,
+		From:     from,
+		To:       to,
+		Warmup:   cfg.ReplayWarmup,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	start := from
+	if start.IsZero() {
+		if timeline := backtest.Timeline(series); len(timeline) > 0 {
+			start = timeline[0]
+		}
+	}
+	return series, replay.NewClock(start, cfg.ReplaySpeed), nil
+}
+
+func newUniswapBridge(cfg config.Config) (*candles.SwapBridge, error) {
+	pools, err := candles.ParsePools(cfg.UniswapPairs)
+	if err != nil {
+		return nil, err
+	}
+	return candles.NewSwapBridge(candles.SwapOptions{
+		Pools:         pools,
+		Interval:      cfg.Interval,
+		RPCURL:        cfg.EthNodeURL,
+		ReplayFile:    cfg.UniswapReplayFile,
+		PollInterval:  cfg.UniswapPollInterval,
+		Confirmations: cfg.UniswapConfirmations,
+		StartBlock:    cfg.UniswapStartBlock,
+		Lookback:      cfg.BackfillLookback,
+	}, log.With().Str("component", "uniswap-bridge").Logger())
+}
@@ -0,0 +1,477 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/candles"
+	"github.com/example/tg-crypto-trader/ta-service/internal/indicators"
+	"github.com/example/tg-crypto-trader/ta-service/internal/model"
+	"github.com/example/tg-crypto-trader/ta-service/internal/telemetry"
+)
+
+// Alert errors.
+var (
+	ErrInvalidAlert   = errors.New("invalid alert")
+	ErrTooManyAlerts  = errors.New("too many alerts")
+	ErrAlertNotFound  = errors.New("alert not found")
+	errSignalsMissing = errors.New("signals unavailable")
+)
+
+const maxLookback = 500
+
+// Store persists alerts and the outbox of fired alerts.
+type Store interface {
+	ActiveAlerts(ctx context.Context) ([]model.Alert, error)
+	ChatAlerts(ctx context.Context, chatID int64) ([]model.Alert, error)
+	CountChatAlerts(ctx context.Context, chatID int64) (int, error)
+	CreateAlert(ctx context.Context, a *model.Alert) error
+	DeleteAlert(ctx context.Context, chatID, id int64) (bool, error)
+	SetAlertArmed(ctx context.Context, id int64, armed bool) error
+	RecordAlertFired(ctx context.Context, a model.Alert, ev *model.AlertEvent) error
+	PendingAlertEvents(ctx context.Context, limit int) ([]model.AlertEvent, error)
+	AckAlertEvents(ctx context.Context, ids []int64) error
+}
+
+// CandleSource returns buffered candles.
+type CandleSource interface {
+	Candles(exchange, pair, interval string) []candles.Candle
+}
+
+// SignalSource returns the latest indicator signals of a Binance pair.
+type SignalSource interface {
+	Signals(pair, interval string) (map[string]float64, error)
+}
+
+// Fired describes a fired alert to listeners such as the WebSocket hub. It
+// leaves out the chat, since listeners may serve other chats.
+type Fired struct {
+	AlertID  int64           `json:"alert_id"`
+	Kind     model.AlertKind `json:"kind"`
+	Exchange string          `json:"exchange"`
+	Pair     string          `json:"pair"`
+	Interval string          `json:"interval"`
+	Message  string          `json:"message"`
+	FiredAt  time.Time       `json:"fired_at"`
+}
+
+// Options tunes the engine.
+type Options struct {
+	// MaxPerChat caps the active alerts of one chat.
+	MaxPerChat int
+	// Refresh is how often alerts are reloaded so alerts created on other
+	// replicas are evaluated.
+	Refresh time.Duration
+	// QueueSize bounds closed candles awaiting evaluation.
+	QueueSize int
+	// Now reads the clock; replays pass their virtual clock. Defaults to
+	// time.Now.
+	Now func() time.Time
+}
+
+// Engine evaluates alerts on closed candles and queues fired alerts for
+// delivery.
+type Engine struct {
+	store   Store
+	candles CandleSource
+	signals SignalSource
+	opts    Options
+	logger  zerolog.Logger
+
+	running atomic.Bool
+	queue   chan candles.Candle
+	// pending counts queued candles and the one being evaluated.
+	pending atomic.Int64
+
+	mu     sync.Mutex
+	alerts map[int64]model.Alert
+
+	listenersMu sync.RWMutex
+	listeners   []func(Fired)
+}
+
+// NewEngine returns an Engine. Evaluation starts with Run.
+func NewEngine(store Store, source CandleSource, signals SignalSource, opts Options, logger zerolog.Logger) *Engine {
+	if opts.MaxPerChat <= 0 {
+		opts.MaxPerChat = 50
+	}
+	if opts.Refresh <= 0 {
+		opts.Refresh = 15 * time.Second
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1024
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &Engine{
+		store:   store,
+		candles: source,
+		signals: signals,
+		opts:    opts,
+		logger:  logger,
+		queue:   make(chan candles.Candle, opts.QueueSize),
+		alerts:  make(map[int64]model.Alert),
+	}
+}
+
+// OnCandleClose queues a closed candle for evaluation. It never blocks the
+// ingest path; candles are dropped while the engine is stopped or behind.
+func (e *Engine) OnCandleClose(c candles.Candle) {
+	if !e.running.Load() {
+		return
+	}
+	e.pending.Add(1)
+	select {
+	case e.queue <- c:
+	default:
+		e.pending.Add(-1)
+		e.logger.Warn().Str("pair", c.Pair).Str("interval", c.Interval).Msg("alert queue full; candle skipped")
+	}
+}
+
+// OnFire registers fn to run after each fired alert is recorded.
+func (e *Engine) OnFire(fn func(Fired)) {
+	e.listenersMu.Lock()
+	defer e.listenersMu.Unlock()
+	e.listeners = append(e.listeners, fn)
+}
+
+// Idle reports whether the alert evaluation is running with no closed
+// candles queued or being evaluated. Replays wait for it before advancing
+// their clock.
+func (e *Engine) Idle() bool {
+	return e.running.Load() && e.pending.Load() == 0
+}
+
+// Run evaluates alerts until ctx ends. In cluster mode only the elected
+// collector runs the engine so each alert fires once.
+func (e *Engine) Run(ctx context.Context) {
+	for len(e.queue) > 0 {
+		<-e.queue
+		e.pending.Add(-1)
+	}
+	e.reload(ctx)
+	e.running.Store(true)
+	defer e.running.Store(false)
+	ticker := time.NewTicker(e.opts.Refresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.reload(ctx)
+		case c := <-e.queue:
+			e.evaluate(ctx, c)
+			e.pending.Add(-1)
+		}
+	}
+}
+
+func (e *Engine) reload(ctx context.Context) {
+	list, err := e.store.ActiveAlerts(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			e.logger.Warn().Err(err).Msg("failed to load alerts")
+		}
+		return
+	}
+	next := make(map[int64]model.Alert, len(list))
+	for _, a := range list {
+		next[a.ID] = a
+	}
+	e.mu.Lock()
+	e.alerts = next
+	e.mu.Unlock()
+}
+
+// Create validates and stores a new alert for a chat.
+func (e *Engine) Create(ctx context.Context, a model.Alert) (model.Alert, error) {
+	if err := normalize(&a); err != nil {
+		return a, err
+	}
+	count, err := e.store.CountChatAlerts(ctx, a.ChatID)
+	if err != nil {
+		return a, err
+	}
+	if count >= e.opts.MaxPerChat {
+		return a, fmt.Errorf("%w: chat has %d active alerts", ErrTooManyAlerts, count)
+	}
+	// Threshold alerts arm once their condition has been seen false.
+	crossing := a.Kind == model.AlertPrice || a.Kind == model.AlertIndicator
+	a.Active, a.Armed, a.LastFired = true, !crossing, nil
+	if err := e.store.CreateAlert(ctx, &a); err != nil {
+		return a, err
+	}
+	e.mu.Lock()
+	e.alerts[a.ID] = a
+	e.mu.Unlock()
+	return a, nil
+}
+
+// List returns a chat's alerts.
+func (e *Engine) List(ctx context.Context, chatID int64) ([]model.Alert, error) {
+	return e.store.ChatAlerts(ctx, chatID)
+}
+
+// Delete removes a chat's alert.
+func (e *Engine) Delete(ctx context.Context, chatID, id int64) error {
+	removed, err := e.store.DeleteAlert(ctx, chatID, id)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return fmt.Errorf("%w: #%d", ErrAlertNotFound, id)
+	}
+	e.mu.Lock()
+	delete(e.alerts, id)
+	e.mu.Unlock()
+	return nil
+}
+
+// PendingEvents returns fired alerts not yet acknowledged by the bot.
+func (e *Engine) PendingEvents(ctx context.Context, limit int) ([]model.AlertEvent, error) {
+	if limit <= 0 || limit > 500 {
+		limit = 100
+	}
+	return e.store.PendingAlertEvents(ctx, limit)
+}
+
+// AckEvents marks events delivered. Acknowledging twice is not an error.
+func (e *Engine) AckEvents(ctx context.Context, ids []int64) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return e.store.AckAlertEvents(ctx, ids)
+}
+
+func (e *Engine) evaluate(ctx context.Context, c candles.Candle) {
+	e.mu.Lock()
+	var matching []model.Alert
+	for _, a := range e.alerts {
+		if a.Exchange == c.Exchange && a.Pair == c.Pair && a.Interval == c.Interval {
+			matching = append(matching, a)
+		}
+	}
+	e.mu.Unlock()
+	if len(matching) == 0 {
+		return
+	}
+	var signals map[string]float64
+	for _, a := range matching {
+		if (a.Kind == model.AlertIndicator || a.Kind == model.AlertBollinger) && signals == nil {
+			var err error
+			if signals, err = e.signals.Signals(c.Pair, c.Interval); err != nil {
+				signals = map[string]float64{}
+			}
+		}
+		hit, detail, err := e.check(a, c, signals)
+		if err != nil {
+			e.logger.Debug().Err(err).Int64("alert", a.ID).Msg("alert not evaluated")
+			continue
+		}
+		switch {
+		case hit && a.Armed:
+			e.fire(ctx, a, c, detail)
+		case !hit && !a.Armed:
+			e.rearm(ctx, a)
+		}
+	}
+}
+
+// check reports whether the alert condition holds on c.
+func (e *Engine) check(a model.Alert, c candles.Candle, signals map[string]float64) (bool, string, error) {
+	switch a.Kind {
+	case model.AlertPrice:
+		hit := compare(c.Close, a.Direction, a.Threshold)
+		return hit, fmt.Sprintf("closed at %s, %s %s", formatNum(c.Close), a.Direction, formatNum(a.Threshold)), nil
+	case model.AlertIndicator:
+		value, ok := signals[a.Indicator]
+		if !ok {
+			return false, "", fmt.Errorf("%w: %s", errSignalsMissing, a.Indicator)
+		}
+		hit := compare(value, a.Direction, a.Threshold)
+		return hit, fmt.Sprintf("%s %s, %s %s", a.Indicator, formatNum(value), a.Direction, formatNum(a.Threshold)), nil
+	case model.AlertMove:
+		history := e.candles.Candles(c.Exchange, c.Pair, c.Interval)
+		idx := -1
+		for i := len(history) - 1; i >= 0; i-- {
+			if history[i].Start.Equal(c.Start) {
+				idx = i
+				break
+			}
+		}
+		if idx < a.Lookback {
+			return false, "", fmt.Errorf("need %d candles before %s", a.Lookback, c.Start)
+		}
+		ref := history[idx-a.Lookback].Close
+		if ref == 0 {
+			return false, "", errors.New("reference close is zero")
+		}
+		pct := (c.Close - ref) / ref * 100
+		var hit bool
+		switch a.Direction {
+		case model.DirectionAbove:
+			hit = pct >= a.Threshold
+		case model.DirectionBelow:
+			hit = pct <= -a.Threshold
+		default:
+			hit = math.Abs(pct) >= a.Threshold
+		}
+		return hit, fmt.Sprintf("moved %+.2f%% over %d candles (%s → %s)", pct, a.Lookback, formatNum(ref), formatNum(c.Close)), nil
+	case model.AlertBollinger:
+		upper, okU := signals["boll_upper"]
+		lower, okL := signals["boll_lower"]
+		if !okU || !okL {
+			return false, "", fmt.Errorf("%w: bollinger", errSignalsMissing)
+		}
+		above := c.Close > upper && a.Direction != model.DirectionBelow
+		below := c.Close < lower && a.Direction != model.DirectionAbove
+		switch {
+		case above:
+			return true, fmt.Sprintf("closed at %s above the upper Bollinger band %s", formatNum(c.Close), formatNum(upper)), nil
+		case below:
+			return true, fmt.Sprintf("closed at %s below the lower Bollinger band %s", formatNum(c.Close), formatNum(lower)), nil
+		}
+		return false, "", nil
+	}
+	return false, "", fmt.Errorf("unknown kind %q", a.Kind)
+}
+
+func (e *Engine) fire(ctx context.Context, a model.Alert, c candles.Candle, detail string) {
+	now := e.opts.Now().UTC()
+	a.Armed = false
+	a.Active = a.Recurring
+	a.LastFired = &now
+	ev := model.AlertEvent{
+		AlertID: a.ID,
+		ChatID:  a.ChatID,
+		Message: fmt.Sprintf("Alert #%d: %s %s %s", a.ID, a.Pair, a.Interval, detail),
+		FiredAt: now,
+	}
+	if err := e.store.RecordAlertFired(ctx, a, &ev); err != nil {
+		// State is unchanged so the next closed candle retries.
+		e.logger.Error().Err(err).Int64("alert", a.ID).Msg("failed to record fired alert")
+		return
+	}
+	telemetry.AlertsFired.WithLabelValues(string(a.Kind)).Inc()
+	e.logger.Info().Int64("alert", a.ID).Int64("chat", a.ChatID).Str("pair", a.Pair).Msg("alert fired")
+	e.notifyFire(Fired{AlertID: a.ID, Kind: a.Kind, Exchange: a.Exchange, Pair: a.Pair, Interval: a.Interval,
+		Message: ev.Message, FiredAt: ev.FiredAt})
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.alerts[a.ID]; !ok {
+		return
+	}
+	if a.Active {
+		e.alerts[a.ID] = a
+	} else {
+		delete(e.alerts, a.ID)
+	}
+}
+
+func (e *Engine) notifyFire(f Fired) {
+	e.listenersMu.RLock()
+	defer e.listenersMu.RUnlock()
+	for _, fn := range e.listeners {
+		fn(f)
+	}
+}
+
+func (e *Engine) rearm(ctx context.Context, a model.Alert) {
+	if err := e.store.SetAlertArmed(ctx, a.ID, true); err != nil {
+		e.logger.Warn().Err(err).Int64("alert", a.ID).Msg("failed to re-arm alert")
+		return
+	}
+	a.Armed = true
+	e.mu.Lock()
+	if _, ok := e.alerts[a.ID]; ok {
+		e.alerts[a.ID] = a
+	}
+	e.mu.Unlock()
+}
+
+// normalize fills defaults and validates a new alert.
+func normalize(a *model.Alert) error {
+	a.Pair = strings.ToUpper(strings.TrimSpace(a.Pair))
+	a.Exchange = strings.ToLower(strings.TrimSpace(a.Exchange))
+	a.Indicator = strings.ToLower(strings.TrimSpace(a.Indicator))
+	a.Direction = strings.ToLower(strings.TrimSpace(a.Direction))
+	if a.Exchange == "" {
+		a.Exchange = "binance"
+	}
+	if a.ChatID == 0 {
+		return fmt.Errorf("%w: chat_id required", ErrInvalidAlert)
+	}
+	if a.Pair == "" {
+		return fmt.Errorf("%w: pair required", ErrInvalidAlert)
+	}
+	if _, ok := candles.IntervalDuration(a.Interval); !ok {
+		return fmt.Errorf("%w: unknown interval %q", ErrInvalidAlert, a.Interval)
+	}
+	switch a.Kind {
+	case model.AlertPrice, model.AlertIndicator:
+		if a.Direction != model.DirectionAbove && a.Direction != model.DirectionBelow {
+			return fmt.Errorf("%w: direction must be above or below", ErrInvalidAlert)
+		}
+		if a.Kind == model.AlertPrice && a.Threshold <= 0 {
+			return fmt.Errorf("%w: price must be positive", ErrInvalidAlert)
+		}
+		if a.Kind == model.AlertIndicator && !validSignal(a.Indicator) {
+			return fmt.Errorf("%w: unknown indicator %q", ErrInvalidAlert, a.Indicator)
+		}
+	case model.AlertMove, model.AlertBollinger:
+		if a.Direction == "" {
+			a.Direction = model.DirectionAny
+		}
+		if a.Direction != model.DirectionAbove && a.Direction != model.DirectionBelow && a.Direction != model.DirectionAny {
+			return fmt.Errorf("%w: direction must be above, below or any", ErrInvalidAlert)
+		}
+		if a.Kind == model.AlertMove {
+			if a.Threshold <= 0 {
+				return fmt.Errorf("%w: move percent must be positive", ErrInvalidAlert)
+			}
+			if a.Lookback == 0 {
+				a.Lookback = 1
+			}
+			if a.Lookback < 0 || a.Lookback > maxLookback {
+				return fmt.Errorf("%w: lookback must be 1-%d candles", ErrInvalidAlert, maxLookback)
+			}
+		}
+	default:
+		return fmt.Errorf("%w: unknown kind %q", ErrInvalidAlert, a.Kind)
+	}
+	if a.Exchange != "binance" && (a.Kind == model.AlertIndicator || a.Kind == model.AlertBollinger) {
+		return fmt.Errorf("%w: indicator alerts require binance pairs", ErrInvalidAlert)
+	}
+	return nil
+}
+
+func validSignal(name string) bool {
+	for _, key := range indicators.SignalKeys() {
+		if key == name {
+			return true
+		}
+	}
+	return false
+}
+
+func compare(value float64, direction string, threshold float64) bool {
+	if direction == model.DirectionAbove {
+		return value >= threshold
+	}
+	return value <= threshold
+}
+
+func formatNum(v float64) string {
+	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.6f", v), "0"), ".")
+}
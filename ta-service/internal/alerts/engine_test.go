@@ -0,0 +1,235 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/candles"
+	"github.com/example/tg-crypto-trader/ta-service/internal/model"
+)
+
+type memStore struct {
+	mu     sync.Mutex
+	alerts map[int64]model.Alert
+	events []model.AlertEvent
+	acked  map[int64]bool
+	nextID int64
+}
+
+func newMemStore() *memStore {
+	return &memStore{alerts: make(map[int64]model.Alert), acked: make(map[int64]bool)}
+}
+
+func (m *memStore) ActiveAlerts(context.Context) ([]model.Alert, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []model.Alert
+	for _, a := range m.alerts {
+		if a.Active {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+func (m *memStore) ChatAlerts(_ context.Context, chatID int64) ([]model.Alert, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []model.Alert
+	for _, a := range m.alerts {
+		if a.ChatID == chatID {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+func (m *memStore) CountChatAlerts(ctx context.Context, chatID int64) (int, error) {
+	list, _ := m.ChatAlerts(ctx, chatID)
+	return len(list), nil
+}
+
+func (m *memStore) CreateAlert(_ context.Context, a *model.Alert) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID++
+	a.ID = m.nextID
+	a.CreatedAt = time.Now()
+	m.alerts[a.ID] = *a
+	return nil
+}
+
+func (m *memStore) DeleteAlert(_ context.Context, chatID, id int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if a, ok := m.alerts[id]; !ok || a.ChatID != chatID {
+		return false, nil
+	}
+	delete(m.alerts, id)
+	return true, nil
+}
+
+func (m *memStore) SetAlertArmed(_ context.Context, id int64, armed bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	a := m.alerts[id]
+	a.Armed = armed
+	m.alerts[id] = a
+	return nil
+}
+
+func (m *memStore) RecordAlertFired(_ context.Context, a model.Alert, ev *model.AlertEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.alerts[a.ID] = a
+	ev.ID = int64(len(m.events) + 1)
+	m.events = append(m.events, *ev)
+	return nil
+}
+
+func (m *memStore) PendingAlertEvents(_ context.Context, limit int) ([]model.AlertEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []model.AlertEvent
+	for _, ev := range m.events {
+		if !m.acked[ev.ID] && len(out) < limit {
+			out = append(out, ev)
+		}
+	}
+	return out, nil
+}
+
+func (m *memStore) AckAlertEvents(_ context.Context, ids []int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, id := range ids {
+		m.acked[id] = true
+	}
+	return nil
+}
+
+type fakeMarket struct {
+	history []candles.Candle
+	signals map[string]float64
+}
+
+func (f *fakeMarket) Candles(string, string, string) []candles.Candle { return f.history }
+
+func (f *fakeMarket) Signals(string, string) (map[string]float64, error) { return f.signals, nil }
+
+func (f *fakeMarket) close(closePrice float64, signals map[string]float64) candles.Candle {
+	c := candles.Candle{Exchange: "binance", Pair: "ETHUSDT", Interval: "1h", Close: closePrice, Closed: true,
+		Start: time.Unix(int64(len(f.history))*3600, 0)}
+	f.history = append(f.history, c)
+	f.signals = signals
+	return c
+}
+
+func TestEngineFiresAndRearms(t *testing.T) {
+	store := newMemStore()
+	market := &fakeMarket{}
+	engine := NewEngine(store, market, market, Options{MaxPerChat: 4}, zerolog.Nop())
+	var pushed []Fired
+	engine.OnFire(func(f Fired) { pushed = append(pushed, f) })
+	ctx := context.Background()
+
+	create := func(a model.Alert) model.Alert {
+		t.Helper()
+		a.ChatID, a.Pair, a.Interval = 7, "ethusdt", "1h"
+		created, err := engine.Create(ctx, a)
+		if err != nil {
+			t.Fatalf("create %+v: %v", a, err)
+		}
+		return created
+	}
+	price := create(model.Alert{Kind: model.AlertPrice, Direction: "above", Threshold: 4000})
+	rsi := create(model.Alert{Kind: model.AlertIndicator, Indicator: "RSI", Direction: "below", Threshold: 25, Recurring: true})
+	move := create(model.Alert{Kind: model.AlertMove, Threshold: 5, Lookback: 2})
+	boll := create(model.Alert{Kind: model.AlertBollinger, Direction: "below"})
+
+	if _, err := engine.Create(ctx, model.Alert{ChatID: 7, Kind: model.AlertIndicator, Pair: "ETHUSDT", Interval: "1h", Indicator: "nope", Direction: "above"}); !errors.Is(err, ErrInvalidAlert) {
+		t.Fatalf("expected unknown indicator to be rejected, got %v", err)
+	}
+	if _, err := engine.Create(ctx, model.Alert{ChatID: 7, Kind: model.AlertPrice, Pair: "ETHUSDT", Interval: "1h", Direction: "above", Threshold: 1}); !errors.Is(err, ErrTooManyAlerts) {
+		t.Fatalf("expected per-chat limit, got %v", err)
+	}
+
+	fired := func() map[int64]int {
+		counts := make(map[int64]int)
+		for _, ev := range store.events {
+			counts[ev.AlertID]++
+		}
+		return counts
+	}
+	bands := func(rsi float64) map[string]float64 {
+		return map[string]float64{"rsi": rsi, "boll_upper": 4100, "boll_lower": 3700}
+	}
+
+	engine.evaluate(ctx, market.close(3800, bands(40)))
+	engine.evaluate(ctx, market.close(3900, bands(24))) // rsi fires
+	engine.evaluate(ctx, market.close(4010, bands(20))) // price and move (+5.5% over 2) fire; rsi still low
+	engine.evaluate(ctx, market.close(4020, bands(30))) // rsi re-arms; one-shot price stays quiet
+	engine.evaluate(ctx, market.close(3650, bands(22))) // rsi fires again; bollinger lower breakout
+
+	got := fired()
+	want := map[int64]int{price.ID: 1, rsi.ID: 2, move.ID: 1, boll.ID: 1}
+	for id, n := range want {
+		if got[id] != n {
+			t.Fatalf("alert %d fired %d times, want %d (events %+v)", id, got[id], n, store.events)
+		}
+	}
+	if a := store.alerts[price.ID]; a.Active || a.LastFired == nil {
+		t.Fatalf("one-shot alert should be inactive after firing: %+v", a)
+	}
+	if a := store.alerts[rsi.ID]; !a.Active {
+		t.Fatalf("recurring alert should stay active: %+v", a)
+	}
+
+	if len(pushed) != 5 || pushed[0].AlertID != rsi.ID || pushed[0].Exchange != "binance" || pushed[0].Pair != "ETHUSDT" ||
+		pushed[0].Kind != model.AlertIndicator || pushed[0].Message != "Alert #2: ETHUSDT 1h rsi 24, below 25" {
+		t.Fatalf("unexpected fire notifications %+v", pushed)
+	}
+
+	pending, _ := engine.PendingEvents(ctx, 0)
+	if len(pending) != 5 || pending[0].ChatID != 7 || pending[0].Message != "Alert #2: ETHUSDT 1h rsi 24, below 25" {
+		t.Fatalf("unexpected pending events %+v", pending)
+	}
+	if err := engine.AckEvents(ctx, []int64{pending[0].ID}); err != nil {
+		t.Fatalf("ack: %v", err)
+	}
+	if pending, _ = engine.PendingEvents(ctx, 0); len(pending) != 4 {
+		t.Fatalf("expected ack to remove the event, got %d pending", len(pending))
+	}
+	if err := engine.Delete(ctx, 8, rsi.ID); !errors.Is(err, ErrAlertNotFound) {
+		t.Fatalf("expected other chats not to delete the alert, got %v", err)
+	}
+}
+
+func TestEngineThresholdAlertsWaitForACrossing(t *testing.T) {
+	store := newMemStore()
+	market := &fakeMarket{}
+	engine := NewEngine(store, market, market, Options{}, zerolog.Nop())
+	ctx := context.Background()
+	rsi, err := engine.Create(ctx, model.Alert{ChatID: 7, Kind: model.AlertIndicator, Pair: "ETHUSDT", Interval: "1h",
+		Indicator: "rsi", Direction: "below", Threshold: 25})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	// RSI was already below 25 when the alert was created.
+	engine.evaluate(ctx, market.close(3800, map[string]float64{"rsi": 20}))
+	engine.evaluate(ctx, market.close(3790, map[string]float64{"rsi": 18}))
+	if len(store.events) != 0 {
+		t.Fatalf("fired without a crossing: %+v", store.events)
+	}
+	engine.evaluate(ctx, market.close(3850, map[string]float64{"rsi": 30}))
+	engine.evaluate(ctx, market.close(3700, map[string]float64{"rsi": 22}))
+	if len(store.events) != 1 || store.events[0].AlertID != rsi.ID {
+		t.Fatalf("expected one fire on the crossing, got %+v", store.events)
+	}
+}
@@ -0,0 +1,747 @@
+package backtest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/candles"
+	"github.com/example/tg-crypto-trader/ta-service/internal/model"
+)
+
+// wave returns hourly candles following a sine wave around 100.
+func wave(n int, amplitude float64) []candles.Candle {
+	out := make([]candles.Candle, n)
+	for i := range out {
+		price := 100 + amplitude*math.Sin(float64(i)/6)
+		out[i] = candles.Candle{
+			Exchange: "backtest", Pair: "BACKTEST", Interval: "1h",
+			Open: price, High: price + 0.5, Low: price - 0.5, Close: price, Volume: 10, Closed: true,
+			Start: time.Unix(int64(i)*3600, 0),
+		}
+	}
+	return out
+}
+
+func TestRegistry(t *testing.T) {
+	var names []string
+	for _, f := range Factories() {
+		names = append(names, f.Name)
+	}
+	if got := strings.Join(names, ","); got != "bollinger,ema,macd,rsi" {
+		t.Fatalf("registered strategies = %s", got)
+	}
+	if _, err := New("RSI", Params{"oversold": 25}); err != nil {
+		t.Fatalf("new rsi: %v", err)
+	}
+	for name, params := range map[string]Params{
+		"rsi":       {"oversold": 80},
+		"macd":      {"fast": 30},
+		"ema":       {"fast": 2.5},
+		"bollinger": {"stop": 1},
+	} {
+		if _, err := New(name, params); err == nil {
+			t.Errorf("New(%s, %v) accepted invalid parameters", name, params)
+		}
+	}
+	if _, err := New("rsi", Params{"lenght": 10}); err == nil || !strings.Contains(err.Error(), "no parameter \"lenght\"") {
+		t.Fatalf("expected unknown parameter error, got %v", err)
+	}
+	if _, err := New("nope", nil); err == nil {
+		t.Fatal("expected unknown strategy error")
+	}
+}
+
+func TestRunStrategies(t *testing.T) {
+	data := wave(400, 10)
+	for _, f := range Factories() {
+		st, err := New(f.Name, nil)
+		if err != nil {
+			t.Fatalf("new %s: %v", f.Name, err)
+		}
+		res := Run(st, data, DefaultConfig())
+		if len(res.Fills) < 4 || len(res.Fills)%2 != 0 {
+			t.Fatalf("%s: expected round trips, got %d fills", f.Name, len(res.Fills))
+		}
+		for i, fill := range res.Fills {
+			want := Buy
+			if i%2 == 1 {
+				want = Sell
+			}
+			if fill.Side != want {
+				t.Fatalf("%s: fill %d is a %s, want %s", f.Name, i, fill.Side, want)
+			}
+		}
+		if res.FinalCash <= 0 {
+			t.Fatalf("%s: final cash %g", f.Name, res.FinalCash)
+		}
+	}
+}
+
+// scripted replays a fixed order per bar.
+type scripted map[int][]Order
+
+func (s scripted) Name() string { return "scripted" }
+
+func (s scripted) OnCandle(c candles.Candle, _ Position) []Order {
+	return s[int(c.Start.Unix()/3600)]
+}
+
+func TestRunAccounting(t *testing.T) {
+	data := wave(30, 10)
+	st := scripted{
+		2:  {{Side: Buy, Qty: 10}},
+		5:  {{Side: Sell, Qty: 4}},
+		8:  {{Side: Sell}, {Side: Sell}}, // second sell has nothing left to close
+		10: {{Side: Buy, Qty: 1e9}},      // capped at what the cash affords
+	}
+	res := Run(st, data, Config{Cash: 10000, QtyStep: 0.01})
+	if len(res.Fills) != 5 {
+		t.Fatalf("expected 5 fills, got %+v", res.Fills)
+	}
+	if res.Fills[2].Qty != 6 || res.Fills[4].Reason != "end of data" {
+		t.Fatalf("unexpected fills %+v", res.Fills)
+	}
+	cash := 10000.0
+	for _, f := range res.Fills {
+		if f.Side == Buy {
+			cash -= f.Price * f.Qty
+		} else {
+			cash += f.Price * f.Qty
+		}
+	}
+	if math.Abs(cash-res.FinalCash) > 1e-6 {
+		t.Fatalf("final cash %g, fills imply %g", res.FinalCash, cash)
+	}
+}
+
+// bar builds hourly candle i.
+func bar(i int, open, high, low, close, volume float64) candles.Candle {
+	return candles.Candle{Open: open, High: high, Low: low, Close: close, Volume: volume, Closed: true, Start: time.Unix(int64(i)*3600, 0)}
+}
+
+func TestFillModel(t *testing.T) {
+	data := []candles.Candle{
+		bar(0, 100, 101, 99, 100, 1000),
+		bar(1, 102, 103, 101, 102, 1000),
+		bar(2, 104, 105, 93, 96, 1000), // trades through the 94 stop
+		bar(3, 90, 92, 88, 91, 1000),   // gaps below it
+		bar(4, 91, 93, 85, 92, 1000),
+		bar(5, 92, 93, 91, 92, 1000),
+	}
+	buyThenStop := scripted{
+		0: {{Side: Buy, Qty: 10}},
+		1: {{Side: Sell, Type: Stop, Price: 94, Reason: "stop"}, {Side: Buy, Type: Limit, Qty: 5, Price: 86}},
+	}
+	cases := []struct {
+		name  string
+		model FillModel
+		want  []Fill
+	}{
+		{"close", FillModel{}, []Fill{
+			{Side: Buy, Type: Market, Price: 100, Qty: 10},
+			{Side: Sell, Type: Stop, Price: 94, Qty: 10, Reason: "stop"},
+			{Side: Buy, Type: Limit, Price: 86, Qty: 5},
+			{Side: Sell, Type: Market, Price: 92, Qty: 5, Reason: "end of data"},
+		}},
+		{"next open, fees", FillModel{NextBarOpen: true, Fees: Fees{Maker: 0.001, Taker: 0.002}}, []Fill{
+			{Side: Buy, Type: Market, Price: 102, Qty: 10, Fee: 2.04},
+			{Side: Sell, Type: Stop, Price: 94, Qty: 10, Fee: 1.88, Reason: "stop"},
+			{Side: Buy, Type: Limit, Price: 86, Qty: 5, Fee: 0.43},
+			{Side: Sell, Type: Market, Price: 92, Qty: 5, Fee: 0.92, Reason: "end of data"},
+		}},
+		{"latency, slippage", FillModel{LatencyBars: 1, Slippage: FixedSlippage{Amount: 0.5}}, []Fill{
+			{Side: Buy, Type: Market, Price: 102.5, Qty: 10},
+			{Side: Sell, Type: Stop, Price: 89.5, Qty: 10, Reason: "stop"}, // gap fills at the open
+			{Side: Buy, Type: Limit, Price: 86, Qty: 5},
+			{Side: Sell, Type: Market, Price: 91.5, Qty: 5, Reason: "end of data"},
+		}},
+	}
+	for _, tc := range cases {
+		res := Run(buyThenStop, data, Config{Cash: 10000, QtyStep: 0.001, Fills: tc.model})
+		if len(res.Fills) != len(tc.want) {
+			t.Fatalf("%s: fills %+v", tc.name, res.Fills)
+		}
+		for i, want := range tc.want {
+			got := res.Fills[i]
+			want.Time = got.Time
+			if got.Side != want.Side || got.Type != want.Type || got.Qty != want.Qty || got.Reason != want.Reason ||
+				math.Abs(got.Price-want.Price) > 1e-9 || math.Abs(got.Fee-want.Fee) > 1e-9 {
+				t.Errorf("%s: fill %d = %+v, want %+v", tc.name, i, got, want)
+			}
+		}
+	}
+
+	// A cancel withdraws resting orders before they trigger.
+	cancelled := scripted{
+		0: {{Side: Buy, Qty: 1}},
+		1: {{Side: Sell, Type: Stop, Price: 97}, {Type: Cancel}},
+	}
+	if res := Run(cancelled, data, DefaultConfig()); len(res.Fills) != 2 || res.Fills[1].Reason != "end of data" || res.Unfilled != 0 {
+		t.Fatalf("cancelled stop filled: %+v", res)
+	}
+}
+
+func TestParseFillSpecs(t *testing.T) {
+	if f, err := ParseFees("0.02%/0.04"); err != nil || f.Maker != 0.0002 || f.Taker != 0.0004 {
+		t.Fatalf("ParseFees = %+v, %v", f, err)
+	}
+	if f, err := ParseFees("Binance"); err != nil || f.Taker != 0.001 {
+		t.Fatalf("ParseFees preset = %+v, %v", f, err)
+	}
+	if _, err := ParseFees("cheap"); err == nil {
+		t.Fatal("expected unknown fee schedule to be rejected")
+	}
+	c := bar(0, 100, 100, 100, 100, 400)
+	for spec, want := range map[string]float64{
+		"none":          100,
+		"fixed:0.25":    100.25,
+		"pct:0.5%":      100.5,
+		"impact:0.1":    101,   // 0.1 * sqrt(4/400) = 1%
+		"impact:1:0.5%": 100.5, // capped
+	} {
+		s, err := ParseSlippage(spec)
+		if err != nil {
+			t.Fatalf("ParseSlippage(%q): %v", spec, err)
+		}
+		if got := s.Price(Buy, 100, 4, c); math.Abs(got-want) > 1e-9 {
+			t.Errorf("%s: buy price %g, want %g", spec, got, want)
+		}
+		if got := s.Price(Sell, 100, 4, c); math.Abs(got-(200-want)) > 1e-9 {
+			t.Errorf("%s: sell price %g, want %g", spec, got, 200-want)
+		}
+	}
+	if _, err := ParseSlippage("magic:1"); err == nil {
+		t.Fatal("expected unknown slippage model to be rejected")
+	}
+}
+
+func TestRunPortfolio(t *testing.T) {
+	a, b := wave(100, 10), wave(100, 5)
+	for i := range b {
+		b[i].Pair = "ETHUSDT"
+	}
+	// b starts later; the portfolio waits for it.
+	b = b[20:]
+	series := []Series{{Pair: "ETHUSDT", Candles: b}, {Pair: "BTCUSDT", Candles: a}}
+	every := func() (Strategy, error) {
+		return scripted{30: {{Side: Buy}}, 60: {{Side: Sell, Reason: "exit"}}}, nil
+	}
+	cfg := DefaultConfig()
+	cfg.TradeFrom = a[10].Start
+	res, err := RunPortfolio(series, every, cfg)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if strings.Join(res.Pairs, ",") != "BTCUSDT,ETHUSDT" || len(res.Fills) != 4 {
+		t.Fatalf("pairs %v, fills %+v", res.Pairs, res.Fills)
+	}
+	// Each symbol spends half the equity, not all of the cash.
+	if buy := res.Fills[0]; buy.Pair != "BTCUSDT" || math.Abs(buy.Qty*buy.Price-5000) > buy.Price*cfg.QtyStep {
+		t.Fatalf("first buy %+v", buy)
+	}
+	if res.Fills[1].Pair != "ETHUSDT" || res.Fills[1].Qty*res.Fills[1].Price < 4900 {
+		t.Fatalf("second buy %+v", res.Fills[1])
+	}
+	if len(res.Equity) != 90 || !res.Equity[0].Time.Equal(a[10].Start) || res.Equity[40].Invested <= 0 {
+		t.Fatalf("equity curve starts %v with %d points", res.Equity[0].Time, len(res.Equity))
+	}
+	trades := NewReport(res, nil).Trades
+	if len(trades) != 2 || trades[0].Pair != "BTCUSDT" || trades[1].Pair != "ETHUSDT" || trades[1].Reason != "exit" {
+		t.Fatalf("trades %+v", trades)
+	}
+
+	stopped, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := RunPortfolioContext(stopped, series, every, cfg); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a cancelled run to stop, got %v", err)
+	}
+
+	cut := Slice(series, a[50].Start, a[60].Start)
+	if len(cut[0].Candles) != 10 || len(Timeline(series)) != 100 {
+		t.Fatalf("slice %d candles, timeline %d", len(cut[0].Candles), len(Timeline(series)))
+	}
+}
+
+// capGate rejects orders above a notional cap or within an hour of the
+// pair's last approval, and tracks the exposure it has booked.
+type capGate struct {
+	max      float64
+	last     map[string]time.Time
+	exposure float64
+}
+
+func (g *capGate) Check(at time.Time, pair string, side Side, qty, price float64) error {
+	if qty*price > g.max {
+		return fmt.Errorf("notional %.0f above %.0f", qty*price, g.max)
+	}
+	if last, ok := g.last[pair]; ok && at.Sub(last) < 90*time.Minute {
+		return errors.New("cooldown")
+	}
+	g.last[pair] = at
+	g.exposure += qty * price
+	return nil
+}
+
+func (g *capGate) Release(pair string, side Side, qty, price float64) {
+	g.exposure -= qty * price
+}
+
+func TestRunGate(t *testing.T) {
+	data := wave(20, 10)
+	for i := range data {
+		data[i].Interval = "1h"
+	}
+	st := scripted{
+		1:  {{Side: Buy, Qty: 200}}, // too big
+		2:  {{Side: Buy, Qty: 10}},
+		3:  {{Side: Sell}}, // cooling down
+		5:  {{Side: Sell}}, // sells the whole position
+		8:  {{Side: Buy, Qty: 5}},
+		10: {{Side: Sell, Type: Stop, Price: 1}},
+		12: {{Type: Cancel}},
+	}
+	gate := &capGate{max: 5000, last: make(map[string]time.Time)}
+	cfg := DefaultConfig()
+	cfg.NewGate = func() Gate { return gate }
+	res := Run(st, data, cfg)
+	if len(res.Rejected) != 2 || !strings.HasSuffix(res.Rejected[0].Reason, "above 5000") || res.Rejected[1].Reason != "cooldown" {
+		t.Fatalf("rejections %+v", res.Rejected)
+	}
+	if !res.Rejected[1].Time.Equal(data[3].Start) || res.Rejected[1].Qty != 10 {
+		t.Fatalf("cooldown rejection %+v", res.Rejected[1])
+	}
+	if len(res.Fills) != 4 || res.Fills[1].Time != data[5].Start {
+		t.Fatalf("fills %+v", res.Fills)
+	}
+	// Sells, the cancelled stop and the end-of-data close all settle.
+	if math.Abs(gate.exposure) > 1e-6 {
+		t.Fatalf("gate exposure %g left booked", gate.exposure)
+	}
+	if m := NewReport(res, nil).Metrics; m.Rejected != 2 {
+		t.Fatalf("report rejected %d", m.Rejected)
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	in := "Volume,Close,Pair,open_time,High,Low,Open,ignored\n" +
+		"5,101,ethusdt,1700000000000,102,99,100,x\n" +
+		"7,11,BTCUSDT,1700000000000,12,9,10,x\n" +
+		"6,102,ETHUSDT,1700000060000,103,100,101,x\n"
+	series, err := ReadCSV(strings.NewReader(in), "", "1m")
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if len(series) != 2 || series[0].Pair != "BTCUSDT" || len(series[1].Candles) != 2 {
+		t.Fatalf("series %+v", series)
+	}
+	c := series[1].Candles[1]
+	if !c.Start.Equal(time.UnixMilli(1700000060000)) || c.Open != 101 || c.Close != 102 || c.Volume != 6 || !c.Closed {
+		t.Fatalf("candle %+v", c)
+	}
+	if s, err := ReadCSV(strings.NewReader("time,open,high,low,close,volume\n1700000000,1,1,1,1,0\n"), "X", "1h"); err != nil || s[0].Candles[0].Start.Unix() != 1700000000 || s[0].Pair != "X" {
+		t.Fatalf("seconds timestamps: %+v, %v", s, err)
+	}
+
+	for in, want := range map[string]string{
+		"open,high,low,close,volume\n":                                "no time column",
+		"time,open,high,low,close,volume\n1,1,1,1,abc,1\n":            "line 2, column close",
+		"time,open,high,low,close,volume\n1,1,1,1,1\n":                "wrong number of fields",
+		"time,open,high,low,close,volume\n1,1,1,1,1,1\n1,1,1,1,1,1\n": "line 3: X 1970-01-01T00:00:01Z is not after",
+		"time,open,high,low,close,volume\n1,5,4,3,3.5,1\n":            "line 2: high 4 and low 3 do not contain open 5",
+		"time,open,high,low,close,volume\nyesterday,1,1,1,1,1\n":      "invalid timestamp",
+		"time,time,open,high,low,close,volume\n":                      "duplicate time column",
+	} {
+		if _, err := ReadCSV(strings.NewReader(in), "X", "1m"); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("ReadCSV(%q) = %v, want %q", in, err, want)
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	day := func(d int) time.Time { return time.Unix(int64(d)*86400, 0) }
+	res := Result{
+		Strategy: "scripted", StartCash: 1000, FinalCash: 1100, Fees: 3,
+		Fills: []Fill{
+			{Time: day(0), Side: Buy, Price: 10, Qty: 50, Fee: 1},
+			{Time: day(1), Side: Sell, Price: 9, Qty: 50, Fee: 1, Reason: "stop"},
+			{Time: day(2), Side: Buy, Price: 9, Qty: 50},
+			{Time: day(2), Side: Buy, Price: 11, Qty: 50, Fee: 1},
+			{Time: day(3), Side: Sell, Price: 11.52, Qty: 100},
+		},
+		Equity: []EquityPoint{
+			{Time: day(0), Equity: 1000, Invested: 500},
+			{Time: day(1), Equity: 948},
+			{Time: day(2), Equity: 900, Invested: 900},
+			{Time: day(3), Equity: 1100},
+		},
+	}
+	r := NewReport(res, Params{"x": 1})
+	m := r.Metrics
+	if len(r.Trades) != 2 || m.Trades != 2 {
+		t.Fatalf("trades %+v", r.Trades)
+	}
+	second := r.Trades[1]
+	if second.EntryPrice != 10 || second.Qty != 100 || math.Abs(second.PnL-151) > 1e-9 || second.Fees != 1 {
+		t.Fatalf("second trade %+v", second)
+	}
+	if r.Trades[0].PnL != -52 || r.Trades[0].Reason != "stop" {
+		t.Fatalf("first trade %+v", r.Trades[0])
+	}
+	if math.Abs(m.TotalReturn-0.1) > 1e-12 || math.Abs(m.MaxDrawdown-0.1) > 1e-12 || m.MaxDrawdownDuration != 24*time.Hour {
+		t.Fatalf("returns/drawdown %+v", m)
+	}
+	if m.WinRate != 0.5 || m.ProfitFactor == nil || math.Abs(*m.ProfitFactor-151.0/52) > 1e-12 || m.Exposure != 0.5 {
+		t.Fatalf("trade stats %+v", m)
+	}
+	if wantCAGR := math.Pow(1.1, 365.0/4) - 1; math.Abs(m.CAGR-wantCAGR) > 1e-6 {
+		t.Fatalf("cagr %g, want %g", m.CAGR, wantCAGR)
+	}
+	if m.Sharpe <= 0 || m.Sortino <= m.Sharpe {
+		t.Fatalf("sharpe %g sortino %g", m.Sharpe, m.Sortino)
+	}
+
+	var html, trades strings.Builder
+	if err := r.WriteHTML(&html); err != nil {
+		t.Fatalf("html: %v", err)
+	}
+	if !strings.Contains(html.String(), "<svg") || !strings.Contains(html.String(), "<td>10.00%</td>") {
+		t.Fatalf("html report missing chart or total return")
+	}
+	if err := r.WriteTradesCSV(&trades); err != nil {
+		t.Fatalf("csv: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(trades.String()), "\n"); len(lines) != 3 || !strings.HasSuffix(lines[1], ",stop") {
+		t.Fatalf("trades csv %q", trades.String())
+	}
+}
+
+func TestMonteCarlo(t *testing.T) {
+	start := time.Unix(0, 0)
+	r := Report{Metrics: Metrics{StartEquity: 1000, FinalEquity: 1000 * 1.1 * 0.8 * 1.1 * 1.1, MaxDrawdown: 0.2}}
+	equity := 1000.0
+	for i, ret := range []float64{0.1, -0.2, 0.1, 0.1} {
+		r.Trades = append(r.Trades, Trade{PnL: equity * ret})
+		equity *= 1 + ret
+		r.Equity = append(r.Equity, DrawdownPoint{EquityPoint: EquityPoint{Time: start.Add(time.Duration(i) * time.Hour), Equity: equity}})
+	}
+
+	opts := DefaultMonteCarlo()
+	opts.Runs = 500
+	shuffled, err := MonteCarlo(r, opts)
+	if err != nil {
+		t.Fatalf("shuffle: %v", err)
+	}
+	// Compounding commutes, so every ordering ends at the same equity.
+	if d := shuffled.FinalEquity; math.Abs(d.Min-r.Metrics.FinalEquity) > 1e-6 || math.Abs(d.Max-r.Metrics.FinalEquity) > 1e-6 {
+		t.Fatalf("shuffled final equity %+v, want %g", d, r.Metrics.FinalEquity)
+	}
+	if d := shuffled.MaxDrawdown; math.Abs(d.Max-0.2) > 1e-9 || math.Abs(d.Min-0.2) > 1e-9 || shuffled.RiskOfRuin != 0 {
+		t.Fatalf("shuffled drawdowns %+v ruin %g", d, shuffled.RiskOfRuin)
+	}
+
+	opts.Method, opts.Ruin = MonteCarloResample, 0.3
+	resampled, err := MonteCarlo(r, opts)
+	if err != nil {
+		t.Fatalf("resample: %v", err)
+	}
+	d := resampled.FinalEquity
+	if d.Min >= d.Max || d.Lower > d.Median || d.Median > d.Upper || math.Abs(d.Max-1000*math.Pow(1.1, 4)) > 1e-6 {
+		t.Fatalf("resampled final equity %+v", d)
+	}
+	// Three losing draws (0.8^3 = 0.512) are ruin at a 30% loss.
+	if resampled.RiskOfRuin <= 0 || resampled.RiskOfRuinLower > resampled.RiskOfRuin || resampled.RiskOfRuinUpper < resampled.RiskOfRuin {
+		t.Fatalf("risk of ruin %g [%g, %g]", resampled.RiskOfRuin, resampled.RiskOfRuinLower, resampled.RiskOfRuinUpper)
+	}
+	again, _ := MonteCarlo(r, opts)
+	if again.FinalEquity != resampled.FinalEquity {
+		t.Fatal("monte carlo is not reproducible with a fixed seed")
+	}
+
+	opts.Method, opts.Block = MonteCarloBootstrap, 2
+	if _, err := MonteCarlo(r, opts); err != nil {
+		t.Fatalf("bootstrap: %v", err)
+	}
+	var text strings.Builder
+	if err := resampled.WriteText(&text); err != nil || !strings.Contains(text.String(), "risk of ruin (losing 30%)") {
+		t.Fatalf("summary %q, %v", text.String(), err)
+	}
+	opts.Method = "dice"
+	if _, err := MonteCarlo(r, opts); err == nil {
+		t.Fatal("expected unknown method to be rejected")
+	}
+	if lo, hi := wilson(0, 100, 0.95); lo != 0 || math.Abs(hi-0.037) > 0.001 {
+		t.Fatalf("wilson(0/100) = [%g, %g]", lo, hi)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	name, values, err := ParseGrid("fast=3:9:2")
+	if err != nil || name != "fast" || len(values) != 4 || values[3] != 9 {
+		t.Fatalf("ParseGrid = %s %v %v", name, values, err)
+	}
+	ema, _ := Lookup("ema")
+	space := ParamSpace{Grid: map[string][]float64{"fast": values, "slow": {5, 20, 30}}}
+	candidates, err := space.Candidates(ema)
+	if err != nil || len(candidates) != 12 {
+		t.Fatalf("grid candidates = %d, %v", len(candidates), err)
+	}
+
+	data := wave(400, 10)
+	series := []Series{{Pair: "BACKTEST", Candles: data}}
+	obj, _ := ParseObjective("return")
+	trials := Sweep(ema, candidates, series, DefaultConfig(), SweepOptions{Objective: obj, Workers: 3})
+	if len(trials) != 12 {
+		t.Fatalf("expected 12 trials, got %d", len(trials))
+	}
+	// fast >= slow is rejected by the strategy and ranks last.
+	if last := trials[11]; last.Err == "" || trials[8].Err != "" {
+		t.Fatalf("invalid combinations not ranked last: %+v", trials[8:])
+	}
+	for i := 1; i < 9; i++ {
+		if trials[i].Score > trials[i-1].Score {
+			t.Fatalf("trials not ranked by score: %v then %v", trials[i-1].Score, trials[i].Score)
+		}
+	}
+	st, _ := New("ema", trials[0].Params)
+	if want := NewReport(Run(st, data, DefaultConfig()), nil).Metrics.TotalReturn; want != trials[0].Score {
+		t.Fatalf("best score %g does not match a direct run (%g)", trials[0].Score, want)
+	}
+
+	random := ParamSpace{Ranges: map[string]Range{"oversold": {Min: 20, Max: 35}, "stop": {Min: 0, Max: 0.1}}, Samples: 8, Seed: 7}
+	rsi, _ := Lookup("rsi")
+	first, err := random.Candidates(rsi)
+	if err != nil || len(first) != 8 {
+		t.Fatalf("random candidates = %d, %v", len(first), err)
+	}
+	again, _ := random.Candidates(rsi)
+	for i := range first {
+		if first[i].String() != again[i].String() {
+			t.Fatal("random search is not reproducible with a fixed seed")
+		}
+		if v := first[i]["oversold"]; v != math.Trunc(v) || v < 20 || v > 35 {
+			t.Fatalf("oversold should be a whole number in range, got %g", v)
+		}
+	}
+	if _, err := (ParamSpace{Ranges: random.Ranges}).Candidates(rsi); err == nil {
+		t.Fatal("expected ranges without samples to be rejected")
+	}
+
+	wf, err := WalkForward(ema, candidates, series, DefaultConfig(), SweepOptions{Objective: obj}, WalkForwardOptions{InSample: 200, OutOfSample: 50})
+	if err != nil {
+		t.Fatalf("walk-forward: %v", err)
+	}
+	if len(wf.Windows) != 4 || !wf.Windows[1].Split.Equal(data[250].Start) {
+		t.Fatalf("unexpected windows %+v", wf.Windows)
+	}
+	if wf.Windows[0].Metrics.Start != data[200].Start {
+		t.Fatalf("out-of-sample metrics should start after the warmup, got %v", wf.Windows[0].Metrics.Start)
+	}
+}
+
+// memJobs is an in-memory JobStore over one pair's candles.
+type memJobs struct {
+	mu     sync.Mutex
+	data   []candles.Candle
+	jobs   []model.BacktestJob
+	queued int
+	// sweeps counts FailStaleBacktests calls.
+	sweeps int
+}
+
+func (m *memJobs) LoadCandleRange(_ context.Context, _, pair, _ string, from, to time.Time) ([]model.Candle, error) {
+	var out []model.Candle
+	for _, c := range m.data {
+		if !c.Start.Before(from) && (to.IsZero() || c.Start.Before(to)) {
+			c.Pair = pair
+			out = append(out, c)
+		}
+	}
+	return out, nil
+}
+
+func (m *memJobs) CreateBacktest(_ context.Context, job *model.BacktestJob) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job.ID, job.Status = int64(len(m.jobs)+1), model.BacktestQueued
+	m.jobs = append(m.jobs, *job)
+	return nil
+}
+
+func (m *memJobs) Backtest(_ context.Context, id int64) (model.BacktestJob, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id < 1 || int(id) > len(m.jobs) {
+		return model.BacktestJob{}, false, nil
+	}
+	return m.jobs[id-1], true, nil
+}
+
+func (m *memJobs) ClaimBacktest(context.Context) (model.BacktestJob, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, job := range m.jobs {
+		if job.Status == model.BacktestQueued {
+			m.jobs[i].Status = model.BacktestRunning
+			return m.jobs[i], true, nil
+		}
+	}
+	return model.BacktestJob{}, false, nil
+}
+
+func (m *memJobs) FinishBacktest(_ context.Context, job model.BacktestJob) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.jobs[job.ID-1].Status == model.BacktestRunning {
+		m.jobs[job.ID-1] = job
+	}
+	return nil
+}
+
+func (m *memJobs) QueuedBacktests(context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := 0
+	for _, job := range m.jobs {
+		if job.Status == model.BacktestQueued {
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (m *memJobs) FailStaleBacktests(context.Context, time.Time, string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sweeps++
+	return 0, nil
+}
+
+func TestJobs(t *testing.T) {
+	store := &memJobs{data: wave(400, 10)}
+	jobs := NewJobs(store, JobOptions{Workers: 2, MaxQueued: 2, MaxBars: 500, Poll: time.Millisecond}, zerolog.Nop())
+	jobs.now = func() time.Time { return time.Unix(400*3600+1800, 0) }
+	ctx := context.Background()
+
+	for _, req := range []JobRequest{
+		{Strategy: "rsi", Pair: "ETHUSDT", Interval: "1h", Range: "10d"},
+		{ChatID: 7, Strategy: "nope", Pair: "ETHUSDT", Interval: "1h", Range: "10d"},
+		{ChatID: 7, Strategy: "rsi", Params: Params{"oversold": 80}, Pair: "ETHUSDT", Interval: "1h", Range: "10d"},
+		{ChatID: 7, Strategy: "rsi", Pair: "ETHUSDT", Interval: "7m", Range: "10d"},
+		{ChatID: 7, Strategy: "rsi", Pair: "ETHUSDT", Interval: "1h"},
+		{ChatID: 7, Strategy: "rsi", Pair: "ETHUSDT", Interval: "1h", Range: "90d"},
+		{ChatID: 7, Strategy: "rsi", Pair: "ETHUSDT", Interval: "1h", Range: "ten days"},
+	} {
+		if _, err := jobs.Submit(ctx, req); !errors.Is(err, ErrInvalidJob) {
+			t.Errorf("Submit(%+v) = %v, want ErrInvalidJob", req, err)
+		}
+	}
+
+	job, err := jobs.Submit(ctx, JobRequest{ChatID: 7, Strategy: "RSI", Pair: "ethusdt", Interval: "1h", Range: "12d"})
+	if err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+	if job.Pair != "ETHUSDT" || job.Strategy != "rsi" || !job.To.Equal(time.Unix(400*3600, 0)) || job.To.Sub(job.From) != 12*24*time.Hour {
+		t.Fatalf("unexpected job %+v", job)
+	}
+	if job.Params["oversold"] == 0 || job.Cash != DefaultConfig().Cash {
+		t.Fatalf("job should carry resolved defaults: %+v", job)
+	}
+	if _, err := jobs.Submit(ctx, JobRequest{ChatID: 7, Strategy: "ema", Pair: "ETHUSDT", Interval: "1h", From: time.Unix(0, 0), To: time.Unix(400*3600, 0)}); err != nil {
+		t.Fatalf("submit second: %v", err)
+	}
+	if _, err := jobs.Submit(ctx, JobRequest{ChatID: 7, Strategy: "rsi", Pair: "ETHUSDT", Interval: "1h", Range: "1d"}); !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("expected full queue, got %v", err)
+	}
+	if _, err := jobs.Get(ctx, 0, 99); !errors.Is(err, ErrJobNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+	if _, err := jobs.Get(ctx, 8, job.ID); !errors.Is(err, ErrJobNotFound) {
+		t.Fatalf("expected other chats not to see the job, got %v", err)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		jobs.Run(runCtx)
+		close(done)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if n, _ := store.QueuedBacktests(ctx); n == 0 {
+			a, _ := jobs.Get(ctx, 7, 1)
+			b, _ := jobs.Get(ctx, 7, 2)
+			if a.Status != model.BacktestRunning && b.Status != model.BacktestRunning {
+				break
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("jobs did not finish")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+
+	for id, chatID := range map[int64]int64{1: 7, 2: 7} {
+		got, err := jobs.Get(ctx, chatID, id)
+		if err != nil {
+			t.Fatalf("get %d: %v", id, err)
+		}
+		if got.Status != model.BacktestDone {
+			t.Fatalf("job %d: status %s, error %q", id, got.Status, got.Error)
+		}
+		var res JobResult
+		if err := json.Unmarshal(got.Result, &res); err != nil {
+			t.Fatalf("job %d result: %v", id, err)
+		}
+		if res.Metrics.StartEquity != got.Cash || res.Metrics.Trades == 0 {
+			t.Fatalf("job %d: unexpected metrics %+v", id, res.Metrics)
+		}
+		if id == 1 && res.Metrics.Start.Before(got.From) {
+			t.Fatalf("job %d traded before its range: %v < %v", id, res.Metrics.Start, got.From)
+		}
+	}
+}
+
+func TestJobsTimeout(t *testing.T) {
+	store := &memJobs{data: wave(400, 10)}
+	jobs := NewJobs(store, JobOptions{Timeout: time.Nanosecond, Poll: time.Millisecond}, zerolog.Nop())
+	jobs.now = func() time.Time { return time.Unix(400*3600+1800, 0) }
+	ctx := context.Background()
+	if _, err := jobs.Submit(ctx, JobRequest{ChatID: 7, Strategy: "rsi", Pair: "ETHUSDT", Interval: "1h", Range: "12d"}); err != nil {
+		t.Fatalf("submit: %v", err)
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		jobs.Run(runCtx)
+		close(done)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, _ := jobs.Get(ctx, 7, 1)
+		store.mu.Lock()
+		sweeps := store.sweeps
+		store.mu.Unlock()
+		// Stale jobs are swept on every timeout, not only at startup.
+		if job.Status == model.BacktestFailed && strings.Contains(job.Error, "timed out") && sweeps > 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s (%q) after %d sweeps", job.Status, job.Error, sweeps)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	<-done
+}
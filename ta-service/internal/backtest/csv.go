@@ -0,0 +1,128 @@
+package backtest
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/candles"
+)
+
+// csvColumns maps accepted header names to candle fields.
+var csvColumns = map[string]string{
+	"timestamp": "time", "time": "time", "open_time": "time", "start": "time", "date": "time",
+	"open": "open", "high": "high", "low": "low", "close": "close", "volume": "volume",
+	"pair": "pair", "symbol": "pair",
+}
+
+// CSVError locates a problem in a candle CSV.
+type CSVError struct {
+	Line   int
+	Column string
+	Err    error
+}
+
+func (e *CSVError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d, column %s: %v", e.Line, e.Column, e.Err)
+}
+
+func (e *CSVError) Unwrap() error { return e.Err }
+
+// ReadCSV reads candles from a CSV whose header names the columns, in any
+// order: a timestamp (timestamp, time, open_time, start or date), open,
+// high, low, close, volume and optionally pair. Other columns are ignored.
+// Timestamps are Unix milliseconds, Unix seconds or RFC 3339; numbers below
+// 1e11 are taken as seconds. Rows without a pair column belong to pair.
+// Any malformed row fails the whole file; series come back sorted by pair.
+func ReadCSV(r io.Reader, pair, interval string) ([]Series, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, &CSVError{Line: 1, Err: errors.New("missing header")}
+	}
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string]int)
+	for i, name := range header {
+		field, ok := csvColumns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))]
+		if !ok {
+			continue
+		}
+		if _, dup := index[field]; dup {
+			return nil, &CSVError{Line: 1, Column: name, Err: fmt.Errorf("duplicate %s column", field)}
+		}
+		index[field] = i
+	}
+	for _, field := range []string{"time", "open", "high", "low", "close", "volume"} {
+		if _, ok := index[field]; !ok {
+			return nil, &CSVError{Line: 1, Err: fmt.Errorf("no %s column in header %q", field, strings.Join(header, ","))}
+		}
+	}
+
+	byPair := make(map[string][]candles.Candle)
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		c := candles.Candle{Exchange: "backtest", Pair: pair, Interval: interval, Closed: true}
+		if i, ok := index["pair"]; ok {
+			if c.Pair = strings.ToUpper(strings.TrimSpace(row[i])); c.Pair == "" {
+				return nil, &CSVError{Line: line, Column: header[i], Err: errors.New("empty pair")}
+			}
+		}
+		if c.Start, err = parseTimestamp(row[index["time"]]); err != nil {
+			return nil, &CSVError{Line: line, Column: header[index["time"]], Err: err}
+		}
+		for field, dst := range map[string]*float64{"open": &c.Open, "high": &c.High, "low": &c.Low, "close": &c.Close, "volume": &c.Volume} {
+			v, err := strconv.ParseFloat(strings.TrimSpace(row[index[field]]), 64)
+			if err != nil || math.IsNaN(v) || math.IsInf(v, 0) || v < 0 {
+				return nil, &CSVError{Line: line, Column: header[index[field]], Err: fmt.Errorf("invalid value %q", row[index[field]])}
+			}
+			*dst = v
+		}
+		if c.High < math.Max(c.Open, c.Close) || c.Low > math.Min(c.Open, c.Close) {
+			return nil, &CSVError{Line: line, Err: fmt.Errorf("high %g and low %g do not contain open %g and close %g", c.High, c.Low, c.Open, c.Close)}
+		}
+		list := byPair[c.Pair]
+		if n := len(list); n > 0 && !c.Start.After(list[n-1].Start) {
+			return nil, &CSVError{Line: line, Err: fmt.Errorf("%s %s is not after the previous row", c.Pair, c.Start.UTC().Format(time.RFC3339))}
+		}
+		byPair[c.Pair] = append(list, c)
+	}
+	out := make([]Series, 0, len(byPair))
+	for p, list := range byPair {
+		out = append(out, Series{Pair: p, Candles: list})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Pair < out[j].Pair })
+	return out, nil
+}
+
+func parseTimestamp(v string) (time.Time, error) {
+	v = strings.TrimSpace(v)
+	if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+		if n < 1e11 {
+			return time.Unix(n, 0).UTC(), nil
+		}
+		return time.UnixMilli(n).UTC(), nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t.UTC(), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q: want Unix milliseconds, seconds or RFC 3339", v)
+}
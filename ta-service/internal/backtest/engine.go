@@ -0,0 +1,419 @@
+package backtest
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/candles"
+)
+
+// checkEvery is how many bars run processes between checks for a cancelled
+// context.
+const checkEvery = 1024
+
+// Config describes the simulated account and how orders fill.
+type Config struct {
+	// Cash is the starting balance in quote currency.
+	Cash float64
+	// QtyStep is the lot size buys are rounded down to.
+	QtyStep float64
+	// PositionSize is the fraction of equity a buy without Qty spends,
+	// capped by the cash left. 0 splits equity evenly across symbols.
+	PositionSize float64
+	Fills        FillModel
+	// TradeFrom makes earlier candles only warm strategies up: they are not
+	// traded or counted in the results.
+	TradeFrom time.Time
+	// NewGate, when set, creates the pre-trade risk check for a run. Every
+	// strategy order passes it before it is sent.
+	NewGate func() Gate
+}
+
+// DefaultConfig is 10000 quote, 0.001 lots and idealised fills: market
+// orders at the close of their bar with no fees or slippage.
+func DefaultConfig() Config {
+	return Config{Cash: 10000, QtyStep: 0.001}
+}
+
+// Series is one symbol's candles, oldest first.
+type Series struct {
+	Pair    string
+	Candles []candles.Candle
+}
+
+// Fill is an executed order.
+type Fill struct {
+	Time   time.Time `json:"time"`
+	Pair   string    `json:"pair"`
+	Side   Side      `json:"side"`
+	Type   OrderType `json:"type"`
+	Price  float64   `json:"price"`
+	Qty    float64   `json:"qty"`
+	Fee    float64   `json:"fee"`
+	Reason string    `json:"reason,omitempty"`
+}
+
+// Result is the outcome of a run.
+type Result struct {
+	Strategy  string   `json:"strategy"`
+	Pairs     []string `json:"pairs"`
+	StartCash float64  `json:"start_cash"`
+	FinalCash float64  `json:"final_cash"`
+	Fees      float64  `json:"fees"`
+	// Unfilled counts orders still pending or resting when the data ended.
+	Unfilled int           `json:"unfilled"`
+	Fills    []Fill        `json:"fills"`
+	Rejected []Rejection   `json:"rejected,omitempty"`
+	Equity   []EquityPoint `json:"equity"`
+}
+
+// EquityPoint is the account marked to market at a bar's close.
+type EquityPoint struct {
+	Time   time.Time `json:"time"`
+	Equity float64   `json:"equity"`
+	// Invested is the market value of open positions.
+	Invested float64 `json:"invested"`
+}
+
+// Run feeds data, oldest first, through st and simulates its orders with
+// cfg.Fills. A position still open at the end is closed at the last close.
+func Run(st Strategy, data []candles.Candle, cfg Config) Result {
+	pair := "BACKTEST"
+	if len(data) > 0 && data[0].Pair != "" {
+		pair = data[0].Pair
+	}
+	res, _ := run(context.Background(), []*leg{{pair: pair, st: st, candles: data}}, cfg)
+	return res
+}
+
+// RunPortfolio runs a fresh strategy from newStrategy on every series.
+// The symbols share one cash balance; bars are processed in time order.
+func RunPortfolio(series []Series, newStrategy func() (Strategy, error), cfg Config) (Result, error) {
+	return RunPortfolioContext(context.Background(), series, newStrategy, cfg)
+}
+
+// RunPortfolioContext is RunPortfolio stopping early with ctx's error once
+// ctx is done.
+func RunPortfolioContext(ctx context.Context, series []Series, newStrategy func() (Strategy, error), cfg Config) (Result, error) {
+	legs := make([]*leg, 0, len(series))
+	for _, s := range series {
+		st, err := newStrategy()
+		if err != nil {
+			return Result{}, err
+		}
+		legs = append(legs, &leg{pair: s.Pair, st: st, candles: s.Candles})
+	}
+	return run(ctx, legs, cfg)
+}
+
+func run(ctx context.Context, legs []*leg, cfg Config) (Result, error) {
+	if cfg.QtyStep <= 0 {
+		cfg.QtyStep = DefaultConfig().QtyStep
+	}
+	if cfg.Fills.Slippage == nil {
+		cfg.Fills.Slippage = NoSlippage{}
+	}
+	if cfg.PositionSize <= 0 && len(legs) > 0 {
+		cfg.PositionSize = 1 / float64(len(legs))
+	}
+	sort.SliceStable(legs, func(i, j int) bool { return legs[i].pair < legs[j].pair })
+	a := &account{cfg: cfg, cash: cfg.Cash, legs: legs}
+	if cfg.NewGate != nil {
+		a.gate = cfg.NewGate()
+	}
+	res := Result{StartCash: cfg.Cash}
+	for _, l := range legs {
+		res.Pairs = append(res.Pairs, l.pair)
+	}
+	if len(legs) > 0 {
+		res.Strategy = legs[0].st.Name()
+	}
+
+	for bars := 0; ; bars++ {
+		if bars%checkEvery == 0 {
+			if err := ctx.Err(); err != nil {
+				return res, err
+			}
+		}
+		var now time.Time
+		for _, l := range legs {
+			if l.next < len(l.candles) && (now.IsZero() || l.candles[l.next].Start.Before(now)) {
+				now = l.candles[l.next].Start
+			}
+		}
+		if now.IsZero() {
+			break
+		}
+		traded := false
+		for _, l := range legs {
+			if l.next >= len(l.candles) || !l.candles[l.next].Start.Equal(now) {
+				continue
+			}
+			c := l.candles[l.next]
+			l.next++
+			if c.Start.Before(cfg.TradeFrom) {
+				l.st.OnCandle(c, Position{Cash: a.cash})
+				l.mark = c.Close
+				continue
+			}
+			a.bar(l, c)
+			traded = true
+		}
+		if traded {
+			value := a.value()
+			a.equity = append(a.equity, EquityPoint{Time: now, Equity: value, Invested: value - a.cash})
+		}
+	}
+	for _, l := range legs {
+		if l.qty > 0 {
+			last := l.candles[len(l.candles)-1]
+			a.fill(l, Order{Side: Sell, Type: Market, Reason: "end of data"}, last.Close, last, true)
+		}
+	}
+	if n := len(a.equity); n > 0 {
+		a.equity[n-1].Equity = a.value()
+	}
+	for _, l := range legs {
+		res.Unfilled += len(l.queue)
+	}
+	res.FinalCash, res.Fees, res.Fills, res.Rejected, res.Equity = a.cash, a.fees, a.fills, a.rejected, a.equity
+	return res, nil
+}
+
+// queued is an order waiting for its bar.
+type queued struct {
+	pending
+	seq    int
+	atOpen bool
+	// approved is the exposure the gate booked for the order.
+	approved exposure
+}
+
+// leg is one symbol's strategy, candles and position.
+type leg struct {
+	pair    string
+	st      Strategy
+	candles []candles.Candle
+	next    int
+	// bars counts traded bars; order latency is measured in them.
+	bars  int
+	qty   float64
+	entry float64
+	mark  float64
+	queue []queued
+	// booked is the gate exposure of the filled buys still held.
+	booked float64
+}
+
+// account is the shared state of one run.
+type account struct {
+	cfg      Config
+	cash     float64
+	legs     []*leg
+	seq      int
+	fills    []Fill
+	fees     float64
+	equity   []EquityPoint
+	gate     Gate
+	rejected []Rejection
+}
+
+// value is cash plus positions at their latest prices.
+func (a *account) value() float64 {
+	v := a.cash
+	for _, l := range a.legs {
+		v += l.qty * l.mark
+	}
+	return v
+}
+
+func (a *account) position(l *leg) Position {
+	pos := Position{Qty: l.qty, EntryPrice: l.entry, Cash: a.cash}
+	for _, q := range l.queue {
+		switch q.Type {
+		case Market:
+			pos.Pending++
+		case Limit, Stop:
+			pos.Resting++
+		}
+	}
+	return pos
+}
+
+// bar processes candle c of l: due cancels, fills at the open, intrabar
+// limit/stop fills, fills at the close, then the strategy's new orders.
+func (a *account) bar(l *leg, c candles.Candle) {
+	i := l.bars
+	l.bars++
+	a.cancelDue(l, i)
+	l.mark = c.Open
+	a.fillQueued(l, i, c, func(q queued) (float64, bool) {
+		return c.Open, q.Type == Market && q.atOpen
+	})
+	a.fillQueued(l, i, c, func(q queued) (float64, bool) {
+		if q.Type == Market {
+			return 0, false
+		}
+		return q.trigger(c)
+	})
+	l.mark = c.Close
+	a.fillQueued(l, i, c, func(q queued) (float64, bool) {
+		return c.Close, q.Type == Market && !q.atOpen
+	})
+	for _, o := range l.st.OnCandle(c, a.position(l)) {
+		a.submit(l, i, o, c)
+	}
+	// Orders with no latency that execute at this close fill right away.
+	a.cancelDue(l, i)
+	a.fillQueued(l, i, c, func(q queued) (float64, bool) {
+		return c.Close, q.Type == Market && !q.atOpen
+	})
+}
+
+// submit queues o, sent at the close of bar i, candle c.
+func (a *account) submit(l *leg, i int, o Order, c candles.Candle) {
+	if o.Type == "" {
+		o.Type = Market
+	}
+	q := queued{pending: pending{Order: o, due: i + a.cfg.Fills.LatencyBars}, seq: a.seq}
+	a.seq++
+	switch o.Type {
+	case Market:
+		if a.cfg.Fills.NextBarOpen {
+			q.due++
+			q.atOpen = true
+		}
+	case Limit, Stop:
+		if o.Price <= 0 {
+			return
+		}
+		q.due++
+	case Cancel:
+	default:
+		return
+	}
+	if o.Type != Cancel && !a.check(l, &q, c) {
+		return
+	}
+	l.queue = append(l.queue, q)
+}
+
+// cancelDue applies cancels that reached the market by bar i; they remove
+// every limit or stop order submitted before them.
+func (a *account) cancelDue(l *leg, i int) {
+	cutoff := -1
+	for _, q := range l.queue {
+		if q.Type == Cancel && q.due <= i {
+			cutoff = q.seq
+		}
+	}
+	if cutoff < 0 {
+		return
+	}
+	kept := l.queue[:0]
+	for _, q := range l.queue {
+		resting := q.Type == Limit || q.Type == Stop
+		if q.seq <= cutoff && (q.Type == Cancel || resting) {
+			a.release(l, q.Side, q.approved)
+			continue
+		}
+		kept = append(kept, q)
+	}
+	l.queue = kept
+}
+
+// fillQueued executes the due orders for which price reports a fill.
+func (a *account) fillQueued(l *leg, i int, c candles.Candle, price func(queued) (float64, bool)) {
+	kept := l.queue[:0]
+	for _, q := range l.queue {
+		if q.due <= i && q.Type != Cancel {
+			if p, ok := price(q); ok {
+				a.settle(l, q, a.fill(l, q.Order, p, c, q.Type != Limit))
+				continue
+			}
+		}
+		kept = append(kept, q)
+	}
+	l.queue = kept
+}
+
+// fill executes o at the quoted price within c and returns the quantity
+// filled. Taker fills pay slippage and the taker fee; maker fills pay the
+// maker fee only.
+func (a *account) fill(l *leg, o Order, quoted float64, c candles.Candle, taker bool) float64 {
+	if quoted <= 0 {
+		return 0
+	}
+	rate := a.cfg.Fills.Fees.Maker
+	if taker {
+		rate = a.cfg.Fills.Fees.Taker
+	}
+	price := func(qty float64) float64 {
+		if !taker {
+			return quoted
+		}
+		return a.cfg.Fills.Slippage.Price(o.Side, quoted, qty, c)
+	}
+	qty := o.Qty
+	var px float64
+	switch o.Side {
+	case Buy:
+		budget := a.cash
+		if qty <= 0 {
+			budget = math.Min(a.cash, a.value()*a.cfg.PositionSize)
+		}
+		// Size against the quote first, then again at the slipped price;
+		// slippage only shrinks with the quantity so the result stays
+		// affordable.
+		affordable := a.lots(budget / (quoted * (1 + rate)))
+		if qty <= 0 || qty > affordable {
+			qty = affordable
+		}
+		px = price(qty)
+		if cost := qty * px * (1 + rate); cost > budget {
+			qty = a.lots(budget / (px * (1 + rate)))
+		}
+		if qty <= 0 {
+			return 0
+		}
+		fee := qty * px * rate
+		l.entry = (l.entry*l.qty + px*qty) / (l.qty + qty)
+		l.qty += qty
+		a.cash -= qty*px + fee
+		a.record(l, o, c, px, qty, fee)
+	case Sell:
+		if qty <= 0 || qty > l.qty {
+			qty = l.qty
+		}
+		if qty <= 0 {
+			return 0
+		}
+		px = price(qty)
+		fee := qty * px * rate
+		if a.gate != nil && l.booked > 0 {
+			// Selling settles the matching share of the buys' exposure.
+			share := l.booked * qty / l.qty
+			a.gate.Release(l.pair, Buy, qty, share/qty)
+			l.booked -= share
+		}
+		l.qty -= qty
+		a.cash += qty*px - fee
+		if l.qty == 0 {
+			l.entry = 0
+		}
+		a.record(l, o, c, px, qty, fee)
+	}
+	return qty
+}
+
+func (a *account) record(l *leg, o Order, c candles.Candle, price, qty, fee float64) {
+	a.fees += fee
+	a.fills = append(a.fills, Fill{Time: c.Start, Pair: l.pair, Side: o.Side, Type: o.Type, Price: price, Qty: qty, Fee: fee, Reason: o.Reason})
+}
+
+// lots rounds qty down to the lot size.
+func (a *account) lots(qty float64) float64 {
+	return math.Floor(qty/a.cfg.QtyStep) * a.cfg.QtyStep
+}
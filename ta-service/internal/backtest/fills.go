@@ -0,0 +1,180 @@
+package backtest
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/candles"
+)
+
+// FillModel decides when and at what price orders execute.
+type FillModel struct {
+	Fees     Fees
+	Slippage Slippage
+	// NextBarOpen fills market orders at the open of the bar after the one
+	// that produced them instead of at its close.
+	NextBarOpen bool
+	// LatencyBars delays every order by this many bars before it reaches the
+	// market.
+	LatencyBars int
+}
+
+// Fees are charged on notional as fractions, e.g. 0.001 for 0.1%. Resting
+// limit orders pay the maker rate; market and stop orders pay taker.
+type Fees struct {
+	Maker float64 `json:"maker"`
+	Taker float64 `json:"taker"`
+}
+
+// feeSchedules are named fee presets for ParseFees.
+var feeSchedules = map[string]Fees{
+	"none":        {},
+	"binance":     {Maker: 0.001, Taker: 0.001},
+	"binance-bnb": {Maker: 0.00075, Taker: 0.00075},
+	"binance-vip": {Maker: 0.0009, Taker: 0.001},
+	"uniswap":     {Maker: 0.003, Taker: 0.003},
+}
+
+// ParseFees reads a preset name (none, binance, binance-bnb, binance-vip,
+// uniswap) or "MAKER/TAKER" in percent, e.g. "0.02%/0.04%".
+func ParseFees(spec string) (Fees, error) {
+	spec = strings.ToLower(strings.TrimSpace(spec))
+	if f, ok := feeSchedules[spec]; ok {
+		return f, nil
+	}
+	maker, taker, ok := strings.Cut(spec, "/")
+	if !ok {
+		return Fees{}, fmt.Errorf("fees %q: want a preset or maker%%/taker%%", spec)
+	}
+	m, err := parsePercent(maker)
+	if err != nil {
+		return Fees{}, fmt.Errorf("maker fee: %w", err)
+	}
+	t, err := parsePercent(taker)
+	if err != nil {
+		return Fees{}, fmt.Errorf("taker fee: %w", err)
+	}
+	return Fees{Maker: m, Taker: t}, nil
+}
+
+// Slippage moves the fill price of taker orders against the trader.
+type Slippage interface {
+	// Price returns the executed price for qty filled at the quoted price
+	// within bar c.
+	Price(side Side, price, qty float64, c candles.Candle) float64
+}
+
+// NoSlippage fills at the quoted price.
+type NoSlippage struct{}
+
+// Price implements Slippage.
+func (NoSlippage) Price(_ Side, price, _ float64, _ candles.Candle) float64 { return price }
+
+// FixedSlippage adds a constant amount of quote currency per unit.
+type FixedSlippage struct{ Amount float64 }
+
+// Price implements Slippage.
+func (s FixedSlippage) Price(side Side, price, _ float64, _ candles.Candle) float64 {
+	return adverse(side, price, s.Amount)
+}
+
+// PercentSlippage moves the price by a fraction of itself.
+type PercentSlippage struct{ Fraction float64 }
+
+// Price implements Slippage.
+func (s PercentSlippage) Price(side Side, price, _ float64, _ candles.Candle) float64 {
+	return adverse(side, price, price*s.Fraction)
+}
+
+// VolumeImpact uses the square-root impact model: the price moves by
+// Coefficient * sqrt(qty / bar volume) as a fraction, capped at Max.
+type VolumeImpact struct {
+	Coefficient float64
+	Max         float64
+}
+
+// Price implements Slippage.
+func (s VolumeImpact) Price(side Side, price, qty float64, c candles.Candle) float64 {
+	impact := s.Max
+	if c.Volume > 0 {
+		impact = math.Min(s.Coefficient*math.Sqrt(qty/c.Volume), s.Max)
+	}
+	return adverse(side, price, price*impact)
+}
+
+func adverse(side Side, price, amount float64) float64 {
+	if side == Buy {
+		return price + amount
+	}
+	return math.Max(price-amount, 0)
+}
+
+// ParseSlippage reads "none", "fixed:AMOUNT", "pct:PERCENT" or
+// "impact:COEFFICIENT[:MAX%]" (max defaults to 5%).
+func ParseSlippage(spec string) (Slippage, error) {
+	kind, arg, _ := strings.Cut(strings.ToLower(strings.TrimSpace(spec)), ":")
+	switch kind {
+	case "", "none":
+		return NoSlippage{}, nil
+	case "fixed":
+		v, err := strconv.ParseFloat(arg, 64)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("fixed slippage %q: want a non-negative amount", arg)
+		}
+		return FixedSlippage{Amount: v}, nil
+	case "pct":
+		v, err := parsePercent(arg)
+		if err != nil {
+			return nil, fmt.Errorf("percentage slippage: %w", err)
+		}
+		return PercentSlippage{Fraction: v}, nil
+	case "impact":
+		coef, max, hasMax := strings.Cut(arg, ":")
+		c, err := strconv.ParseFloat(coef, 64)
+		if err != nil || c < 0 {
+			return nil, fmt.Errorf("impact coefficient %q: want a non-negative number", coef)
+		}
+		m := 0.05
+		if hasMax {
+			if m, err = parsePercent(max); err != nil {
+				return nil, fmt.Errorf("impact cap: %w", err)
+			}
+		}
+		return VolumeImpact{Coefficient: c, Max: m}, nil
+	}
+	return nil, fmt.Errorf("unknown slippage model %q (none, fixed, pct, impact)", kind)
+}
+
+// parsePercent reads "0.1" or "0.1%" as 0.001.
+func parsePercent(v string) (float64, error) {
+	f, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(v), "%"), 64)
+	if err != nil || f < 0 || f >= 100 {
+		return 0, fmt.Errorf("invalid percentage %q", v)
+	}
+	return f / 100, nil
+}
+
+// pending is an order waiting for its bar.
+type pending struct {
+	Order
+	// due is the index of the first bar the order can fill on.
+	due int
+}
+
+// trigger returns the price a resting order fills at within c, if it does.
+// Gaps through the level fill at the open.
+func (p pending) trigger(c candles.Candle) (float64, bool) {
+	switch {
+	case p.Type == Limit && p.Side == Buy && c.Low <= p.Price:
+		return math.Min(c.Open, p.Price), true
+	case p.Type == Limit && p.Side == Sell && c.High >= p.Price:
+		return math.Max(c.Open, p.Price), true
+	case p.Type == Stop && p.Side == Buy && c.High >= p.Price:
+		return math.Max(c.Open, p.Price), true
+	case p.Type == Stop && p.Side == Sell && c.Low <= p.Price:
+		return math.Min(c.Open, p.Price), true
+	}
+	return 0, false
+}
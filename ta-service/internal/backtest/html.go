@@ -0,0 +1,139 @@
+package backtest
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// chartWidth and chartHeight size the inline SVG charts.
+const (
+	chartWidth  = 960
+	chartHeight = 240
+)
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"pct":   func(v float64) string { return fmt.Sprintf("%.2f%%", v*100) },
+	"num":   func(v float64) string { return fmt.Sprintf("%.4g", v) },
+	"money": func(v float64) string { return fmt.Sprintf("%.2f", v) },
+	"when":  func(t time.Time) string { return t.UTC().Format("2006-01-02 15:04") },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Backtest: {{.Report.Strategy}}</title>
+<style>
+body { font: 14px/1.4 system-ui, sans-serif; margin: 2em auto; max-width: 1000px; color: #222; }
+table { border-collapse: collapse; margin: 1em 0; }
+td, th { padding: 3px 10px; border-bottom: 1px solid #ddd; text-align: right; }
+th { background: #f4f4f4; }
+td:first-child, th:first-child { text-align: left; }
+svg { background: #fafafa; border: 1px solid #ddd; }
+.pos { color: #1a7f37; } .neg { color: #cf222e; }
+</style>
+</head>
+<body>
+<h1>Backtest: {{.Report.Strategy}}</h1>
+{{with .Report.Pairs}}<p>Symbols: {{range $i, $p := .}}{{if $i}}, {{end}}{{$p}}{{end}}</p>{{end}}
+{{with .Report.Params}}<p>Parameters: {{.}}</p>{{end}}
+{{with .Report.Metrics}}<p>{{when .Start}} to {{when .End}}</p>
+<table>
+<tr><td>Start equity</td><td>{{money .StartEquity}}</td><td>Final equity</td><td>{{money .FinalEquity}}</td></tr>
+<tr><td>Total return</td><td>{{pct .TotalReturn}}</td><td>CAGR</td><td>{{pct .CAGR}}</td></tr>
+<tr><td>Sharpe</td><td>{{num .Sharpe}}</td><td>Sortino</td><td>{{num .Sortino}}</td></tr>
+<tr><td>Max drawdown</td><td>{{pct .MaxDrawdown}}</td><td>Longest drawdown</td><td>{{.MaxDrawdownDuration}}</td></tr>
+<tr><td>Trades</td><td>{{.Trades}}</td><td>Win rate</td><td>{{pct .WinRate}}</td></tr>
+<tr><td>Profit factor</td><td>{{with .ProfitFactor}}{{num .}}{{else}}n/a{{end}}</td><td>Average trade</td><td>{{money .AvgTrade}} ({{pct .AvgReturn}})</td></tr>
+<tr><td>Exposure</td><td>{{pct .Exposure}}</td><td>Fees</td><td>{{money .Fees}}</td></tr>
+{{if .Rejected}}<tr><td>Rejected orders</td><td>{{.Rejected}}</td><td></td><td></td></tr>{{end}}
+</table>{{end}}
+<h2>Equity</h2>
+<svg width="{{.Width}}" height="{{.Height}}" viewBox="0 0 {{.Width}} {{.Height}}">
+<path d="{{.EquityPath}}" fill="none" stroke="#0969da" stroke-width="1.5"/>
+<text x="4" y="14" font-size="12">{{money .EquityMax}}</text>
+<text x="4" y="{{.Height}}" dy="-4" font-size="12">{{money .EquityMin}}</text>
+</svg>
+<h2>Drawdown</h2>
+<svg width="{{.Width}}" height="{{.Height}}" viewBox="0 0 {{.Width}} {{.Height}}">
+<path d="{{.DrawdownPath}}" fill="#cf222e" fill-opacity="0.25" stroke="#cf222e"/>
+<text x="4" y="{{.Height}}" dy="-4" font-size="12">-{{pct .Report.Metrics.MaxDrawdown}}</text>
+</svg>
+<h2>Trades</h2>
+<table>
+<tr><th>Pair</th><th>Entry</th><th>Exit</th><th>Entry price</th><th>Exit price</th><th>Qty</th><th>Fees</th><th>PnL</th><th>Return</th><th>Exit reason</th></tr>
+{{range .Report.Trades}}<tr><td>{{.Pair}}</td><td>{{when .EntryTime}}</td><td>{{when .ExitTime}}</td><td>{{num .EntryPrice}}</td><td>{{num .ExitPrice}}</td><td>{{num .Qty}}</td><td>{{money .Fees}}</td><td class="{{if gt .PnL 0.0}}pos{{else}}neg{{end}}">{{money .PnL}}</td><td>{{pct .Return}}</td><td>{{.Reason}}</td></tr>
+{{end}}</table>
+{{with .Report.Rejected}}<h2>Rejected by risk checks</h2>
+<table>
+<tr><th>Time</th><th>Pair</th><th>Side</th><th>Type</th><th>Qty</th><th>Price</th><th>Reason</th></tr>
+{{range .}}<tr><td>{{when .Time}}</td><td>{{.Pair}}</td><td>{{.Side}}</td><td>{{.Type}}</td><td>{{num .Qty}}</td><td>{{num .Price}}</td><td>{{.Reason}}</td></tr>
+{{end}}</table>{{end}}
+</body>
+</html>
+`))
+
+// WriteHTML writes a self-contained HTML report with inline SVG charts.
+func (r Report) WriteHTML(w io.Writer) error {
+	equity := make([]float64, len(r.Equity))
+	drawdown := make([]float64, len(r.Equity))
+	for i, p := range r.Equity {
+		equity[i] = p.Equity
+		drawdown[i] = -p.Drawdown
+	}
+	lo, hi := bounds(equity)
+	return reportTemplate.Execute(w, map[string]any{
+		"Report":       r,
+		"Width":        chartWidth,
+		"Height":       chartHeight,
+		"EquityPath":   svgPath(equity, lo, hi, false),
+		"EquityMin":    lo,
+		"EquityMax":    hi,
+		"DrawdownPath": svgPath(drawdown, math.Min(-r.Metrics.MaxDrawdown, -1e-9), 0, true),
+	})
+}
+
+func bounds(values []float64) (lo, hi float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	lo, hi = values[0], values[0]
+	for _, v := range values {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	return lo, hi
+}
+
+// svgPath plots values across the chart, scaling lo..hi to the full
+// height. Filled paths are closed along the top edge, where hi sits.
+func svgPath(values []float64, lo, hi float64, filled bool) string {
+	if len(values) == 0 {
+		return ""
+	}
+	if hi == lo {
+		hi, lo = hi+1, lo-1
+	}
+	var b strings.Builder
+	step := float64(chartWidth)
+	if len(values) > 1 {
+		step /= float64(len(values) - 1)
+	}
+	for i, v := range values {
+		cmd := "L"
+		if i == 0 {
+			cmd = "M"
+			if filled {
+				b.WriteString("M0 0 ")
+				cmd = "L"
+			}
+		}
+		y := (hi - v) / (hi - lo) * chartHeight
+		fmt.Fprintf(&b, "%s%.1f %.1f ", cmd, float64(i)*step, y)
+	}
+	if filled {
+		fmt.Fprintf(&b, "L%.1f 0 Z", step*float64(len(values)-1))
+	}
+	return strings.TrimSpace(b.String())
+}
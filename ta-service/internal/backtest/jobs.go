@@ -0,0 +1,321 @@
+package backtest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/candles"
+	"github.com/example/tg-crypto-trader/ta-service/internal/model"
+)
+
+// Job errors.
+var (
+	ErrInvalidJob  = errors.New("invalid backtest")
+	ErrJobNotFound = errors.New("backtest not found")
+	ErrQueueFull   = errors.New("backtest queue full")
+)
+
+// JobStore persists backtest jobs; *storage.Store implements it.
+type JobStore interface {
+	CandleStore
+	CreateBacktest(ctx context.Context, job *model.BacktestJob) error
+	Backtest(ctx context.Context, id int64) (model.BacktestJob, bool, error)
+	ClaimBacktest(ctx context.Context) (model.BacktestJob, bool, error)
+	FinishBacktest(ctx context.Context, job model.BacktestJob) error
+	QueuedBacktests(ctx context.Context) (int, error)
+	FailStaleBacktests(ctx context.Context, cutoff time.Time, reason string) (int64, error)
+}
+
+// JobOptions configure the job runner.
+type JobOptions struct {
+	// Workers bounds the backtests running at once on this replica.
+	Workers int
+	// MaxQueued bounds the jobs waiting for a worker.
+	MaxQueued int
+	// MaxBars bounds the candles a job may cover.
+	MaxBars int
+	// Timeout bounds one job. Jobs left running longer than that, such as
+	// those of a replica that stopped mid-run, are failed as interrupted when
+	// the runner starts and on every Timeout after.
+	Timeout time.Duration
+	// Poll is how often idle workers look for jobs queued on other replicas.
+	Poll time.Duration
+	// Exchange is the exchange whose stored candles jobs run on.
+	Exchange string
+	// Warmup is the number of bars before a job's range loaded to warm
+	// indicators up.
+	Warmup int
+}
+
+// JobRequest asks for a backtest. Range, such as "90d", sets From back
+// from To, which defaults to now.
+type JobRequest struct {
+	ChatID   int64     `json:"chat_id"`
+	Strategy string    `json:"strategy"`
+	Params   Params    `json:"params"`
+	Pair     string    `json:"pair"`
+	Interval string    `json:"interval"`
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Range    string    `json:"range"`
+	Cash     float64   `json:"cash"`
+}
+
+// JobResult is what a finished job stores.
+type JobResult struct {
+	Metrics  Metrics `json:"metrics"`
+	Fills    int     `json:"fills"`
+	Unfilled int     `json:"unfilled"`
+}
+
+// Jobs queues backtests in Postgres and runs them on a bounded worker pool.
+type Jobs struct {
+	store  JobStore
+	opts   JobOptions
+	logger zerolog.Logger
+	wake   chan struct{}
+	now    func() time.Time
+}
+
+// NewJobs returns a job runner.
+func NewJobs(store JobStore, opts JobOptions, logger zerolog.Logger) *Jobs {
+	if opts.Workers <= 0 {
+		opts.Workers = 2
+	}
+	if opts.MaxQueued <= 0 {
+		opts.MaxQueued = 20
+	}
+	if opts.MaxBars <= 0 {
+		opts.MaxBars = 100000
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Minute
+	}
+	if opts.Poll <= 0 {
+		opts.Poll = 5 * time.Second
+	}
+	if opts.Exchange == "" {
+		opts.Exchange = "binance"
+	}
+	return &Jobs{store: store, opts: opts, logger: logger, wake: make(chan struct{}, 1), now: time.Now}
+}
+
+// Submit validates req and queues it.
+func (j *Jobs) Submit(ctx context.Context, req JobRequest) (model.BacktestJob, error) {
+	job, err := j.validate(req)
+	if err != nil {
+		return job, err
+	}
+	queued, err := j.store.QueuedBacktests(ctx)
+	if err != nil {
+		return job, err
+	}
+	if queued >= j.opts.MaxQueued {
+		return job, fmt.Errorf("%w: %d jobs waiting", ErrQueueFull, queued)
+	}
+	if err := j.store.CreateBacktest(ctx, &job); err != nil {
+		return job, err
+	}
+	select {
+	case j.wake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// Get returns a chat's job.
+func (j *Jobs) Get(ctx context.Context, chatID, id int64) (model.BacktestJob, error) {
+	job, found, err := j.store.Backtest(ctx, id)
+	if err != nil {
+		return job, err
+	}
+	if !found || job.ChatID != chatID {
+		return job, fmt.Errorf("%w: #%d", ErrJobNotFound, id)
+	}
+	return job, nil
+}
+
+func (j *Jobs) validate(req JobRequest) (model.BacktestJob, error) {
+	job := model.BacktestJob{ChatID: req.ChatID, Strategy: strings.ToLower(strings.TrimSpace(req.Strategy)),
+		Pair: strings.ToUpper(strings.TrimSpace(req.Pair)), Interval: req.Interval, Cash: req.Cash}
+	if job.ChatID == 0 {
+		return job, fmt.Errorf("%w: chat_id required", ErrInvalidJob)
+	}
+	f, ok := Lookup(job.Strategy)
+	if !ok {
+		return job, fmt.Errorf("%w: unknown strategy %q", ErrInvalidJob, req.Strategy)
+	}
+	params, err := f.Resolve(req.Params)
+	if err != nil {
+		return job, fmt.Errorf("%w: %v", ErrInvalidJob, err)
+	}
+	if _, err := f.New(params); err != nil {
+		return job, fmt.Errorf("%w: %v", ErrInvalidJob, err)
+	}
+	job.Params = params
+	if job.Pair == "" {
+		return job, fmt.Errorf("%w: pair required", ErrInvalidJob)
+	}
+	step, ok := candles.IntervalDuration(job.Interval)
+	if !ok {
+		return job, fmt.Errorf("%w: invalid interval %q", ErrInvalidJob, req.Interval)
+	}
+	job.From, job.To = req.From.UTC(), req.To.UTC()
+	if job.To.IsZero() {
+		job.To = j.now().UTC().Truncate(step)
+	}
+	if req.Range != "" {
+		span, err := ParseLookback(req.Range)
+		if err != nil {
+			return job, fmt.Errorf("%w: %v", ErrInvalidJob, err)
+		}
+		job.From = job.To.Add(-span)
+	}
+	if !job.To.After(job.From) || job.From.IsZero() {
+		return job, fmt.Errorf("%w: range required, as from and to or a range such as 90d", ErrInvalidJob)
+	}
+	if bars := int(job.To.Sub(job.From) / step); bars > j.opts.MaxBars {
+		return job, fmt.Errorf("%w: range covers %d %s bars, limit is %d", ErrInvalidJob, bars, job.Interval, j.opts.MaxBars)
+	}
+	if job.Cash == 0 {
+		job.Cash = DefaultConfig().Cash
+	}
+	if job.Cash < 0 {
+		return job, fmt.Errorf("%w: cash must be positive", ErrInvalidJob)
+	}
+	return job, nil
+}
+
+// ParseLookback reads a lookback such as 90d, 12w, 36h or 1y.
+func ParseLookback(v string) (time.Duration, error) {
+	v = strings.ToLower(strings.TrimSpace(v))
+	units := map[byte]time.Duration{'h': time.Hour, 'd': 24 * time.Hour, 'w': 7 * 24 * time.Hour, 'y': 365 * 24 * time.Hour}
+	if len(v) > 1 {
+		if unit, ok := units[v[len(v)-1]]; ok {
+			if n, err := strconv.Atoi(v[:len(v)-1]); err == nil && n > 0 {
+				return time.Duration(n) * unit, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("invalid range %q: want e.g. 90d, 12w, 36h or 1y", v)
+}
+
+// staleGrace gives a job that hit its timeout time to store the failure
+// before it is counted as interrupted.
+const staleGrace = time.Minute
+
+// Run works through queued jobs until ctx ends.
+func (j *Jobs) Run(ctx context.Context) {
+	j.failStale(ctx)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(j.opts.Timeout)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				j.failStale(ctx)
+			}
+		}
+	}()
+	for i := 0; i < j.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			j.work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (j *Jobs) failStale(ctx context.Context) {
+	cutoff := j.now().Add(-j.opts.Timeout - staleGrace)
+	if n, err := j.store.FailStaleBacktests(ctx, cutoff, "interrupted"); err != nil {
+		if ctx.Err() == nil {
+			j.logger.Warn().Err(err).Msg("failed to clear stale backtests")
+		}
+	} else if n > 0 {
+		j.logger.Warn().Int64("jobs", n).Msg("failed interrupted backtests")
+	}
+}
+
+func (j *Jobs) work(ctx context.Context) {
+	ticker := time.NewTicker(j.opts.Poll)
+	defer ticker.Stop()
+	for {
+		job, found, err := j.store.ClaimBacktest(ctx)
+		if err != nil && ctx.Err() == nil {
+			j.logger.Warn().Err(err).Msg("failed to claim backtest")
+		}
+		if found {
+			j.finish(ctx, job)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-j.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+func (j *Jobs) finish(ctx context.Context, job model.BacktestJob) {
+	started := time.Now()
+	res, err := j.execute(ctx, job)
+	if err != nil {
+		job.Status, job.Error = model.BacktestFailed, err.Error()
+		j.logger.Warn().Err(err).Int64("job", job.ID).Msg("backtest failed")
+	} else {
+		job.Status, job.Result = model.BacktestDone, res
+		j.logger.Info().Int64("job", job.ID).Str("strategy", job.Strategy).Str("pair", job.Pair).
+			Dur("took", time.Since(started)).Msg("backtest done")
+	}
+	// Record the outcome even if the service is stopping.
+	storeCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := j.store.FinishBacktest(storeCtx, job); err != nil {
+		j.logger.Error().Err(err).Int64("job", job.ID).Msg("failed to store backtest result")
+	}
+}
+
+func (j *Jobs) execute(ctx context.Context, job model.BacktestJob) (out json.RawMessage, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("backtest panicked: %v", r)
+		}
+	}()
+	ctx, cancel := context.WithTimeout(ctx, j.opts.Timeout)
+	defer cancel()
+	series, err := LoadStored(ctx, j.store, j.opts.Exchange, []string{job.Pair}, job.Interval, job.From, job.To, j.opts.Warmup)
+	if err != nil {
+		return nil, err
+	}
+	if tl := Timeline(series); len(tl) == 0 || tl[len(tl)-1].Before(job.From) {
+		return nil, fmt.Errorf("no stored %s %s candles in range; watch the pair to collect them", job.Pair, job.Interval)
+	}
+	cfg := DefaultConfig()
+	cfg.Cash, cfg.TradeFrom = job.Cash, job.From
+	params := Params(job.Params)
+	res, err := RunPortfolioContext(ctx, series, func() (Strategy, error) { return New(job.Strategy, params) }, cfg)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, fmt.Errorf("backtest timed out after %s", j.opts.Timeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+	report := NewReport(res, params)
+	return json.Marshal(JobResult{Metrics: report.Metrics, Fills: len(res.Fills), Unfilled: res.Unfilled})
+}
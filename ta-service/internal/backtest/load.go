@@ -0,0 +1,76 @@
+package backtest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/candles"
+	"github.com/example/tg-crypto-trader/ta-service/internal/model"
+)
+
+// CandleStore reads stored candles; *storage.Store implements it.
+type CandleStore interface {
+	LoadCandleRange(ctx context.Context, exchange, pair, interval string, from, to time.Time) ([]model.Candle, error)
+}
+
+// LoadStored loads the closed candles of every pair starting in [from, to),
+// reaching back warmup bars before from. A zero to loads up to now.
+func LoadStored(ctx context.Context, store CandleStore, exchange string, pairs []string, interval string, from, to time.Time, warmup int) ([]Series, error) {
+	step, ok := candles.IntervalDuration(interval)
+	if !ok {
+		return nil, fmt.Errorf("invalid interval %q", interval)
+	}
+	start := from
+	if !start.IsZero() {
+		start = start.Add(-time.Duration(warmup) * step)
+	}
+	now := time.Now()
+	out := make([]Series, 0, len(pairs))
+	for _, pair := range pairs {
+		pair = strings.ToUpper(strings.TrimSpace(pair))
+		list, err := store.LoadCandleRange(ctx, exchange, pair, interval, start, to)
+		if err != nil {
+			return nil, fmt.Errorf("load %s: %w", pair, err)
+		}
+		// The latest row may still be forming.
+		for len(list) > 0 && list[len(list)-1].Start.Add(step).After(now) {
+			list = list[:len(list)-1]
+		}
+		for i := range list {
+			list[i].Closed = true
+		}
+		out = append(out, Series{Pair: pair, Candles: list})
+	}
+	return out, nil
+}
+
+// LoadCSVFiles reads every file with ReadCSV; files without a pair column
+// are named after the file. A pair may only come from one file.
+func LoadCSVFiles(paths []string, interval string) ([]Series, error) {
+	var out []Series
+	seen := make(map[string]string)
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.ToUpper(strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+		series, err := ReadCSV(f, name, interval)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, s := range series {
+			if other, dup := seen[s.Pair]; dup {
+				return nil, fmt.Errorf("%s: pair %s is also in %s", path, s.Pair, other)
+			}
+			seen[s.Pair] = path
+			out = append(out, s)
+		}
+	}
+	return out, nil
+}
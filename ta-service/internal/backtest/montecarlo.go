@@ -0,0 +1,261 @@
+package backtest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// Monte Carlo methods.
+const (
+	// MonteCarloShuffle replays the trades in random order. Final equity
+	// stays the same; drawdowns and ruin show how much the order mattered.
+	MonteCarloShuffle = "shuffle"
+	// MonteCarloResample draws as many trades as the run had, with
+	// replacement.
+	MonteCarloResample = "resample"
+	// MonteCarloBootstrap rebuilds the equity curve from blocks of per-bar
+	// returns drawn with replacement.
+	MonteCarloBootstrap = "bootstrap"
+)
+
+// MonteCarloOptions configure a Monte Carlo analysis.
+type MonteCarloOptions struct {
+	Method string `json:"method"`
+	Runs   int    `json:"runs"`
+	Seed   int64  `json:"seed"`
+	// Ruin is the loss of starting equity, as a fraction, that counts as
+	// ruin; 0.5 means a path that ever halves the account.
+	Ruin float64 `json:"ruin"`
+	// Confidence is the level of the reported intervals, e.g. 0.95.
+	Confidence float64 `json:"confidence"`
+	// Block is the bootstrap block length in bars; longer blocks keep more
+	// of the returns' autocorrelation.
+	Block int `json:"block,omitempty"`
+}
+
+// DefaultMonteCarlo is 1000 shuffles with ruin at a 50% loss and 95%
+// intervals.
+func DefaultMonteCarlo() MonteCarloOptions {
+	return MonteCarloOptions{Method: MonteCarloShuffle, Runs: 1000, Seed: 1, Ruin: 0.5, Confidence: 0.95, Block: 1}
+}
+
+// Distribution summarises one statistic across the simulated paths. Lower
+// and Upper bound the central Confidence share of the paths.
+type Distribution struct {
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stddev"`
+	Min    float64 `json:"min"`
+	P5     float64 `json:"p5"`
+	P25    float64 `json:"p25"`
+	Median float64 `json:"median"`
+	P75    float64 `json:"p75"`
+	P95    float64 `json:"p95"`
+	Max    float64 `json:"max"`
+	Lower  float64 `json:"ci_lower"`
+	Upper  float64 `json:"ci_upper"`
+}
+
+// MonteCarloResult is the outcome of MonteCarlo.
+type MonteCarloResult struct {
+	Options     MonteCarloOptions `json:"options"`
+	Samples     int               `json:"samples"`
+	StartEquity float64           `json:"start_equity"`
+	// Actual is the backtest's own final equity and max drawdown.
+	ActualEquity   float64      `json:"actual_final_equity"`
+	ActualDrawdown float64      `json:"actual_max_drawdown"`
+	FinalEquity    Distribution `json:"final_equity"`
+	TotalReturn    Distribution `json:"total_return"`
+	MaxDrawdown    Distribution `json:"max_drawdown"`
+	// RiskOfRuin is the share of paths that lost Ruin of the start; the
+	// interval is the Wilson score interval at Confidence.
+	RiskOfRuin      float64 `json:"risk_of_ruin"`
+	RiskOfRuinLower float64 `json:"risk_of_ruin_ci_lower"`
+	RiskOfRuinUpper float64 `json:"risk_of_ruin_ci_upper"`
+	// ProbabilityOfLoss is the share of paths ending below the start.
+	ProbabilityOfLoss float64 `json:"probability_of_loss"`
+}
+
+// MonteCarlo simulates alternative histories of r. Trade methods compound
+// each trade's return on the account equity at its exit; the bootstrap
+// uses the bar-by-bar returns of the equity curve.
+func MonteCarlo(r Report, opts MonteCarloOptions) (MonteCarloResult, error) {
+	res := MonteCarloResult{Options: opts, StartEquity: r.Metrics.StartEquity,
+		ActualEquity: r.Metrics.FinalEquity, ActualDrawdown: r.Metrics.MaxDrawdown}
+	if opts.Runs <= 0 {
+		return res, errors.New("monte carlo needs a positive number of runs")
+	}
+	if opts.Ruin <= 0 || opts.Ruin > 1 {
+		return res, errors.New("ruin must be a loss fraction in (0, 1]")
+	}
+	if opts.Confidence <= 0 || opts.Confidence >= 1 {
+		return res, errors.New("confidence must be in (0, 1)")
+	}
+	if r.Metrics.StartEquity <= 0 {
+		return res, errors.New("monte carlo needs a positive starting equity")
+	}
+
+	var returns []float64
+	block := 1
+	switch opts.Method {
+	case MonteCarloShuffle, MonteCarloResample:
+		equity := r.Metrics.StartEquity
+		for _, t := range r.Trades {
+			returns = append(returns, t.PnL/equity)
+			equity += t.PnL
+		}
+		if len(returns) == 0 {
+			return res, errors.New("no closed trades to resample")
+		}
+	case MonteCarloBootstrap:
+		prev := r.Metrics.StartEquity
+		for _, p := range r.Equity {
+			if prev > 0 {
+				returns = append(returns, p.Equity/prev-1)
+			}
+			prev = p.Equity
+		}
+		if len(returns) < 2 {
+			return res, errors.New("equity curve too short to bootstrap")
+		}
+		if opts.Block > 1 {
+			block = opts.Block
+		}
+		if block > len(returns) {
+			block = len(returns)
+		}
+	default:
+		return res, fmt.Errorf("unknown monte carlo method %q (have %s, %s, %s)", opts.Method, MonteCarloBootstrap, MonteCarloResample, MonteCarloShuffle)
+	}
+	res.Samples = len(returns)
+
+	rng := rand.New(rand.NewSource(opts.Seed))
+	finals := make([]float64, opts.Runs)
+	totals := make([]float64, opts.Runs)
+	drawdowns := make([]float64, opts.Runs)
+	path := make([]float64, len(returns))
+	ruined, losses := 0, 0
+	floor := r.Metrics.StartEquity * (1 - opts.Ruin)
+	for run := 0; run < opts.Runs; run++ {
+		switch opts.Method {
+		case MonteCarloShuffle:
+			copy(path, returns)
+			rng.Shuffle(len(path), func(i, j int) { path[i], path[j] = path[j], path[i] })
+		case MonteCarloResample:
+			for i := range path {
+				path[i] = returns[rng.Intn(len(returns))]
+			}
+		case MonteCarloBootstrap:
+			for i := 0; i < len(path); {
+				start := rng.Intn(len(returns) - block + 1)
+				for k := 0; k < block && i < len(path); k++ {
+					path[i] = returns[start+k]
+					i++
+				}
+			}
+		}
+		equity, peak, dd, ruin := r.Metrics.StartEquity, r.Metrics.StartEquity, 0.0, false
+		for _, ret := range path {
+			equity = math.Max(equity*(1+ret), 0)
+			peak = math.Max(peak, equity)
+			dd = math.Max(dd, 1-equity/peak)
+			ruin = ruin || equity <= floor
+		}
+		finals[run], totals[run], drawdowns[run] = equity, equity/r.Metrics.StartEquity-1, dd
+		if ruin {
+			ruined++
+		}
+		if equity < r.Metrics.StartEquity {
+			losses++
+		}
+	}
+
+	res.FinalEquity = distribution(finals, opts.Confidence)
+	res.TotalReturn = distribution(totals, opts.Confidence)
+	res.MaxDrawdown = distribution(drawdowns, opts.Confidence)
+	n := float64(opts.Runs)
+	res.RiskOfRuin = float64(ruined) / n
+	res.RiskOfRuinLower, res.RiskOfRuinUpper = wilson(ruined, opts.Runs, opts.Confidence)
+	res.ProbabilityOfLoss = float64(losses) / n
+	return res, nil
+}
+
+// distribution sorts values and summarises them.
+func distribution(values []float64, confidence float64) Distribution {
+	sort.Float64s(values)
+	var d Distribution
+	for _, v := range values {
+		d.Mean += v
+	}
+	d.Mean /= float64(len(values))
+	if len(values) > 1 {
+		var variance float64
+		for _, v := range values {
+			variance += (v - d.Mean) * (v - d.Mean)
+		}
+		d.StdDev = math.Sqrt(variance / float64(len(values)-1))
+	}
+	tail := (1 - confidence) / 2
+	d.Min, d.Max = values[0], values[len(values)-1]
+	d.P5, d.P25, d.Median = quantile(values, 0.05), quantile(values, 0.25), quantile(values, 0.5)
+	d.P75, d.P95 = quantile(values, 0.75), quantile(values, 0.95)
+	d.Lower, d.Upper = quantile(values, tail), quantile(values, 1-tail)
+	return d
+}
+
+// quantile interpolates the q-th quantile of sorted values.
+func quantile(sorted []float64, q float64) float64 {
+	pos := q * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	if lo >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	frac := pos - float64(lo)
+	return sorted[lo]*(1-frac) + sorted[lo+1]*frac
+}
+
+// wilson is the Wilson score interval of k successes in n trials.
+func wilson(k, n int, confidence float64) (lower, upper float64) {
+	z := normalQuantile(1 - (1-confidence)/2)
+	p, nf := float64(k)/float64(n), float64(n)
+	denom := 1 + z*z/nf
+	centre := (p + z*z/(2*nf)) / denom
+	half := z * math.Sqrt(p*(1-p)/nf+z*z/(4*nf*nf)) / denom
+	return math.Max(0, centre-half), math.Min(1, centre+half)
+}
+
+// normalQuantile inverts the standard normal CDF by bisection.
+func normalQuantile(p float64) float64 {
+	lo, hi := -10.0, 10.0
+	for i := 0; i < 100; i++ {
+		mid := (lo + hi) / 2
+		if 0.5*math.Erfc(-mid/math.Sqrt2) < p {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2
+}
+
+// WriteText writes a human-readable summary.
+func (m MonteCarloResult) WriteText(w io.Writer) error {
+	var b strings.Builder
+	o := m.Options
+	conf := o.Confidence * 100
+	fmt.Fprintf(&b, "Monte Carlo: %d %s runs over %d samples (seed %d)\n", o.Runs, o.Method, m.Samples, o.Seed)
+	fmt.Fprintf(&b, "%-14s %12s %12s %12s %12s %12s %12s\n", "", "actual", "mean", "median", fmt.Sprintf("%g%% low", conf), fmt.Sprintf("%g%% high", conf), "worst")
+	fmt.Fprintf(&b, "%-14s %12.2f %12.2f %12.2f %12.2f %12.2f %12.2f\n", "final equity",
+		m.ActualEquity, m.FinalEquity.Mean, m.FinalEquity.Median, m.FinalEquity.Lower, m.FinalEquity.Upper, m.FinalEquity.Min)
+	fmt.Fprintf(&b, "%-14s %11.2f%% %11.2f%% %11.2f%% %11.2f%% %11.2f%% %11.2f%%\n", "max drawdown",
+		m.ActualDrawdown*100, m.MaxDrawdown.Mean*100, m.MaxDrawdown.Median*100, m.MaxDrawdown.Lower*100, m.MaxDrawdown.Upper*100, m.MaxDrawdown.Max*100)
+	fmt.Fprintf(&b, "risk of ruin (losing %g%%): %.2f%% (%g%% CI %.2f%%-%.2f%%)\n",
+		o.Ruin*100, m.RiskOfRuin*100, conf, m.RiskOfRuinLower*100, m.RiskOfRuinUpper*100)
+	fmt.Fprintf(&b, "probability of ending below the start: %.2f%%\n", m.ProbabilityOfLoss*100)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
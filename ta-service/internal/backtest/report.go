@@ -0,0 +1,300 @@
+package backtest
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const year = 365 * 24 * time.Hour
+
+// Trade is a round trip in one symbol from flat back to flat.
+type Trade struct {
+	Pair       string    `json:"pair"`
+	EntryTime  time.Time `json:"entry_time"`
+	ExitTime   time.Time `json:"exit_time"`
+	EntryPrice float64   `json:"entry_price"`
+	ExitPrice  float64   `json:"exit_price"`
+	Qty        float64   `json:"qty"`
+	Fees       float64   `json:"fees"`
+	// PnL is net of fees; Return is PnL over the cost of the entries.
+	PnL    float64 `json:"pnl"`
+	Return float64 `json:"return"`
+	Reason string  `json:"exit_reason,omitempty"`
+}
+
+// Metrics summarises a run. Returns and drawdowns are fractions; Sharpe and
+// Sortino are annualised from per-bar returns with a zero risk-free rate.
+type Metrics struct {
+	StartEquity float64 `json:"start_equity"`
+	FinalEquity float64 `json:"final_equity"`
+	TotalReturn float64 `json:"total_return"`
+	CAGR        float64 `json:"cagr"`
+	Sharpe      float64 `json:"sharpe"`
+	Sortino     float64 `json:"sortino"`
+	MaxDrawdown float64 `json:"max_drawdown"`
+	// MaxDrawdownDuration is the longest time spent below a prior peak.
+	MaxDrawdownDuration time.Duration `json:"-"`
+	MaxDrawdownSeconds  int64         `json:"max_drawdown_seconds"`
+	Trades              int           `json:"trades"`
+	WinRate             float64       `json:"win_rate"`
+	// ProfitFactor is gross profit over gross loss; nil without losing trades.
+	ProfitFactor *float64  `json:"profit_factor,omitempty"`
+	AvgTrade     float64   `json:"avg_trade"`
+	AvgReturn    float64   `json:"avg_trade_return"`
+	Exposure     float64   `json:"exposure"`
+	Fees         float64   `json:"fees"`
+	Start        time.Time `json:"start"`
+	End          time.Time `json:"end"`
+	// Rejected counts orders the risk gate refused.
+	Rejected int `json:"rejected_orders"`
+}
+
+// DrawdownPoint is the equity curve with its distance below the prior peak.
+type DrawdownPoint struct {
+	EquityPoint
+	Drawdown float64 `json:"drawdown"`
+}
+
+// Report is the analysed outcome of a run.
+type Report struct {
+	Strategy string          `json:"strategy"`
+	Pairs    []string        `json:"pairs,omitempty"`
+	Params   Params          `json:"params,omitempty"`
+	Metrics  Metrics         `json:"metrics"`
+	Trades   []Trade         `json:"trades"`
+	Rejected []Rejection     `json:"rejected,omitempty"`
+	Equity   []DrawdownPoint `json:"equity"`
+}
+
+// NewReport computes metrics, trades and the drawdown curve of res.
+func NewReport(res Result, params Params) Report {
+	r := Report{Strategy: res.Strategy, Pairs: res.Pairs, Params: params, Trades: Trades(res.Fills)}
+	m := &r.Metrics
+	m.StartEquity, m.FinalEquity, m.Fees = res.StartCash, res.FinalCash, res.Fees
+	r.Rejected, m.Rejected = res.Rejected, len(res.Rejected)
+	if res.StartCash > 0 {
+		m.TotalReturn = res.FinalCash/res.StartCash - 1
+	}
+
+	peak, exposed := res.StartCash, 0
+	var underwaterSince time.Time
+	returns := make([]float64, 0, len(res.Equity))
+	prev := res.StartCash
+	for _, p := range res.Equity {
+		if p.Equity >= peak {
+			peak = p.Equity
+			underwaterSince = time.Time{}
+		} else if underwaterSince.IsZero() {
+			underwaterSince = p.Time
+		}
+		dd := 0.0
+		if peak > 0 {
+			dd = 1 - p.Equity/peak
+		}
+		m.MaxDrawdown = math.Max(m.MaxDrawdown, dd)
+		if !underwaterSince.IsZero() {
+			if d := p.Time.Sub(underwaterSince); d > m.MaxDrawdownDuration {
+				m.MaxDrawdownDuration = d
+			}
+		}
+		if p.Invested > 0 {
+			exposed++
+		}
+		if prev > 0 {
+			returns = append(returns, p.Equity/prev-1)
+		}
+		prev = p.Equity
+		r.Equity = append(r.Equity, DrawdownPoint{EquityPoint: p, Drawdown: dd})
+	}
+	m.MaxDrawdownSeconds = int64(m.MaxDrawdownDuration / time.Second)
+	if n := len(res.Equity); n > 0 {
+		bar := barDuration(res.Equity)
+		m.Start, m.End = res.Equity[0].Time, res.Equity[n-1].Time.Add(bar)
+		m.Exposure = float64(exposed) / float64(n)
+		if span := m.End.Sub(m.Start); span > 0 && res.StartCash > 0 && res.FinalCash > 0 {
+			// Annualising a short run can overflow; clamp so JSON stays valid.
+			m.CAGR = math.Min(math.Pow(res.FinalCash/res.StartCash, float64(year)/float64(span))-1, math.MaxFloat64)
+		}
+		if bar > 0 {
+			m.Sharpe, m.Sortino = ratios(returns, float64(year)/float64(bar))
+		}
+	}
+
+	var gross, loss float64
+	for _, t := range r.Trades {
+		m.AvgTrade += t.PnL
+		m.AvgReturn += t.Return
+		if t.PnL > 0 {
+			m.WinRate++
+			gross += t.PnL
+		} else {
+			loss -= t.PnL
+		}
+	}
+	if m.Trades = len(r.Trades); m.Trades > 0 {
+		n := float64(m.Trades)
+		m.AvgTrade /= n
+		m.AvgReturn /= n
+		m.WinRate /= n
+	}
+	if loss > 0 {
+		pf := gross / loss
+		m.ProfitFactor = &pf
+	}
+	return r
+}
+
+// Trades groups fills into round trips per symbol, in order of exit. A
+// trade still open at the end of the fills is left out.
+func Trades(fills []Fill) []Trade {
+	type open struct {
+		Trade
+		held, bought, cost, sold, proceeds float64
+	}
+	var out []Trade
+	trades := make(map[string]*open)
+	for _, f := range fills {
+		t := trades[f.Pair]
+		if f.Side == Buy {
+			if t == nil || t.held == 0 {
+				t = &open{Trade: Trade{Pair: f.Pair, EntryTime: f.Time}}
+				trades[f.Pair] = t
+			}
+			t.held += f.Qty
+			t.bought += f.Qty
+			t.cost += f.Qty * f.Price
+		} else {
+			if t == nil || t.held == 0 {
+				continue
+			}
+			t.held -= f.Qty
+			t.sold += f.Qty
+			t.proceeds += f.Qty * f.Price
+		}
+		t.Fees += f.Fee
+		if f.Side == Sell && t.held <= 1e-12 {
+			t.held = 0
+			t.ExitTime, t.Reason, t.Qty = f.Time, f.Reason, t.bought
+			t.EntryPrice, t.ExitPrice = t.cost/t.bought, t.proceeds/t.sold
+			t.PnL = t.proceeds - t.cost - t.Fees
+			t.Return = t.PnL / t.cost
+			out = append(out, t.Trade)
+		}
+	}
+	return out
+}
+
+// barDuration is the median spacing of the equity curve.
+func barDuration(points []EquityPoint) time.Duration {
+	if len(points) < 2 {
+		return 0
+	}
+	gaps := make([]time.Duration, 0, len(points)-1)
+	for i := 1; i < len(points); i++ {
+		gaps = append(gaps, points[i].Time.Sub(points[i-1].Time))
+	}
+	sort.Slice(gaps, func(i, j int) bool { return gaps[i] < gaps[j] })
+	return gaps[len(gaps)/2]
+}
+
+// ratios returns the annualised Sharpe and Sortino ratios of returns.
+func ratios(returns []float64, periodsPerYear float64) (sharpe, sortino float64) {
+	if len(returns) < 2 {
+		return 0, 0
+	}
+	var mean float64
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+	var variance, downside float64
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+		if r < 0 {
+			downside += r * r
+		}
+	}
+	scale := math.Sqrt(periodsPerYear)
+	if sd := math.Sqrt(variance / float64(len(returns)-1)); sd > 0 {
+		sharpe = mean / sd * scale
+	}
+	if dd := math.Sqrt(downside / float64(len(returns))); dd > 0 {
+		sortino = mean / dd * scale
+	}
+	return sharpe, sortino
+}
+
+// WriteJSON writes the full report.
+func (r Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteTradesCSV writes the trade list.
+func (r Report) WriteTradesCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"pair", "entry_time", "exit_time", "entry_price", "exit_price", "qty", "fees", "pnl", "return", "exit_reason"})
+	for _, t := range r.Trades {
+		cw.Write([]string{
+			t.Pair, t.EntryTime.UTC().Format(time.RFC3339), t.ExitTime.UTC().Format(time.RFC3339),
+			formatFloat(t.EntryPrice), formatFloat(t.ExitPrice), formatFloat(t.Qty),
+			formatFloat(t.Fees), formatFloat(t.PnL), formatFloat(t.Return), t.Reason,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteEquityCSV writes the equity and drawdown curve.
+func (r Report) WriteEquityCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"time", "equity", "invested", "drawdown"})
+	for _, p := range r.Equity {
+		cw.Write([]string{p.Time.UTC().Format(time.RFC3339), formatFloat(p.Equity), formatFloat(p.Invested), formatFloat(p.Drawdown)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteFiles writes report.json, trades.csv, equity.csv and report.html
+// into dir, creating it if needed.
+func (r Report) WriteFiles(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	outputs := []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{"report.json", r.WriteJSON},
+		{"trades.csv", r.WriteTradesCSV},
+		{"equity.csv", r.WriteEquityCSV},
+		{"report.html", r.WriteHTML},
+	}
+	for _, o := range outputs {
+		f, err := os.Create(filepath.Join(dir, o.name))
+		if err != nil {
+			return err
+		}
+		err = o.write(f)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
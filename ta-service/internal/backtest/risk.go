@@ -0,0 +1,90 @@
+package backtest
+
+import (
+	"math"
+	"time"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/candles"
+)
+
+// Gate is a pre-trade risk check, such as the live risk engine. Check sees
+// the simulated time the order is sent and books its exposure when it
+// approves; Release frees exposure that settled or will not fill.
+type Gate interface {
+	Check(at time.Time, pair string, side Side, qty, price float64) error
+	Release(pair string, side Side, qty, price float64)
+}
+
+// Rejection is a strategy order the gate refused.
+type Rejection struct {
+	Time   time.Time `json:"time"`
+	Pair   string    `json:"pair"`
+	Side   Side      `json:"side"`
+	Type   OrderType `json:"type"`
+	Qty    float64   `json:"qty"`
+	Price  float64   `json:"price"`
+	Reason string    `json:"reason"`
+}
+
+// exposure is the size and price the gate approved an order at.
+type exposure struct {
+	qty, price float64
+}
+
+// check passes q through the gate at the close of c, sizing orders without
+// a quantity the way fill will. It records rejections and reports whether
+// the order may be sent.
+func (a *account) check(l *leg, q *queued, c candles.Candle) bool {
+	if a.gate == nil {
+		return true
+	}
+	price := c.Close
+	if q.Type != Market {
+		price = q.Price
+	}
+	qty := q.Qty
+	switch {
+	case qty > 0:
+	case q.Side == Buy:
+		qty = math.Min(a.cash, a.value()*a.cfg.PositionSize) / price
+	default:
+		qty = l.qty
+	}
+	if qty <= 0 || price <= 0 {
+		// Nothing to send; the order will not fill either.
+		return true
+	}
+	at := c.Start
+	if d, ok := candles.IntervalDuration(c.Interval); ok {
+		at = at.Add(d)
+	}
+	if err := a.gate.Check(at, l.pair, q.Side, qty, price); err != nil {
+		a.rejected = append(a.rejected, Rejection{Time: c.Start, Pair: l.pair, Side: q.Side, Type: q.Type, Qty: qty, Price: price, Reason: err.Error()})
+		return false
+	}
+	q.approved = exposure{qty: qty, price: price}
+	return true
+}
+
+// release frees an approval that will not fill.
+func (a *account) release(l *leg, side Side, e exposure) {
+	if a.gate != nil && e.qty > 0 {
+		a.gate.Release(l.pair, side, e.qty, e.price)
+	}
+}
+
+// settle books a filled buy's approved exposure against the position until
+// it is sold, and frees whatever was approved but not filled. Sells only
+// free their own approval; fill settles the position they close.
+func (a *account) settle(l *leg, q queued, filled float64) {
+	e := q.approved
+	if a.gate == nil || e.qty <= 0 {
+		return
+	}
+	if q.Side == Buy && filled > 0 {
+		held := math.Min(filled, e.qty)
+		l.booked += held * e.price
+		e.qty -= held
+	}
+	a.release(l, q.Side, e)
+}
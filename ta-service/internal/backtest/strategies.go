@@ -0,0 +1,267 @@
+package backtest
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/candles"
+	"github.com/example/tg-crypto-trader/ta-service/internal/indicators"
+)
+
+func init() {
+	Register(Factory{
+		Name:        "rsi",
+		Description: "mean reversion: buy when RSI falls below oversold, sell above overbought or on the stop",
+		Defaults:    Params{"period": 14, "oversold": 30, "overbought": 70, "stop": 0.05},
+		New:         newRSIReversion,
+	})
+	Register(Factory{
+		Name:        "macd",
+		Description: "buy when the MACD line crosses above its signal line, sell when it crosses below",
+		Defaults:    Params{"fast": 12, "slow": 26, "signal": 9, "stop": 0},
+		New:         newMACDCross,
+	})
+	Register(Factory{
+		Name:        "bollinger",
+		Description: "breakout: buy when the close breaks above the upper band, sell below the middle band",
+		Defaults:    Params{"period": 20, "stddev": 2, "stop": 0},
+		New:         newBollingerBreakout,
+	})
+	Register(Factory{
+		Name:        "ema",
+		Description: "buy when the fast EMA crosses above the slow EMA, sell when it crosses below",
+		Defaults:    Params{"fast": 9, "slow": 21, "stop": 0},
+		New:         newEMACross,
+	})
+}
+
+// period reads a positive whole-number parameter.
+func period(p Params, name string) (int, error) {
+	v := p[name]
+	if v < 1 || v != math.Trunc(v) {
+		return 0, fmt.Errorf("%s must be a positive whole number, got %g", name, v)
+	}
+	return int(v), nil
+}
+
+// stopLoss reads the stop parameter, a fraction below the entry price;
+// 0 disables it.
+func stopLoss(p Params) (float64, error) {
+	if v := p["stop"]; v < 0 || v >= 1 {
+		return 0, fmt.Errorf("stop must be a fraction in [0, 1), got %g", v)
+	}
+	return p["stop"], nil
+}
+
+// stopper keeps a resting stop order offset (a fraction) below the entry of
+// each new position.
+type stopper struct {
+	offset float64
+	placed bool
+}
+
+// orders places the stop once a position is open.
+func (s *stopper) orders(pos Position) []Order {
+	if pos.Qty == 0 {
+		s.placed = false
+		return nil
+	}
+	if s.offset == 0 || s.placed {
+		return nil
+	}
+	s.placed = true
+	return []Order{{Side: Sell, Type: Stop, Price: pos.EntryPrice * (1 - s.offset), Reason: "stop"}}
+}
+
+// exit closes the position at market after cancelling the stop.
+func (s *stopper) exit(reason string) []Order {
+	return []Order{{Type: Cancel}, {Side: Sell, Reason: reason}}
+}
+
+// crossover tracks the sign of a difference between two lines.
+type crossover struct {
+	prev   float64
+	primed bool
+}
+
+// update returns 1 when diff turns positive, -1 when it turns negative and
+// 0 otherwise, using the same rule as the filter crosses_* operators.
+func (x *crossover) update(diff float64) int {
+	prev, primed := x.prev, x.primed
+	x.prev, x.primed = diff, true
+	switch {
+	case !primed:
+		return 0
+	case prev <= 0 && diff > 0:
+		return 1
+	case prev >= 0 && diff < 0:
+		return -1
+	}
+	return 0
+}
+
+type rsiReversion struct {
+	rsi        indicators.Streamer
+	oversold   float64
+	overbought float64
+	stop       stopper
+}
+
+func newRSIReversion(p Params) (Strategy, error) {
+	n, err := period(p, "period")
+	if err != nil {
+		return nil, err
+	}
+	stop, err := stopLoss(p)
+	if err != nil {
+		return nil, err
+	}
+	if p["oversold"] >= p["overbought"] {
+		return nil, fmt.Errorf("oversold (%g) must be below overbought (%g)", p["oversold"], p["overbought"])
+	}
+	return &rsiReversion{rsi: indicators.NewRSIStream(n), oversold: p["oversold"], overbought: p["overbought"], stop: stopper{offset: stop}}, nil
+}
+
+func (s *rsiReversion) Name() string { return "rsi" }
+
+func (s *rsiReversion) OnCandle(c candles.Candle, pos Position) []Order {
+	s.rsi.Update(c)
+	rsi, ok := s.rsi.Result()
+	if !ok {
+		return nil
+	}
+	switch {
+	case pos.CanBuy() && rsi.Value < s.oversold:
+		return []Order{{Side: Buy, Reason: fmt.Sprintf("rsi %.1f < %g", rsi.Value, s.oversold)}}
+	case pos.CanSell() && rsi.Value > s.overbought:
+		return s.stop.exit(fmt.Sprintf("rsi %.1f > %g", rsi.Value, s.overbought))
+	}
+	return s.stop.orders(pos)
+}
+
+type macdCross struct {
+	macd  indicators.Streamer
+	cross crossover
+	stop  stopper
+}
+
+func newMACDCross(p Params) (Strategy, error) {
+	fast, err := period(p, "fast")
+	if err != nil {
+		return nil, err
+	}
+	slow, err := period(p, "slow")
+	if err != nil {
+		return nil, err
+	}
+	signal, err := period(p, "signal")
+	if err != nil {
+		return nil, err
+	}
+	if fast >= slow {
+		return nil, fmt.Errorf("fast (%d) must be below slow (%d)", fast, slow)
+	}
+	stop, err := stopLoss(p)
+	if err != nil {
+		return nil, err
+	}
+	return &macdCross{macd: indicators.NewMACDStream(fast, slow, signal), stop: stopper{offset: stop}}, nil
+}
+
+func (s *macdCross) Name() string { return "macd" }
+
+func (s *macdCross) OnCandle(c candles.Candle, pos Position) []Order {
+	s.macd.Update(c)
+	macd, ok := s.macd.Result()
+	if !ok {
+		return nil
+	}
+	switch dir := s.cross.update(macd.Components["histogram"]); {
+	case dir > 0 && pos.CanBuy():
+		return []Order{{Side: Buy, Reason: "macd crossed above signal"}}
+	case dir < 0 && pos.CanSell():
+		return s.stop.exit("macd crossed below signal")
+	}
+	return s.stop.orders(pos)
+}
+
+type bollingerBreakout struct {
+	bands indicators.Streamer
+	stop  stopper
+}
+
+func newBollingerBreakout(p Params) (Strategy, error) {
+	n, err := period(p, "period")
+	if err != nil {
+		return nil, err
+	}
+	if p["stddev"] <= 0 {
+		return nil, fmt.Errorf("stddev must be positive, got %g", p["stddev"])
+	}
+	stop, err := stopLoss(p)
+	if err != nil {
+		return nil, err
+	}
+	return &bollingerBreakout{bands: indicators.NewBollingerStream(n, p["stddev"]), stop: stopper{offset: stop}}, nil
+}
+
+func (s *bollingerBreakout) Name() string { return "bollinger" }
+
+func (s *bollingerBreakout) OnCandle(c candles.Candle, pos Position) []Order {
+	s.bands.Update(c)
+	bands, ok := s.bands.Result()
+	if !ok {
+		return nil
+	}
+	switch {
+	case pos.CanBuy() && c.Close > bands.Components["upper"]:
+		return []Order{{Side: Buy, Reason: "close above upper band"}}
+	case pos.CanSell() && c.Close < bands.Components["middle"]:
+		return s.stop.exit("close below middle band")
+	}
+	return s.stop.orders(pos)
+}
+
+type emaCross struct {
+	fast, slow indicators.Streamer
+	cross      crossover
+	stop       stopper
+}
+
+func newEMACross(p Params) (Strategy, error) {
+	fast, err := period(p, "fast")
+	if err != nil {
+		return nil, err
+	}
+	slow, err := period(p, "slow")
+	if err != nil {
+		return nil, err
+	}
+	if fast >= slow {
+		return nil, fmt.Errorf("fast (%d) must be below slow (%d)", fast, slow)
+	}
+	stop, err := stopLoss(p)
+	if err != nil {
+		return nil, err
+	}
+	return &emaCross{fast: indicators.NewEMAStream(fast), slow: indicators.NewEMAStream(slow), stop: stopper{offset: stop}}, nil
+}
+
+func (s *emaCross) Name() string { return "ema" }
+
+func (s *emaCross) OnCandle(c candles.Candle, pos Position) []Order {
+	s.fast.Update(c)
+	s.slow.Update(c)
+	fast, _ := s.fast.Result()
+	slow, ok := s.slow.Result()
+	if !ok {
+		return nil
+	}
+	switch dir := s.cross.update(fast.Value - slow.Value); {
+	case dir > 0 && pos.CanBuy():
+		return []Order{{Side: Buy, Reason: "fast ema crossed above slow"}}
+	case dir < 0 && pos.CanSell():
+		return s.stop.exit("fast ema crossed below slow")
+	}
+	return s.stop.orders(pos)
+}
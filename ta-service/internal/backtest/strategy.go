@@ -0,0 +1,156 @@
+// Package backtest replays candles through trading strategies and simulates
+// the resulting orders.
+package backtest
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/candles"
+)
+
+// ErrUnknownStrategy is returned for names missing from the registry.
+var ErrUnknownStrategy = errors.New("unknown strategy")
+
+// Side is the direction of an order.
+type Side string
+
+// Order sides.
+const (
+	Buy  Side = "buy"
+	Sell Side = "sell"
+)
+
+// OrderType selects how an order executes.
+type OrderType string
+
+// Order types. Limit and stop orders rest until the bar range reaches
+// Price; Cancel withdraws every resting order.
+const (
+	Market OrderType = "market"
+	Limit  OrderType = "limit"
+	Stop   OrderType = "stop"
+	Cancel OrderType = "cancel"
+)
+
+// Order asks the backtester to trade. An empty Type is a market order. A
+// zero Qty buys with all available cash or sells the whole position.
+type Order struct {
+	Side   Side
+	Type   OrderType
+	Qty    float64
+	Price  float64
+	Reason string
+}
+
+// Position is the account state a strategy sees before each candle.
+type Position struct {
+	Qty        float64
+	EntryPrice float64
+	Cash       float64
+	// Pending counts market orders sent but not filled yet; Resting counts
+	// open limit and stop orders.
+	Pending int
+	Resting int
+}
+
+// CanBuy reports whether the account is flat with nothing in flight.
+func (p Position) CanBuy() bool { return p.Qty == 0 && p.Pending == 0 }
+
+// CanSell reports whether there is a position and no market order in flight.
+func (p Position) CanSell() bool { return p.Qty > 0 && p.Pending == 0 }
+
+// Strategy turns closed candles into orders. Implementations keep their own
+// indicator state and are used for a single run.
+type Strategy interface {
+	Name() string
+	OnCandle(c candles.Candle, pos Position) []Order
+}
+
+// Params holds numeric strategy parameters by name.
+type Params map[string]float64
+
+// Factory builds a registered strategy from parameters.
+type Factory struct {
+	Name        string
+	Description string
+	// Defaults lists every accepted parameter with its default value.
+	Defaults Params
+	New      func(p Params) (Strategy, error)
+}
+
+var registry = make(map[string]Factory)
+
+// Register adds a strategy factory; it panics on duplicate names.
+func Register(f Factory) {
+	if _, dup := registry[f.Name]; dup {
+		panic("backtest: strategy registered twice: " + f.Name)
+	}
+	registry[f.Name] = f
+}
+
+// Lookup returns the factory registered under name.
+func Lookup(name string) (Factory, bool) {
+	f, ok := registry[strings.ToLower(name)]
+	return f, ok
+}
+
+// Factories returns the registered factories sorted by name.
+func Factories() []Factory {
+	out := make([]Factory, 0, len(registry))
+	for _, f := range registry {
+		out = append(out, f)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// New builds the named strategy, filling unset parameters from its defaults
+// and rejecting parameters it does not know.
+func New(name string, params Params) (Strategy, error) {
+	f, ok := Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownStrategy, name)
+	}
+	merged, err := f.Resolve(params)
+	if err != nil {
+		return nil, err
+	}
+	return f.New(merged)
+}
+
+// Resolve merges params over the defaults.
+func (f Factory) Resolve(params Params) (Params, error) {
+	merged := make(Params, len(f.Defaults))
+	for k, v := range f.Defaults {
+		merged[k] = v
+	}
+	for k, v := range params {
+		if _, ok := f.Defaults[k]; !ok {
+			return nil, fmt.Errorf("strategy %s has no parameter %q (have %s)", f.Name, k, strings.Join(f.Defaults.Names(), ", "))
+		}
+		merged[k] = v
+	}
+	return merged, nil
+}
+
+// Names returns the parameter names in sorted order.
+func (p Params) Names() []string {
+	names := make([]string, 0, len(p))
+	for k := range p {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// String formats the parameters as name=value pairs.
+func (p Params) String() string {
+	parts := make([]string, 0, len(p))
+	for _, k := range p.Names() {
+		parts = append(parts, fmt.Sprintf("%s=%g", k, p[k]))
+	}
+	return strings.Join(parts, " ")
+}
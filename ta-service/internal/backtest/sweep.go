@@ -0,0 +1,423 @@
+package backtest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCandidates bounds the size of a parameter grid.
+const maxCandidates = 100000
+
+// Objective scores a run; higher is better.
+type Objective struct {
+	Name  string
+	Score func(m Metrics) float64
+}
+
+var objectives = map[string]func(Metrics) float64{
+	"sharpe":  func(m Metrics) float64 { return m.Sharpe },
+	"sortino": func(m Metrics) float64 { return m.Sortino },
+	"return":  func(m Metrics) float64 { return m.TotalReturn },
+	"cagr":    func(m Metrics) float64 { return m.CAGR },
+	// calmar is CAGR over max drawdown, or plain CAGR without a drawdown.
+	"calmar": func(m Metrics) float64 {
+		if m.MaxDrawdown == 0 {
+			return m.CAGR
+		}
+		return m.CAGR / m.MaxDrawdown
+	},
+	"drawdown": func(m Metrics) float64 { return -m.MaxDrawdown },
+}
+
+// ParseObjective returns the named objective: sharpe, sortino, return,
+// cagr, calmar or drawdown (smallest max drawdown first).
+func ParseObjective(name string) (Objective, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	score, ok := objectives[name]
+	if !ok {
+		names := make([]string, 0, len(objectives))
+		for k := range objectives {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		return Objective{}, fmt.Errorf("unknown objective %q (have %s)", name, strings.Join(names, ", "))
+	}
+	return Objective{Name: name, Score: score}, nil
+}
+
+// Range is a continuous interval for random search.
+type Range struct {
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+}
+
+// ParamSpace describes the parameter combinations to try. Without Samples
+// it is the full grid of Grid values; with Samples it draws that many
+// random combinations, picking Grid parameters from their values and Ranges
+// parameters uniformly. Parameters whose default and bounds are whole
+// numbers are drawn as whole numbers.
+type ParamSpace struct {
+	Grid    map[string][]float64 `json:"grid,omitempty"`
+	Ranges  map[string]Range     `json:"ranges,omitempty"`
+	Samples int                  `json:"samples,omitempty"`
+	Seed    int64                `json:"seed,omitempty"`
+}
+
+// ParseGrid reads "name=v1|v2|v3" or "name=min:max:step".
+func ParseGrid(spec string) (string, []float64, error) {
+	name, values, ok := strings.Cut(spec, "=")
+	name = strings.ToLower(strings.TrimSpace(name))
+	if !ok || name == "" {
+		return "", nil, fmt.Errorf("grid %q: want name=v1|v2 or name=min:max:step", spec)
+	}
+	if parts := strings.Split(values, ":"); len(parts) == 3 {
+		bounds, err := parseFloats(parts)
+		if err != nil {
+			return "", nil, fmt.Errorf("grid %s: %w", name, err)
+		}
+		lo, hi, step := bounds[0], bounds[1], bounds[2]
+		if step <= 0 || hi < lo || (hi-lo)/step > maxCandidates {
+			return "", nil, fmt.Errorf("grid %s: want min <= max and a positive step", name)
+		}
+		var out []float64
+		for i := 0; ; i++ {
+			v := lo + float64(i)*step
+			if v > hi+step*1e-9 {
+				break
+			}
+			out = append(out, math.Round(v*1e9)/1e9)
+		}
+		return name, out, nil
+	}
+	out, err := parseFloats(strings.Split(values, "|"))
+	if err != nil {
+		return "", nil, fmt.Errorf("grid %s: %w", name, err)
+	}
+	return name, out, nil
+}
+
+// ParseRange reads "name=min:max".
+func ParseRange(spec string) (string, Range, error) {
+	name, values, ok := strings.Cut(spec, "=")
+	name = strings.ToLower(strings.TrimSpace(name))
+	parts := strings.Split(values, ":")
+	if !ok || name == "" || len(parts) != 2 {
+		return "", Range{}, fmt.Errorf("range %q: want name=min:max", spec)
+	}
+	bounds, err := parseFloats(parts)
+	if err != nil || bounds[1] < bounds[0] {
+		return "", Range{}, fmt.Errorf("range %q: want numbers with min <= max", spec)
+	}
+	return name, Range{Min: bounds[0], Max: bounds[1]}, nil
+}
+
+func parseFloats(parts []string) ([]float64, error) {
+	out := make([]float64, 0, len(parts))
+	for _, p := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", p)
+		}
+		out = append(out, v)
+	}
+	return out, nil
+}
+
+// Candidates expands the space into parameter sets for f.
+func (s ParamSpace) Candidates(f Factory) ([]Params, error) {
+	names := make([]string, 0, len(s.Grid)+len(s.Ranges))
+	for name, values := range s.Grid {
+		if len(values) == 0 {
+			return nil, fmt.Errorf("grid %s has no values", name)
+		}
+		names = append(names, name)
+	}
+	for name := range s.Ranges {
+		if _, dup := s.Grid[name]; dup {
+			return nil, fmt.Errorf("%s is both a grid and a range", name)
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := f.Defaults[name]; !ok {
+			return nil, fmt.Errorf("strategy %s has no parameter %q (have %s)", f.Name, name, strings.Join(f.Defaults.Names(), ", "))
+		}
+	}
+	if len(names) == 0 {
+		return nil, errors.New("empty parameter space")
+	}
+	if s.Samples > 0 {
+		return s.sample(f, names), nil
+	}
+	if len(s.Ranges) > 0 {
+		return nil, errors.New("ranges need a number of random samples")
+	}
+
+	out := []Params{{}}
+	for _, name := range names {
+		if len(out)*len(s.Grid[name]) > maxCandidates {
+			return nil, fmt.Errorf("grid has more than %d combinations", maxCandidates)
+		}
+		next := make([]Params, 0, len(out)*len(s.Grid[name]))
+		for _, p := range out {
+			for _, v := range s.Grid[name] {
+				q := make(Params, len(p)+1)
+				for k, x := range p {
+					q[k] = x
+				}
+				q[name] = v
+				next = append(next, q)
+			}
+		}
+		out = next
+	}
+	return out, nil
+}
+
+func (s ParamSpace) sample(f Factory, names []string) []Params {
+	rng := rand.New(rand.NewSource(s.Seed))
+	seen := make(map[string]bool)
+	whole := func(v float64) bool { return v == math.Trunc(v) }
+	var out []Params
+	// Small discrete spaces run out of new combinations; stop after enough
+	// consecutive repeats.
+	for misses := 0; len(out) < s.Samples && misses < 100; {
+		p := make(Params, len(names))
+		for _, name := range names {
+			if values, ok := s.Grid[name]; ok {
+				p[name] = values[rng.Intn(len(values))]
+				continue
+			}
+			r := s.Ranges[name]
+			if whole(r.Min) && whole(r.Max) && whole(f.Defaults[name]) {
+				p[name] = r.Min + float64(rng.Int63n(int64(r.Max-r.Min)+1))
+			} else {
+				p[name] = r.Min + rng.Float64()*(r.Max-r.Min)
+			}
+		}
+		if key := p.String(); !seen[key] {
+			seen[key] = true
+			out = append(out, p)
+			misses = 0
+		} else {
+			misses++
+		}
+	}
+	return out
+}
+
+// SweepOptions control a parameter sweep.
+type SweepOptions struct {
+	Objective Objective
+	// Workers is the number of parallel runs; 0 uses every CPU.
+	Workers int
+}
+
+// Trial is one parameter set's outcome.
+type Trial struct {
+	Params  Params  `json:"params"`
+	Score   float64 `json:"score"`
+	Metrics Metrics `json:"metrics"`
+	Err     string  `json:"error,omitempty"`
+}
+
+// Sweep backtests every candidate in parallel and returns the trials best
+// first. Candidates the strategy rejects sort last with Err set.
+func Sweep(f Factory, candidates []Params, data []Series, cfg Config, opts SweepOptions) []Trial {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	trials := make([]Trial, len(candidates))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				trials[i] = runTrial(f, candidates[i], data, cfg, opts.Objective)
+			}
+		}()
+	}
+	for i := range candidates {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	sortTrials(trials)
+	return trials
+}
+
+func runTrial(f Factory, candidate Params, data []Series, cfg Config, obj Objective) Trial {
+	params, err := f.Resolve(candidate)
+	if err != nil {
+		return Trial{Params: candidate, Err: err.Error()}
+	}
+	res, err := RunPortfolio(data, f.factory(params), cfg)
+	if err != nil {
+		return Trial{Params: params, Err: err.Error()}
+	}
+	m := NewReport(res, params).Metrics
+	return Trial{Params: params, Score: obj.score(m), Metrics: m}
+}
+
+// score evaluates m, mapping undefined results to 0.
+func (o Objective) score(m Metrics) float64 {
+	v := o.Score(m)
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0
+	}
+	return v
+}
+
+func sortTrials(trials []Trial) {
+	sort.SliceStable(trials, func(i, j int) bool {
+		a, b := trials[i], trials[j]
+		if (a.Err == "") != (b.Err == "") {
+			return a.Err == ""
+		}
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.Params.String() < b.Params.String()
+	})
+}
+
+// factory binds params for RunPortfolio.
+func (f Factory) factory(params Params) func() (Strategy, error) {
+	return func() (Strategy, error) { return f.New(params) }
+}
+
+// WalkForwardOptions sizes the rolling windows in bars. Each window
+// optimises on InSample bars and validates the winner on the OutOfSample
+// bars that follow; windows advance by OutOfSample.
+type WalkForwardOptions struct {
+	InSample    int `json:"in_sample"`
+	OutOfSample int `json:"out_of_sample"`
+}
+
+// Window is one walk-forward step.
+type Window struct {
+	Start       time.Time `json:"start"`
+	Split       time.Time `json:"split"`
+	End         time.Time `json:"end"`
+	Params      Params    `json:"params"`
+	InSample    float64   `json:"in_sample_score"`
+	OutOfSample float64   `json:"out_of_sample_score"`
+	Metrics     Metrics   `json:"out_of_sample_metrics"`
+}
+
+// WalkForwardResult summarises the out-of-sample performance of parameters
+// chosen in-sample. An Efficiency well below 1 suggests overfitting.
+type WalkForwardResult struct {
+	Objective       string   `json:"objective"`
+	Windows         []Window `json:"windows"`
+	InSampleMean    float64  `json:"in_sample_mean"`
+	OutOfSampleMean float64  `json:"out_of_sample_mean"`
+	Efficiency      float64  `json:"efficiency"`
+	// Return compounds the out-of-sample total returns of every window.
+	Return float64 `json:"out_of_sample_return"`
+}
+
+// WalkForward runs rolling in-sample sweeps and out-of-sample validation.
+// Windows count bars on the combined timeline of every series.
+// Out-of-sample runs warm their indicators up on the in-sample bars.
+func WalkForward(f Factory, candidates []Params, data []Series, cfg Config, opts SweepOptions, wf WalkForwardOptions) (WalkForwardResult, error) {
+	res := WalkForwardResult{Objective: opts.Objective.Name}
+	if wf.InSample <= 0 || wf.OutOfSample <= 0 {
+		return res, errors.New("walk-forward windows must be positive")
+	}
+	if len(candidates) == 0 {
+		return res, errors.New("no parameter candidates")
+	}
+	timeline := Timeline(data)
+	if len(timeline) < wf.InSample+wf.OutOfSample {
+		return res, fmt.Errorf("walk-forward needs at least %d bars, have %d", wf.InSample+wf.OutOfSample, len(timeline))
+	}
+	growth := 1.0
+	for start := 0; start+wf.InSample+wf.OutOfSample <= len(timeline); start += wf.OutOfSample {
+		split, end := start+wf.InSample, start+wf.InSample+wf.OutOfSample
+		var until time.Time
+		if end < len(timeline) {
+			until = timeline[end]
+		}
+		trials := Sweep(f, candidates, Slice(data, timeline[start], timeline[split]), cfg, opts)
+		best := trials[0]
+		if best.Err != "" {
+			return res, fmt.Errorf("no valid parameters: %s", best.Err)
+		}
+		oosCfg := cfg
+		oosCfg.TradeFrom = timeline[split]
+		run, err := RunPortfolio(Slice(data, timeline[start], until), f.factory(best.Params), oosCfg)
+		if err != nil {
+			return res, err
+		}
+		m := NewReport(run, best.Params).Metrics
+		w := Window{
+			Start:       timeline[start],
+			Split:       timeline[split],
+			End:         timeline[end-1],
+			Params:      best.Params,
+			InSample:    best.Score,
+			OutOfSample: opts.Objective.score(m),
+			Metrics:     m,
+		}
+		res.Windows = append(res.Windows, w)
+		res.InSampleMean += w.InSample
+		res.OutOfSampleMean += w.OutOfSample
+		growth *= 1 + m.TotalReturn
+	}
+	n := float64(len(res.Windows))
+	res.InSampleMean /= n
+	res.OutOfSampleMean /= n
+	if res.InSampleMean != 0 {
+		res.Efficiency = res.OutOfSampleMean / res.InSampleMean
+	}
+	res.Return = growth - 1
+	return res, nil
+}
+
+// Timeline returns the distinct candle start times of every series, in
+// order.
+func Timeline(data []Series) []time.Time {
+	seen := make(map[int64]bool)
+	var out []time.Time
+	for _, s := range data {
+		for _, c := range s.Candles {
+			if k := c.Start.UnixNano(); !seen[k] {
+				seen[k] = true
+				out = append(out, c.Start)
+			}
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Before(out[j]) })
+	return out
+}
+
+// Slice cuts every series to candles starting in [from, to); a zero to
+// keeps the rest.
+func Slice(data []Series, from, to time.Time) []Series {
+	out := make([]Series, len(data))
+	for i, s := range data {
+		lo := sort.Search(len(s.Candles), func(j int) bool { return !s.Candles[j].Start.Before(from) })
+		hi := len(s.Candles)
+		if !to.IsZero() {
+			hi = sort.Search(len(s.Candles), func(j int) bool { return !s.Candles[j].Start.Before(to) })
+		}
+		if hi < lo {
+			hi = lo
+		}
+		out[i] = Series{Pair: s.Pair, Candles: s.Candles[lo:hi]}
+	}
+	return out
+}
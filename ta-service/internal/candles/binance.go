@@ -0,0 +1,358 @@
+package candles
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	binance "github.com/adshao/go-binance/v2"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/telemetry"
+)
+
+const (
+	reconnectMin = time.Second
+	reconnectMax = time.Minute
+	// streamStaleAfter marks a connected stream unhealthy when Binance stops
+	// pushing kline updates, which it normally does every couple of seconds.
+	streamStaleAfter = time.Minute
+	// gapRetryEvery throttles REST gap-fill retries after a failed fill.
+	gapRetryEvery  = 10 * time.Second
+	klinesPageSize = 1000
+)
+
+// klineDialer opens a kline stream; it matches binance.WsKlineServe.
+type klineDialer func(symbol, interval string, handler binance.WsKlineHandler, errHandler binance.ErrHandler) (doneC, stopC chan struct{}, err error)
+
+// klineFetcher loads candles starting in [start, end) from the REST API.
+type klineFetcher func(ctx context.Context, symbol, interval string, start, end time.Time) ([]Candle, error)
+
+// StreamHealth reports the state of one exchange stream.
+type StreamHealth struct {
+	Exchange   string    `json:"exchange"`
+	Symbol     string    `json:"symbol"`
+	Interval   string    `json:"interval"`
+	Connected  bool      `json:"connected"`
+	Healthy    bool      `json:"healthy"`
+	LastEvent  time.Time `json:"last_event,omitempty"`
+	Reconnects int       `json:"reconnects"`
+	GapsFilled int       `json:"gaps_filled"`
+	LastError  string    `json:"last_error,omitempty"`
+}
+
+// binanceStream supervises the kline stream of one symbol and interval. mu
+// serialises event handling with gap-fills so candles are delivered in start
+// order.
+type binanceStream struct {
+	svc      *Service
+	symbol   string
+	interval string
+	dur      time.Duration
+	cancel   context.CancelFunc
+	// unwatched is set under streamsMu when the symbol is removed, so the
+	// stream drops its buffered candles once it has stopped.
+	unwatched bool
+
+	mu         sync.Mutex
+	lastClosed time.Time
+	fillFailed time.Time
+
+	healthMu sync.Mutex
+	health   StreamHealth
+}
+
+// runBinance supervises a stream per watched symbol and interval until ctx
+// ends, reloading the watchlist so changes made on other replicas apply.
+func (s *Service) runBinance(ctx context.Context) {
+	s.streamsMu.Lock()
+	s.runCtx = ctx
+	s.streamsMu.Unlock()
+	defer func() {
+		s.streamsMu.Lock()
+		if s.runCtx == ctx {
+			s.runCtx = nil
+		}
+		s.streamsMu.Unlock()
+	}()
+	refresh := s.cfg.WatchRefresh
+	if refresh <= 0 {
+		refresh = watchRefresh
+	}
+	ticker := time.NewTicker(refresh)
+	defer ticker.Stop()
+	for {
+		s.reconcile(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// startStreamLocked starts supervising symbol/interval. Callers hold streamsMu.
+func (s *Service) startStreamLocked(ctx context.Context, symbol, interval string) {
+	dur, ok := IntervalDuration(interval)
+	if !ok {
+		s.logger.Error().Str("symbol", symbol).Str("interval", interval).Msg("unknown interval; binance stream disabled")
+		return
+	}
+	streamCtx, cancel := context.WithCancel(ctx)
+	st := &binanceStream{
+		svc:      s,
+		symbol:   symbol,
+		interval: interval,
+		dur:      dur,
+		cancel:   cancel,
+		health:   StreamHealth{Exchange: "binance", Symbol: symbol, Interval: interval},
+	}
+	if buffered := s.buffer.Get("binance", symbol, interval); len(buffered) > 0 {
+		for i := len(buffered) - 1; i >= 0; i-- {
+			if buffered[i].Closed {
+				st.lastClosed = buffered[i].Start
+				break
+			}
+		}
+	}
+	key := streamKey(symbol, interval)
+	s.streams[key] = st
+	go func() {
+		st.run(streamCtx)
+		// A replica that loses leadership stops reporting the stream.
+		s.streamsMu.Lock()
+		if s.streams[key] == st {
+			delete(s.streams, key)
+		}
+		_, rewatched := s.streams[key]
+		drop := st.unwatched && !rewatched
+		s.streamsMu.Unlock()
+		if drop {
+			s.drop("binance", symbol, interval)
+		}
+	}()
+}
+
+// stopStreamLocked stops supervising symbol/interval. Callers hold streamsMu.
+func (s *Service) stopStreamLocked(key string) {
+	if st, ok := s.streams[key]; ok {
+		st.unwatched = true
+		st.cancel()
+		delete(s.streams, key)
+	}
+}
+
+func streamKey(symbol, interval string) string {
+	return symbol + ":" + interval
+}
+
+// StreamHealth reports every supervised exchange stream.
+func (s *Service) StreamHealth() []StreamHealth {
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
+	out := make([]StreamHealth, 0, len(s.streams))
+	for _, st := range s.streams {
+		out = append(out, st.snapshot())
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Symbol != out[j].Symbol {
+			return out[i].Symbol < out[j].Symbol
+		}
+		return out[i].Interval < out[j].Interval
+	})
+	return out
+}
+
+// run keeps the stream connected until ctx ends, backing off exponentially
+// between attempts and filling any candles missed while disconnected.
+func (st *binanceStream) run(ctx context.Context) {
+	log := st.svc.logger.With().Str("symbol", st.symbol).Str("interval", st.interval).Logger()
+	backoff := st.svc.reconnectMin
+	for {
+		handler := func(event *binance.WsKlineEvent) { st.onEvent(ctx, event) }
+		errHandler := func(err error) {
+			log.Warn().Err(err).Msg("binance ws error")
+			st.update(func(h *StreamHealth) { h.LastError = err.Error() })
+		}
+		doneC, stopC, err := st.svc.dial(st.symbol, st.interval, handler, errHandler)
+		if err == nil {
+			connectedAt := time.Now()
+			st.setConnected(true)
+			log.Info().Msg("binance stream connected")
+			st.fill(ctx, time.Now())
+			select {
+			case <-ctx.Done():
+				close(stopC)
+				st.setConnected(false)
+				return
+			case <-doneC:
+			}
+			st.setConnected(false)
+			if time.Since(connectedAt) > st.svc.reconnectMax {
+				backoff = st.svc.reconnectMin
+			}
+			log.Warn().Msg("binance stream dropped")
+		} else {
+			log.Error().Err(err).Msg("binance ws dial failed")
+			st.update(func(h *StreamHealth) { h.LastError = err.Error() })
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		wait := backoff + time.Duration(rand.Int63n(int64(backoff)/5+1))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		backoff *= 2
+		if backoff > st.svc.reconnectMax {
+			backoff = st.svc.reconnectMax
+		}
+		telemetry.StreamReconnects.WithLabelValues("binance", st.symbol, st.interval).Inc()
+		st.update(func(h *StreamHealth) { h.Reconnects++ })
+	}
+}
+
+func (st *binanceStream) onEvent(ctx context.Context, event *binance.WsKlineEvent) {
+	if event == nil {
+		return
+	}
+	now := time.Now()
+	telemetry.StreamLastEvent.WithLabelValues("binance", st.symbol, st.interval).Set(float64(now.Unix()))
+	st.update(func(h *StreamHealth) { h.LastEvent = now })
+	candle := Candle{
+		Exchange: "binance",
+		Pair:     event.Symbol,
+		Interval: event.Kline.Interval,
+		Open:     parseFloat(event.Kline.Open),
+		High:     parseFloat(event.Kline.High),
+		Low:      parseFloat(event.Kline.Low),
+		Close:    parseFloat(event.Kline.Close),
+		Volume:   parseFloat(event.Kline.Volume),
+		Start:    time.UnixMilli(event.Kline.StartTime),
+		Closed:   event.Kline.IsFinal,
+	}
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if !st.lastClosed.IsZero() {
+		if !candle.Start.After(st.lastClosed) {
+			// Already delivered, typically by a gap-fill racing a reconnect.
+			return
+		}
+		if expected := st.lastClosed.Add(st.dur); candle.Start.After(expected) && now.Sub(st.fillFailed) >= gapRetryEvery {
+			telemetry.CandleGaps.WithLabelValues("binance", st.symbol, st.interval).Inc()
+			st.svc.logger.Warn().Str("symbol", st.symbol).Time("from", expected).Time("to", candle.Start).Msg("candle gap detected")
+			st.fillLocked(ctx, candle.Start)
+		}
+	}
+	st.svc.accept(candle)
+	// Leave lastClosed behind an unfilled gap so the next event retries it.
+	if candle.Closed && (st.lastClosed.IsZero() || !candle.Start.After(st.lastClosed.Add(st.dur))) {
+		st.lastClosed = candle.Start
+	}
+}
+
+// fill loads closed candles from the last delivered one up to end.
+func (st *binanceStream) fill(ctx context.Context, end time.Time) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	st.fillLocked(ctx, end)
+}
+
+func (st *binanceStream) fillLocked(ctx context.Context, end time.Time) {
+	start := st.lastClosed.Add(st.dur)
+	if st.lastClosed.IsZero() {
+		start = end.Add(-st.svc.cfg.BackfillLookback).Truncate(st.dur)
+	}
+	if !start.Before(end) {
+		return
+	}
+	fetched, err := st.svc.fetch(ctx, st.symbol, st.interval, start, end)
+	if err != nil {
+		st.fillFailed = time.Now()
+		st.svc.logger.Error().Err(err).Str("symbol", st.symbol).Msg("binance gap-fill failed")
+		st.update(func(h *StreamHealth) { h.LastError = err.Error() })
+		return
+	}
+	filled := 0
+	for _, c := range fetched {
+		if !c.Closed || !c.Start.After(st.lastClosed) {
+			continue
+		}
+		st.svc.accept(c)
+		st.lastClosed = c.Start
+		filled++
+	}
+	if filled > 0 {
+		telemetry.GapFillCandles.WithLabelValues("binance", st.symbol, st.interval).Add(float64(filled))
+		st.update(func(h *StreamHealth) { h.GapsFilled += filled })
+		st.svc.logger.Info().Str("symbol", st.symbol).Int("candles", filled).Msg("filled candle gap from rest")
+	}
+}
+
+func (st *binanceStream) setConnected(connected bool) {
+	value := 0.0
+	if connected {
+		value = 1
+	}
+	telemetry.StreamConnected.WithLabelValues("binance", st.symbol, st.interval).Set(value)
+	st.update(func(h *StreamHealth) { h.Connected = connected })
+}
+
+func (st *binanceStream) update(fn func(h *StreamHealth)) {
+	st.healthMu.Lock()
+	defer st.healthMu.Unlock()
+	fn(&st.health)
+}
+
+func (st *binanceStream) snapshot() StreamHealth {
+	st.healthMu.Lock()
+	defer st.healthMu.Unlock()
+	h := st.health
+	h.Healthy = h.Connected && time.Since(h.LastEvent) < streamStaleAfter
+	return h
+}
+
+// fetchBinanceKlines pages through the REST klines endpoint.
+func (s *Service) fetchBinanceKlines(ctx context.Context, symbol, interval string, start, end time.Time) ([]Candle, error) {
+	var out []Candle
+	for start.Before(end) {
+		klines, err := s.rest.NewKlinesService().
+			Symbol(symbol).
+			Interval(interval).
+			StartTime(start.UnixMilli()).
+			EndTime(end.UnixMilli() - 1).
+			Limit(klinesPageSize).
+			Do(ctx)
+		if err != nil {
+			return out, err
+		}
+		now := time.Now().UnixMilli()
+		for _, k := range klines {
+			out = append(out, Candle{
+				Exchange: "binance",
+				Pair:     symbol,
+				Interval: interval,
+				Open:     parseFloat(k.Open),
+				High:     parseFloat(k.High),
+				Low:      parseFloat(k.Low),
+				Close:    parseFloat(k.Close),
+				Volume:   parseFloat(k.Volume),
+				Start:    time.UnixMilli(k.OpenTime),
+				Closed:   k.CloseTime < now,
+			})
+		}
+		if len(klines) < klinesPageSize {
+			break
+		}
+		next := time.UnixMilli(klines[len(klines)-1].OpenTime + 1)
+		if !next.After(start) {
+			break
+		}
+		start = next
+	}
+	return out, nil
+}
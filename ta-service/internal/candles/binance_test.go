@@ -0,0 +1,146 @@
+package candles
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	binance "github.com/adshao/go-binance/v2"
+	"github.com/rs/zerolog"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/config"
+)
+
+type fakeConn struct {
+	handler binance.WsKlineHandler
+	done    chan struct{}
+}
+
+type fakeBinance struct {
+	conns chan fakeConn
+
+	mu    sync.Mutex
+	fills [][2]time.Time
+}
+
+func (f *fakeBinance) dial(symbol, interval string, handler binance.WsKlineHandler, _ binance.ErrHandler) (chan struct{}, chan struct{}, error) {
+	conn := fakeConn{handler: handler, done: make(chan struct{})}
+	stop := make(chan struct{})
+	go func() {
+		<-stop
+		close(conn.done)
+	}()
+	f.conns <- conn
+	return conn.done, stop, nil
+}
+
+// fetch serves one candle per minute in [start, end); candles that have not
+// ended by end are still forming.
+func (f *fakeBinance) fetch(_ context.Context, symbol, interval string, start, end time.Time) ([]Candle, error) {
+	f.mu.Lock()
+	f.fills = append(f.fills, [2]time.Time{start, end})
+	f.mu.Unlock()
+	var out []Candle
+	for t := start; t.Before(end); t = t.Add(time.Minute) {
+		out = append(out, Candle{Exchange: "binance", Pair: symbol, Interval: interval, Close: 1, Start: t, Closed: !t.Add(time.Minute).After(end)})
+	}
+	return out, nil
+}
+
+func klineEvent(start time.Time, final bool) *binance.WsKlineEvent {
+	return &binance.WsKlineEvent{
+		Symbol: "ETHUSDT",
+		Kline:  binance.WsKline{StartTime: start.UnixMilli(), Interval: "1m", Close: "2", IsFinal: final},
+	}
+}
+
+func nextClosed(t *testing.T, closed <-chan Candle) Candle {
+	t.Helper()
+	select {
+	case c := <-closed:
+		return c
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for closed candle")
+		return Candle{}
+	}
+}
+
+func nextConn(t *testing.T, conns <-chan fakeConn) fakeConn {
+	t.Helper()
+	select {
+	case c := <-conns:
+		return c
+	case <-time.After(5 * time.Second):
+		t.Fatalf("timed out waiting for dial")
+		return fakeConn{}
+	}
+}
+
+func TestBinanceStreamFillsGapsAndReconnects(t *testing.T) {
+	fake := &fakeBinance{conns: make(chan fakeConn, 4)}
+	svc := NewService(config.Config{
+		CandleLimit:      100,
+		Interval:         "1m",
+		BinanceSymbols:   []string{"ethusdt"},
+		BackfillLookback: 5 * time.Minute,
+	}, nil, nil, nil, zerolog.Nop())
+	svc.dial = fake.dial
+	svc.fetch = fake.fetch
+	svc.reconnectMin = 10 * time.Millisecond
+	svc.reconnectMax = 50 * time.Millisecond
+	closed := make(chan Candle, 64)
+	svc.OnCandleClose(func(c Candle) { closed <- c })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	svc.Start(ctx)
+
+	// The first connection backfills the lookback window.
+	conn := nextConn(t, fake.conns)
+	var last Candle
+	for i := 0; i < 5; i++ {
+		c := nextClosed(t, closed)
+		if i > 0 && !c.Start.Equal(last.Start.Add(time.Minute)) {
+			t.Fatalf("backfill out of order: %s after %s", c.Start, last.Start)
+		}
+		last = c
+	}
+
+	// A closed kline three minutes on leaves a two-candle gap that is filled
+	// before the kline itself is delivered.
+	gapEnd := last.Start.Add(3 * time.Minute)
+	conn.handler(klineEvent(gapEnd, true))
+	for i := 1; i <= 3; i++ {
+		c := nextClosed(t, closed)
+		if want := last.Start.Add(time.Duration(i) * time.Minute); !c.Start.Equal(want) {
+			t.Fatalf("expected candle at %s, got %s", want, c.Start)
+		}
+	}
+	fake.mu.Lock()
+	fill := fake.fills[len(fake.fills)-1]
+	fake.mu.Unlock()
+	if !fill[0].Equal(last.Start.Add(time.Minute)) || !fill[1].Equal(gapEnd) {
+		t.Fatalf("unexpected gap-fill range %v", fill)
+	}
+
+	// Replays of delivered candles are ignored.
+	conn.handler(klineEvent(gapEnd, true))
+
+	// A dropped socket is redialled.
+	close(conn.done)
+	conn = nextConn(t, fake.conns)
+	conn.handler(klineEvent(gapEnd.Add(time.Minute), true))
+	if c := nextClosed(t, closed); !c.Start.Equal(gapEnd.Add(time.Minute)) {
+		t.Fatalf("expected candle after reconnect, got %s", c.Start)
+	}
+	health := svc.StreamHealth()
+	if len(health) != 1 || !health[0].Connected || !health[0].Healthy || health[0].Reconnects != 1 || health[0].GapsFilled < 6 {
+		t.Fatalf("unexpected stream health %+v", health)
+	}
+	select {
+	case c := <-closed:
+		t.Fatalf("unexpected extra candle %s", c.Start)
+	default:
+	}
+}
@@ -2,6 +2,7 @@ package candles
 
 import (
 	"context"
+	"sort"
 	"strconv"
 	"sync"
 	"time"
@@ -12,6 +13,7 @@ import (
 	"github.com/example/tg-crypto-trader/ta-service/internal/config"
 	"github.com/example/tg-crypto-trader/ta-service/internal/model"
 	"github.com/example/tg-crypto-trader/ta-service/internal/storage"
+	"github.com/example/tg-crypto-trader/ta-service/internal/telemetry"
 )
 
 // Candle represents OHLCV data.
@@ -29,18 +31,37 @@ func NewBuffer(limit int) *Buffer {
 	return &Buffer{storage: make(map[string][]Candle), limit: limit}
 }
 
-// Add stores a candle and evicts old values.
+// Add stores a candle and evicts old values. Updates to a candle that is
+// already buffered, such as the forming kline, replace it in place.
 func (b *Buffer) Add(c Candle) {
 	key := c.Exchange + ":" + c.Pair + ":" + c.Interval
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	arr := append(b.storage[key], c)
+	arr := b.storage[key]
+	idx := sort.Search(len(arr), func(i int) bool { return !arr[i].Start.Before(c.Start) })
+	switch {
+	case idx < len(arr) && arr[idx].Start.Equal(c.Start):
+		arr[idx] = c
+	case idx == len(arr):
+		arr = append(arr, c)
+	default:
+		arr = append(arr, Candle{})
+		copy(arr[idx+1:], arr[idx:])
+		arr[idx] = c
+	}
 	if len(arr) > b.limit {
 		arr = arr[len(arr)-b.limit:]
 	}
 	b.storage[key] = arr
 }
 
+// Drop removes the candles for the key.
+func (b *Buffer) Drop(exchange, pair, interval string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.storage, exchange+":"+pair+":"+interval)
+}
+
 // Get returns candles for the key.
 func (b *Buffer) Get(exchange, pair, interval string) []Candle {
 	key := exchange + ":" + pair + ":" + interval
@@ -53,28 +74,63 @@ func (b *Buffer) Get(exchange, pair, interval string) []Candle {
 
 // Service coordinates candle ingest.
 type Service struct {
-	cfg    config.Config
-	logger zerolog.Logger
-	store  *storage.Store
-	buffer *Buffer
-	rust   UniswapBridge
+	cfg     config.Config
+	logger  zerolog.Logger
+	store   *storage.Store
+	symbols SymbolStore
+	writer  CandleWriter
+	buffer  *Buffer
+	rust    UniswapBridge
+
+	rest         *binance.Client
+	dial         klineDialer
+	fetch        klineFetcher
+	reconnectMin time.Duration
+	reconnectMax time.Duration
+	watchMu      sync.Mutex
+	streamsMu    sync.Mutex
+	streams      map[string]*binanceStream
+	runCtx       context.Context
+
+	listenersMu   sync.RWMutex
+	listeners     []func(Candle)
+	dropListeners []func(exchange, pair, interval string)
 }
 
-// UniswapBridge exposes the Uniswap candle stream.
+// UniswapBridge exposes the Uniswap candle stream. Stream may be called once
+// per collection run, such as each leadership term; Close ends the bridge on
+// shutdown.
 type UniswapBridge interface {
-	Stream() <-chan Candle
+	Stream(ctx context.Context) <-chan Candle
 	Close()
 }
 
-// NewService returns a Service.
-func NewService(cfg config.Config, store *storage.Store, bridge UniswapBridge, logger zerolog.Logger) *Service {
-	return &Service{
-		cfg:    cfg,
-		store:  store,
-		buffer: NewBuffer(cfg.CandleLimit),
-		rust:   bridge,
-		logger: logger,
+// CandleWriter persists candles off the ingest path.
+type CandleWriter interface {
+	Enqueue(c Candle)
+}
+
+// NewService returns a Service. Candles are loaded from store and persisted
+// through writer; either may be nil.
+func NewService(cfg config.Config, store *storage.Store, writer CandleWriter, bridge UniswapBridge, logger zerolog.Logger) *Service {
+	s := &Service{
+		cfg:          cfg,
+		store:        store,
+		writer:       writer,
+		buffer:       NewBuffer(cfg.CandleLimit),
+		rust:         bridge,
+		logger:       logger,
+		rest:         binance.NewClient(cfg.BinanceAPIKey, cfg.BinanceSecret),
+		dial:         binance.WsKlineServe,
+		reconnectMin: reconnectMin,
+		reconnectMax: reconnectMax,
+		streams:      make(map[string]*binanceStream),
 	}
+	s.fetch = s.fetchBinanceKlines
+	if store != nil {
+		s.symbols = store
+	}
+	return s
 }
 
 // Start begins Binance and Uniswap streaming.
@@ -83,46 +139,6 @@ func (s *Service) Start(ctx context.Context) {
 	go s.runUniswap(ctx)
 }
 
-func (s *Service) runBinance(ctx context.Context) {
-	if len(s.cfg.BinanceSymbols) == 0 {
-		return
-	}
-	wsHandler := func(event *binance.WsKlineEvent) {
-		if event == nil {
-			return
-		}
-		candle := Candle{
-			Exchange: "binance",
-			Pair:     event.Symbol,
-			Interval: event.Kline.Interval,
-			Open:     parseFloat(event.Kline.Open),
-			High:     parseFloat(event.Kline.High),
-			Low:      parseFloat(event.Kline.Low),
-			Close:    parseFloat(event.Kline.Close),
-			Volume:   parseFloat(event.Kline.Volume),
-			Start:    time.UnixMilli(event.Kline.StartTime),
-		}
-		s.buffer.Add(candle)
-		if err := s.store.UpsertCandle(ctx, candle); err != nil {
-			s.logger.Error().Err(err).Msg("failed to persist binance candle")
-		}
-	}
-	errHandler := func(err error) {
-		s.logger.Error().Err(err).Msg("binance ws error")
-	}
-	for _, symbol := range s.cfg.BinanceSymbols {
-		go func(sym string) {
-			done, _, err := binance.WsKlineServe(sym, s.cfg.Interval, wsHandler, errHandler)
-			if err != nil {
-				s.logger.Error().Err(err).Str("symbol", sym).Msg("binance ws failed")
-				return
-			}
-			<-ctx.Done()
-			done <- struct{}{}
-		}(symbol)
-	}
-}
-
 func (s *Service) runUniswap(ctx context.Context) {
 	if len(s.cfg.UniswapPairs) == 0 {
 		return
@@ -131,28 +147,108 @@ func (s *Service) runUniswap(ctx context.Context) {
 		s.logger.Warn().Msg("uniswap bridge disabled")
 		return
 	}
-	stream := s.rust.Stream()
-	go func() {
-		<-ctx.Done()
-		s.rust.Close()
-	}()
+	stream := s.rust.Stream(ctx)
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case candle, ok := <-stream:
 			if !ok {
-				s.logger.Warn().Msg("uniswap stream closed")
+				if ctx.Err() == nil {
+					s.logger.Warn().Msg("uniswap stream closed")
+				}
 				return
 			}
-			s.buffer.Add(candle)
-			if err := s.store.UpsertCandle(context.Background(), candle); err != nil {
-				s.logger.Error().Err(err).Msg("failed to persist uniswap candle")
-			}
+			s.accept(candle)
+		}
+	}
+}
+
+// accept buffers, persists and announces a collected candle.
+func (s *Service) accept(c Candle) {
+	telemetry.ObserveCandle(c.Exchange, c.Pair, c.Closed)
+	s.buffer.Add(c)
+	if s.writer != nil {
+		s.writer.Enqueue(c)
+	}
+	s.notifyClose(c)
+}
+
+// Ingest buffers a candle produced by another collector, such as the elected
+// replica, without persisting it again.
+func (s *Service) Ingest(c Candle) {
+	telemetry.ObserveCandle(c.Exchange, c.Pair, c.Closed)
+	s.buffer.Add(c)
+	s.notifyClose(c)
+}
+
+// Preload buffers history without announcing it, so indicators have data
+// before a replay starts.
+func (s *Service) Preload(c Candle) {
+	s.buffer.Add(c)
+}
+
+// Warm loads recent candles for the watched symbols from Postgres so
+// queries are served before live streams catch up.
+func (s *Service) Warm(ctx context.Context) {
+	watched, err := s.Watched(ctx)
+	if err != nil {
+		s.logger.Warn().Err(err).Msg("failed to load watchlist; warming configured symbols only")
+	}
+	now := time.Now()
+	for _, sym := range watched {
+		dur, ok := IntervalDuration(sym.Interval)
+		if !ok {
+			s.logger.Warn().Str("interval", sym.Interval).Msg("unknown interval; skipping warm-up")
+			continue
+		}
+		stored, err := s.store.LoadCandles(ctx, "binance", sym.Pair, sym.Interval, s.cfg.CandleLimit)
+		if err != nil {
+			s.logger.Warn().Err(err).Str("symbol", sym.Pair).Str("interval", sym.Interval).Msg("failed to warm candles")
+			continue
+		}
+		for _, c := range stored {
+			c.Closed = !c.Start.Add(dur).After(now)
+			s.buffer.Add(c)
 		}
 	}
 }
 
+// OnCandleClose registers fn to run after each closed candle is buffered.
+func (s *Service) OnCandleClose(fn func(Candle)) {
+	s.listenersMu.Lock()
+	defer s.listenersMu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+func (s *Service) notifyClose(c Candle) {
+	if !c.Closed {
+		return
+	}
+	s.listenersMu.RLock()
+	defer s.listenersMu.RUnlock()
+	for _, fn := range s.listeners {
+		fn(c)
+	}
+}
+
+// OnDrop registers fn to run after an unwatched symbol's candles are
+// dropped from the buffer.
+func (s *Service) OnDrop(fn func(exchange, pair, interval string)) {
+	s.listenersMu.Lock()
+	defer s.listenersMu.Unlock()
+	s.dropListeners = append(s.dropListeners, fn)
+}
+
+func (s *Service) drop(exchange, pair, interval string) {
+	s.buffer.Drop(exchange, pair, interval)
+	s.listenersMu.RLock()
+	defer s.listenersMu.RUnlock()
+	for _, fn := range s.dropListeners {
+		fn(exchange, pair, interval)
+	}
+}
+
 // Candles returns the latest candles for pair/interval.
 func (s *Service) Candles(exchange, pair, interval string) []Candle {
 	return s.buffer.Get(exchange, pair, interval)
@@ -165,3 +261,33 @@ func parseFloat(input string) float64 {
 	}
 	return f
 }
+
+// IntervalDuration converts a Binance kline interval such as "1m" or "4h"
+// into its duration. Months are approximated as 30 days.
+func IntervalDuration(interval string) (time.Duration, bool) {
+	if len(interval) < 2 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(interval[:len(interval)-1])
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	unit := time.Duration(0)
+	switch interval[len(interval)-1] {
+	case 's':
+		unit = time.Second
+	case 'm':
+		unit = time.Minute
+	case 'h':
+		unit = time.Hour
+	case 'd':
+		unit = 24 * time.Hour
+	case 'w':
+		unit = 7 * 24 * time.Hour
+	case 'M':
+		unit = 30 * 24 * time.Hour
+	default:
+		return 0, false
+	}
+	return time.Duration(n) * unit, true
+}
@@ -0,0 +1,385 @@
+package candles
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event signatures emitted by Uniswap pools.
+const (
+	topicV2Swap = "0xd78ad95fa46c994b6551d0da85fc275fe613ce37657fb8d5e3d130840159d822"
+	topicV2Sync = "0x1c411e9a96e071241c2f21f7726b17ae89e3cab4c78be50e062b03a9fffbbad1"
+	topicV3Swap = "0xc42079f94a6350d7e6235f29174924f928cc2ac818eb64fed8004e115fbcca67"
+)
+
+// Pool describes a Uniswap pool tracked for candles.
+type Pool struct {
+	Name      string
+	Address   string
+	Version   int
+	Decimals0 int
+	Decimals1 int
+	// Inverse quotes token0 in units of token1 instead of token1 in token0,
+	// and measures volume in token1.
+	Inverse bool
+}
+
+// ParsePool parses a pool spec of the form
+//
+//	NAME=ADDRESS:VERSION:DECIMALS0:DECIMALS1[:inverse]
+//
+// such as "ETHUSDC=0x88e6a0c2ddd26feeb64f039a2c41296fcb3f5640:v3:6:18:inverse".
+// A bare address is accepted as a V2 pool of two 18-decimal tokens.
+func ParsePool(spec string) (Pool, error) {
+	spec = strings.TrimSpace(spec)
+	name, rest, named := strings.Cut(spec, "=")
+	if !named {
+		rest = spec
+	}
+	parts := strings.Split(rest, ":")
+	p := Pool{Address: strings.ToLower(parts[0]), Version: 2, Decimals0: 18, Decimals1: 18}
+	if !isAddress(p.Address) {
+		return Pool{}, fmt.Errorf("pool %q: invalid address", spec)
+	}
+	p.Name = strings.ToUpper(name)
+	if !named {
+		p.Name = p.Address
+	}
+	if len(parts) == 1 {
+		return p, nil
+	}
+	if len(parts) < 4 || len(parts) > 5 {
+		return Pool{}, fmt.Errorf("pool %q: expected ADDRESS:VERSION:DECIMALS0:DECIMALS1[:inverse]", spec)
+	}
+	switch strings.ToLower(parts[1]) {
+	case "v2", "2":
+		p.Version = 2
+	case "v3", "3":
+		p.Version = 3
+	default:
+		return Pool{}, fmt.Errorf("pool %q: unknown version %q", spec, parts[1])
+	}
+	var err error
+	if p.Decimals0, err = strconv.Atoi(parts[2]); err != nil || p.Decimals0 < 0 {
+		return Pool{}, fmt.Errorf("pool %q: invalid decimals0", spec)
+	}
+	if p.Decimals1, err = strconv.Atoi(parts[3]); err != nil || p.Decimals1 < 0 {
+		return Pool{}, fmt.Errorf("pool %q: invalid decimals1", spec)
+	}
+	if len(parts) == 5 {
+		if !strings.EqualFold(parts[4], "inverse") {
+			return Pool{}, fmt.Errorf("pool %q: unknown flag %q", spec, parts[4])
+		}
+		p.Inverse = true
+	}
+	return p, nil
+}
+
+func isAddress(s string) bool {
+	if len(s) != 42 || !strings.HasPrefix(s, "0x") {
+		return false
+	}
+	_, err := hex.DecodeString(s[2:])
+	return err == nil
+}
+
+// ethLog is a log entry as returned by eth_getLogs. Recorded fixtures may
+// also carry blockTimestamp so they replay without block lookups.
+type ethLog struct {
+	Address        string   `json:"address"`
+	Topics         []string `json:"topics"`
+	Data           string   `json:"data"`
+	BlockNumber    string   `json:"blockNumber"`
+	BlockTimestamp string   `json:"blockTimestamp,omitempty"`
+	LogIndex       string   `json:"logIndex"`
+	Removed        bool     `json:"removed,omitempty"`
+}
+
+func parseQuantity(s string) (uint64, error) {
+	if !strings.HasPrefix(s, "0x") {
+		return 0, fmt.Errorf("invalid quantity %q", s)
+	}
+	return strconv.ParseUint(s[2:], 16, 64)
+}
+
+func formatQuantity(n uint64) string {
+	return "0x" + strconv.FormatUint(n, 16)
+}
+
+// words splits ABI-encoded data into 32-byte words.
+func words(data string, n int) ([][]byte, error) {
+	raw, err := hex.DecodeString(strings.TrimPrefix(data, "0x"))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < 32*n {
+		return nil, fmt.Errorf("expected %d words, got %d bytes", n, len(raw))
+	}
+	out := make([][]byte, n)
+	for i := range out {
+		out[i] = raw[32*i : 32*(i+1)]
+	}
+	return out, nil
+}
+
+func uint256(word []byte) *big.Int {
+	return new(big.Int).SetBytes(word)
+}
+
+// int256 decodes a two's complement word.
+func int256(word []byte) *big.Int {
+	v := new(big.Int).SetBytes(word)
+	if word[0]&0x80 != 0 {
+		v.Sub(v, new(big.Int).Lsh(big.NewInt(1), 256))
+	}
+	return v
+}
+
+var (
+	q192      = new(big.Float).SetPrec(256).SetInt(new(big.Int).Lsh(big.NewInt(1), 192))
+	errNoSwap = errors.New("not a swap")
+)
+
+// scale converts a raw token amount into whole units.
+func scale(amount *big.Int, decimals int) *big.Float {
+	f := new(big.Float).SetPrec(256).SetInt(amount)
+	return f.Quo(f, pow10(decimals))
+}
+
+func pow10(n int) *big.Float {
+	return new(big.Float).SetPrec(256).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil))
+}
+
+// swap is a decoded trade with its price in quote units per base unit and
+// its size in base units.
+type swap struct {
+	price  float64
+	volume float64
+}
+
+// poolState tracks a pool's reserves, the block being accumulated and the
+// forming candle.
+type poolState struct {
+	pool     Pool
+	reserve0 *big.Int
+	reserve1 *big.Int
+
+	block     uint64
+	blockTime time.Time
+	pending   bool
+	notional  float64
+	volume    float64
+	last      float64
+
+	candle *Candle
+}
+
+// price converts a token1/token0 ratio of raw amounts into the pool's quote.
+func (ps *poolState) price(num, den *big.Float) (float64, bool) {
+	if den.Sign() == 0 || num.Sign() == 0 {
+		return 0, false
+	}
+	p := new(big.Float).SetPrec(256).Quo(num, den)
+	p.Mul(p, pow10(ps.pool.Decimals0))
+	p.Quo(p, pow10(ps.pool.Decimals1))
+	if ps.pool.Inverse {
+		p.Quo(big.NewFloat(1).SetPrec(256), p)
+	}
+	f, _ := p.Float64()
+	return f, true
+}
+
+func (ps *poolState) baseVolume(amount0, amount1 *big.Int) float64 {
+	var v *big.Float
+	if ps.pool.Inverse {
+		v = scale(new(big.Int).Abs(amount1), ps.pool.Decimals1)
+	} else {
+		v = scale(new(big.Int).Abs(amount0), ps.pool.Decimals0)
+	}
+	f, _ := v.Float64()
+	return f
+}
+
+// decode applies a log to the pool, returning the swap it describes.
+// Sync events only update reserves and return errNoSwap, as do events that
+// do not belong to the pool's version.
+func (ps *poolState) decode(l ethLog) (swap, error) {
+	topic := strings.ToLower(l.Topics[0])
+	if (topic == topicV3Swap) != (ps.pool.Version == 3) {
+		return swap{}, errNoSwap
+	}
+	switch topic {
+	case topicV2Sync:
+		w, err := words(l.Data, 2)
+		if err != nil {
+			return swap{}, err
+		}
+		ps.reserve0, ps.reserve1 = uint256(w[0]), uint256(w[1])
+		return swap{}, errNoSwap
+	case topicV2Swap:
+		w, err := words(l.Data, 4)
+		if err != nil {
+			return swap{}, err
+		}
+		amount0 := new(big.Int).Add(uint256(w[0]), uint256(w[2]))
+		amount1 := new(big.Int).Add(uint256(w[1]), uint256(w[3]))
+		num, den := amount1, amount0
+		// Pairs emit Sync with post-trade reserves just before Swap; fall
+		// back to the execution price when no reserves have been seen.
+		if ps.reserve0 != nil {
+			num, den = ps.reserve1, ps.reserve0
+		}
+		p, ok := ps.price(new(big.Float).SetInt(num), new(big.Float).SetInt(den))
+		if !ok {
+			return swap{}, errNoSwap
+		}
+		return swap{price: p, volume: ps.baseVolume(amount0, amount1)}, nil
+	case topicV3Swap:
+		w, err := words(l.Data, 5)
+		if err != nil {
+			return swap{}, err
+		}
+		sqrt := new(big.Float).SetPrec(256).SetInt(uint256(w[2]))
+		p, ok := ps.price(new(big.Float).SetPrec(256).Mul(sqrt, sqrt), q192)
+		if !ok {
+			return swap{}, errNoSwap
+		}
+		return swap{price: p, volume: ps.baseVolume(int256(w[0]), int256(w[1]))}, nil
+	}
+	return swap{}, errNoSwap
+}
+
+// swapAggregator folds pool logs into OHLCV candles. Swaps within one block
+// are collapsed into a single volume-weighted price so intra-block ordering,
+// such as sandwiches, does not set the candle's extremes.
+type swapAggregator struct {
+	interval string
+	dur      time.Duration
+	pools    map[string]*poolState
+}
+
+func newSwapAggregator(pools []Pool, interval string) (*swapAggregator, error) {
+	dur, ok := IntervalDuration(interval)
+	if !ok {
+		return nil, fmt.Errorf("unknown interval %q", interval)
+	}
+	a := &swapAggregator{interval: interval, dur: dur, pools: make(map[string]*poolState, len(pools))}
+	for _, p := range pools {
+		a.pools[p.Address] = &poolState{pool: p}
+	}
+	return a, nil
+}
+
+// addresses lists tracked pool addresses in a stable order.
+func (a *swapAggregator) addresses() []string {
+	out := make([]string, 0, len(a.pools))
+	for addr := range a.pools {
+		out = append(out, addr)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// apply consumes one log and returns candles that changed as a result.
+func (a *swapAggregator) apply(l ethLog, ts time.Time) ([]Candle, error) {
+	ps, ok := a.pools[strings.ToLower(l.Address)]
+	if !ok || l.Removed || len(l.Topics) == 0 {
+		return nil, nil
+	}
+	block, err := parseQuantity(l.BlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	var out []Candle
+	if ps.pending && block != ps.block {
+		out = a.commit(ps)
+	}
+	s, err := ps.decode(l)
+	if errors.Is(err, errNoSwap) {
+		return out, nil
+	}
+	if err != nil {
+		return out, fmt.Errorf("pool %s block %d: %w", ps.pool.Name, block, err)
+	}
+	ps.pending = true
+	ps.block = block
+	ps.blockTime = ts
+	ps.notional += s.price * s.volume
+	ps.volume += s.volume
+	ps.last = s.price
+	return out, nil
+}
+
+// commit folds the pending block into the forming candle, closing the
+// previous candle if the block starts a new interval.
+func (a *swapAggregator) commit(ps *poolState) []Candle {
+	if !ps.pending {
+		return nil
+	}
+	price := ps.last
+	if ps.volume > 0 {
+		price = ps.notional / ps.volume
+	}
+	volume := ps.volume
+	ps.pending, ps.notional, ps.volume = false, 0, 0
+
+	var out []Candle
+	start := ps.blockTime.UTC().Truncate(a.dur)
+	if ps.candle != nil && start.After(ps.candle.Start) {
+		ps.candle.Closed = true
+		out = append(out, *ps.candle)
+		ps.candle = nil
+	}
+	if ps.candle == nil {
+		ps.candle = &Candle{
+			Exchange: "uniswap",
+			Pair:     ps.pool.Name,
+			Interval: a.interval,
+			Open:     price,
+			High:     price,
+			Low:      price,
+			Start:    start,
+		}
+	}
+	c := ps.candle
+	if price > c.High {
+		c.High = price
+	}
+	if price < c.Low {
+		c.Low = price
+	}
+	c.Close = price
+	c.Volume += volume
+	return append(out, *c)
+}
+
+// flush commits every pending block. Call it once all logs up to a block
+// have been applied.
+func (a *swapAggregator) flush() []Candle {
+	var out []Candle
+	for _, addr := range a.addresses() {
+		out = append(out, a.commit(a.pools[addr])...)
+	}
+	return out
+}
+
+// closeBefore closes forming candles whose interval ended at or before now.
+func (a *swapAggregator) closeBefore(now time.Time) []Candle {
+	var out []Candle
+	for _, addr := range a.addresses() {
+		ps := a.pools[addr]
+		if ps.candle == nil || ps.candle.Start.Add(a.dur).After(now) {
+			continue
+		}
+		ps.candle.Closed = true
+		out = append(out, *ps.candle)
+		ps.candle = nil
+	}
+	return out
+}
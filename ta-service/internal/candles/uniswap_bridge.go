@@ -1,38 +1,343 @@
 package candles
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/rs/zerolog"
 )
 
-// RustBridge provides a stubbed adapter when the Rust collector is unavailable.
-type RustBridge struct {
-	stream chan Candle
-	once   sync.Once
-	log    zerolog.Logger
+// secondsPerBlock approximates mainnet block time when converting the
+// backfill lookback into a starting block.
+const secondsPerBlock = 12
+
+// SwapOptions configures a SwapBridge. Exactly one of RPCURL or ReplayFile
+// selects the log source.
+type SwapOptions struct {
+	Pools    []Pool
+	Interval string
+	// RPCURL is an Ethereum JSON-RPC endpoint polled with eth_getLogs.
+	RPCURL string
+	// ReplayFile holds recorded logs, one eth_getLogs entry per line with a
+	// blockTimestamp field, and is replayed once in order.
+	ReplayFile    string
+	PollInterval  time.Duration
+	Confirmations uint64
+	BlockRange    uint64
+	// StartBlock is the first block polled; zero starts Lookback before head.
+	StartBlock uint64
+	Lookback   time.Duration
+	HTTPClient *http.Client
+}
+
+// SwapBridge builds Uniswap candles from V2 and V3 pool swap logs.
+type SwapBridge struct {
+	opts   SwapOptions
+	ctx    context.Context
+	cancel context.CancelFunc
+	logger zerolog.Logger
+
+	// mu is held by the running source, so one stream reads the chain at a
+	// time and the next resumes where it stopped.
+	mu  sync.Mutex
+	agg *swapAggregator
+	// next is the next block to poll and line the replay lines consumed.
+	next uint64
+	line int
+	// pending holds built candles a stopped stream did not deliver.
+	pending []Candle
+}
+
+// NewSwapBridge validates opts and returns an idle bridge; each call to
+// Stream starts a source.
+func NewSwapBridge(opts SwapOptions, logger zerolog.Logger) (*SwapBridge, error) {
+	if len(opts.Pools) == 0 {
+		return nil, errors.New("no uniswap pools configured")
+	}
+	if (opts.RPCURL == "") == (opts.ReplayFile == "") {
+		return nil, errors.New("exactly one of rpc url or replay file is required")
+	}
+	agg, err := newSwapAggregator(opts.Pools, opts.Interval)
+	if err != nil {
+		return nil, err
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = secondsPerBlock * time.Second
+	}
+	if opts.BlockRange == 0 {
+		opts.BlockRange = 2000
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &SwapBridge{
+		opts:   opts,
+		ctx:    ctx,
+		cancel: cancel,
+		logger: logger,
+		agg:    agg,
+		next:   opts.StartBlock,
+	}, nil
+}
+
+// ParsePools parses pool specs as accepted by ParsePool.
+func ParsePools(specs []string) ([]Pool, error) {
+	pools := make([]Pool, 0, len(specs))
+	for _, spec := range specs {
+		if strings.TrimSpace(spec) == "" {
+			continue
+		}
+		p, err := ParsePool(spec)
+		if err != nil {
+			return nil, err
+		}
+		pools = append(pools, p)
+	}
+	return pools, nil
+}
+
+// Stream starts reading swaps for ctx and returns the candle channel.
+// Forming candles are sent after every block with swaps and closed candles
+// once their interval has passed; the channel closes when ctx ends, the
+// source is exhausted or the bridge is closed. A later call resumes after
+// the last block read, so each leadership term can stream in turn.
+func (b *SwapBridge) Stream(ctx context.Context) <-chan Candle {
+	out := make(chan Candle, 64)
+	go b.run(ctx, out)
+	return out
+}
+
+// Close stops the bridge for good; it is called on process shutdown.
+func (b *SwapBridge) Close() {
+	b.cancel()
+}
+
+func (b *SwapBridge) run(parent context.Context, out chan<- Candle) {
+	defer close(out)
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	go func() {
+		select {
+		case <-b.ctx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.send(ctx, out, nil) {
+		return
+	}
+	var err error
+	if b.opts.ReplayFile != "" {
+		err = b.replay(ctx, out)
+	} else {
+		err = b.poll(ctx, out, newRPCClient(b.opts.RPCURL, b.opts.HTTPClient))
+	}
+	if err != nil && ctx.Err() == nil {
+		b.logger.Error().Err(err).Msg("uniswap swap source stopped")
+	}
+}
+
+// send queues list behind any undelivered candles and delivers them in
+// order. It reports false if ctx ended first; the rest stays pending for the
+// next stream.
+func (b *SwapBridge) send(ctx context.Context, out chan<- Candle, list []Candle) bool {
+	b.pending = append(b.pending, list...)
+	for len(b.pending) > 0 {
+		select {
+		case <-ctx.Done():
+			return false
+		case out <- b.pending[0]:
+			b.pending = b.pending[1:]
+		}
+	}
+	b.pending = nil
+	return true
 }
 
-// NewRustBridge returns a bridge that emits no events when the Rust library is absent.
-func NewRustBridge(pairs []string, logger zerolog.Logger) (*RustBridge, error) {
-	b := &RustBridge{
-		stream: make(chan Candle),
-		log:    logger,
+// replay feeds recorded logs through the aggregator and closes every
+// candle at the end of the recording. Lines consumed by an earlier stream
+// are skipped.
+func (b *SwapBridge) replay(ctx context.Context, out chan<- Candle) error {
+	f, err := os.Open(b.opts.ReplayFile)
+	if err != nil {
+		return err
 	}
-	if len(pairs) > 0 {
-		logger.Warn().Msg("uniswap bridge unavailable: built without ta_engine; stream will remain idle")
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if line <= b.line || text == "" {
+			continue
+		}
+		var l ethLog
+		if err := json.Unmarshal([]byte(text), &l); err != nil {
+			return fmt.Errorf("%s:%d: %w", b.opts.ReplayFile, line, err)
+		}
+		ts, err := parseQuantity(l.BlockTimestamp)
+		if err != nil {
+			return fmt.Errorf("%s:%d: blockTimestamp: %w", b.opts.ReplayFile, line, err)
+		}
+		list, err := b.agg.apply(l, time.Unix(int64(ts), 0).UTC())
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", b.opts.ReplayFile, line, err)
+		}
+		b.line = line
+		if !b.send(ctx, out, list) {
+			return nil
+		}
 	}
-	return b, nil
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	list := b.agg.flush()
+	list = append(list, b.agg.closeBefore(time.Unix(1<<62, 0))...)
+	b.send(ctx, out, list)
+	return nil
 }
 
-// Stream exposes the candle channel.
-func (b *RustBridge) Stream() <-chan Candle {
-	return b.stream
+// poll follows the chain from the start block, or the block after the last
+// one an earlier stream read, staying Confirmations blocks behind head to
+// avoid most reorgs.
+func (b *SwapBridge) poll(ctx context.Context, out chan<- Candle, rpc *rpcClient) error {
+	addresses := b.agg.addresses()
+	for ctx.Err() == nil {
+		head, err := rpc.blockNumber(ctx)
+		if err != nil {
+			b.logger.Warn().Err(err).Msg("eth_blockNumber failed")
+			sleepCtx(ctx, b.opts.PollInterval)
+			continue
+		}
+		if head < b.opts.Confirmations {
+			sleepCtx(ctx, b.opts.PollInterval)
+			continue
+		}
+		head -= b.opts.Confirmations
+		if b.next == 0 {
+			back := uint64(b.opts.Lookback / (secondsPerBlock * time.Second))
+			if back < head {
+				b.next = head - back
+			}
+		}
+		if b.next > head {
+			sleepCtx(ctx, b.opts.PollInterval)
+			continue
+		}
+		to := b.next + b.opts.BlockRange - 1
+		if to > head {
+			to = head
+		}
+		if err := b.pollRange(ctx, out, rpc, addresses, b.next, to); err != nil {
+			b.logger.Warn().Err(err).Uint64("from", b.next).Uint64("to", to).Msg("uniswap log poll failed")
+			sleepCtx(ctx, b.opts.PollInterval)
+			continue
+		}
+		if to == head {
+			sleepCtx(ctx, b.opts.PollInterval)
+		}
+	}
+	return nil
 }
 
-// Close releases resources.
-func (b *RustBridge) Close() {
-	b.once.Do(func() {
-		close(b.stream)
-	})
+// pollRange applies the swaps in [from, to] and advances past to. The
+// candles built are delivered, or left pending if ctx ends.
+func (b *SwapBridge) pollRange(ctx context.Context, out chan<- Candle, rpc *rpcClient, addresses []string, from, to uint64) error {
+	logs, err := rpc.swapLogs(ctx, from, to, addresses)
+	if err != nil {
+		return err
+	}
+	keys := make([][2]uint64, len(logs))
+	for i, l := range logs {
+		block, err := parseQuantity(l.BlockNumber)
+		if err != nil {
+			return err
+		}
+		index, err := parseQuantity(l.LogIndex)
+		if err != nil {
+			return err
+		}
+		keys[i] = [2]uint64{block, index}
+	}
+	sort.Sort(logOrder{logs: logs, keys: keys})
+
+	// Resolve every timestamp before touching the aggregator so a failed
+	// lookup can be retried without double-counting swaps.
+	times := make(map[uint64]time.Time)
+	stamps := make([]time.Time, len(logs))
+	for i, l := range logs {
+		if stamps[i], err = b.blockTime(ctx, rpc, times, keys[i][0], l.BlockTimestamp); err != nil {
+			return err
+		}
+	}
+	end, err := b.blockTime(ctx, rpc, times, to, "")
+	if err != nil {
+		return err
+	}
+	var list []Candle
+	for i, l := range logs {
+		built, err := b.agg.apply(l, stamps[i])
+		if err != nil {
+			b.logger.Warn().Err(err).Msg("skipping undecodable swap log")
+		}
+		list = append(list, built...)
+	}
+	list = append(list, b.agg.flush()...)
+	list = append(list, b.agg.closeBefore(end)...)
+	b.next = to + 1
+	b.send(ctx, out, list)
+	return nil
+}
+
+func (b *SwapBridge) blockTime(ctx context.Context, rpc *rpcClient, cache map[uint64]time.Time, number uint64, hint string) (time.Time, error) {
+	if ts, ok := cache[number]; ok {
+		return ts, nil
+	}
+	var ts time.Time
+	if secs, err := parseQuantity(hint); err == nil {
+		ts = time.Unix(int64(secs), 0).UTC()
+	} else if ts, err = rpc.blockTime(ctx, number); err != nil {
+		return time.Time{}, err
+	}
+	cache[number] = ts
+	return ts, nil
+}
+
+type logOrder struct {
+	logs []ethLog
+	keys [][2]uint64
+}
+
+func (o logOrder) Len() int { return len(o.logs) }
+
+func (o logOrder) Less(i, j int) bool {
+	if o.keys[i][0] != o.keys[j][0] {
+		return o.keys[i][0] < o.keys[j][0]
+	}
+	return o.keys[i][1] < o.keys[j][1]
+}
+
+func (o logOrder) Swap(i, j int) {
+	o.logs[i], o.logs[j] = o.logs[j], o.logs[i]
+	o.keys[i], o.keys[j] = o.keys[j], o.keys[i]
+}
+
+func sleepCtx(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+	case <-t.C:
+	}
 }
@@ -0,0 +1,105 @@
+package candles
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// rpcClient is a minimal Ethereum JSON-RPC client covering the calls the
+// swap bridge needs.
+type rpcClient struct {
+	url  string
+	http *http.Client
+	id   atomic.Uint64
+}
+
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func newRPCClient(url string, client *http.Client) *rpcClient {
+	if client == nil {
+		client = &http.Client{Timeout: 15 * time.Second}
+	}
+	return &rpcClient{url: url, http: client}
+}
+
+func (c *rpcClient) call(ctx context.Context, method string, params []interface{}, out interface{}) error {
+	body, err := json.Marshal(rpcRequest{JSONRPC: "2.0", ID: c.id.Add(1), Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected status %d", method, resp.StatusCode)
+	}
+	var decoded rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	if decoded.Error != nil {
+		return fmt.Errorf("%s: rpc error %d: %s", method, decoded.Error.Code, decoded.Error.Message)
+	}
+	return json.Unmarshal(decoded.Result, out)
+}
+
+func (c *rpcClient) blockNumber(ctx context.Context) (uint64, error) {
+	var out string
+	if err := c.call(ctx, "eth_blockNumber", nil, &out); err != nil {
+		return 0, err
+	}
+	return parseQuantity(out)
+}
+
+func (c *rpcClient) blockTime(ctx context.Context, number uint64) (time.Time, error) {
+	var out struct {
+		Timestamp string `json:"timestamp"`
+	}
+	if err := c.call(ctx, "eth_getBlockByNumber", []interface{}{formatQuantity(number), false}, &out); err != nil {
+		return time.Time{}, err
+	}
+	ts, err := parseQuantity(out.Timestamp)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("block %d timestamp: %w", number, err)
+	}
+	return time.Unix(int64(ts), 0).UTC(), nil
+}
+
+func (c *rpcClient) swapLogs(ctx context.Context, from, to uint64, addresses []string) ([]ethLog, error) {
+	filter := map[string]interface{}{
+		"fromBlock": formatQuantity(from),
+		"toBlock":   formatQuantity(to),
+		"address":   addresses,
+		"topics":    [][]string{{topicV2Swap, topicV2Sync, topicV3Swap}},
+	}
+	var out []ethLog
+	if err := c.call(ctx, "eth_getLogs", []interface{}{filter}, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
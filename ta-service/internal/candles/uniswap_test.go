@@ -0,0 +1,244 @@
+package candles
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/config"
+)
+
+const swapFixture = "testdata/uniswap_swaps.jsonl"
+
+var fixturePools = []string{
+	"ETHUSDT=0x0d4a11d5eeaac28ec3f61d100daf4d40471f1852:v2:18:6",
+	"ETHUSDC=0x88e6a0c2ddd26feeb64f039a2c41296fcb3f5640:v3:6:18:inverse",
+}
+
+var fixtureStart = time.Unix(1699999980, 0).UTC()
+
+func newFixtureBridge(t *testing.T, opts SwapOptions) *SwapBridge {
+	t.Helper()
+	pools, err := ParsePools(fixturePools)
+	if err != nil {
+		t.Fatalf("parse pools: %v", err)
+	}
+	opts.Pools = pools
+	opts.Interval = "1m"
+	b, err := NewSwapBridge(opts, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("new bridge: %v", err)
+	}
+	t.Cleanup(b.Close)
+	return b
+}
+
+// collectClosed drains the stream until want closed candles arrive, keyed by pair and start.
+func collectClosed(t *testing.T, stream <-chan Candle, want int) map[string]Candle {
+	t.Helper()
+	out := make(map[string]Candle)
+	timeout := time.After(5 * time.Second)
+	for len(out) < want {
+		select {
+		case c, ok := <-stream:
+			if !ok {
+				t.Fatalf("stream closed after %d of %d closed candles", len(out), want)
+			}
+			if c.Closed {
+				out[c.Pair+"@"+c.Start.Format(time.RFC3339)] = c
+			}
+		case <-timeout:
+			t.Fatalf("timed out after %d of %d closed candles", len(out), want)
+		}
+	}
+	return out
+}
+
+func assertCandle(t *testing.T, got map[string]Candle, pair string, start time.Time, o, h, l, c, v float64) {
+	t.Helper()
+	candle, ok := got[pair+"@"+start.Format(time.RFC3339)]
+	if !ok {
+		t.Fatalf("missing %s candle at %s", pair, start)
+	}
+	if candle.Exchange != "uniswap" || candle.Interval != "1m" {
+		t.Fatalf("unexpected candle identity %+v", candle)
+	}
+	for _, f := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"open", candle.Open, o},
+		{"high", candle.High, h},
+		{"low", candle.Low, l},
+		{"close", candle.Close, c},
+		{"volume", candle.Volume, v},
+	} {
+		if math.Abs(f.got-f.want) > 1e-6 {
+			t.Fatalf("%s %s: expected %.6f, got %.6f", pair, f.name, f.want, f.got)
+		}
+	}
+}
+
+func TestSwapBridgeReplay(t *testing.T) {
+	b := newFixtureBridge(t, SwapOptions{ReplayFile: swapFixture})
+	stream := b.Stream(context.Background())
+	got := collectClosed(t, stream, 3)
+	// Block 1 holds two swaps at 2010 and 1990 whose volume-weighted price,
+	// 2005, is what reaches the candle.
+	assertCandle(t, got, "ETHUSDT", fixtureStart, 2000, 2020, 2000, 2020, 6)
+	assertCandle(t, got, "ETHUSDT", fixtureStart.Add(time.Minute), 2015, 2015, 2015, 2015, 0.5)
+	assertCandle(t, got, "ETHUSDC", fixtureStart, 2000, 2002, 2000, 2002, 3)
+	for range stream {
+	}
+}
+
+// fakeNode serves the fixture swaps over JSON-RPC with a movable head.
+type fakeNode struct {
+	*httptest.Server
+	head atomic.Uint64
+}
+
+func newFakeNode(t *testing.T, head uint64) *fakeNode {
+	t.Helper()
+	var logs []ethLog
+	f, err := os.Open(swapFixture)
+	if err != nil {
+		t.Fatalf("open fixture: %v", err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var l ethLog
+		if err := json.Unmarshal(scanner.Bytes(), &l); err != nil {
+			t.Fatalf("decode fixture: %v", err)
+		}
+		// Real nodes omit timestamps, forcing block lookups.
+		l.BlockTimestamp = ""
+		logs = append(logs, l)
+	}
+
+	node := &fakeNode{}
+	node.head.Store(head)
+	node.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID     uint64            `json:"id"`
+			Method string            `json:"method"`
+			Params []json.RawMessage `json:"params"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var result interface{}
+		switch req.Method {
+		case "eth_blockNumber":
+			result = formatQuantity(node.head.Load())
+		case "eth_getBlockByNumber":
+			var number string
+			_ = json.Unmarshal(req.Params[0], &number)
+			// Fixture blocks are 12s apart from block 18000000.
+			n, _ := parseQuantity(number)
+			result = map[string]string{"number": number, "timestamp": formatQuantity(uint64(fixtureStart.Unix()) + 12*(n-18000000))}
+		case "eth_getLogs":
+			var filter struct {
+				FromBlock string `json:"fromBlock"`
+				ToBlock   string `json:"toBlock"`
+			}
+			_ = json.Unmarshal(req.Params[0], &filter)
+			from, _ := parseQuantity(filter.FromBlock)
+			to, _ := parseQuantity(filter.ToBlock)
+			matched := []ethLog{}
+			for _, l := range logs {
+				if n, _ := parseQuantity(l.BlockNumber); n >= from && n <= to {
+					matched = append(matched, l)
+				}
+			}
+			result = matched
+		default:
+			http.Error(w, "unknown method", http.StatusBadRequest)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": req.ID, "result": result})
+	}))
+	t.Cleanup(node.Close)
+	return node
+}
+
+func TestSwapBridgeJSONRPC(t *testing.T) {
+	node := newFakeNode(t, 18000005+2)
+	b := newFixtureBridge(t, SwapOptions{
+		RPCURL:        node.URL,
+		StartBlock:    18000000,
+		Confirmations: 2,
+		PollInterval:  time.Hour,
+	})
+	// Head is block 5 at the start of the second minute, so only the first
+	// minute's candles have closed.
+	got := collectClosed(t, b.Stream(context.Background()), 2)
+	assertCandle(t, got, "ETHUSDT", fixtureStart, 2000, 2020, 2000, 2020, 6)
+	assertCandle(t, got, "ETHUSDC", fixtureStart, 2000, 2002, 2000, 2002, 3)
+}
+
+// TestUniswapAcrossLeadershipTerms collects through two leadership terms in
+// a row, as a replica does when it loses the collector lock and wins it back.
+func TestUniswapAcrossLeadershipTerms(t *testing.T) {
+	node := newFakeNode(t, 18000005+2)
+	b := newFixtureBridge(t, SwapOptions{
+		RPCURL:        node.URL,
+		StartBlock:    18000000,
+		Confirmations: 2,
+		PollInterval:  10 * time.Millisecond,
+	})
+	svc := NewService(config.Config{CandleLimit: 100, Interval: "1m", UniswapPairs: fixturePools}, nil, nil, b, zerolog.Nop())
+	closed := make(chan Candle, 16)
+	svc.OnCandleClose(func(c Candle) { closed <- c })
+
+	term, end := context.WithCancel(context.Background())
+	svc.Start(term)
+	got := collectClosed(t, closed, 2)
+	assertCandle(t, got, "ETHUSDT", fixtureStart, 2000, 2020, 2000, 2020, 6)
+	assertCandle(t, got, "ETHUSDC", fixtureStart, 2000, 2002, 2000, 2002, 3)
+	end()
+
+	// The chain moves on while another replica leads.
+	node.head.Store(18000010 + 2)
+	term, end = context.WithCancel(context.Background())
+	defer end()
+	svc.Start(term)
+	// The second minute's swaps were read in the first term; counting them
+	// again would double the volume.
+	got = collectClosed(t, closed, 1)
+	assertCandle(t, got, "ETHUSDT", fixtureStart.Add(time.Minute), 2015, 2015, 2015, 2015, 0.5)
+	if list := svc.Candles("uniswap", "ETHUSDT", "1m"); len(list) != 2 {
+		t.Fatalf("expected 2 buffered ETHUSDT candles, got %d", len(list))
+	}
+}
+
+func TestParsePool(t *testing.T) {
+	p, err := ParsePool("ethusdc=0x88E6A0c2dDD26FEEb64F039a2c41296FcB3f5640:v3:6:18:inverse")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	want := Pool{Name: "ETHUSDC", Address: "0x88e6a0c2ddd26feeb64f039a2c41296fcb3f5640", Version: 3, Decimals0: 6, Decimals1: 18, Inverse: true}
+	if p != want {
+		t.Fatalf("expected %+v, got %+v", want, p)
+	}
+	bare, err := ParsePool("0x0000000000000000000000000000000000000000")
+	if err != nil || bare.Version != 2 || bare.Decimals0 != 18 || bare.Name != bare.Address {
+		t.Fatalf("unexpected bare pool %+v (%v)", bare, err)
+	}
+	for _, spec := range []string{"0x1234", "X=0x0000000000000000000000000000000000000000:v4:18:18", "X=0x0000000000000000000000000000000000000000:v2:18"} {
+		if _, err := ParsePool(spec); err == nil {
+			t.Fatalf("expected error for %q", spec)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package candles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/storage"
+)
+
+// watchRefresh is how often the collector reloads the watchlist when
+// Config.WatchRefresh is unset.
+const watchRefresh = 30 * time.Second
+
+// watchMaxSymbols caps the runtime watchlist when Config.WatchMaxSymbols is
+// unset.
+const watchMaxSymbols = 50
+
+// Watchlist errors.
+var (
+	ErrInvalidSymbol    = errors.New("invalid symbol")
+	ErrInvalidInterval  = errors.New("unsupported interval")
+	ErrConfiguredSymbol = errors.New("symbol is configured statically")
+	ErrNotWatched       = errors.New("symbol is not watched")
+	ErrTooManySymbols   = errors.New("too many watched symbols")
+	ErrNotOwner         = errors.New("symbol was added by someone else")
+	ErrNoSymbolStore    = errors.New("symbol store unavailable")
+)
+
+var symbolPattern = regexp.MustCompile(`^[A-Z0-9]{5,20}$`)
+
+// binanceIntervals lists the kline intervals Binance streams.
+var binanceIntervals = map[string]bool{
+	"1s": true, "1m": true, "3m": true, "5m": true, "15m": true, "30m": true,
+	"1h": true, "2h": true, "4h": true, "6h": true, "8h": true, "12h": true,
+	"1d": true, "3d": true, "1w": true, "1M": true,
+}
+
+// ValidInterval reports whether interval is a Binance kline interval.
+func ValidInterval(interval string) bool {
+	return binanceIntervals[interval]
+}
+
+// SymbolStore persists the runtime watchlist; *storage.Store implements it.
+type SymbolStore interface {
+	ListSymbols(ctx context.Context) ([]storage.Symbol, error)
+	AddSymbol(ctx context.Context, sym storage.Symbol) error
+	RemoveSymbol(ctx context.Context, exchange, pair, interval string) (bool, error)
+}
+
+// Watched returns the configured symbols followed by those added at runtime.
+// On a store error the configured symbols are still returned.
+func (s *Service) Watched(ctx context.Context) ([]storage.Symbol, error) {
+	out := s.configured()
+	if s.symbols == nil {
+		return out, nil
+	}
+	stored, err := s.symbols.ListSymbols(ctx)
+	if err != nil {
+		return out, err
+	}
+	seen := make(map[string]bool, len(out))
+	for _, sym := range out {
+		seen[streamKey(sym.Pair, sym.Interval)] = true
+	}
+	for _, sym := range stored {
+		if sym.Exchange != "binance" || seen[streamKey(sym.Pair, sym.Interval)] {
+			continue
+		}
+		out = append(out, sym)
+	}
+	return out, nil
+}
+
+// Watch validates pair/interval against Binance, persists it and, on the
+// collecting replica, starts its stream. The stream backfills
+// Config.BackfillLookback on connect. Watching a tracked pair is a no-op, and
+// new pairs are refused once Config.WatchMaxSymbols are watched at runtime.
+func (s *Service) Watch(ctx context.Context, pair, interval, addedBy string) (storage.Symbol, error) {
+	pair = strings.ToUpper(strings.TrimSpace(pair))
+	sym := storage.Symbol{Exchange: "binance", Pair: pair, Interval: interval, AddedBy: addedBy, CreatedAt: time.Now().UTC()}
+	if !symbolPattern.MatchString(pair) {
+		return sym, fmt.Errorf("%w %q", ErrInvalidSymbol, pair)
+	}
+	if !binanceIntervals[interval] {
+		return sym, fmt.Errorf("%w %q", ErrInvalidInterval, interval)
+	}
+	if s.isConfigured(pair, interval) {
+		sym.AddedBy = "config"
+		return sym, nil
+	}
+	if s.symbols == nil {
+		return sym, ErrNoSymbolStore
+	}
+	// Probe the REST API so unknown symbols fail here rather than as a
+	// stream that never receives events.
+	dur, _ := IntervalDuration(interval)
+	now := time.Now()
+	if _, err := s.fetch(ctx, pair, interval, now.Add(-dur), now); err != nil {
+		return sym, fmt.Errorf("%w %q: %v", ErrInvalidSymbol, pair, err)
+	}
+
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	stored, err := s.symbols.ListSymbols(ctx)
+	if err != nil {
+		return sym, fmt.Errorf("list symbols: %w", err)
+	}
+	if findSymbol(stored, pair, interval) == nil && len(stored) >= s.maxSymbols() {
+		return sym, fmt.Errorf("%w: %d watched", ErrTooManySymbols, len(stored))
+	}
+	if err := s.symbols.AddSymbol(ctx, sym); err != nil {
+		return sym, fmt.Errorf("persist symbol: %w", err)
+	}
+	s.streamsMu.Lock()
+	if s.runCtx != nil {
+		if _, running := s.streams[streamKey(pair, interval)]; !running {
+			s.startStreamLocked(s.runCtx, pair, interval)
+		}
+	}
+	s.streamsMu.Unlock()
+	s.logger.Info().Str("symbol", pair).Str("interval", interval).Str("added_by", addedBy).Msg("symbol watched")
+	return sym, nil
+}
+
+// Unwatch removes a runtime symbol, stops its stream and, once it has
+// stopped, drops its buffered candles. Other replicas stop theirs on the next
+// watchlist reload. A non-empty owner only removes symbols it added.
+func (s *Service) Unwatch(ctx context.Context, pair, interval, owner string) error {
+	pair = strings.ToUpper(strings.TrimSpace(pair))
+	if s.isConfigured(pair, interval) {
+		return fmt.Errorf("%w: %s %s", ErrConfiguredSymbol, pair, interval)
+	}
+	if s.symbols == nil {
+		return ErrNoSymbolStore
+	}
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	if owner != "" {
+		stored, err := s.symbols.ListSymbols(ctx)
+		if err != nil {
+			return fmt.Errorf("list symbols: %w", err)
+		}
+		sym := findSymbol(stored, pair, interval)
+		if sym == nil {
+			return fmt.Errorf("%w: %s %s", ErrNotWatched, pair, interval)
+		}
+		if sym.AddedBy != owner {
+			return fmt.Errorf("%w: %s %s", ErrNotOwner, pair, interval)
+		}
+	}
+	removed, err := s.symbols.RemoveSymbol(ctx, "binance", pair, interval)
+	if err != nil {
+		return fmt.Errorf("remove symbol: %w", err)
+	}
+	if !removed {
+		return fmt.Errorf("%w: %s %s", ErrNotWatched, pair, interval)
+	}
+	s.streamsMu.Lock()
+	s.stopStreamLocked(streamKey(pair, interval))
+	s.streamsMu.Unlock()
+	s.logger.Info().Str("symbol", pair).Str("interval", interval).Msg("symbol unwatched")
+	return nil
+}
+
+// reconcile starts streams for watched symbols and stops removed ones. When
+// the store is unreachable running streams are left alone.
+func (s *Service) reconcile(ctx context.Context) {
+	s.watchMu.Lock()
+	defer s.watchMu.Unlock()
+	list, err := s.Watched(ctx)
+	if err != nil {
+		s.logger.Warn().Err(err).Msg("failed to load watchlist")
+	}
+	want := make(map[string]bool, len(list))
+	s.streamsMu.Lock()
+	defer s.streamsMu.Unlock()
+	if ctx.Err() != nil {
+		return
+	}
+	for _, sym := range list {
+		key := streamKey(sym.Pair, sym.Interval)
+		want[key] = true
+		if _, running := s.streams[key]; !running {
+			s.startStreamLocked(ctx, sym.Pair, sym.Interval)
+		}
+	}
+	if err != nil {
+		return
+	}
+	for key := range s.streams {
+		if !want[key] {
+			s.stopStreamLocked(key)
+		}
+	}
+}
+
+func (s *Service) maxSymbols() int {
+	if s.cfg.WatchMaxSymbols > 0 {
+		return s.cfg.WatchMaxSymbols
+	}
+	return watchMaxSymbols
+}
+
+// findSymbol returns the Binance entry for pair/interval in list, or nil.
+func findSymbol(list []storage.Symbol, pair, interval string) *storage.Symbol {
+	for i := range list {
+		if list[i].Exchange == "binance" && list[i].Pair == pair && list[i].Interval == interval {
+			return &list[i]
+		}
+	}
+	return nil
+}
+
+func (s *Service) configured() []storage.Symbol {
+	out := make([]storage.Symbol, 0, len(s.cfg.BinanceSymbols))
+	for _, symbol := range s.cfg.BinanceSymbols {
+		out = append(out, storage.Symbol{Exchange: "binance", Pair: strings.ToUpper(symbol), Interval: s.cfg.Interval, AddedBy: "config"})
+	}
+	return out
+}
+
+func (s *Service) isConfigured(pair, interval string) bool {
+	if interval != s.cfg.Interval {
+		return false
+	}
+	for _, symbol := range s.cfg.BinanceSymbols {
+		if strings.EqualFold(symbol, pair) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,138 @@
+package candles
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/config"
+	"github.com/example/tg-crypto-trader/ta-service/internal/storage"
+)
+
+type fakeSymbols struct {
+	mu   sync.Mutex
+	rows []storage.Symbol
+}
+
+func (f *fakeSymbols) ListSymbols(context.Context) ([]storage.Symbol, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]storage.Symbol(nil), f.rows...), nil
+}
+
+func (f *fakeSymbols) AddSymbol(_ context.Context, sym storage.Symbol) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, row := range f.rows {
+		if row.Pair == sym.Pair && row.Interval == sym.Interval {
+			return nil
+		}
+	}
+	f.rows = append(f.rows, sym)
+	return nil
+}
+
+func (f *fakeSymbols) RemoveSymbol(_ context.Context, _, pair, interval string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, row := range f.rows {
+		if row.Pair == pair && row.Interval == interval {
+			f.rows = append(f.rows[:i], f.rows[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestWatchStartsAndStopsStreams(t *testing.T) {
+	fake := &fakeBinance{conns: make(chan fakeConn, 4)}
+	svc := NewService(config.Config{
+		CandleLimit:      100,
+		Interval:         "1m",
+		BinanceSymbols:   []string{"ETHUSDT"},
+		BackfillLookback: 3 * time.Minute,
+		WatchMaxSymbols:  1,
+	}, nil, nil, nil, zerolog.Nop())
+	svc.dial = fake.dial
+	svc.fetch = fake.fetch
+	svc.symbols = &fakeSymbols{}
+	closed := make(chan Candle, 64)
+	svc.OnCandleClose(func(c Candle) { closed <- c })
+	dropped := make(chan string, 4)
+	svc.OnDrop(func(exchange, pair, interval string) { dropped <- exchange + ":" + pair + ":" + interval })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	svc.Start(ctx)
+	nextConn(t, fake.conns)
+	for i := 0; i < 3; i++ {
+		nextClosed(t, closed)
+	}
+
+	if _, err := svc.Watch(ctx, "btc-usdt", "1m", "test"); !errors.Is(err, ErrInvalidSymbol) {
+		t.Fatalf("expected invalid symbol, got %v", err)
+	}
+	if _, err := svc.Watch(ctx, "BTCUSDT", "7m", "test"); !errors.Is(err, ErrInvalidInterval) {
+		t.Fatalf("expected invalid interval, got %v", err)
+	}
+
+	// A new pair is persisted, streamed and backfilled immediately.
+	if _, err := svc.Watch(ctx, "btcusdt", "1m", "test"); err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	conn := nextConn(t, fake.conns)
+	for i := 0; i < 3; i++ {
+		if c := nextClosed(t, closed); c.Pair != "BTCUSDT" {
+			t.Fatalf("expected BTCUSDT backfill, got %s", c.Pair)
+		}
+	}
+	watched, err := svc.Watched(ctx)
+	if err != nil || len(watched) != 2 || watched[1].Pair != "BTCUSDT" || watched[1].AddedBy != "test" {
+		t.Fatalf("unexpected watchlist %+v (%v)", watched, err)
+	}
+	if len(svc.StreamHealth()) != 2 {
+		t.Fatalf("expected two streams, got %+v", svc.StreamHealth())
+	}
+	if _, err := svc.Watch(ctx, "SOLUSDT", "1m", "test"); !errors.Is(err, ErrTooManySymbols) {
+		t.Fatalf("expected the watchlist limit, got %v", err)
+	}
+	if _, err := svc.Watch(ctx, "BTCUSDT", "1m", "test"); err != nil {
+		t.Fatalf("re-watching at the limit: %v", err)
+	}
+
+	if err := svc.Unwatch(ctx, "ETHUSDT", "1m", ""); !errors.Is(err, ErrConfiguredSymbol) {
+		t.Fatalf("expected configured symbols to be protected, got %v", err)
+	}
+	if err := svc.Unwatch(ctx, "BTCUSDT", "1m", "other"); !errors.Is(err, ErrNotOwner) {
+		t.Fatalf("expected another owner to be refused, got %v", err)
+	}
+	if err := svc.Unwatch(ctx, "BTCUSDT", "1m", "test"); err != nil {
+		t.Fatalf("unwatch: %v", err)
+	}
+	select {
+	case <-conn.done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the BTCUSDT stream to close")
+	}
+	select {
+	case key := <-dropped:
+		if key != "binance:BTCUSDT:1m" {
+			t.Fatalf("dropped %s", key)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the BTCUSDT candles to be dropped")
+	}
+	if n := len(svc.Candles("binance", "BTCUSDT", "1m")); n != 0 {
+		t.Fatalf("%d BTCUSDT candles still buffered", n)
+	}
+	if health := svc.StreamHealth(); len(health) != 1 || health[0].Symbol != "ETHUSDT" {
+		t.Fatalf("unexpected streams after unwatch %+v", health)
+	}
+	if err := svc.Unwatch(ctx, "BTCUSDT", "1m", ""); !errors.Is(err, ErrNotWatched) {
+		t.Fatalf("expected not watched, got %v", err)
+	}
+}
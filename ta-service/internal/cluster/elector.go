@@ -0,0 +1,156 @@
+// Package cluster coordinates TA replicas through Redis: one elected
+// collector ingests exchange streams and publishes closed candles and fired
+// alerts, and the other replicas subscribe to keep their buffers current and
+// push alerts to their WebSocket clients.
+package cluster
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+)
+
+var renewScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// NewRedisClient accepts either a redis:// URL or a bare host:port address.
+func NewRedisClient(raw string) (*redis.Client, error) {
+	if strings.Contains(raw, "://") {
+		opts, err := redis.ParseURL(raw)
+		if err != nil {
+			return nil, err
+		}
+		return redis.NewClient(opts), nil
+	}
+	return redis.NewClient(&redis.Options{Addr: raw}), nil
+}
+
+// Elector campaigns for a Redis lock so exactly one replica collects.
+type Elector struct {
+	client *redis.Client
+	key    string
+	id     string
+	ttl    time.Duration
+	logger zerolog.Logger
+	leader atomic.Bool
+}
+
+// NewElector returns an Elector holding key with the given TTL.
+func NewElector(client *redis.Client, key, id string, ttl time.Duration, logger zerolog.Logger) *Elector {
+	if ttl <= 0 {
+		ttl = 15 * time.Second
+	}
+	return &Elector{client: client, key: key, id: id, ttl: ttl, logger: logger}
+}
+
+// IsLeader reports whether this replica currently holds the lock.
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Run campaigns until ctx ends. lead runs while this replica holds the lock
+// and follow runs while another replica does; each receives a context that
+// is cancelled when the role changes, and a role has returned before the
+// next one starts.
+func (e *Elector) Run(ctx context.Context, lead, follow func(ctx context.Context)) {
+	retry := e.ttl / 3
+	var stopFollow func()
+	defer func() {
+		if stopFollow != nil {
+			stopFollow()
+		}
+	}()
+	for {
+		acquired, err := e.client.SetNX(ctx, e.key, e.id, e.ttl).Result()
+		if err != nil && ctx.Err() == nil {
+			e.logger.Warn().Err(err).Msg("leader lock attempt failed")
+		}
+		if acquired {
+			if stopFollow != nil {
+				stopFollow()
+				stopFollow = nil
+			}
+			e.lead(ctx, lead)
+		} else if stopFollow == nil && ctx.Err() == nil {
+			e.logger.Info().Msg("following elected collector")
+			stopFollow = startRole(ctx, follow)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+	}
+}
+
+// startRole runs role in a goroutine. The returned stop cancels it and
+// waits for it to return.
+func startRole(ctx context.Context, role func(ctx context.Context)) (stop func()) {
+	roleCtx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		role(roleCtx)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// lead holds the lock, renewing it until ctx ends, another replica holds it
+// or renewals have failed for a whole TTL. The lead role has stopped by the
+// time it returns.
+func (e *Elector) lead(ctx context.Context, lead func(ctx context.Context)) {
+	e.leader.Store(true)
+	defer e.leader.Store(false)
+	e.logger.Info().Str("instance", e.id).Msg("elected candle collector")
+	stop := startRole(ctx, lead)
+
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+	renewed := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			// Stop collecting before another replica can take over.
+			stop()
+			releaseCtx, done := context.WithTimeout(context.Background(), time.Second)
+			defer done()
+			if err := releaseScript.Run(releaseCtx, e.client, []string{e.key}, e.id).Err(); err != nil {
+				e.logger.Warn().Err(err).Msg("failed to release leader lock")
+			}
+			return
+		case <-ticker.C:
+			ok, err := renewScript.Run(ctx, e.client, []string{e.key}, e.id, e.ttl.Milliseconds()).Int()
+			switch {
+			case ctx.Err() != nil:
+			case err == nil && ok == 1:
+				renewed = time.Now()
+			case err == nil:
+				e.logger.Warn().Msg("lost leader lock")
+				stop()
+				return
+			case time.Since(renewed) >= e.ttl:
+				e.logger.Warn().Err(err).Msg("leader lock expired while renewals failed")
+				stop()
+				return
+			default:
+				e.logger.Warn().Err(err).Msg("failed to renew leader lock; retrying")
+			}
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package cluster
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+)
+
+const testKey = "ta:collector:leader"
+
+func newRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return mr, client
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// roles records the contexts an elector hands to its lead and follow roles.
+type roles struct {
+	mu      sync.Mutex
+	leads   []context.Context
+	follows []context.Context
+}
+
+func (r *roles) lead(ctx context.Context) {
+	r.mu.Lock()
+	r.leads = append(r.leads, ctx)
+	r.mu.Unlock()
+}
+
+func (r *roles) follow(ctx context.Context) {
+	r.mu.Lock()
+	r.follows = append(r.follows, ctx)
+	r.mu.Unlock()
+}
+
+func (r *roles) counts() (leads, follows int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.leads), len(r.follows)
+}
+
+func (r *roles) lastLead() context.Context {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.leads[len(r.leads)-1]
+}
+
+func (r *roles) lastFollow() context.Context {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.follows[len(r.follows)-1]
+}
+
+func runElector(t *testing.T, client *redis.Client, id string) (*Elector, *roles, context.CancelFunc) {
+	t.Helper()
+	e := NewElector(client, testKey, id, 300*time.Millisecond, zerolog.Nop())
+	r := &roles{}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.Run(ctx, r.lead, r.follow)
+	}()
+	stop := func() {
+		cancel()
+		<-done
+	}
+	t.Cleanup(stop)
+	return e, r, stop
+}
+
+func TestElectorAcquireRenewRelease(t *testing.T) {
+	mr, client := newRedis(t)
+	e, r, stop := runElector(t, client, "a")
+
+	waitFor(t, "leadership", e.IsLeader)
+	if got, _ := mr.Get(testKey); got != "a" {
+		t.Fatalf("lock held by %q, want a", got)
+	}
+	if ttl := mr.TTL(testKey); ttl <= 0 || ttl > 300*time.Millisecond {
+		t.Fatalf("lock ttl %v", ttl)
+	}
+
+	// miniredis only expires keys on FastForward; shorten the TTL and wait
+	// for the renewal to restore it.
+	mr.SetTTL(testKey, time.Millisecond)
+	waitFor(t, "renewal", func() bool { return mr.TTL(testKey) == 300*time.Millisecond })
+	if leads, follows := r.counts(); leads != 1 || follows != 0 {
+		t.Fatalf("lead ran %d times, follow %d times", leads, follows)
+	}
+
+	leadCtx := r.lastLead()
+	stop()
+	if leadCtx.Err() == nil {
+		t.Fatal("lead context still live after shutdown")
+	}
+	if mr.Exists(testKey) {
+		t.Fatal("lock not released on shutdown")
+	}
+	if e.IsLeader() {
+		t.Fatal("still leader after shutdown")
+	}
+}
+
+func TestElectorLockLoss(t *testing.T) {
+	mr, client := newRedis(t)
+	e, r, _ := runElector(t, client, "a")
+	waitFor(t, "leadership", e.IsLeader)
+	leadCtx := r.lastLead()
+
+	// Another replica took the lock, e.g. after this one stalled past the TTL.
+	if err := mr.Set(testKey, "b"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "lead context cancelled", func() bool { return leadCtx.Err() != nil })
+	waitFor(t, "follow role", func() bool { _, follows := r.counts(); return follows == 1 })
+	if e.IsLeader() {
+		t.Fatal("still leader after losing the lock")
+	}
+	if got, _ := mr.Get(testKey); got != "b" {
+		t.Fatalf("renewal overwrote the new holder's lock: %q", got)
+	}
+}
+
+func TestElectorFailover(t *testing.T) {
+	mr, client := newRedis(t)
+	a, ra, stopA := runElector(t, client, "a")
+	waitFor(t, "a leads", a.IsLeader)
+	b, rb, _ := runElector(t, client, "b")
+	waitFor(t, "b follows", func() bool { _, follows := rb.counts(); return follows == 1 })
+	if b.IsLeader() {
+		t.Fatal("two leaders")
+	}
+	followCtx := rb.lastFollow()
+
+	stopA()
+	if leads, _ := ra.counts(); leads != 1 {
+		t.Fatalf("a led %d times", leads)
+	}
+	waitFor(t, "b leads", b.IsLeader)
+	if followCtx.Err() == nil {
+		t.Fatal("b's follow context still live after it was elected")
+	}
+	if got, _ := mr.Get(testKey); got != "b" {
+		t.Fatalf("lock held by %q after failover", got)
+	}
+}
+
+func TestElectorWaitsForPreviousTerm(t *testing.T) {
+	mr, client := newRedis(t)
+	e := NewElector(client, testKey, "a", 300*time.Millisecond, zerolog.Nop())
+	var mu sync.Mutex
+	active, maxActive, terms := 0, 0, 0
+	lead := func(ctx context.Context) {
+		mu.Lock()
+		active++
+		terms++
+		if active > maxActive {
+			maxActive = active
+		}
+		mu.Unlock()
+		<-ctx.Done()
+		// A slow shutdown, like the collector draining its streams.
+		time.Sleep(300 * time.Millisecond)
+		mu.Lock()
+		active--
+		mu.Unlock()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.Run(ctx, lead, func(context.Context) {})
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	waitFor(t, "leadership", e.IsLeader)
+	// The lock briefly moves away and frees up again.
+	if err := mr.Set(testKey, "b"); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "lock loss", func() bool { return !e.IsLeader() })
+	mr.Del(testKey)
+	waitFor(t, "second term", func() bool { mu.Lock(); defer mu.Unlock(); return terms == 2 })
+	mu.Lock()
+	defer mu.Unlock()
+	if maxActive != 1 {
+		t.Fatalf("%d terms ran at once", maxActive)
+	}
+}
+
+func TestElectorRetriesRenewal(t *testing.T) {
+	mr, client := newRedis(t)
+	e, r, _ := runElector(t, client, "a")
+	waitFor(t, "leadership", e.IsLeader)
+	leadCtx := r.lastLead()
+
+	// A blip shorter than the TTL keeps the lock.
+	mr.SetError("LOADING Redis is loading the dataset in memory")
+	time.Sleep(120 * time.Millisecond)
+	mr.SetError("")
+	waitFor(t, "renewal", func() bool { return mr.TTL(testKey) == 300*time.Millisecond })
+	if leadCtx.Err() != nil || !e.IsLeader() {
+		t.Fatal("gave up leadership on a transient renewal error")
+	}
+
+	// Failing for a whole TTL gives it up.
+	mr.SetError("LOADING Redis is loading the dataset in memory")
+	waitFor(t, "lead context cancelled", func() bool { return leadCtx.Err() != nil })
+	mr.SetError("")
+	if leads, _ := r.counts(); leads != 1 {
+		t.Fatalf("lead ran %d times before the lock was lost", leads)
+	}
+}
@@ -0,0 +1,113 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/alerts"
+	"github.com/example/tg-crypto-trader/ta-service/internal/candles"
+)
+
+// publishQueue bounds the messages awaiting fan-out.
+const publishQueue = 1024
+
+// Publisher fans closed candles, or other messages such as fired alerts, out
+// to replicas while this replica leads.
+type Publisher struct {
+	client  *redis.Client
+	channel string
+	elector *Elector
+	logger  zerolog.Logger
+	queue   chan []byte
+}
+
+// NewPublisher returns a Publisher for channel. Messages are sent by Run.
+func NewPublisher(client *redis.Client, channel string, elector *Elector, logger zerolog.Logger) *Publisher {
+	return &Publisher{client: client, channel: channel, elector: elector, logger: logger, queue: make(chan []byte, publishQueue)}
+}
+
+// Publish queues the candle for replicas. It is a no-op on followers so it
+// can be registered as a candle-close listener on every replica, and it
+// never blocks the ingest path; candles are dropped while Redis is behind.
+func (p *Publisher) Publish(c candles.Candle) {
+	p.Send(c)
+}
+
+// Send queues any JSON payload for replicas, with the same rules as Publish.
+func (p *Publisher) Send(v interface{}) {
+	if !p.elector.IsLeader() {
+		return
+	}
+	payload, err := json.Marshal(v)
+	if err != nil {
+		p.logger.Error().Err(err).Str("channel", p.channel).Msg("marshal fan-out message")
+		return
+	}
+	select {
+	case p.queue <- payload:
+	default:
+		p.logger.Warn().Str("channel", p.channel).Msg("fan-out queue full; message not published")
+	}
+}
+
+// Run sends queued messages until ctx ends.
+func (p *Publisher) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case payload := <-p.queue:
+			sendCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+			if err := p.client.Publish(sendCtx, p.channel, payload).Err(); err != nil && ctx.Err() == nil {
+				p.logger.Error().Err(err).Str("channel", p.channel).Msg("fan-out publish failed")
+			}
+			cancel()
+		}
+	}
+}
+
+// Subscribe hands candles published on channel to ingest until ctx ends.
+func Subscribe(ctx context.Context, client *redis.Client, channel string, ingest func(candles.Candle), logger zerolog.Logger) {
+	listen(ctx, client, channel, func(payload string) {
+		var c candles.Candle
+		if err := json.Unmarshal([]byte(payload), &c); err != nil {
+			logger.Warn().Err(err).Msg("invalid candle on fan-out channel")
+			return
+		}
+		ingest(c)
+	})
+}
+
+// SubscribeAlerts hands alerts fired on the collector and published on
+// channel to deliver until ctx ends.
+func SubscribeAlerts(ctx context.Context, client *redis.Client, channel string, deliver func(alerts.Fired), logger zerolog.Logger) {
+	listen(ctx, client, channel, func(payload string) {
+		var f alerts.Fired
+		if err := json.Unmarshal([]byte(payload), &f); err != nil {
+			logger.Warn().Err(err).Msg("invalid alert on fan-out channel")
+			return
+		}
+		deliver(f)
+	})
+}
+
+func listen(ctx context.Context, client *redis.Client, channel string, handle func(payload string)) {
+	sub := client.Subscribe(ctx, channel)
+	defer sub.Close()
+	msgs := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-msgs:
+			if !ok {
+				return
+			}
+			handle(msg.Payload)
+		}
+	}
+}
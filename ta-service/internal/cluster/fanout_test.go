@@ -0,0 +1,102 @@
+package cluster
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/alerts"
+	"github.com/example/tg-crypto-trader/ta-service/internal/candles"
+)
+
+func TestFanout(t *testing.T) {
+	_, client := newRedis(t)
+	leader, _, _ := runElector(t, client, "a")
+	waitFor(t, "leadership", leader.IsLeader)
+	follower := NewElector(client, testKey, "b", time.Second, zerolog.Nop())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var mu sync.Mutex
+	var got []candles.Candle
+	go Subscribe(ctx, client, "ta:candles", func(c candles.Candle) {
+		mu.Lock()
+		got = append(got, c)
+		mu.Unlock()
+	}, zerolog.Nop())
+	// Wait for the subscription before publishing; Redis drops messages
+	// nobody is subscribed to.
+	waitFor(t, "subscription", func() bool {
+		n, _ := client.PubSubNumSub(ctx, "ta:candles").Result()
+		return n["ta:candles"] == 1
+	})
+
+	pub := NewPublisher(client, "ta:candles", leader, zerolog.Nop())
+	go pub.Run(ctx)
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	pub.Publish(candles.Candle{Exchange: "binance", Pair: "ETHUSDT", Interval: "1m", Close: 2300, Start: start, Closed: true})
+	// Followers register the same listener; their candles must not be sent.
+	NewPublisher(client, "ta:candles", follower, zerolog.Nop()).Publish(candles.Candle{Pair: "BTCUSDT"})
+
+	waitFor(t, "fan-out", func() bool { mu.Lock(); defer mu.Unlock(); return len(got) == 1 })
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 1 {
+		t.Fatalf("received %d candles, want 1", len(got))
+	}
+	if c := got[0]; c.Pair != "ETHUSDT" || c.Close != 2300 || !c.Start.Equal(start) || !c.Closed {
+		t.Fatalf("unexpected candle %+v", c)
+	}
+}
+
+func TestPublishDoesNotBlock(t *testing.T) {
+	_, client := newRedis(t)
+	leader, _, _ := runElector(t, client, "a")
+	waitFor(t, "leadership", leader.IsLeader)
+
+	// Nothing drains the queue, as when Redis is down.
+	pub := NewPublisher(client, "ta:candles", leader, zerolog.Nop())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 2*publishQueue; i++ {
+			pub.Publish(candles.Candle{Pair: "ETHUSDT"})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Publish blocked on a full queue")
+	}
+}
+
+func TestAlertFanout(t *testing.T) {
+	_, client := newRedis(t)
+	leader, _, _ := runElector(t, client, "a")
+	waitFor(t, "leadership", leader.IsLeader)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	got := make(chan alerts.Fired, 1)
+	go SubscribeAlerts(ctx, client, "ta:alerts", func(f alerts.Fired) { got <- f }, zerolog.Nop())
+	waitFor(t, "subscription", func() bool {
+		n, _ := client.PubSubNumSub(ctx, "ta:alerts").Result()
+		return n["ta:alerts"] == 1
+	})
+
+	pub := NewPublisher(client, "ta:alerts", leader, zerolog.Nop())
+	go pub.Run(ctx)
+	pub.Send(alerts.Fired{AlertID: 4, Exchange: "binance", Pair: "ETHUSDT", Interval: "1h", Message: "Alert #4"})
+	select {
+	case f := <-got:
+		if f.AlertID != 4 || f.Pair != "ETHUSDT" || f.Message != "Alert #4" {
+			t.Fatalf("unexpected alert %+v", f)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("alert not relayed")
+	}
+}
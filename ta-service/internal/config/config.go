@@ -1,26 +1,85 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"os"
 	"time"
 
 	"github.com/vrischmann/envconfig"
 )
 
+// Roles select how the service obtains candles.
+const (
+	// RoleStandalone collects exchange streams directly.
+	RoleStandalone = "standalone"
+	// RoleCluster elects one collector through Redis and fans candles out to replicas.
+	RoleCluster = "cluster"
+)
+
+// Modes select where candles come from.
+const (
+	// ModeLive streams candles from the exchanges.
+	ModeLive = "live"
+	// ModeReplay plays stored or CSV candles back on a virtual clock.
+	ModeReplay = "replay"
+)
+
 // Config holds runtime configuration for the TA service.
 type Config struct {
-	HTTPAddr         string        `envconfig:"default=0.0.0.0:9100"`
-	WSAddr           string        `envconfig:"default=0.0.0.0:9101"`
-	MetricsAddr      string        `envconfig:"default=0.0.0.0:9102"`
-	PostgresURL      string        `envconfig:"required"`
-	RedisURL         string        `envconfig:"optional"`
-	CandleLimit      int           `envconfig:"default=1000"`
-	BinanceAPIKey    string        `envconfig:"optional"`
-	BinanceSecret    string        `envconfig:"optional"`
-	BinanceSymbols   []string      `envconfig:"optional"`
-	UniswapPairs     []string      `envconfig:"optional"`
-	Interval         string        `envconfig:"default=1m"`
-	BackfillLookback time.Duration `envconfig:"default=48h"`
-	RustLibPath      string        `envconfig:"optional"`
+	HTTPAddr             string        `envconfig:"default=0.0.0.0:9100"`
+	WSAddr               string        `envconfig:"default=0.0.0.0:9101"`
+	MetricsAddr          string        `envconfig:"default=0.0.0.0:9102"`
+	PostgresURL          string        `envconfig:"required"`
+	RedisURL             string        `envconfig:"optional"`
+	CandleLimit          int           `envconfig:"default=1000"`
+	PersistBatchSize     int           `envconfig:"default=500"`
+	PersistFlush         time.Duration `envconfig:"default=1s"`
+	PersistMaxPending    int           `envconfig:"default=100000"`
+	BinanceAPIKey        string        `envconfig:"optional"`
+	BinanceSecret        string        `envconfig:"optional"`
+	BinanceSymbols       []string      `envconfig:"optional"`
+	WatchRefresh         time.Duration `envconfig:"default=30s"`
+	WatchMaxSymbols      int           `envconfig:"default=50"`
+	AdminToken           string        `envconfig:"optional"`
+	AlertMaxPerChat      int           `envconfig:"default=50"`
+	AlertRefresh         time.Duration `envconfig:"default=15s"`
+	APIURL               string        `envconfig:"optional"`
+	APIToken             string        `envconfig:"optional"`
+	StrategyMaxPerChat   int           `envconfig:"default=10"`
+	StrategyRefresh      time.Duration `envconfig:"default=15s"`
+	UniswapPairs         []string      `envconfig:"optional"`
+	EthNodeURL           string        `envconfig:"optional"`
+	UniswapReplayFile    string        `envconfig:"optional"`
+	UniswapPollInterval  time.Duration `envconfig:"default=12s"`
+	UniswapConfirmations uint64        `envconfig:"default=2"`
+	UniswapStartBlock    uint64        `envconfig:"optional"`
+	Interval             string        `envconfig:"default=1m"`
+	BackfillLookback     time.Duration `envconfig:"default=48h"`
+	RustLibPath          string        `envconfig:"optional"`
+	WSToken              string        `envconfig:"optional"`
+	WSSendBuffer         int           `envconfig:"default=64"`
+	WSSlowPolicy         string        `envconfig:"default=disconnect"`
+	Role                 string        `envconfig:"default=standalone"`
+	InstanceID           string        `envconfig:"optional"`
+	LeaderLockKey        string        `envconfig:"default=ta:collector:leader"`
+	LeaderLockTTL        time.Duration `envconfig:"default=15s"`
+	CandleChannel        string        `envconfig:"default=ta:candles"`
+	AlertChannel         string        `envconfig:"default=ta:alerts"`
+	Mode                 string        `envconfig:"default=live"`
+	ReplayFiles          []string      `envconfig:"optional"`
+	ReplayPairs          []string      `envconfig:"optional"`
+	ReplayExchange       string        `envconfig:"default=binance"`
+	ReplayInterval       string        `envconfig:"optional"`
+	ReplayFrom           string        `envconfig:"optional"`
+	ReplayTo             string        `envconfig:"optional"`
+	ReplaySpeed          float64       `envconfig:"default=60"`
+	ReplayWarmup         int           `envconfig:"default=200"`
+	BacktestWorkers      int           `envconfig:"default=2"`
+	BacktestMaxQueued    int           `envconfig:"default=20"`
+	BacktestMaxBars      int           `envconfig:"default=100000"`
+	BacktestTimeout      time.Duration `envconfig:"default=10m"`
+	BacktestWarmup       int           `envconfig:"default=200"`
 }
 
 // Load returns Config populated from environment variables.
@@ -32,5 +91,75 @@ func Load() (Config, error) {
 	if cfg.CandleLimit <= 0 {
 		cfg.CandleLimit = 1000
 	}
+	if cfg.APIURL != "" && cfg.AdminToken == "" {
+		// Live strategies trade, so whoever can create them must be known.
+		return Config{}, errors.New("admin token required when api url is set")
+	}
+	switch cfg.Role {
+	case RoleStandalone:
+	case RoleCluster:
+		if cfg.RedisURL == "" {
+			return Config{}, fmt.Errorf("redis url required for role %q", cfg.Role)
+		}
+		if cfg.InstanceID == "" {
+			host, err := os.Hostname()
+			if err != nil {
+				return Config{}, fmt.Errorf("instance id: %w", err)
+			}
+			cfg.InstanceID = fmt.Sprintf("%s-%d", host, os.Getpid())
+		}
+	default:
+		return Config{}, fmt.Errorf("unknown role %q", cfg.Role)
+	}
+	switch cfg.Mode {
+	case ModeLive:
+	case ModeReplay:
+		if cfg.Role != RoleStandalone {
+			return Config{}, fmt.Errorf("replay mode requires role %q", RoleStandalone)
+		}
+		if len(cfg.ReplayFiles) == 0 && len(cfg.ReplayPairs) == 0 {
+			return Config{}, errors.New("replay mode needs replay files or replay pairs")
+		}
+		if cfg.ReplaySpeed < 0 {
+			return Config{}, errors.New("replay speed must not be negative")
+		}
+		if cfg.ReplayInterval == "" {
+			cfg.ReplayInterval = cfg.Interval
+		}
+		if _, _, err := cfg.ReplayRange(); err != nil {
+			return Config{}, err
+		}
+	default:
+		return Config{}, fmt.Errorf("unknown mode %q", cfg.Mode)
+	}
 	return cfg, nil
 }
+
+// ReplayRange parses ReplayFrom and ReplayTo, given as YYYY-MM-DD or
+// RFC 3339. Empty bounds are the zero time.
+func (c Config) ReplayRange() (from, to time.Time, err error) {
+	if from, err = parseDate(c.ReplayFrom); err != nil {
+		return from, to, fmt.Errorf("replay from: %w", err)
+	}
+	if to, err = parseDate(c.ReplayTo); err != nil {
+		return from, to, fmt.Errorf("replay to: %w", err)
+	}
+	if !to.IsZero() && !to.After(from) {
+		return from, to, errors.New("replay to must be after replay from")
+	}
+	return from, to, nil
+}
+
+func parseDate(v string) (time.Time, error) {
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02", v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return t, fmt.Errorf("invalid date %q: want YYYY-MM-DD or RFC 3339", v)
+	}
+	return t, nil
+}
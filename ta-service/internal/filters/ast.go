@@ -0,0 +1,102 @@
+// Package filters implements the auto-trade filter language: boolean
+// expressions over indicator signals such as
+//
+//	rsi@1h < 30 and macd_histogram@5m crosses_above 0
+//
+// Identifiers are signal keys or candle fields, optionally suffixed with
+// @interval; bare identifiers use the filter's default interval.
+package filters
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Type is the static type of an expression.
+type Type int
+
+const (
+	Number Type = iota
+	Bool
+)
+
+func (t Type) String() string {
+	if t == Bool {
+		return "bool"
+	}
+	return "number"
+}
+
+// Expr is a node of a parsed filter.
+type Expr interface {
+	Pos() int
+	String() string
+}
+
+// NumberLit is a numeric constant.
+type NumberLit struct {
+	At    int
+	Value float64
+}
+
+// BoolLit is true or false.
+type BoolLit struct {
+	At    int
+	Value bool
+}
+
+// Ref reads a signal or candle field on an interval.
+type Ref struct {
+	At       int
+	Name     string
+	Interval string
+}
+
+// Unary is "not x" or "-x".
+type Unary struct {
+	At int
+	Op string
+	X  Expr
+}
+
+// Binary is an arithmetic, comparison, logical or crossover operation.
+type Binary struct {
+	At   int
+	Op   string
+	X, Y Expr
+}
+
+func (e *NumberLit) Pos() int { return e.At }
+func (e *BoolLit) Pos() int   { return e.At }
+func (e *Ref) Pos() int       { return e.At }
+func (e *Unary) Pos() int     { return e.At }
+func (e *Binary) Pos() int    { return e.At }
+
+func (e *NumberLit) String() string { return strconv.FormatFloat(e.Value, 'g', -1, 64) }
+func (e *BoolLit) String() string   { return strconv.FormatBool(e.Value) }
+func (e *Ref) String() string       { return e.Name + "@" + e.Interval }
+
+func (e *Unary) String() string {
+	if e.Op == "not" {
+		return "(not " + e.X.String() + ")"
+	}
+	return "(" + e.Op + e.X.String() + ")"
+}
+
+func (e *Binary) String() string {
+	return fmt.Sprintf("(%s %s %s)", e.X, e.Op, e.Y)
+}
+
+// Error reports a syntax or type error at a byte offset of the source.
+type Error struct {
+	Pos int
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("col %d: %s", e.Pos+1, e.Msg)
+}
+
+func errorf(pos int, format string, args ...interface{}) *Error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,220 @@
+package filters
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/candles"
+)
+
+// ErrNoValue is returned when a referenced value is not available yet, e.g.
+// while an interval is still warming up.
+var ErrNoValue = errors.New("value not available")
+
+// Env supplies values to the evaluator. back counts closed bars before the
+// latest closed one: 0 is the current bar, 1 the previous.
+type Env interface {
+	Value(name, interval string, back int) (float64, error)
+}
+
+// Eval evaluates the filter against env.
+func (f *Filter) Eval(env Env) (bool, error) {
+	return evalBool(f.Root, env, 0)
+}
+
+func evalBool(e Expr, env Env, back int) (bool, error) {
+	switch e := e.(type) {
+	case *BoolLit:
+		return e.Value, nil
+	case *Unary:
+		v, err := evalBool(e.X, env, back)
+		return !v, err
+	case *Binary:
+		switch e.Op {
+		case "and", "or":
+			x, err := evalBool(e.X, env, back)
+			if err != nil || x == (e.Op == "or") {
+				return x, err
+			}
+			return evalBool(e.Y, env, back)
+		case "crosses_above", "crosses_below":
+			return evalCross(e, env, back)
+		}
+		if isBoolExpr(e.X) {
+			x, err := evalBool(e.X, env, back)
+			if err != nil {
+				return false, err
+			}
+			y, err := evalBool(e.Y, env, back)
+			if err != nil {
+				return false, err
+			}
+			return (x == y) == (e.Op == "=="), nil
+		}
+		x, err := evalNumber(e.X, env, back)
+		if err != nil {
+			return false, err
+		}
+		y, err := evalNumber(e.Y, env, back)
+		if err != nil {
+			return false, err
+		}
+		switch e.Op {
+		case "<":
+			return x < y, nil
+		case "<=":
+			return x <= y, nil
+		case ">":
+			return x > y, nil
+		case ">=":
+			return x >= y, nil
+		case "==":
+			return x == y, nil
+		case "!=":
+			return x != y, nil
+		}
+	}
+	return false, errorf(e.Pos(), "not a condition")
+}
+
+// evalCross reports whether X moved from at or below Y on the previous bar
+// to above it on this one (or the mirror image for crosses_below).
+func evalCross(e *Binary, env Env, back int) (bool, error) {
+	var cur, prev [2]float64
+	for i, side := range []Expr{e.X, e.Y} {
+		var err error
+		if cur[i], err = evalNumber(side, env, back); err != nil {
+			return false, err
+		}
+		if prev[i], err = evalNumber(side, env, back+1); err != nil {
+			return false, err
+		}
+	}
+	if e.Op == "crosses_above" {
+		return prev[0] <= prev[1] && cur[0] > cur[1], nil
+	}
+	return prev[0] >= prev[1] && cur[0] < cur[1], nil
+}
+
+func evalNumber(e Expr, env Env, back int) (float64, error) {
+	switch e := e.(type) {
+	case *NumberLit:
+		return e.Value, nil
+	case *Ref:
+		return env.Value(e.Name, e.Interval, back)
+	case *Unary:
+		v, err := evalNumber(e.X, env, back)
+		return -v, err
+	case *Binary:
+		x, err := evalNumber(e.X, env, back)
+		if err != nil {
+			return 0, err
+		}
+		y, err := evalNumber(e.Y, env, back)
+		if err != nil {
+			return 0, err
+		}
+		switch e.Op {
+		case "+":
+			return x + y, nil
+		case "-":
+			return x - y, nil
+		case "*":
+			return x * y, nil
+		case "/":
+			if y == 0 {
+				return 0, errorf(e.At, "division by zero")
+			}
+			return x / y, nil
+		}
+	}
+	return 0, errorf(e.Pos(), "not a number")
+}
+
+// isBoolExpr reports whether e has type Bool; Compile guarantees it checks.
+func isBoolExpr(e Expr) bool {
+	t, _ := check(e)
+	return t == Bool
+}
+
+// SignalSource computes indicator signals as of a past closed bar;
+// *indicators.Service implements it.
+type SignalSource interface {
+	SignalsAt(pair, interval string, back int) (map[string]float64, error)
+}
+
+// CandleSource returns buffered candles.
+type CandleSource interface {
+	Candles(exchange, pair, interval string) []candles.Candle
+}
+
+// MarketEnv reads a pair's signals and candle fields. It caches what it
+// fetches, so use a fresh one per evaluation round.
+type MarketEnv struct {
+	signals SignalSource
+	candles CandleSource
+	pair    string
+	cache   map[string]map[string]float64
+	closed  map[string][]candles.Candle
+}
+
+// NewMarketEnv returns an Env for pair.
+func NewMarketEnv(signals SignalSource, source CandleSource, pair string) *MarketEnv {
+	return &MarketEnv{
+		signals: signals,
+		candles: source,
+		pair:    pair,
+		cache:   make(map[string]map[string]float64),
+		closed:  make(map[string][]candles.Candle),
+	}
+}
+
+// Value implements Env.
+func (m *MarketEnv) Value(name, interval string, back int) (float64, error) {
+	if candleFields[name] {
+		return m.candleField(name, interval, back)
+	}
+	key := fmt.Sprintf("%s:%d", interval, back)
+	values, ok := m.cache[key]
+	if !ok {
+		var err error
+		if values, err = m.signals.SignalsAt(m.pair, interval, back); err != nil {
+			values = nil
+		}
+		m.cache[key] = values
+	}
+	v, ok := values[name]
+	if !ok {
+		return 0, fmt.Errorf("%w: %s@%s", ErrNoValue, name, interval)
+	}
+	return v, nil
+}
+
+func (m *MarketEnv) candleField(name, interval string, back int) (float64, error) {
+	list, ok := m.closed[interval]
+	if !ok {
+		for _, c := range m.candles.Candles("binance", m.pair, interval) {
+			if c.Closed {
+				list = append(list, c)
+			}
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].Start.Before(list[j].Start) })
+		m.closed[interval] = list
+	}
+	if back >= len(list) {
+		return 0, fmt.Errorf("%w: %s@%s", ErrNoValue, name, interval)
+	}
+	c := list[len(list)-1-back]
+	switch name {
+	case "open":
+		return c.Open, nil
+	case "high":
+		return c.High, nil
+	case "low":
+		return c.Low, nil
+	case "volume":
+		return c.Volume, nil
+	}
+	return c.Close, nil
+}
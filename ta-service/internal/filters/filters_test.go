@@ -0,0 +1,98 @@
+package filters
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// bars maps "name@interval" to values, latest bar last.
+type bars map[string][]float64
+
+func (b bars) Value(name, interval string, back int) (float64, error) {
+	series := b[name+"@"+interval]
+	if back >= len(series) {
+		return 0, ErrNoValue
+	}
+	return series[len(series)-1-back], nil
+}
+
+func TestCompile(t *testing.T) {
+	f, err := Compile("RSI@1h < 30 and macd_histogram@5m crosses_above 0 or not (close > ema21 * 1.02)", "15m")
+	if err != nil {
+		t.Fatalf("compile: %v", err)
+	}
+	want := "(((rsi@1h < 30) and (macd_histogram@5m crosses_above 0)) or (not (close@15m > (ema21@15m * 1.02))))"
+	if got := f.String(); got != want {
+		t.Fatalf("canonical form\n got %s\nwant %s", got, want)
+	}
+	if strings.Join(f.Intervals, ",") != "15m,1h,5m" || f.Lookback != 1 {
+		t.Fatalf("unexpected metadata %+v", f)
+	}
+
+	for src, msg := range map[string]string{
+		"":                      "col 1: unexpected end of expression",
+		"rsi < ":                "col 7: unexpected end of expression",
+		"rsi < 30 and":          "col 13: unexpected end of expression",
+		"rsi & 30":              "col 5: unexpected character '&'",
+		"rsii < 30":             `col 1: unknown signal "rsii"`,
+		"rsi@2x < 30":           `col 4: invalid interval "2x"`,
+		"rsi@ < 30":             "col 4: missing interval after @",
+		"rsi + 30":              "col 1: filter must be a condition, got a number",
+		"rsi and true":          "col 5: and needs conditions on both sides",
+		"(rsi < 30) > 1":        "col 12: > needs numbers on both sides",
+		"20 < rsi < 30":         "col 10: comparisons cannot be chained; use and",
+		"(rsi < 30":             "col 10: expected ) to close ( at col 1, got end of expression",
+		"rsi < 30 atr":          `col 10: unexpected "atr"`,
+		"not rsi":               "col 5: not needs a bool operand, got a number",
+		"rsi == (close > open)": "col 5: cannot compare a number with a bool",
+	} {
+		_, err := Compile(src, "1h")
+		var ferr *Error
+		if !errors.As(err, &ferr) || err.Error() != msg {
+			t.Errorf("Compile(%q) error = %v, want %q", src, err, msg)
+		}
+	}
+	if _, err := Compile("rsi < 30", "7m"); err == nil {
+		t.Fatal("expected invalid default interval to be rejected")
+	}
+}
+
+func TestEval(t *testing.T) {
+	env := bars{
+		"rsi@1h":            {35, 28},
+		"macd_histogram@5m": {-0.4, 0.2},
+		"close@1h":          {100, 104},
+		"ema21@1h":          {101, 102},
+	}
+	cases := map[string]bool{
+		"rsi@1h < 30 and macd_histogram@5m crosses_above 0": true,
+		"rsi crosses_below 30":                              true,
+		"rsi crosses_above 30":                              false,
+		"close crosses_above ema21":                         true,
+		"close - ema21 >= 2":                                true,
+		"not (rsi < 30) || close / 2 == 52":                 true,
+		"rsi > 50 and atr > 0":                              false, // short-circuits before the missing atr
+		"(rsi < 30) == (close > ema21)":                     true,
+		"-macd_histogram@5m < 0":                            true,
+	}
+	for src, want := range cases {
+		f, err := Compile(src, "1h")
+		if err != nil {
+			t.Fatalf("compile %q: %v", src, err)
+		}
+		got, err := f.Eval(env)
+		if err != nil || got != want {
+			t.Errorf("Eval(%q) = %v, %v; want %v", src, got, err, want)
+		}
+	}
+
+	f, _ := Compile("atr > 0", "1h")
+	if _, err := f.Eval(env); !errors.Is(err, ErrNoValue) {
+		t.Fatalf("expected missing value error, got %v", err)
+	}
+	f, _ = Compile("close / (rsi - rsi) > 1", "1h")
+	if _, err := f.Eval(env); err == nil || !strings.Contains(err.Error(), "division by zero") {
+		t.Fatalf("expected division by zero, got %v", err)
+	}
+}
@@ -0,0 +1,431 @@
+package filters
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/candles"
+	"github.com/example/tg-crypto-trader/ta-service/internal/indicators"
+)
+
+// MaxLength bounds the source of a filter.
+const MaxLength = 512
+
+// candleFields are the identifiers read from the candle itself.
+var candleFields = map[string]bool{"open": true, "high": true, "low": true, "close": true, "volume": true}
+
+// Filter is a parsed and type-checked expression.
+type Filter struct {
+	Source    string   `json:"expression"`
+	Interval  string   `json:"interval"`
+	Root      Expr     `json:"-"`
+	Intervals []string `json:"intervals"`
+	Refs      []string `json:"refs"`
+	// Lookback is the number of closed bars before the latest one the
+	// filter reads; crossovers need 1.
+	Lookback int `json:"lookback"`
+}
+
+// Compile parses src and type-checks it. Identifiers without an @interval
+// suffix read the default interval.
+func Compile(src, interval string) (*Filter, error) {
+	if !candles.ValidInterval(interval) {
+		return nil, errorf(0, "invalid default interval %q", interval)
+	}
+	if len(src) > MaxLength {
+		return nil, errorf(MaxLength, "expression longer than %d characters", MaxLength)
+	}
+	p := &parser{src: src, interval: interval}
+	p.next()
+	root, err := p.parseOr()
+	if p.err != nil {
+		return nil, p.err
+	}
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, errorf(p.tok.pos, "unexpected %s", p.tok)
+	}
+	t, err := check(root)
+	if err != nil {
+		return nil, err
+	}
+	if t != Bool {
+		return nil, errorf(0, "filter must be a condition, got a %s", t)
+	}
+	f := &Filter{Source: src, Interval: interval, Root: root}
+	intervals := make(map[string]bool)
+	refs := make(map[string]bool)
+	walk(root, func(e Expr) {
+		switch e := e.(type) {
+		case *Ref:
+			intervals[e.Interval] = true
+			refs[e.String()] = true
+		case *Binary:
+			if isCross(e.Op) {
+				f.Lookback = 1
+			}
+		}
+	})
+	f.Intervals = sortedKeys(intervals)
+	f.Refs = sortedKeys(refs)
+	return f, nil
+}
+
+// String returns the canonical, fully parenthesised form of the filter.
+func (f *Filter) String() string {
+	return f.Root.String()
+}
+
+func walk(e Expr, fn func(Expr)) {
+	fn(e)
+	switch e := e.(type) {
+	case *Unary:
+		walk(e.X, fn)
+	case *Binary:
+		walk(e.X, fn)
+		walk(e.Y, fn)
+	}
+}
+
+func sortedKeys(m map[string]bool) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func isCross(op string) bool {
+	return op == "crosses_above" || op == "crosses_below"
+}
+
+// check returns the type of e or the first type error.
+func check(e Expr) (Type, error) {
+	switch e := e.(type) {
+	case *NumberLit, *Ref:
+		return Number, nil
+	case *BoolLit:
+		return Bool, nil
+	case *Unary:
+		t, err := check(e.X)
+		if err != nil {
+			return t, err
+		}
+		want := Number
+		if e.Op == "not" {
+			want = Bool
+		}
+		if t != want {
+			return t, errorf(e.X.Pos(), "%s needs a %s operand, got a %s", e.Op, want, t)
+		}
+		return want, nil
+	case *Binary:
+		x, err := check(e.X)
+		if err != nil {
+			return x, err
+		}
+		y, err := check(e.Y)
+		if err != nil {
+			return y, err
+		}
+		switch e.Op {
+		case "and", "or":
+			if x != Bool || y != Bool {
+				return Bool, errorf(e.At, "%s needs conditions on both sides", e.Op)
+			}
+			return Bool, nil
+		case "==", "!=":
+			if x != y {
+				return Bool, errorf(e.At, "cannot compare a %s with a %s", x, y)
+			}
+			return Bool, nil
+		case "<", "<=", ">", ">=", "crosses_above", "crosses_below":
+			if x != Number || y != Number {
+				return Bool, errorf(e.At, "%s needs numbers on both sides", e.Op)
+			}
+			return Bool, nil
+		default:
+			if x != Number || y != Number {
+				return Number, errorf(e.At, "%s needs numbers on both sides", e.Op)
+			}
+			return Number, nil
+		}
+	}
+	return Number, errorf(e.Pos(), "unknown expression")
+}
+
+type parser struct {
+	src      string
+	off      int
+	tok      token
+	interval string
+	err      *Error
+}
+
+// parseOr parses or-expressions, the lowest precedence level.
+func (p *parser) parseOr() (Expr, error) {
+	x, err := p.parseAnd()
+	for err == nil && p.tok.is("or") {
+		at := p.tok.pos
+		p.next()
+		var y Expr
+		if y, err = p.parseAnd(); err == nil {
+			x = &Binary{At: at, Op: "or", X: x, Y: y}
+		}
+	}
+	return x, err
+}
+
+func (p *parser) parseAnd() (Expr, error) {
+	x, err := p.parseNot()
+	for err == nil && p.tok.is("and") {
+		at := p.tok.pos
+		p.next()
+		var y Expr
+		if y, err = p.parseNot(); err == nil {
+			x = &Binary{At: at, Op: "and", X: x, Y: y}
+		}
+	}
+	return x, err
+}
+
+func (p *parser) parseNot() (Expr, error) {
+	if p.tok.is("not") {
+		at := p.tok.pos
+		p.next()
+		x, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &Unary{At: at, Op: "not", X: x}, nil
+	}
+	return p.parseComparison()
+}
+
+var comparisons = []string{"<", "<=", ">", ">=", "==", "!=", "crosses_above", "crosses_below"}
+
+// parseComparison parses one non-associative comparison or crossover.
+func (p *parser) parseComparison() (Expr, error) {
+	x, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	if !p.tok.is(comparisons...) {
+		return x, nil
+	}
+	op, at := p.tok.text, p.tok.pos
+	p.next()
+	y, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.is(comparisons...) {
+		return nil, errorf(p.tok.pos, "comparisons cannot be chained; use and")
+	}
+	return &Binary{At: at, Op: op, X: x, Y: y}, nil
+}
+
+func (p *parser) parseSum() (Expr, error) {
+	x, err := p.parseProduct()
+	for err == nil && p.tok.is("+", "-") {
+		op, at := p.tok.text, p.tok.pos
+		p.next()
+		var y Expr
+		if y, err = p.parseProduct(); err == nil {
+			x = &Binary{At: at, Op: op, X: x, Y: y}
+		}
+	}
+	return x, err
+}
+
+func (p *parser) parseProduct() (Expr, error) {
+	x, err := p.parseUnary()
+	for err == nil && p.tok.is("*", "/") {
+		op, at := p.tok.text, p.tok.pos
+		p.next()
+		var y Expr
+		if y, err = p.parseUnary(); err == nil {
+			x = &Binary{At: at, Op: op, X: x, Y: y}
+		}
+	}
+	return x, err
+}
+
+func (p *parser) parseUnary() (Expr, error) {
+	if p.tok.is("-") {
+		at := p.tok.pos
+		p.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &Unary{At: at, Op: "-", X: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Expr, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokError:
+		return nil, p.err
+	case tokNumber:
+		p.next()
+		v, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, errorf(tok.pos, "invalid number %q", tok.text)
+		}
+		return &NumberLit{At: tok.pos, Value: v}, nil
+	case tokIdent:
+		p.next()
+		switch tok.text {
+		case "true", "false":
+			return &BoolLit{At: tok.pos, Value: tok.text == "true"}, nil
+		case "and", "or", "not", "crosses_above", "crosses_below":
+			return nil, errorf(tok.pos, "unexpected %s", tok)
+		}
+		if !candleFields[tok.text] && !knownSignal(tok.text) {
+			return nil, errorf(tok.pos, "unknown signal %q", tok.text)
+		}
+		ref := &Ref{At: tok.pos, Name: tok.text, Interval: p.interval}
+		if p.tok.kind == tokInterval {
+			if !candles.ValidInterval(p.tok.text) {
+				return nil, errorf(p.tok.pos, "invalid interval %q", p.tok.text)
+			}
+			ref.Interval = p.tok.text
+			p.next()
+		}
+		return ref, nil
+	case tokPunct:
+		if tok.text == "(" {
+			p.next()
+			x, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			if !p.tok.is(")") {
+				return nil, errorf(p.tok.pos, "expected ) to close ( at col %d, got %s", tok.pos+1, p.tok)
+			}
+			p.next()
+			return x, nil
+		}
+	}
+	return nil, errorf(tok.pos, "unexpected %s", tok)
+}
+
+func knownSignal(name string) bool {
+	keys := indicators.SignalKeys()
+	i := sort.SearchStrings(keys, name)
+	return i < len(keys) && keys[i] == name
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokError
+	tokNumber
+	tokIdent
+	tokInterval
+	tokPunct
+)
+
+type token struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+// is reports whether the token is an operator, punctuation or keyword
+// spelled as one of texts.
+func (t token) is(texts ...string) bool {
+	if t.kind != tokPunct && t.kind != tokIdent {
+		return false
+	}
+	for _, text := range texts {
+		if t.text == text {
+			return true
+		}
+	}
+	return false
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokEOF:
+		return "end of expression"
+	case tokInterval:
+		return "@" + t.text
+	}
+	return strconv.Quote(t.text)
+}
+
+// aliases maps symbolic spellings onto keywords.
+var aliases = map[string]string{"&&": "and", "||": "or", "!": "not", "=": "=="}
+
+// next advances to the following token. Identifiers and keywords are
+// lowercased; interval suffixes keep their case since 1m and 1M differ.
+func (p *parser) next() {
+	for p.off < len(p.src) && strings.ContainsRune(" \t\r\n", rune(p.src[p.off])) {
+		p.off++
+	}
+	start := p.off
+	if p.off >= len(p.src) {
+		p.tok = token{kind: tokEOF, pos: start}
+		return
+	}
+	c := p.src[p.off]
+	switch {
+	case isDigit(c) || c == '.':
+		for p.off < len(p.src) && (isDigit(p.src[p.off]) || p.src[p.off] == '.') {
+			p.off++
+		}
+		p.tok = token{kind: tokNumber, text: p.src[start:p.off], pos: start}
+	case isLetter(c):
+		for p.off < len(p.src) && (isLetter(p.src[p.off]) || isDigit(p.src[p.off])) {
+			p.off++
+		}
+		p.tok = token{kind: tokIdent, text: strings.ToLower(p.src[start:p.off]), pos: start}
+	case c == '@':
+		p.off++
+		for p.off < len(p.src) && (isLetter(p.src[p.off]) || isDigit(p.src[p.off])) {
+			p.off++
+		}
+		if p.off == start+1 {
+			p.fail(start, "missing interval after @")
+			return
+		}
+		p.tok = token{kind: tokInterval, text: p.src[start+1 : p.off], pos: start}
+	default:
+		for _, op := range []string{"<=", ">=", "==", "!=", "&&", "||", "<", ">", "=", "!", "+", "-", "*", "/", "(", ")"} {
+			if strings.HasPrefix(p.src[p.off:], op) {
+				p.off += len(op)
+				text := op
+				if alias, ok := aliases[op]; ok {
+					text = alias
+				}
+				kind := tokPunct
+				if text == "and" || text == "or" || text == "not" {
+					kind = tokIdent
+				}
+				p.tok = token{kind: kind, text: text, pos: start}
+				return
+			}
+		}
+		p.fail(start, "unexpected character %q", c)
+	}
+}
+
+func (p *parser) fail(pos int, format string, args ...interface{}) {
+	p.err = errorf(pos, format, args...)
+	p.tok = token{kind: tokError, pos: pos}
+	p.off = len(p.src)
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+func isLetter(c byte) bool { return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') }
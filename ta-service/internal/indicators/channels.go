@@ -0,0 +1,86 @@
+package indicators
+
+import (
+	"errors"
+	"math"
+)
+
+// ComputeKeltner calculates Keltner Channels around an EMA using ATR width.
+func ComputeKeltner(high, low, close Series, emaPeriod, atrPeriod int, multiplier float64) (IndicatorResult, error) {
+	if len(close) < emaPeriod || len(close) < atrPeriod {
+		return IndicatorResult{}, errors.New("not enough data for Keltner")
+	}
+	upper, middle, lower := keltnerSeries(high, low, close, emaPeriod, atrPeriod, multiplier)
+	if len(upper) == 0 {
+		return IndicatorResult{}, errors.New("failed to compute Keltner")
+	}
+	idx := len(upper) - 1
+	return IndicatorResult{
+		Value: middle[idx],
+		Components: map[string]float64{
+			"upper":  upper[idx],
+			"middle": middle[idx],
+			"lower":  lower[idx],
+		},
+	}, nil
+}
+
+// ComputeDonchian calculates Donchian Channels from the highest high and lowest low.
+func ComputeDonchian(high, low Series, period int) (IndicatorResult, error) {
+	if len(high) < period || len(low) < period {
+		return IndicatorResult{}, errors.New("not enough data for Donchian")
+	}
+	upper, lower := channelSeries(high, low, period)
+	if len(upper) == 0 {
+		return IndicatorResult{}, errors.New("failed to compute Donchian")
+	}
+	idx := len(upper) - 1
+	middle := (upper[idx] + lower[idx]) / 2
+	return IndicatorResult{
+		Value: middle,
+		Components: map[string]float64{
+			"upper":  upper[idx],
+			"middle": middle,
+			"lower":  lower[idx],
+		},
+	}, nil
+}
+
+func keltnerSeries(high, low, close Series, emaPeriod, atrPeriod int, multiplier float64) (upper, middle, lower []float64) {
+	middle = emaSeries(close, emaPeriod)
+	atr := atrSeries(high, low, close, atrPeriod)
+	if len(middle) == 0 || len(atr) == 0 {
+		return nil, nil, nil
+	}
+	start := emaPeriod - 1
+	if atrPeriod-1 > start {
+		start = atrPeriod - 1
+	}
+	upper = make([]float64, len(close))
+	lower = make([]float64, len(close))
+	for i := start; i < len(close); i++ {
+		upper[i] = middle[i] + multiplier*atr[i]
+		lower[i] = middle[i] - multiplier*atr[i]
+	}
+	return upper, middle, lower
+}
+
+// channelSeries returns the rolling highest high and lowest low.
+func channelSeries(high, low Series, period int) (upper, lower []float64) {
+	n := len(high)
+	if period <= 0 || len(low) != n || n < period {
+		return nil, nil
+	}
+	upper = make([]float64, n)
+	lower = make([]float64, n)
+	for i := period - 1; i < n; i++ {
+		hi, lo := high[i], low[i]
+		for j := i - period + 1; j < i; j++ {
+			hi = math.Max(hi, high[j])
+			lo = math.Min(lo, low[j])
+		}
+		upper[i] = hi
+		lower[i] = lo
+	}
+	return upper, lower
+}
@@ -0,0 +1,103 @@
+package indicators
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/candles"
+	"github.com/example/tg-crypto-trader/ta-service/internal/telemetry"
+)
+
+// StreamEngine keeps incremental indicators current as candles close.
+// Streamers are keyed by exchange/pair/interval and an indicator key that
+// encodes the parameters, e.g. "rsi:14".
+type StreamEngine struct {
+	mu     sync.Mutex
+	source CandleSource
+	sets   map[string]*streamSet
+}
+
+type streamSet struct {
+	last      time.Time
+	streamers map[string]Streamer
+}
+
+// NewStreamEngine returns an engine that warms new streamers from source.
+func NewStreamEngine(source CandleSource) *StreamEngine {
+	return &StreamEngine{source: source, sets: make(map[string]*streamSet)}
+}
+
+// OnClose feeds a closed candle to every streamer tracking its pair and interval.
+func (e *StreamEngine) OnClose(c candles.Candle) {
+	key := c.Exchange + ":" + c.Pair + ":" + c.Interval
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	set, ok := e.sets[key]
+	if !ok || !c.Start.After(set.last) {
+		return
+	}
+	start := time.Now()
+	for _, streamer := range set.streamers {
+		streamer.Update(c)
+	}
+	telemetry.IndicatorSeconds.WithLabelValues("all", "update").Observe(time.Since(start).Seconds())
+	set.last = c.Start
+}
+
+// Lookup returns the latest value for key, creating the streamer with build
+// and replaying the buffered closed candles on first use. Pairs without
+// buffered candles get no streamers, so lookups of arbitrary pairs hold no
+// memory.
+func (e *StreamEngine) Lookup(exchange, pair, interval, key string, build func() Streamer) (IndicatorResult, bool) {
+	setKey := exchange + ":" + pair + ":" + interval
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	set, ok := e.sets[setKey]
+	var streamer Streamer
+	if ok {
+		streamer = set.streamers[key]
+	}
+	if streamer == nil {
+		closed := e.closed(exchange, pair, interval)
+		if len(closed) == 0 {
+			return IndicatorResult{}, false
+		}
+		if !ok {
+			set = &streamSet{streamers: make(map[string]Streamer)}
+			e.sets[setKey] = set
+		}
+		streamer = build()
+		fresh := len(set.streamers) == 0
+		for _, c := range closed {
+			if !fresh && c.Start.After(set.last) {
+				break
+			}
+			streamer.Update(c)
+			if fresh {
+				set.last = c.Start
+			}
+		}
+		set.streamers[key] = streamer
+	}
+	return streamer.Result()
+}
+
+// Drop discards the streamers of a pair no longer collected.
+func (e *StreamEngine) Drop(exchange, pair, interval string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.sets, exchange+":"+pair+":"+interval)
+}
+
+func (e *StreamEngine) closed(exchange, pair, interval string) []candles.Candle {
+	all := e.source.Candles(exchange, pair, interval)
+	out := all[:0]
+	for _, c := range all {
+		if c.Closed {
+			out = append(out, c)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Start.Before(out[j].Start) })
+	return out
+}
@@ -226,17 +226,7 @@ func atrSeries(high, low, close Series, period int) []float64 {
 	if period <= 0 || len(high) != n || len(low) != n || n < period {
 		return nil
 	}
-	tr := make([]float64, n)
-	for i := 0; i < n; i++ {
-		highLow := high[i] - low[i]
-		if i == 0 {
-			tr[i] = math.Abs(highLow)
-			continue
-		}
-		highClose := math.Abs(high[i] - close[i-1])
-		lowClose := math.Abs(low[i] - close[i-1])
-		tr[i] = math.Max(highLow, math.Max(highClose, lowClose))
-	}
+	tr := trueRange(high, low, close)
 
 	result := make([]float64, n)
 	sum := 0.0
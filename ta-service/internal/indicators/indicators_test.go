@@ -1,6 +1,12 @@
 package indicators
 
-import "testing"
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/candles"
+)
 
 func TestComputeRSI(t *testing.T) {
 	data := Series{44.34, 44.09, 44.15, 43.61, 44.33, 44.83, 45.10, 45.42, 45.84, 46.08, 45.89, 46.03, 45.61, 46.28, 46.28}
@@ -30,3 +36,162 @@ func abs(v float64) float64 {
 	}
 	return v
 }
+
+type staticSource []candles.Candle
+
+func (s staticSource) Candles(exchange, pair, interval string) []candles.Candle {
+	return append([]candles.Candle(nil), s...)
+}
+
+func TestIndicatorSeriesMatchesLatest(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	var data staticSource
+	for i := 0; i < 60; i++ {
+		price := 100 + 5*math.Sin(float64(i)/4)
+		data = append(data, candles.Candle{Close: price, High: price + 1, Low: price - 1, Start: start.Add(time.Duration(i) * time.Minute)})
+	}
+	svc := NewService(data)
+	series, err := svc.IndicatorSeries("rsi", "ETHUSDT", "1m", SeriesQuery{Limit: 10})
+	if err != nil {
+		t.Fatalf("series failed: %v", err)
+	}
+	if len(series.Values) != 10 || len(series.Timestamps) != 10 {
+		t.Fatalf("expected 10 points, got %d", len(series.Values))
+	}
+	latest, err := svc.RSI("ETHUSDT", "1m")
+	if err != nil {
+		t.Fatalf("rsi failed: %v", err)
+	}
+	if diff := abs(series.Values[9] - latest.Value); diff > 1e-9 {
+		t.Fatalf("series tail %.4f != latest %.4f", series.Values[9], latest.Value)
+	}
+	if !series.Timestamps[9].Equal(data[59].Start) {
+		t.Fatalf("unexpected last timestamp %s", series.Timestamps[9])
+	}
+
+	macd, err := svc.IndicatorSeries("macd", "ETHUSDT", "1m", SeriesQuery{From: data[40].Start})
+	if err != nil {
+		t.Fatalf("macd series failed: %v", err)
+	}
+	if len(macd.Values) != 20 || len(macd.Components["histogram"]) != 20 {
+		t.Fatalf("expected 20 macd points, got %d", len(macd.Values))
+	}
+}
+
+// referenceOHLCV builds a deterministic trending, oscillating dataset.
+// Expected values below were produced by an independent textbook
+// implementation of each indicator over the same data.
+func referenceOHLCV() (high, low, close, volume Series) {
+	for i := 0; i < 90; i++ {
+		c := 100 + 10*math.Sin(float64(i)/5) + float64(i)*0.3
+		close = append(close, c)
+		high = append(high, c+1+float64(i%3)*0.5)
+		low = append(low, c-1-float64(i%4)*0.25)
+		volume = append(volume, 1000+float64(i%7)*100)
+	}
+	return high, low, close, volume
+}
+
+func TestComputeStochRSI(t *testing.T) {
+	_, _, closes, _ := referenceOHLCV()
+	result, err := ComputeStochRSI(closes, 14, 14, 3, 3)
+	if err != nil {
+		t.Fatalf("stoch rsi failed: %v", err)
+	}
+	assertClose(t, "stoch k", result.Components["k"], 14.550988)
+	assertClose(t, "stoch d", result.Components["d"], 6.951351)
+}
+
+func TestComputeADX(t *testing.T) {
+	high, low, closes, _ := referenceOHLCV()
+	result, err := ComputeADX(high, low, closes, 14)
+	if err != nil {
+		t.Fatalf("adx failed: %v", err)
+	}
+	assertClose(t, "adx", result.Value, 28.940469)
+	assertClose(t, "+di", result.Components["plus_di"], 17.997068)
+	assertClose(t, "-di", result.Components["minus_di"], 19.870978)
+}
+
+func TestComputeVWAP(t *testing.T) {
+	day := time.Date(2024, 1, 1, 23, 58, 0, 0, time.UTC)
+	times := []time.Time{day, day.Add(time.Minute), day.Add(2 * time.Minute)}
+	high := Series{11, 21, 31}
+	low := Series{9, 19, 29}
+	closes := Series{10, 20, 30}
+	volume := Series{100, 300, 50}
+	series := vwapSeries(times, high, low, closes, volume)
+	assertClose(t, "vwap day one", series[1], 17.5)
+	result, err := ComputeVWAP(times, high, low, closes, volume)
+	if err != nil {
+		t.Fatalf("vwap failed: %v", err)
+	}
+	assertClose(t, "vwap session reset", result.Value, 30)
+}
+
+func TestComputeOBV(t *testing.T) {
+	result, err := ComputeOBV(Series{10, 11, 11, 9, 12}, Series{100, 200, 300, 400, 500})
+	if err != nil {
+		t.Fatalf("obv failed: %v", err)
+	}
+	assertClose(t, "obv", result.Value, 300)
+}
+
+func TestComputeIchimoku(t *testing.T) {
+	high, low, _, _ := referenceOHLCV()
+	result, err := ComputeIchimoku(high, low, 9, 26, 52)
+	if err != nil {
+		t.Fatalf("ichimoku failed: %v", err)
+	}
+	assertClose(t, "tenkan", result.Components["tenkan"], 117.453390)
+	assertClose(t, "kijun", result.Components["kijun"], 123.805633)
+	assertClose(t, "senkou a", result.Components["senkou_a"], 114.370181)
+	assertClose(t, "senkou b", result.Components["senkou_b"], 109.460198)
+}
+
+func TestComputeSuperTrend(t *testing.T) {
+	high, low, closes, _ := referenceOHLCV()
+	result, err := ComputeSuperTrend(high, low, closes, 10, 3)
+	if err != nil {
+		t.Fatalf("supertrend failed: %v", err)
+	}
+	assertClose(t, "supertrend", result.Value, 124.835379)
+	assertClose(t, "direction", result.Components["direction"], -1)
+}
+
+func TestComputeKeltner(t *testing.T) {
+	high, low, closes, _ := referenceOHLCV()
+	result, err := ComputeKeltner(high, low, closes, 20, 10, 2)
+	if err != nil {
+		t.Fatalf("keltner failed: %v", err)
+	}
+	assertClose(t, "keltner middle", result.Value, 119.891581)
+	assertClose(t, "keltner upper", result.Components["upper"], 125.871547)
+	assertClose(t, "keltner lower", result.Components["lower"], 113.911616)
+}
+
+func TestComputeDonchian(t *testing.T) {
+	result, err := ComputeDonchian(Series{5, 7, 6, 9, 8}, Series{3, 4, 2, 5, 6}, 3)
+	if err != nil {
+		t.Fatalf("donchian failed: %v", err)
+	}
+	assertClose(t, "donchian upper", result.Components["upper"], 9)
+	assertClose(t, "donchian lower", result.Components["lower"], 2)
+	assertClose(t, "donchian middle", result.Value, 5.5)
+}
+
+func TestComputeCCI(t *testing.T) {
+	high, low, closes, _ := referenceOHLCV()
+	result, err := ComputeCCI(high, low, closes, 20)
+	if err != nil {
+		t.Fatalf("cci failed: %v", err)
+	}
+	assertClose(t, "cci", result.Value, -57.920901)
+}
+
+func assertClose(t *testing.T, name string, got, want float64) {
+	t.Helper()
+	if diff := abs(got - want); diff > 1e-4 {
+		t.Fatalf("unexpected %s %.6f, want %.6f", name, got, want)
+	}
+}
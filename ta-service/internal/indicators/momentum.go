@@ -0,0 +1,105 @@
+package indicators
+
+import (
+	"errors"
+	"math"
+)
+
+// ComputeStochRSI calculates the Stochastic RSI %K and %D lines.
+func ComputeStochRSI(values Series, rsiPeriod, stochPeriod, kPeriod, dPeriod int) (IndicatorResult, error) {
+	if len(values) < stochRSIWarmup(rsiPeriod, stochPeriod, kPeriod, dPeriod)+1 {
+		return IndicatorResult{}, errors.New("not enough data for Stochastic RSI")
+	}
+	k, d := stochRSISeries(values, rsiPeriod, stochPeriod, kPeriod, dPeriod)
+	if len(k) == 0 {
+		return IndicatorResult{}, errors.New("failed to compute Stochastic RSI")
+	}
+	idx := len(k) - 1
+	return IndicatorResult{
+		Value:      k[idx],
+		Components: map[string]float64{"k": k[idx], "d": d[idx]},
+	}, nil
+}
+
+// ComputeCCI calculates the Commodity Channel Index.
+func ComputeCCI(high, low, close Series, period int) (IndicatorResult, error) {
+	if len(high) < period || len(low) < period || len(close) < period {
+		return IndicatorResult{}, errors.New("not enough data for CCI")
+	}
+	arr := cciSeries(high, low, close, period)
+	if len(arr) == 0 {
+		return IndicatorResult{}, errors.New("failed to compute CCI")
+	}
+	return IndicatorResult{Value: arr[len(arr)-1]}, nil
+}
+
+// stochRSIWarmup returns the first index holding a valid %D value.
+func stochRSIWarmup(rsiPeriod, stochPeriod, kPeriod, dPeriod int) int {
+	return rsiPeriod + stochPeriod - 1 + kPeriod - 1 + dPeriod - 1
+}
+
+func stochRSISeries(values Series, rsiPeriod, stochPeriod, kPeriod, dPeriod int) (k, d []float64) {
+	if rsiPeriod <= 0 || stochPeriod <= 0 || kPeriod <= 0 || dPeriod <= 0 {
+		return nil, nil
+	}
+	if len(values) < stochRSIWarmup(rsiPeriod, stochPeriod, kPeriod, dPeriod)+1 {
+		return nil, nil
+	}
+	rsi := rsiSeries(values, rsiPeriod)
+	if len(rsi) == 0 {
+		return nil, nil
+	}
+	n := len(values)
+	stoch := make(Series, 0, n-rsiPeriod)
+	for i := rsiPeriod + stochPeriod - 1; i < n; i++ {
+		lo, hi := rsi[i], rsi[i]
+		for j := i - stochPeriod + 1; j < i; j++ {
+			lo = math.Min(lo, rsi[j])
+			hi = math.Max(hi, rsi[j])
+		}
+		if hi == lo {
+			stoch = append(stoch, 0)
+			continue
+		}
+		stoch = append(stoch, (rsi[i]-lo)/(hi-lo)*100)
+	}
+	kTrim := smaSeries(stoch, kPeriod)
+	if len(kTrim) == 0 {
+		return nil, nil
+	}
+	dTrim := smaSeries(kTrim[kPeriod-1:], dPeriod)
+	if len(dTrim) == 0 {
+		return nil, nil
+	}
+	k = make([]float64, n)
+	d = make([]float64, n)
+	offset := rsiPeriod + stochPeriod - 1
+	copy(k[offset:], kTrim)
+	copy(d[offset+kPeriod-1:], dTrim)
+	return k, d
+}
+
+func cciSeries(high, low, close Series, period int) []float64 {
+	n := len(close)
+	if period <= 0 || len(high) != n || len(low) != n || n < period {
+		return nil
+	}
+	typical := make(Series, n)
+	for i := range typical {
+		typical[i] = (high[i] + low[i] + close[i]) / 3
+	}
+	mean := smaSeries(typical, period)
+	result := make([]float64, n)
+	for i := period - 1; i < n; i++ {
+		deviation := 0.0
+		for j := i - period + 1; j <= i; j++ {
+			deviation += math.Abs(typical[j] - mean[i])
+		}
+		deviation /= float64(period)
+		if deviation == 0 {
+			continue
+		}
+		result[i] = (typical[i] - mean[i]) / (0.015 * deviation)
+	}
+	return result
+}
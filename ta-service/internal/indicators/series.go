@@ -0,0 +1,199 @@
+package indicators
+
+import (
+	"fmt"
+	"time"
+)
+
+// SeriesQuery narrows an indicator series to a time window.
+type SeriesQuery struct {
+	From  time.Time
+	To    time.Time
+	Limit int
+}
+
+// SeriesResult carries an indicator history aligned to candle start times.
+type SeriesResult struct {
+	Name       string               `json:"name"`
+	Pair       string               `json:"pair"`
+	Interval   string               `json:"interval"`
+	Timestamps []time.Time          `json:"timestamps"`
+	Values     []float64            `json:"values"`
+	Components map[string][]float64 `json:"components,omitempty"`
+}
+
+// IndicatorSeries returns the full history of the named indicator.
+func (s *Service) IndicatorSeries(name, pair, interval string, query SeriesQuery) (SeriesResult, error) {
+	data, err := s.series("binance", pair, interval)
+	if err != nil {
+		return SeriesResult{}, err
+	}
+	values, components, start, err := computeSeries(name, data)
+	if err != nil {
+		return SeriesResult{}, err
+	}
+	result := SeriesResult{
+		Name:       name,
+		Pair:       pair,
+		Interval:   interval,
+		Timestamps: []time.Time{},
+		Values:     []float64{},
+	}
+	if len(components) > 0 {
+		result.Components = make(map[string][]float64, len(components))
+		for key := range components {
+			result.Components[key] = []float64{}
+		}
+	}
+	for i := start; i < len(values); i++ {
+		ts := data.Time[i]
+		if !query.From.IsZero() && ts.Before(query.From) {
+			continue
+		}
+		if !query.To.IsZero() && ts.After(query.To) {
+			continue
+		}
+		result.Timestamps = append(result.Timestamps, ts)
+		result.Values = append(result.Values, values[i])
+		for key, arr := range components {
+			result.Components[key] = append(result.Components[key], arr[i])
+		}
+	}
+	if query.Limit > 0 && len(result.Values) > query.Limit {
+		cut := len(result.Values) - query.Limit
+		result.Timestamps = result.Timestamps[cut:]
+		result.Values = result.Values[cut:]
+		for key, arr := range result.Components {
+			result.Components[key] = arr[cut:]
+		}
+	}
+	return result, nil
+}
+
+// computeSeries returns the indicator values, optional component lines and
+// the first index holding a fully warmed-up value.
+func computeSeries(name string, data ohlcSeries) ([]float64, map[string][]float64, int, error) {
+	switch name {
+	case "rsi":
+		arr := rsiSeries(data.Close, 14)
+		if len(arr) == 0 {
+			return nil, nil, 0, fmt.Errorf("not enough data for RSI")
+		}
+		return arr, nil, 14, nil
+	case "ema":
+		arr := emaSeries(data.Close, 21)
+		if len(arr) == 0 {
+			return nil, nil, 0, fmt.Errorf("not enough data for EMA")
+		}
+		return arr, nil, 20, nil
+	case "sma":
+		arr := smaSeries(data.Close, 50)
+		if len(arr) == 0 {
+			return nil, nil, 0, fmt.Errorf("not enough data for SMA")
+		}
+		return arr, nil, 49, nil
+	case "atr":
+		arr := atrSeries(data.High, data.Low, data.Close, 14)
+		if len(arr) == 0 {
+			return nil, nil, 0, fmt.Errorf("not enough data for ATR")
+		}
+		return arr, nil, 13, nil
+	case "bollinger":
+		upper, middle, lower := bollingerBands(data.Close, 20, 2.0)
+		if len(upper) == 0 {
+			return nil, nil, 0, fmt.Errorf("not enough data for Bollinger")
+		}
+		return middle, map[string][]float64{
+			"upper":  upper,
+			"middle": middle,
+			"lower":  lower,
+		}, 19, nil
+	case "macd":
+		macdLine, signalLine, histogram := macdSeries(data.Close, 12, 26, 9)
+		if len(macdLine) == 0 {
+			return nil, nil, 0, fmt.Errorf("not enough data for MACD")
+		}
+		return macdLine, map[string][]float64{
+			"macd":      macdLine,
+			"signal":    signalLine,
+			"histogram": histogram,
+		}, 26 + 9 - 2, nil
+	case "stochrsi":
+		k, d := stochRSISeries(data.Close, 14, 14, 3, 3)
+		if len(k) == 0 {
+			return nil, nil, 0, fmt.Errorf("not enough data for Stochastic RSI")
+		}
+		return k, map[string][]float64{"k": k, "d": d}, stochRSIWarmup(14, 14, 3, 3), nil
+	case "adx":
+		adx, plusDI, minusDI := adxSeries(data.High, data.Low, data.Close, 14)
+		if len(adx) == 0 {
+			return nil, nil, 0, fmt.Errorf("not enough data for ADX")
+		}
+		return adx, map[string][]float64{
+			"adx":      adx,
+			"plus_di":  plusDI,
+			"minus_di": minusDI,
+		}, 2*14 - 1, nil
+	case "vwap":
+		arr := vwapSeries(data.Time, data.High, data.Low, data.Close, data.Volume)
+		if len(arr) == 0 {
+			return nil, nil, 0, fmt.Errorf("not enough data for VWAP")
+		}
+		return arr, nil, 0, nil
+	case "obv":
+		arr := obvSeries(data.Close, data.Volume)
+		if len(arr) == 0 {
+			return nil, nil, 0, fmt.Errorf("not enough data for OBV")
+		}
+		return arr, nil, 0, nil
+	case "ichimoku":
+		tenkan, kijun, spanA, spanB := ichimokuSeries(data.High, data.Low, 9, 26, 52)
+		if len(tenkan) == 0 {
+			return nil, nil, 0, fmt.Errorf("not enough data for Ichimoku")
+		}
+		return tenkan, map[string][]float64{
+			"tenkan":   tenkan,
+			"kijun":    kijun,
+			"senkou_a": spanA,
+			"senkou_b": spanB,
+		}, 26 + 52 - 2, nil
+	case "supertrend":
+		line, direction := superTrendSeries(data.High, data.Low, data.Close, 10, 3.0)
+		if len(line) == 0 {
+			return nil, nil, 0, fmt.Errorf("not enough data for SuperTrend")
+		}
+		return line, map[string][]float64{"direction": direction}, 9, nil
+	case "keltner":
+		upper, middle, lower := keltnerSeries(data.High, data.Low, data.Close, 20, 10, 2.0)
+		if len(upper) == 0 {
+			return nil, nil, 0, fmt.Errorf("not enough data for Keltner")
+		}
+		return middle, map[string][]float64{
+			"upper":  upper,
+			"middle": middle,
+			"lower":  lower,
+		}, 19, nil
+	case "donchian":
+		upper, lower := channelSeries(data.High, data.Low, 20)
+		if len(upper) == 0 {
+			return nil, nil, 0, fmt.Errorf("not enough data for Donchian")
+		}
+		middle := make([]float64, len(upper))
+		for i := range upper {
+			middle[i] = (upper[i] + lower[i]) / 2
+		}
+		return middle, map[string][]float64{
+			"upper":  upper,
+			"middle": middle,
+			"lower":  lower,
+		}, 19, nil
+	case "cci":
+		arr := cciSeries(data.High, data.Low, data.Close, 20)
+		if len(arr) == 0 {
+			return nil, nil, 0, fmt.Errorf("not enough data for CCI")
+		}
+		return arr, nil, 19, nil
+	default:
+		return nil, nil, 0, fmt.Errorf("unknown indicator %q", name)
+	}
+}
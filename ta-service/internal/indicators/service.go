@@ -4,13 +4,16 @@ import (
 	"errors"
 	"fmt"
 	"sort"
+	"time"
 
 	"github.com/example/tg-crypto-trader/ta-service/internal/candles"
+	"github.com/example/tg-crypto-trader/ta-service/internal/telemetry"
 )
 
 // Service computes indicator values for candle data.
 type Service struct {
 	candleSource CandleSource
+	stream       *StreamEngine
 }
 
 // CandleSource fetches candles for a pair/interval.
@@ -18,62 +21,129 @@ type CandleSource interface {
 	Candles(exchange, pair, interval string) []candles.Candle
 }
 
-// NewService constructs Service.
+// CloseNotifier is implemented by candle sources that report closed candles.
+type CloseNotifier interface {
+	OnCandleClose(fn func(candles.Candle))
+}
+
+// DropNotifier is implemented by candle sources that report pairs they stop
+// collecting.
+type DropNotifier interface {
+	OnDrop(fn func(exchange, pair, interval string))
+}
+
+// NewService constructs Service. Sources that report closed candles get
+// incremental indicators; other sources are recomputed per query.
 func NewService(source CandleSource) *Service {
-	return &Service{candleSource: source}
+	svc := &Service{candleSource: source}
+	if notifier, ok := source.(CloseNotifier); ok {
+		svc.stream = NewStreamEngine(source)
+		notifier.OnCandleClose(svc.stream.OnClose)
+		if dropper, ok := source.(DropNotifier); ok {
+			dropper.OnDrop(svc.stream.Drop)
+		}
+	}
+	return svc
 }
 
 // RSI calculates RSI using close prices.
 func (s *Service) RSI(pair, interval string) (IndicatorResult, error) {
-	series, err := s.series(pair, interval)
-	if err != nil {
-		return IndicatorResult{}, err
-	}
-	return ComputeRSI(series.Close, 14)
+	return s.Indicator("rsi", pair, interval)
 }
 
 // MACD calculates MACD using close prices.
 func (s *Service) MACD(pair, interval string) (MACDResult, error) {
-	series, err := s.series(pair, interval)
+	result, err := s.Indicator("macd", pair, interval)
 	if err != nil {
 		return MACDResult{}, err
 	}
-	return ComputeMACD(series.Close, 12, 26, 9)
+	return MACDResult{
+		MACD:         result.Components["macd"],
+		Signal:       result.Components["signal"],
+		Histogram:    result.Components["histogram"],
+		FastPeriod:   12,
+		SlowPeriod:   26,
+		SignalPeriod: 9,
+	}, nil
 }
 
-// Signals returns a summary map.
-func (s *Service) Signals(pair, interval string) (map[string]float64, error) {
-	series, err := s.series(pair, interval)
+// Indicator returns the latest value of the named indicator with its components.
+func (s *Service) Indicator(name, pair, interval string) (IndicatorResult, error) {
+	spec, ok := specs[name]
+	if !ok {
+		return IndicatorResult{}, fmt.Errorf("unknown indicator %q", name)
+	}
+	if s.stream != nil {
+		return s.lookup(name, spec, "binance", pair, interval)
+	}
+	data, err := s.series("binance", pair, interval)
 	if err != nil {
-		return nil, err
+		return IndicatorResult{}, err
+	}
+	return s.batch(name, spec, data)
+}
+
+// Signals returns a summary map for a Binance pair.
+func (s *Service) Signals(pair, interval string) (map[string]float64, error) {
+	return s.ExchangeSignals("binance", pair, interval)
+}
+
+// ExchangeSignals returns a summary map for a pair on any collected exchange.
+func (s *Service) ExchangeSignals(exchange, pair, interval string) (map[string]float64, error) {
+	var data ohlcSeries
+	if s.stream == nil {
+		var err error
+		if data, err = s.series(exchange, pair, interval); err != nil {
+			return nil, err
+		}
 	}
 	result := make(map[string]float64)
-	rsi, err := ComputeRSI(series.Close, 14)
-	if err == nil {
-		result["rsi"] = rsi.Value
+	for _, name := range signalOrder {
+		spec := specs[name]
+		var value IndicatorResult
+		var err error
+		if s.stream != nil {
+			value, err = s.lookup(name, spec, exchange, pair, interval)
+		} else {
+			value, err = s.batch(name, spec, data)
+		}
+		if err == nil {
+			spec.signals(value, result)
+		}
+	}
+	if len(result) == 0 {
+		return nil, errors.New("no indicators available")
 	}
-	ema, err := ComputeEMA(series.Close, 21)
-	if err == nil {
-		result["ema21"] = ema.Value
+	return result, nil
+}
+
+// SignalsAt returns the signals as of the closed candle back bars before the
+// latest closed one. Past bars are recomputed from the buffer; back 0 is
+// served by the streaming engine when available.
+func (s *Service) SignalsAt(pair, interval string, back int) (map[string]float64, error) {
+	if back < 0 {
+		return nil, fmt.Errorf("invalid offset %d", back)
 	}
-	sma, err := ComputeSMA(series.Close, 50)
-	if err == nil {
-		result["sma50"] = sma.Value
+	if back == 0 && s.stream != nil {
+		return s.Signals(pair, interval)
 	}
-	boll, err := ComputeBollinger(series.Close, 20, 2.0)
-	if err == nil {
-		result["boll_upper"] = boll.Components["upper"]
-		result["boll_lower"] = boll.Components["lower"]
+	var closed []candles.Candle
+	for _, c := range s.candleSource.Candles("binance", pair, interval) {
+		if c.Closed {
+			closed = append(closed, c)
+		}
 	}
-	atr, err := ComputeATR(series.High, series.Low, series.Close, 14)
-	if err == nil {
-		result["atr"] = atr.Value
+	sort.Slice(closed, func(i, j int) bool { return closed[i].Start.Before(closed[j].Start) })
+	if len(closed) <= back {
+		return nil, fmt.Errorf("not enough candles for %s %s", pair, interval)
 	}
-	macd, err := ComputeMACD(series.Close, 12, 26, 9)
-	if err == nil {
-		result["macd"] = macd.MACD
-		result["macd_signal"] = macd.Signal
-		result["macd_histogram"] = macd.Histogram
+	data := seriesOf(closed[:len(closed)-back])
+	result := make(map[string]float64)
+	for _, name := range signalOrder {
+		spec := specs[name]
+		if value, err := s.batch(name, spec, data); err == nil {
+			spec.signals(value, result)
+		}
 	}
 	if len(result) == 0 {
 		return nil, errors.New("no indicators available")
@@ -81,23 +151,50 @@ func (s *Service) Signals(pair, interval string) (map[string]float64, error) {
 	return result, nil
 }
 
+func (s *Service) lookup(name string, spec indicatorSpec, exchange, pair, interval string) (IndicatorResult, error) {
+	start := time.Now()
+	value, ready := s.stream.Lookup(exchange, pair, interval, spec.key, spec.stream)
+	telemetry.IndicatorSeconds.WithLabelValues(name, "stream").Observe(time.Since(start).Seconds())
+	if !ready {
+		return IndicatorResult{}, fmt.Errorf("not enough data for %s", name)
+	}
+	return value, nil
+}
+
+func (s *Service) batch(name string, spec indicatorSpec, data ohlcSeries) (IndicatorResult, error) {
+	start := time.Now()
+	defer func() {
+		telemetry.IndicatorSeconds.WithLabelValues(name, "batch").Observe(time.Since(start).Seconds())
+	}()
+	return spec.batch(data)
+}
+
 type ohlcSeries struct {
-	Close Series
-	High  Series
-	Low   Series
+	Time   []time.Time
+	Close  Series
+	High   Series
+	Low    Series
+	Volume Series
 }
 
-func (s *Service) series(pair, interval string) (ohlcSeries, error) {
-	candles := s.candleSource.Candles("binance", pair, interval)
+func (s *Service) series(exchange, pair, interval string) (ohlcSeries, error) {
+	candles := s.candleSource.Candles(exchange, pair, interval)
 	if len(candles) == 0 {
 		return ohlcSeries{}, fmt.Errorf("no candles for %s %s", pair, interval)
 	}
 	sort.Slice(candles, func(i, j int) bool { return candles[i].Start.Before(candles[j].Start) })
+	return seriesOf(candles), nil
+}
+
+// seriesOf converts candles sorted by start time into columns.
+func seriesOf(list []candles.Candle) ohlcSeries {
 	series := ohlcSeries{}
-	for _, c := range candles {
+	for _, c := range list {
+		series.Time = append(series.Time, c.Start)
 		series.Close = append(series.Close, c.Close)
 		series.High = append(series.High, c.High)
 		series.Low = append(series.Low, c.Low)
+		series.Volume = append(series.Volume, c.Volume)
 	}
-	return series, nil
+	return series
 }
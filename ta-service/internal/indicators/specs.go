@@ -0,0 +1,186 @@
+package indicators
+
+import "sort"
+
+// indicatorSpec binds an indicator name to its default parameters, its
+// streaming and batch implementations, and the keys it contributes to Signals.
+type indicatorSpec struct {
+	key     string
+	stream  func() Streamer
+	batch   func(ohlcSeries) (IndicatorResult, error)
+	signals func(IndicatorResult, map[string]float64)
+}
+
+// signalOrder lists the indicators reported by Signals.
+var signalOrder = []string{
+	"rsi", "ema", "sma", "bollinger", "atr", "macd",
+	"stochrsi", "adx", "vwap", "obv", "ichimoku", "supertrend", "keltner", "donchian", "cci",
+}
+
+var specs = map[string]indicatorSpec{
+	"rsi": {
+		key:    "rsi:14",
+		stream: func() Streamer { return NewRSIStream(14) },
+		batch:  func(d ohlcSeries) (IndicatorResult, error) { return ComputeRSI(d.Close, 14) },
+		signals: func(r IndicatorResult, out map[string]float64) {
+			out["rsi"] = r.Value
+		},
+	},
+	"ema": {
+		key:    "ema:21",
+		stream: func() Streamer { return NewEMAStream(21) },
+		batch:  func(d ohlcSeries) (IndicatorResult, error) { return ComputeEMA(d.Close, 21) },
+		signals: func(r IndicatorResult, out map[string]float64) {
+			out["ema21"] = r.Value
+		},
+	},
+	"sma": {
+		key:    "sma:50",
+		stream: func() Streamer { return NewSMAStream(50) },
+		batch:  func(d ohlcSeries) (IndicatorResult, error) { return ComputeSMA(d.Close, 50) },
+		signals: func(r IndicatorResult, out map[string]float64) {
+			out["sma50"] = r.Value
+		},
+	},
+	"bollinger": {
+		key:    "bollinger:20,2",
+		stream: func() Streamer { return NewBollingerStream(20, 2.0) },
+		batch:  func(d ohlcSeries) (IndicatorResult, error) { return ComputeBollinger(d.Close, 20, 2.0) },
+		signals: func(r IndicatorResult, out map[string]float64) {
+			out["boll_upper"] = r.Components["upper"]
+			out["boll_lower"] = r.Components["lower"]
+		},
+	},
+	"atr": {
+		key:    "atr:14",
+		stream: func() Streamer { return NewATRStream(14) },
+		batch:  func(d ohlcSeries) (IndicatorResult, error) { return ComputeATR(d.High, d.Low, d.Close, 14) },
+		signals: func(r IndicatorResult, out map[string]float64) {
+			out["atr"] = r.Value
+		},
+	},
+	"macd": {
+		key:    "macd:12,26,9",
+		stream: func() Streamer { return NewMACDStream(12, 26, 9) },
+		batch: func(d ohlcSeries) (IndicatorResult, error) {
+			macd, err := ComputeMACD(d.Close, 12, 26, 9)
+			if err != nil {
+				return IndicatorResult{}, err
+			}
+			return IndicatorResult{
+				Value: macd.MACD,
+				Components: map[string]float64{
+					"macd":      macd.MACD,
+					"signal":    macd.Signal,
+					"histogram": macd.Histogram,
+				},
+			}, nil
+		},
+		signals: func(r IndicatorResult, out map[string]float64) {
+			out["macd"] = r.Components["macd"]
+			out["macd_signal"] = r.Components["signal"]
+			out["macd_histogram"] = r.Components["histogram"]
+		},
+	},
+	"stochrsi": {
+		key:    "stochrsi:14,14,3,3",
+		stream: func() Streamer { return NewStochRSIStream(14, 14, 3, 3) },
+		batch:  func(d ohlcSeries) (IndicatorResult, error) { return ComputeStochRSI(d.Close, 14, 14, 3, 3) },
+		signals: func(r IndicatorResult, out map[string]float64) {
+			out["stoch_rsi_k"] = r.Components["k"]
+			out["stoch_rsi_d"] = r.Components["d"]
+		},
+	},
+	"adx": {
+		key:    "adx:14",
+		stream: func() Streamer { return NewADXStream(14) },
+		batch:  func(d ohlcSeries) (IndicatorResult, error) { return ComputeADX(d.High, d.Low, d.Close, 14) },
+		signals: func(r IndicatorResult, out map[string]float64) {
+			out["adx"] = r.Value
+			out["plus_di"] = r.Components["plus_di"]
+			out["minus_di"] = r.Components["minus_di"]
+		},
+	},
+	"vwap": {
+		key:    "vwap",
+		stream: func() Streamer { return NewVWAPStream() },
+		batch: func(d ohlcSeries) (IndicatorResult, error) {
+			return ComputeVWAP(d.Time, d.High, d.Low, d.Close, d.Volume)
+		},
+		signals: func(r IndicatorResult, out map[string]float64) {
+			out["vwap"] = r.Value
+		},
+	},
+	"obv": {
+		key:    "obv",
+		stream: func() Streamer { return NewOBVStream() },
+		batch:  func(d ohlcSeries) (IndicatorResult, error) { return ComputeOBV(d.Close, d.Volume) },
+		signals: func(r IndicatorResult, out map[string]float64) {
+			out["obv"] = r.Value
+		},
+	},
+	"ichimoku": {
+		key:    "ichimoku:9,26,52",
+		stream: func() Streamer { return NewIchimokuStream(9, 26, 52) },
+		batch:  func(d ohlcSeries) (IndicatorResult, error) { return ComputeIchimoku(d.High, d.Low, 9, 26, 52) },
+		signals: func(r IndicatorResult, out map[string]float64) {
+			out["ichimoku_tenkan"] = r.Components["tenkan"]
+			out["ichimoku_kijun"] = r.Components["kijun"]
+			out["ichimoku_senkou_a"] = r.Components["senkou_a"]
+			out["ichimoku_senkou_b"] = r.Components["senkou_b"]
+		},
+	},
+	"supertrend": {
+		key:    "supertrend:10,3",
+		stream: func() Streamer { return NewSuperTrendStream(10, 3.0) },
+		batch: func(d ohlcSeries) (IndicatorResult, error) {
+			return ComputeSuperTrend(d.High, d.Low, d.Close, 10, 3.0)
+		},
+		signals: func(r IndicatorResult, out map[string]float64) {
+			out["supertrend"] = r.Value
+			out["supertrend_direction"] = r.Components["direction"]
+		},
+	},
+	"keltner": {
+		key:    "keltner:20,10,2",
+		stream: func() Streamer { return NewKeltnerStream(20, 10, 2.0) },
+		batch: func(d ohlcSeries) (IndicatorResult, error) {
+			return ComputeKeltner(d.High, d.Low, d.Close, 20, 10, 2.0)
+		},
+		signals: func(r IndicatorResult, out map[string]float64) {
+			out["keltner_upper"] = r.Components["upper"]
+			out["keltner_lower"] = r.Components["lower"]
+		},
+	},
+	"donchian": {
+		key:    "donchian:20",
+		stream: func() Streamer { return NewDonchianStream(20) },
+		batch:  func(d ohlcSeries) (IndicatorResult, error) { return ComputeDonchian(d.High, d.Low, 20) },
+		signals: func(r IndicatorResult, out map[string]float64) {
+			out["donchian_upper"] = r.Components["upper"]
+			out["donchian_lower"] = r.Components["lower"]
+		},
+	},
+	"cci": {
+		key:    "cci:20",
+		stream: func() Streamer { return NewCCIStream(20) },
+		batch:  func(d ohlcSeries) (IndicatorResult, error) { return ComputeCCI(d.High, d.Low, d.Close, 20) },
+		signals: func(r IndicatorResult, out map[string]float64) {
+			out["cci"] = r.Value
+		},
+	},
+}
+
+// SignalKeys lists the keys Signals can report, sorted.
+func SignalKeys() []string {
+	out := make(map[string]float64)
+	for _, name := range signalOrder {
+		specs[name].signals(IndicatorResult{}, out)
+	}
+	keys := make([]string, 0, len(out))
+	for key := range out {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
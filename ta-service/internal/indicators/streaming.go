@@ -0,0 +1,793 @@
+package indicators
+
+import (
+	"math"
+	"time"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/candles"
+)
+
+// Streamer updates an indicator one closed candle at a time in O(1).
+type Streamer interface {
+	Update(c candles.Candle)
+	Result() (IndicatorResult, bool)
+}
+
+// emaCore is an EMA seeded with the SMA of its first period values,
+// matching emaSeries.
+type emaCore struct {
+	period int
+	k      float64
+	count  int
+	sum    float64
+	value  float64
+}
+
+func newEMACore(period int) emaCore {
+	return emaCore{period: period, k: 2.0 / (float64(period) + 1.0)}
+}
+
+func (e *emaCore) add(x float64) bool {
+	e.count++
+	switch {
+	case e.count < e.period:
+		e.sum += x
+		return false
+	case e.count == e.period:
+		e.sum += x
+		e.value = e.sum / float64(e.period)
+		return true
+	default:
+		e.value = x*e.k + e.value*(1-e.k)
+		return true
+	}
+}
+
+// window is a fixed-size ring holding running sums. Sums are rebuilt from the
+// ring each time it wraps so floating point drift stays bounded while updates
+// remain amortised O(1).
+type window struct {
+	values []float64
+	next   int
+	count  int
+	sum    float64
+	sumSq  float64
+}
+
+func newWindow(period int) window {
+	return window{values: make([]float64, period)}
+}
+
+func (w *window) push(x float64) bool {
+	period := len(w.values)
+	if w.count >= period {
+		old := w.values[w.next]
+		w.sum += x
+		w.sum -= old
+		w.sumSq += x*x - old*old
+	} else {
+		w.sum += x
+		w.sumSq += x * x
+	}
+	w.values[w.next] = x
+	w.count++
+	w.next++
+	if w.next == period {
+		w.next = 0
+		w.sum, w.sumSq = 0, 0
+		for _, v := range w.values {
+			w.sum += v
+			w.sumSq += v * v
+		}
+	}
+	return w.count >= period
+}
+
+func (w *window) mean() float64 {
+	return w.sum / float64(len(w.values))
+}
+
+func (w *window) stddev() float64 {
+	mean := w.mean()
+	variance := w.sumSq/float64(len(w.values)) - mean*mean
+	if variance < 0 {
+		variance = 0
+	}
+	return math.Sqrt(variance)
+}
+
+// extremum tracks the rolling maximum or minimum with a monotonic deque.
+type extremum struct {
+	period int
+	max    bool
+	n      int
+	idx    []int
+	vals   []float64
+}
+
+func newExtremum(period int, max bool) extremum {
+	return extremum{period: period, max: max}
+}
+
+func (e *extremum) push(x float64) float64 {
+	for len(e.vals) > 0 {
+		last := e.vals[len(e.vals)-1]
+		if (e.max && last > x) || (!e.max && last < x) {
+			break
+		}
+		e.vals = e.vals[:len(e.vals)-1]
+		e.idx = e.idx[:len(e.idx)-1]
+	}
+	e.vals = append(e.vals, x)
+	e.idx = append(e.idx, e.n)
+	for e.idx[0] <= e.n-e.period {
+		e.vals = e.vals[1:]
+		e.idx = e.idx[1:]
+	}
+	e.n++
+	return e.vals[0]
+}
+
+// trueRangeCore tracks the previous close to produce true range values.
+type trueRangeCore struct {
+	count     int
+	prevClose float64
+}
+
+func (t *trueRangeCore) add(c candles.Candle) float64 {
+	highLow := c.High - c.Low
+	var tr float64
+	if t.count == 0 {
+		tr = math.Abs(highLow)
+	} else {
+		tr = math.Max(highLow, math.Max(math.Abs(c.High-t.prevClose), math.Abs(c.Low-t.prevClose)))
+	}
+	t.count++
+	t.prevClose = c.Close
+	return tr
+}
+
+// rsiCore is Wilder's RSI over a stream of values.
+type rsiCore struct {
+	period  int
+	count   int
+	prev    float64
+	gains   float64
+	losses  float64
+	avgGain float64
+	avgLoss float64
+	value   float64
+}
+
+func (r *rsiCore) add(x float64) bool {
+	r.count++
+	if r.count == 1 {
+		r.prev = x
+		return false
+	}
+	delta := x - r.prev
+	r.prev = x
+	gain, loss := 0.0, 0.0
+	if delta > 0 {
+		gain = delta
+	} else {
+		loss = -delta
+	}
+	p := float64(r.period)
+	switch {
+	case r.count <= r.period:
+		r.gains += gain
+		r.losses += loss
+		return false
+	case r.count == r.period+1:
+		r.gains += gain
+		r.losses += loss
+		r.avgGain = r.gains / p
+		r.avgLoss = r.losses / p
+	default:
+		r.avgGain = (r.avgGain*(p-1) + gain) / p
+		r.avgLoss = (r.avgLoss*(p-1) + loss) / p
+	}
+	if r.avgLoss == 0 {
+		r.value = 100
+	} else {
+		r.value = 100 - (100 / (1 + r.avgGain/r.avgLoss))
+	}
+	return true
+}
+
+// atrCore is Wilder's ATR seeded with the mean of the first period ranges.
+type atrCore struct {
+	period int
+	tr     trueRangeCore
+	sum    float64
+	value  float64
+}
+
+func (a *atrCore) add(c candles.Candle) bool {
+	tr := a.tr.add(c)
+	switch {
+	case a.tr.count < a.period:
+		a.sum += tr
+		return false
+	case a.tr.count == a.period:
+		a.sum += tr
+		a.value = a.sum / float64(a.period)
+	default:
+		a.value = (a.value*float64(a.period-1) + tr) / float64(a.period)
+	}
+	return true
+}
+
+// NewRSIStream returns an incremental RSI.
+func NewRSIStream(period int) Streamer {
+	return &rsiStream{core: rsiCore{period: period}}
+}
+
+type rsiStream struct {
+	core  rsiCore
+	ready bool
+}
+
+func (s *rsiStream) Update(c candles.Candle) {
+	s.ready = s.core.add(c.Close)
+}
+
+func (s *rsiStream) Result() (IndicatorResult, bool) {
+	return IndicatorResult{Value: s.core.value}, s.ready
+}
+
+// NewEMAStream returns an incremental EMA.
+func NewEMAStream(period int) Streamer {
+	return &emaStream{core: newEMACore(period)}
+}
+
+type emaStream struct {
+	core  emaCore
+	ready bool
+}
+
+func (s *emaStream) Update(c candles.Candle) {
+	s.ready = s.core.add(c.Close)
+}
+
+func (s *emaStream) Result() (IndicatorResult, bool) {
+	return IndicatorResult{Value: s.core.value}, s.ready
+}
+
+// NewSMAStream returns an incremental SMA.
+func NewSMAStream(period int) Streamer {
+	return &smaStream{win: newWindow(period)}
+}
+
+type smaStream struct {
+	win   window
+	ready bool
+}
+
+func (s *smaStream) Update(c candles.Candle) {
+	s.ready = s.win.push(c.Close)
+}
+
+func (s *smaStream) Result() (IndicatorResult, bool) {
+	return IndicatorResult{Value: s.win.mean()}, s.ready
+}
+
+// NewBollingerStream returns incremental Bollinger Bands.
+func NewBollingerStream(period int, stddev float64) Streamer {
+	return &bollingerStream{win: newWindow(period), width: stddev}
+}
+
+type bollingerStream struct {
+	win   window
+	width float64
+	ready bool
+}
+
+func (s *bollingerStream) Update(c candles.Candle) {
+	s.ready = s.win.push(c.Close)
+}
+
+func (s *bollingerStream) Result() (IndicatorResult, bool) {
+	mean := s.win.mean()
+	std := s.win.stddev()
+	return IndicatorResult{
+		Value: mean,
+		Components: map[string]float64{
+			"upper":  mean + s.width*std,
+			"lower":  mean - s.width*std,
+			"middle": mean,
+		},
+	}, s.ready
+}
+
+// NewATRStream returns an incremental ATR.
+func NewATRStream(period int) Streamer {
+	return &atrStream{core: atrCore{period: period}}
+}
+
+type atrStream struct {
+	core  atrCore
+	ready bool
+}
+
+func (s *atrStream) Update(c candles.Candle) {
+	s.ready = s.core.add(c)
+}
+
+func (s *atrStream) Result() (IndicatorResult, bool) {
+	return IndicatorResult{Value: s.core.value}, s.ready
+}
+
+// NewMACDStream returns an incremental MACD.
+func NewMACDStream(fast, slow, signal int) Streamer {
+	return &macdStream{
+		fast:     newEMACore(fast),
+		slow:     newEMACore(slow),
+		signal:   newEMACore(signal),
+		required: slow + signal,
+	}
+}
+
+type macdStream struct {
+	fast      emaCore
+	slow      emaCore
+	signal    emaCore
+	count     int
+	required  int
+	macd      float64
+	histogram float64
+}
+
+func (s *macdStream) Update(c candles.Candle) {
+	s.count++
+	s.fast.add(c.Close)
+	if !s.slow.add(c.Close) {
+		return
+	}
+	s.macd = s.fast.value - s.slow.value
+	if s.signal.add(s.macd) {
+		s.histogram = s.macd - s.signal.value
+	}
+}
+
+func (s *macdStream) Result() (IndicatorResult, bool) {
+	return IndicatorResult{
+		Value: s.macd,
+		Components: map[string]float64{
+			"macd":      s.macd,
+			"signal":    s.signal.value,
+			"histogram": s.histogram,
+		},
+	}, s.count >= s.required
+}
+
+// NewStochRSIStream returns an incremental Stochastic RSI.
+func NewStochRSIStream(rsiPeriod, stochPeriod, kPeriod, dPeriod int) Streamer {
+	return &stochRSIStream{
+		rsi:      rsiCore{period: rsiPeriod},
+		lo:       newExtremum(stochPeriod, false),
+		hi:       newExtremum(stochPeriod, true),
+		period:   stochPeriod,
+		k:        newWindow(kPeriod),
+		d:        newWindow(dPeriod),
+		required: stochRSIWarmup(rsiPeriod, stochPeriod, kPeriod, dPeriod) + 1,
+	}
+}
+
+type stochRSIStream struct {
+	rsi      rsiCore
+	lo       extremum
+	hi       extremum
+	period   int
+	rsiCount int
+	k        window
+	d        window
+	count    int
+	required int
+}
+
+func (s *stochRSIStream) Update(c candles.Candle) {
+	s.count++
+	if !s.rsi.add(c.Close) {
+		return
+	}
+	value := s.rsi.value
+	lo := s.lo.push(value)
+	hi := s.hi.push(value)
+	s.rsiCount++
+	if s.rsiCount < s.period {
+		return
+	}
+	stoch := 0.0
+	if hi != lo {
+		stoch = (value - lo) / (hi - lo) * 100
+	}
+	if s.k.push(stoch) {
+		s.d.push(s.k.mean())
+	}
+}
+
+func (s *stochRSIStream) Result() (IndicatorResult, bool) {
+	k := s.k.mean()
+	return IndicatorResult{
+		Value:      k,
+		Components: map[string]float64{"k": k, "d": s.d.mean()},
+	}, s.count >= s.required
+}
+
+// NewCCIStream returns an incremental CCI. The mean deviation still walks
+// the window, so updates cost O(period).
+func NewCCIStream(period int) Streamer {
+	return &cciStream{win: newWindow(period)}
+}
+
+type cciStream struct {
+	win   window
+	value float64
+	ready bool
+}
+
+func (s *cciStream) Update(c candles.Candle) {
+	typical := (c.High + c.Low + c.Close) / 3
+	s.ready = s.win.push(typical)
+	if !s.ready {
+		return
+	}
+	mean := s.win.mean()
+	deviation := 0.0
+	for _, v := range s.win.values {
+		deviation += math.Abs(v - mean)
+	}
+	deviation /= float64(len(s.win.values))
+	if deviation == 0 {
+		s.value = 0
+		return
+	}
+	s.value = (typical - mean) / (0.015 * deviation)
+}
+
+func (s *cciStream) Result() (IndicatorResult, bool) {
+	return IndicatorResult{Value: s.value}, s.ready
+}
+
+// NewADXStream returns an incremental ADX with +DI/-DI.
+func NewADXStream(period int) Streamer {
+	return &adxStream{period: period}
+}
+
+type adxStream struct {
+	period   int
+	tr       trueRangeCore
+	prevHigh float64
+	prevLow  float64
+	smTR     float64
+	smPlus   float64
+	smMinus  float64
+	dxSum    float64
+	plusDI   float64
+	minusDI  float64
+	adx      float64
+}
+
+func (s *adxStream) Update(c candles.Candle) {
+	i := s.tr.count
+	tr := s.tr.add(c)
+	var plusDM, minusDM float64
+	if i > 0 {
+		up := c.High - s.prevHigh
+		down := s.prevLow - c.Low
+		if up > down && up > 0 {
+			plusDM = up
+		}
+		if down > up && down > 0 {
+			minusDM = down
+		}
+	}
+	s.prevHigh, s.prevLow = c.High, c.Low
+	if i == 0 {
+		return
+	}
+	p := float64(s.period)
+	if i <= s.period {
+		s.smTR += tr
+		s.smPlus += plusDM
+		s.smMinus += minusDM
+		if i < s.period {
+			return
+		}
+	} else {
+		s.smTR = s.smTR - s.smTR/p + tr
+		s.smPlus = s.smPlus - s.smPlus/p + plusDM
+		s.smMinus = s.smMinus - s.smMinus/p + minusDM
+	}
+	s.plusDI, s.minusDI = 0, 0
+	if s.smTR > 0 {
+		s.plusDI = 100 * s.smPlus / s.smTR
+		s.minusDI = 100 * s.smMinus / s.smTR
+	}
+	dx := 0.0
+	if sum := s.plusDI + s.minusDI; sum > 0 {
+		dx = 100 * math.Abs(s.plusDI-s.minusDI) / sum
+	}
+	switch {
+	case i < 2*s.period-1:
+		s.dxSum += dx
+	case i == 2*s.period-1:
+		s.dxSum += dx
+		s.adx = s.dxSum / p
+	default:
+		s.adx = (s.adx*(p-1) + dx) / p
+	}
+}
+
+func (s *adxStream) Result() (IndicatorResult, bool) {
+	return IndicatorResult{
+		Value: s.adx,
+		Components: map[string]float64{
+			"adx":      s.adx,
+			"plus_di":  s.plusDI,
+			"minus_di": s.minusDI,
+		},
+	}, s.tr.count >= 2*s.period
+}
+
+// NewVWAPStream returns an incremental session VWAP.
+func NewVWAPStream() Streamer {
+	return &vwapStream{}
+}
+
+type vwapStream struct {
+	session time.Time
+	count   int
+	pv      float64
+	vol     float64
+	value   float64
+}
+
+func (s *vwapStream) Update(c candles.Candle) {
+	day := c.Start.UTC().Truncate(24 * time.Hour)
+	if s.count == 0 || !day.Equal(s.session) {
+		s.session = day
+		s.pv, s.vol = 0, 0
+	}
+	s.count++
+	typical := (c.High + c.Low + c.Close) / 3
+	s.pv += typical * c.Volume
+	s.vol += c.Volume
+	if s.vol > 0 {
+		s.value = s.pv / s.vol
+	} else {
+		s.value = typical
+	}
+}
+
+func (s *vwapStream) Result() (IndicatorResult, bool) {
+	return IndicatorResult{Value: s.value}, s.count > 0
+}
+
+// NewOBVStream returns an incremental On-Balance Volume.
+func NewOBVStream() Streamer {
+	return &obvStream{}
+}
+
+type obvStream struct {
+	count int
+	prev  float64
+	value float64
+}
+
+func (s *obvStream) Update(c candles.Candle) {
+	if s.count > 0 {
+		switch {
+		case c.Close > s.prev:
+			s.value += c.Volume
+		case c.Close < s.prev:
+			s.value -= c.Volume
+		}
+	}
+	s.prev = c.Close
+	s.count++
+}
+
+func (s *obvStream) Result() (IndicatorResult, bool) {
+	return IndicatorResult{Value: s.value}, s.count >= 2
+}
+
+// NewIchimokuStream returns an incremental Ichimoku Cloud.
+func NewIchimokuStream(conversion, base, spanB int) Streamer {
+	return &ichimokuStream{
+		conversion: conversion,
+		base:       base,
+		spanB:      spanB,
+		convHigh:   newExtremum(conversion, true),
+		convLow:    newExtremum(conversion, false),
+		baseHigh:   newExtremum(base, true),
+		baseLow:    newExtremum(base, false),
+		spanHigh:   newExtremum(spanB, true),
+		spanLow:    newExtremum(spanB, false),
+		spanARing:  make([]float64, base),
+		spanBRing:  make([]float64, base),
+	}
+}
+
+type ichimokuStream struct {
+	conversion int
+	base       int
+	spanB      int
+	count      int
+	convHigh   extremum
+	convLow    extremum
+	baseHigh   extremum
+	baseLow    extremum
+	spanHigh   extremum
+	spanLow    extremum
+	spanARing  []float64
+	spanBRing  []float64
+	tenkan     float64
+	kijun      float64
+	senkouA    float64
+	senkouB    float64
+}
+
+func (s *ichimokuStream) Update(c candles.Candle) {
+	i := s.count
+	s.count++
+	tenkan := (s.convHigh.push(c.High) + s.convLow.push(c.Low)) / 2
+	kijun := (s.baseHigh.push(c.High) + s.baseLow.push(c.Low)) / 2
+	rawB := (s.spanHigh.push(c.High) + s.spanLow.push(c.Low)) / 2
+	if i >= s.conversion-1 {
+		s.tenkan = tenkan
+	}
+	if i >= s.base-1 {
+		s.kijun = kijun
+	}
+	slot := i % s.base
+	s.spanARing[slot], s.spanBRing[slot] = 0, 0
+	if i >= s.base-1 && i >= s.conversion-1 {
+		s.spanARing[slot] = (tenkan + kijun) / 2
+	}
+	if i >= s.spanB-1 && i >= s.base-1 {
+		s.spanBRing[slot] = rawB
+	}
+	// Values computed base-1 candles ago describe the cloud under this candle.
+	if lag := i - (s.base - 1); lag >= 0 {
+		s.senkouA = s.spanARing[lag%s.base]
+		s.senkouB = s.spanBRing[lag%s.base]
+	}
+}
+
+func (s *ichimokuStream) Result() (IndicatorResult, bool) {
+	return IndicatorResult{
+		Value: s.tenkan,
+		Components: map[string]float64{
+			"tenkan":   s.tenkan,
+			"kijun":    s.kijun,
+			"senkou_a": s.senkouA,
+			"senkou_b": s.senkouB,
+		},
+	}, s.count >= s.base+s.spanB-1
+}
+
+// NewSuperTrendStream returns an incremental SuperTrend.
+func NewSuperTrendStream(period int, multiplier float64) Streamer {
+	return &superTrendStream{atr: atrCore{period: period}, multiplier: multiplier}
+}
+
+type superTrendStream struct {
+	atr        atrCore
+	multiplier float64
+	started    bool
+	prevClose  float64
+	finalUpper float64
+	finalLower float64
+	direction  float64
+	line       float64
+}
+
+func (s *superTrendStream) Update(c candles.Candle) {
+	defer func() { s.prevClose = c.Close }()
+	if !s.atr.add(c) {
+		return
+	}
+	mid := (c.High + c.Low) / 2
+	basicUpper := mid + s.multiplier*s.atr.value
+	basicLower := mid - s.multiplier*s.atr.value
+	if !s.started {
+		s.started = true
+		s.finalUpper, s.finalLower = basicUpper, basicLower
+		if c.Close > s.finalUpper {
+			s.direction = 1
+		} else {
+			s.direction = -1
+		}
+	} else {
+		if basicUpper < s.finalUpper || s.prevClose > s.finalUpper {
+			s.finalUpper = basicUpper
+		}
+		if basicLower > s.finalLower || s.prevClose < s.finalLower {
+			s.finalLower = basicLower
+		}
+		switch {
+		case s.direction < 0 && c.Close > s.finalUpper:
+			s.direction = 1
+		case s.direction > 0 && c.Close < s.finalLower:
+			s.direction = -1
+		}
+	}
+	if s.direction > 0 {
+		s.line = s.finalLower
+	} else {
+		s.line = s.finalUpper
+	}
+}
+
+func (s *superTrendStream) Result() (IndicatorResult, bool) {
+	return IndicatorResult{
+		Value:      s.line,
+		Components: map[string]float64{"direction": s.direction},
+	}, s.started
+}
+
+// NewKeltnerStream returns incremental Keltner Channels.
+func NewKeltnerStream(emaPeriod, atrPeriod int, multiplier float64) Streamer {
+	return &keltnerStream{ema: newEMACore(emaPeriod), atr: atrCore{period: atrPeriod}, multiplier: multiplier}
+}
+
+type keltnerStream struct {
+	ema        emaCore
+	atr        atrCore
+	multiplier float64
+	emaReady   bool
+	atrReady   bool
+}
+
+func (s *keltnerStream) Update(c candles.Candle) {
+	s.emaReady = s.ema.add(c.Close)
+	s.atrReady = s.atr.add(c)
+}
+
+func (s *keltnerStream) Result() (IndicatorResult, bool) {
+	middle := s.ema.value
+	width := s.multiplier * s.atr.value
+	return IndicatorResult{
+		Value: middle,
+		Components: map[string]float64{
+			"upper":  middle + width,
+			"middle": middle,
+			"lower":  middle - width,
+		},
+	}, s.emaReady && s.atrReady
+}
+
+// NewDonchianStream returns incremental Donchian Channels.
+func NewDonchianStream(period int) Streamer {
+	return &donchianStream{high: newExtremum(period, true), low: newExtremum(period, false), period: period}
+}
+
+type donchianStream struct {
+	high   extremum
+	low    extremum
+	period int
+	count  int
+	upper  float64
+	lower  float64
+}
+
+func (s *donchianStream) Update(c candles.Candle) {
+	s.upper = s.high.push(c.High)
+	s.lower = s.low.push(c.Low)
+	s.count++
+}
+
+func (s *donchianStream) Result() (IndicatorResult, bool) {
+	middle := (s.upper + s.lower) / 2
+	return IndicatorResult{
+		Value: middle,
+		Components: map[string]float64{
+			"upper":  s.upper,
+			"middle": middle,
+			"lower":  s.lower,
+		},
+	}, s.count >= s.period
+}
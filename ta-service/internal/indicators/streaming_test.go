@@ -0,0 +1,224 @@
+package indicators
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/candles"
+)
+
+func referenceCandles(n int) []candles.Candle {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	out := make([]candles.Candle, n)
+	for i := range out {
+		c := 100 + 10*math.Sin(float64(i)/5) + float64(i%90)*0.3
+		out[i] = candles.Candle{
+			Exchange: "binance",
+			Pair:     "ETHUSDT",
+			Interval: "1h",
+			Open:     c - 0.2,
+			High:     c + 1 + float64(i%3)*0.5,
+			Low:      c - 1 - float64(i%4)*0.25,
+			Close:    c,
+			Volume:   1000 + float64(i%7)*100,
+			Start:    start.Add(time.Duration(i) * time.Hour),
+			Closed:   true,
+		}
+	}
+	return out
+}
+
+func toSeries(data []candles.Candle) ohlcSeries {
+	var series ohlcSeries
+	for _, c := range data {
+		series.Time = append(series.Time, c.Start)
+		series.Close = append(series.Close, c.Close)
+		series.High = append(series.High, c.High)
+		series.Low = append(series.Low, c.Low)
+		series.Volume = append(series.Volume, c.Volume)
+	}
+	return series
+}
+
+func TestStreamersMatchBatch(t *testing.T) {
+	data := referenceCandles(300)
+	for name, spec := range specs {
+		streamer := spec.stream()
+		for i, c := range data {
+			streamer.Update(c)
+			got, ready := streamer.Result()
+			want, err := spec.batch(toSeries(data[:i+1]))
+			if ready != (err == nil) {
+				t.Fatalf("%s: readiness mismatch at %d (stream %v, batch err %v)", name, i, ready, err)
+			}
+			if !ready {
+				continue
+			}
+			if diff := math.Abs(got.Value - want.Value); diff > 1e-6 {
+				t.Fatalf("%s: value mismatch at %d: %.8f vs %.8f", name, i, got.Value, want.Value)
+			}
+			for key, w := range want.Components {
+				if diff := math.Abs(got.Components[key] - w); diff > 1e-6 {
+					t.Fatalf("%s: %s mismatch at %d: %.8f vs %.8f", name, key, i, got.Components[key], w)
+				}
+			}
+		}
+	}
+}
+
+type notifyingSource struct {
+	mu        sync.Mutex
+	data      []candles.Candle
+	listeners []func(candles.Candle)
+}
+
+func (s *notifyingSource) Candles(exchange, pair, interval string) []candles.Candle {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]candles.Candle(nil), s.data...)
+}
+
+func (s *notifyingSource) OnCandleClose(fn func(candles.Candle)) {
+	s.listeners = append(s.listeners, fn)
+}
+
+func (s *notifyingSource) add(c candles.Candle) {
+	s.mu.Lock()
+	s.data = append(s.data, c)
+	s.mu.Unlock()
+	for _, fn := range s.listeners {
+		fn(c)
+	}
+}
+
+func TestServiceStreamsClosedCandles(t *testing.T) {
+	data := referenceCandles(200)
+	source := &notifyingSource{}
+	svc := NewService(source)
+	for _, c := range data[:100] {
+		source.add(c)
+	}
+	// First lookup warms streamers from the buffered candles.
+	if _, err := svc.Signals("ETHUSDT", "1h"); err != nil {
+		t.Fatalf("signals failed: %v", err)
+	}
+	for _, c := range data[100:] {
+		source.add(c)
+	}
+	got, err := svc.RSI("ETHUSDT", "1h")
+	if err != nil {
+		t.Fatalf("rsi failed: %v", err)
+	}
+	want, _ := ComputeRSI(toSeries(data).Close, 14)
+	if diff := math.Abs(got.Value - want.Value); diff > 1e-9 {
+		t.Fatalf("streamed rsi %.6f, batch %.6f", got.Value, want.Value)
+	}
+}
+
+func TestEngineOnlyTracksCollectedPairs(t *testing.T) {
+	source := &notifyingSource{}
+	svc := NewService(source)
+	if _, err := svc.ExchangeSignals("binance", "NOPEUSDT", "1m"); err == nil {
+		t.Fatal("expected no signals for a pair without candles")
+	}
+	if n := len(svc.stream.sets); n != 0 {
+		t.Fatalf("lookups of uncollected pairs created %d streamer sets", n)
+	}
+
+	for _, c := range referenceCandles(100) {
+		source.add(c)
+	}
+	if _, err := svc.Signals("ETHUSDT", "1h"); err != nil {
+		t.Fatalf("signals failed: %v", err)
+	}
+	if n := len(svc.stream.sets); n != 1 {
+		t.Fatalf("expected one streamer set, got %d", n)
+	}
+	svc.stream.Drop("binance", "ETHUSDT", "1h")
+	if n := len(svc.stream.sets); n != 0 {
+		t.Fatalf("dropped pair kept %d streamer sets", n)
+	}
+}
+
+func BenchmarkBatchSignals(b *testing.B) {
+	data := referenceCandles(1000)
+	svc := NewService(staticSource(data))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := svc.Signals("ETHUSDT", "1h"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStreamingSignals(b *testing.B) {
+	data := referenceCandles(1000 + b.N)
+	source := &notifyingSource{data: data[:1000]}
+	svc := NewService(source)
+	if _, err := svc.Signals("ETHUSDT", "1h"); err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		svc.stream.OnClose(data[1000+i])
+		if _, err := svc.Signals("ETHUSDT", "1h"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkBatchRSI(b *testing.B) {
+	closes := toSeries(referenceCandles(1000)).Close
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = ComputeRSI(closes, 14)
+	}
+}
+
+func BenchmarkStreamingRSI(b *testing.B) {
+	data := referenceCandles(b.N + 1)
+	streamer := NewRSIStream(14)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		streamer.Update(data[i])
+		_, _ = streamer.Result()
+	}
+}
+
+func BenchmarkBatchBollinger(b *testing.B) {
+	closes := toSeries(referenceCandles(1000)).Close
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = ComputeBollinger(closes, 20, 2.0)
+	}
+}
+
+func BenchmarkStreamingBollinger(b *testing.B) {
+	data := referenceCandles(b.N + 1)
+	streamer := NewBollingerStream(20, 2.0)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		streamer.Update(data[i])
+		_, _ = streamer.Result()
+	}
+}
+
+func BenchmarkBatchMACD(b *testing.B) {
+	closes := toSeries(referenceCandles(1000)).Close
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = ComputeMACD(closes, 12, 26, 9)
+	}
+}
+
+func BenchmarkStreamingMACD(b *testing.B) {
+	data := referenceCandles(b.N + 1)
+	streamer := NewMACDStream(12, 26, 9)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		streamer.Update(data[i])
+		_, _ = streamer.Result()
+	}
+}
@@ -0,0 +1,221 @@
+package indicators
+
+import (
+	"errors"
+	"math"
+)
+
+// ComputeADX calculates the Average Directional Index with the +DI/-DI lines.
+func ComputeADX(high, low, close Series, period int) (IndicatorResult, error) {
+	if len(close) < 2*period || len(high) < 2*period || len(low) < 2*period {
+		return IndicatorResult{}, errors.New("not enough data for ADX")
+	}
+	adx, plusDI, minusDI := adxSeries(high, low, close, period)
+	if len(adx) == 0 {
+		return IndicatorResult{}, errors.New("failed to compute ADX")
+	}
+	idx := len(adx) - 1
+	return IndicatorResult{
+		Value: adx[idx],
+		Components: map[string]float64{
+			"adx":      adx[idx],
+			"plus_di":  plusDI[idx],
+			"minus_di": minusDI[idx],
+		},
+	}, nil
+}
+
+// ComputeIchimoku calculates the Ichimoku Cloud lines for the latest candle.
+// Senkou spans are displaced by the base period so they describe the cloud
+// under the current candle.
+func ComputeIchimoku(high, low Series, conversion, base, spanB int) (IndicatorResult, error) {
+	if len(high) < base+spanB-1 || len(low) < base+spanB-1 {
+		return IndicatorResult{}, errors.New("not enough data for Ichimoku")
+	}
+	tenkan, kijun, spanA, spanBLine := ichimokuSeries(high, low, conversion, base, spanB)
+	if len(tenkan) == 0 {
+		return IndicatorResult{}, errors.New("failed to compute Ichimoku")
+	}
+	idx := len(tenkan) - 1
+	return IndicatorResult{
+		Value: tenkan[idx],
+		Components: map[string]float64{
+			"tenkan":   tenkan[idx],
+			"kijun":    kijun[idx],
+			"senkou_a": spanA[idx],
+			"senkou_b": spanBLine[idx],
+		},
+	}, nil
+}
+
+// ComputeSuperTrend calculates the SuperTrend line and its direction
+// (1 for uptrend, -1 for downtrend).
+func ComputeSuperTrend(high, low, close Series, period int, multiplier float64) (IndicatorResult, error) {
+	if len(close) < period || len(high) < period || len(low) < period {
+		return IndicatorResult{}, errors.New("not enough data for SuperTrend")
+	}
+	line, direction := superTrendSeries(high, low, close, period, multiplier)
+	if len(line) == 0 {
+		return IndicatorResult{}, errors.New("failed to compute SuperTrend")
+	}
+	idx := len(line) - 1
+	return IndicatorResult{
+		Value:      line[idx],
+		Components: map[string]float64{"direction": direction[idx]},
+	}, nil
+}
+
+func trueRange(high, low, close Series) []float64 {
+	tr := make([]float64, len(close))
+	for i := range close {
+		highLow := high[i] - low[i]
+		if i == 0 {
+			tr[i] = math.Abs(highLow)
+			continue
+		}
+		highClose := math.Abs(high[i] - close[i-1])
+		lowClose := math.Abs(low[i] - close[i-1])
+		tr[i] = math.Max(highLow, math.Max(highClose, lowClose))
+	}
+	return tr
+}
+
+func adxSeries(high, low, close Series, period int) (adx, plusDI, minusDI []float64) {
+	n := len(close)
+	if period <= 0 || len(high) != n || len(low) != n || n < 2*period {
+		return nil, nil, nil
+	}
+	tr := trueRange(high, low, close)
+	plusDM := make([]float64, n)
+	minusDM := make([]float64, n)
+	for i := 1; i < n; i++ {
+		up := high[i] - high[i-1]
+		down := low[i-1] - low[i]
+		if up > down && up > 0 {
+			plusDM[i] = up
+		}
+		if down > up && down > 0 {
+			minusDM[i] = down
+		}
+	}
+
+	adx = make([]float64, n)
+	plusDI = make([]float64, n)
+	minusDI = make([]float64, n)
+	dx := make([]float64, n)
+	var smTR, smPlus, smMinus float64
+	for i := 1; i <= period; i++ {
+		smTR += tr[i]
+		smPlus += plusDM[i]
+		smMinus += minusDM[i]
+	}
+	p := float64(period)
+	for i := period; i < n; i++ {
+		if i > period {
+			smTR = smTR - smTR/p + tr[i]
+			smPlus = smPlus - smPlus/p + plusDM[i]
+			smMinus = smMinus - smMinus/p + minusDM[i]
+		}
+		if smTR > 0 {
+			plusDI[i] = 100 * smPlus / smTR
+			minusDI[i] = 100 * smMinus / smTR
+		}
+		if sum := plusDI[i] + minusDI[i]; sum > 0 {
+			dx[i] = 100 * math.Abs(plusDI[i]-minusDI[i]) / sum
+		}
+	}
+
+	seed := 0.0
+	for i := period; i < 2*period; i++ {
+		seed += dx[i]
+	}
+	value := seed / p
+	adx[2*period-1] = value
+	for i := 2 * period; i < n; i++ {
+		value = (value*(p-1) + dx[i]) / p
+		adx[i] = value
+	}
+	return adx, plusDI, minusDI
+}
+
+func ichimokuSeries(high, low Series, conversion, base, spanB int) (tenkan, kijun, spanA, spanBLine []float64) {
+	n := len(high)
+	if conversion <= 0 || base <= 0 || spanB <= 0 || len(low) != n || n < base+spanB-1 {
+		return nil, nil, nil, nil
+	}
+	tenkan = midpointSeries(high, low, conversion)
+	kijun = midpointSeries(high, low, base)
+	rawB := midpointSeries(high, low, spanB)
+	spanA = make([]float64, n)
+	spanBLine = make([]float64, n)
+	for i := base - 1; i+base-1 < n; i++ {
+		if i >= conversion-1 && i >= base-1 {
+			spanA[i+base-1] = (tenkan[i] + kijun[i]) / 2
+		}
+		if i >= spanB-1 {
+			spanBLine[i+base-1] = rawB[i]
+		}
+	}
+	return tenkan, kijun, spanA, spanBLine
+}
+
+// midpointSeries returns (highest high + lowest low) / 2 over the window.
+func midpointSeries(high, low Series, period int) []float64 {
+	upper, lower := channelSeries(high, low, period)
+	if len(upper) == 0 {
+		return nil
+	}
+	result := make([]float64, len(high))
+	for i := period - 1; i < len(high); i++ {
+		result[i] = (upper[i] + lower[i]) / 2
+	}
+	return result
+}
+
+func superTrendSeries(high, low, close Series, period int, multiplier float64) (line, direction []float64) {
+	n := len(close)
+	if period <= 0 || len(high) != n || len(low) != n || n < period {
+		return nil, nil
+	}
+	atr := atrSeries(high, low, close, period)
+	if len(atr) == 0 {
+		return nil, nil
+	}
+	line = make([]float64, n)
+	direction = make([]float64, n)
+	var finalUpper, finalLower float64
+	for i := period - 1; i < n; i++ {
+		mid := (high[i] + low[i]) / 2
+		basicUpper := mid + multiplier*atr[i]
+		basicLower := mid - multiplier*atr[i]
+		if i == period-1 {
+			finalUpper, finalLower = basicUpper, basicLower
+			if close[i] > finalUpper {
+				line[i], direction[i] = finalLower, 1
+			} else {
+				line[i], direction[i] = finalUpper, -1
+			}
+			continue
+		}
+		if basicUpper < finalUpper || close[i-1] > finalUpper {
+			finalUpper = basicUpper
+		}
+		if basicLower > finalLower || close[i-1] < finalLower {
+			finalLower = basicLower
+		}
+		switch {
+		case direction[i-1] < 0 && close[i] > finalUpper:
+			direction[i] = 1
+		case direction[i-1] > 0 && close[i] < finalLower:
+			direction[i] = -1
+		default:
+			direction[i] = direction[i-1]
+		}
+		if direction[i] > 0 {
+			line[i] = finalLower
+		} else {
+			line[i] = finalUpper
+		}
+	}
+	return line, direction
+}
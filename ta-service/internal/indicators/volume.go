@@ -0,0 +1,75 @@
+package indicators
+
+import (
+	"errors"
+	"time"
+)
+
+// ComputeVWAP calculates the session VWAP, resetting at each UTC day boundary.
+func ComputeVWAP(times []time.Time, high, low, close, volume Series) (IndicatorResult, error) {
+	if len(close) == 0 {
+		return IndicatorResult{}, errors.New("not enough data for VWAP")
+	}
+	arr := vwapSeries(times, high, low, close, volume)
+	if len(arr) == 0 {
+		return IndicatorResult{}, errors.New("failed to compute VWAP")
+	}
+	return IndicatorResult{Value: arr[len(arr)-1]}, nil
+}
+
+// ComputeOBV calculates On-Balance Volume.
+func ComputeOBV(close, volume Series) (IndicatorResult, error) {
+	if len(close) < 2 {
+		return IndicatorResult{}, errors.New("not enough data for OBV")
+	}
+	arr := obvSeries(close, volume)
+	if len(arr) == 0 {
+		return IndicatorResult{}, errors.New("failed to compute OBV")
+	}
+	return IndicatorResult{Value: arr[len(arr)-1]}, nil
+}
+
+func vwapSeries(times []time.Time, high, low, close, volume Series) []float64 {
+	n := len(close)
+	if n == 0 || len(times) != n || len(high) != n || len(low) != n || len(volume) != n {
+		return nil
+	}
+	result := make([]float64, n)
+	var session time.Time
+	var pv, vol float64
+	for i := 0; i < n; i++ {
+		day := times[i].UTC().Truncate(24 * time.Hour)
+		if i == 0 || !day.Equal(session) {
+			session = day
+			pv, vol = 0, 0
+		}
+		typical := (high[i] + low[i] + close[i]) / 3
+		pv += typical * volume[i]
+		vol += volume[i]
+		if vol > 0 {
+			result[i] = pv / vol
+		} else {
+			result[i] = typical
+		}
+	}
+	return result
+}
+
+func obvSeries(close, volume Series) []float64 {
+	n := len(close)
+	if n == 0 || len(volume) != n {
+		return nil
+	}
+	result := make([]float64, n)
+	for i := 1; i < n; i++ {
+		switch {
+		case close[i] > close[i-1]:
+			result[i] = result[i-1] + volume[i]
+		case close[i] < close[i-1]:
+			result[i] = result[i-1] - volume[i]
+		default:
+			result[i] = result[i-1]
+		}
+	}
+	return result
+}
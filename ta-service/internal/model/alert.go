@@ -0,0 +1,58 @@
+package model
+
+import "time"
+
+// AlertKind selects how an alert is evaluated.
+type AlertKind string
+
+// Alert kinds.
+const (
+	// AlertPrice fires when the close crosses above or below Threshold.
+	AlertPrice AlertKind = "price"
+	// AlertMove fires when the close moved Threshold percent over Lookback candles.
+	AlertMove AlertKind = "move"
+	// AlertIndicator fires when the Indicator signal crosses above or below
+	// Threshold.
+	AlertIndicator AlertKind = "indicator"
+	// AlertBollinger fires when the close breaks out of the Bollinger bands.
+	AlertBollinger AlertKind = "bollinger"
+)
+
+// Alert directions. Moves treat above/below as up/down.
+const (
+	DirectionAbove = "above"
+	DirectionBelow = "below"
+	DirectionAny   = "any"
+)
+
+// Alert is a chat's condition on closed candles of one pair and interval.
+// Recurring alerts re-arm once the condition stops holding; one-shot alerts
+// deactivate after firing. Price and indicator alerts start disarmed so they
+// only fire on a crossing, not on a condition that already held when they
+// were created.
+type Alert struct {
+	ID        int64      `json:"id"`
+	ChatID    int64      `json:"chat_id"`
+	Kind      AlertKind  `json:"kind"`
+	Exchange  string     `json:"exchange"`
+	Pair      string     `json:"pair"`
+	Interval  string     `json:"interval"`
+	Indicator string     `json:"indicator,omitempty"`
+	Direction string     `json:"direction"`
+	Threshold float64    `json:"threshold"`
+	Lookback  int        `json:"lookback,omitempty"`
+	Recurring bool       `json:"recurring"`
+	Active    bool       `json:"active"`
+	Armed     bool       `json:"-"`
+	LastFired *time.Time `json:"last_fired,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// AlertEvent is a fired alert awaiting delivery to its chat.
+type AlertEvent struct {
+	ID      int64     `json:"id"`
+	AlertID int64     `json:"alert_id"`
+	ChatID  int64     `json:"chat_id"`
+	Message string    `json:"message"`
+	FiredAt time.Time `json:"fired_at"`
+}
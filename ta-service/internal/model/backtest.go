@@ -0,0 +1,35 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Backtest job statuses.
+const (
+	BacktestQueued  = "queued"
+	BacktestRunning = "running"
+	BacktestDone    = "done"
+	BacktestFailed  = "failed"
+)
+
+// BacktestJob is a queued backtest of one strategy on a pair's stored
+// candles between From and To. Result holds the report metrics once the job
+// is done; Error says why it failed.
+type BacktestJob struct {
+	ID         int64              `json:"id"`
+	ChatID     int64              `json:"chat_id,omitempty"`
+	Strategy   string             `json:"strategy"`
+	Params     map[string]float64 `json:"params"`
+	Pair       string             `json:"pair"`
+	Interval   string             `json:"interval"`
+	From       time.Time          `json:"from"`
+	To         time.Time          `json:"to"`
+	Cash       float64            `json:"cash"`
+	Status     string             `json:"status"`
+	Error      string             `json:"error,omitempty"`
+	Result     json.RawMessage    `json:"result,omitempty"`
+	CreatedAt  time.Time          `json:"created_at"`
+	StartedAt  *time.Time         `json:"started_at,omitempty"`
+	FinishedAt *time.Time         `json:"finished_at,omitempty"`
+}
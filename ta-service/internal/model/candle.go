@@ -12,4 +12,5 @@ type Candle struct {
     Close    float64   `json:"close"`
     Volume   float64   `json:"volume"`
     Start    time.Time `json:"start"`
+    Closed   bool      `json:"closed"`
 }
@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+// Strategy is a chat's auto-trade rule set on one pair and interval. Entry
+// and Exit are filter expressions evaluated on every closed candle; a
+// dry-run strategy only notifies the chat.
+type Strategy struct {
+	ID              int64      `json:"id"`
+	ChatID          int64      `json:"chat_id"`
+	Name            string     `json:"name"`
+	Pair            string     `json:"pair"`
+	Interval        string     `json:"interval"`
+	Entry           string     `json:"entry"`
+	Exit            string     `json:"exit,omitempty"`
+	Size            float64    `json:"size"`
+	SlippageBps     int        `json:"slippage_bps"`
+	CooldownSeconds int        `json:"cooldown_seconds"`
+	MaxPositions    int        `json:"max_positions"`
+	DryRun          bool       `json:"dry_run"`
+	PaperTrading    bool       `json:"paper_trading"`
+	Enabled         bool       `json:"enabled"`
+	OpenPositions   int        `json:"open_positions"`
+	LastTrade       *time.Time `json:"last_trade,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+// Cooldown is the minimum time between a strategy's trades.
+func (s Strategy) Cooldown() time.Duration {
+	return time.Duration(s.CooldownSeconds) * time.Second
+}
+
+// ChatFilter gates every strategy entry of a chat; /autotrade sets it.
+type ChatFilter struct {
+	ChatID     int64     `json:"chat_id"`
+	Expression string    `json:"expression"`
+	Interval   string    `json:"interval"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
@@ -0,0 +1,53 @@
+package replay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/backtest"
+)
+
+// Source selects the candles to replay: CSV files, or pairs stored in
+// ta_candles.
+type Source struct {
+	Files    []string
+	Exchange string
+	Pairs    []string
+	Interval string
+	From     time.Time
+	To       time.Time
+	// Warmup is the number of bars before From loaded to warm indicators.
+	Warmup int
+}
+
+// Load reads the candles src selects.
+func Load(ctx context.Context, store backtest.CandleStore, src Source) ([]backtest.Series, error) {
+	var (
+		series []backtest.Series
+		err    error
+	)
+	switch {
+	case len(src.Files) > 0 && len(src.Pairs) > 0:
+		return nil, errors.New("replay either CSV files or stored pairs, not both")
+	case len(src.Files) > 0:
+		series, err = backtest.LoadCSVFiles(src.Files, src.Interval)
+		if err == nil && !src.To.IsZero() {
+			series = backtest.Slice(series, time.Time{}, src.To)
+		}
+	case len(src.Pairs) > 0:
+		series, err = backtest.LoadStored(ctx, store, src.Exchange, src.Pairs, src.Interval, src.From, src.To, src.Warmup)
+	default:
+		return nil, errors.New("replay needs CSV files or pairs")
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range series {
+		if len(s.Candles) == 0 {
+			return nil, fmt.Errorf("no %s candles to replay", s.Pair)
+		}
+	}
+	return series, nil
+}
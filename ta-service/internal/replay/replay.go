@@ -0,0 +1,195 @@
+// Package replay feeds stored or CSV candles through the candle service on
+// a virtual clock, so alerts, strategies and the HTTP/WS APIs behave as
+// they would have live.
+package replay
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/backtest"
+	"github.com/example/tg-crypto-trader/ta-service/internal/candles"
+)
+
+// Clock is the replay's virtual time. Between candles it runs at the
+// replay speed, but never past the close of the next candle, so nothing
+// observes a time whose candle has not been delivered yet.
+type Clock struct {
+	mu    sync.RWMutex
+	at    time.Time
+	set   time.Time
+	limit time.Time
+	speed float64
+	wall  func() time.Time
+}
+
+// NewClock returns a clock stopped at start. A speed of 0 only moves the
+// clock as candles are delivered.
+func NewClock(start time.Time, speed float64) *Clock {
+	return &Clock{at: start, set: time.Now(), limit: start, speed: speed, wall: time.Now}
+}
+
+// Now returns the virtual time.
+func (c *Clock) Now() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.speed <= 0 {
+		return c.at
+	}
+	now := c.at.Add(time.Duration(float64(c.wall().Sub(c.set)) * c.speed))
+	if now.After(c.limit) {
+		return c.limit
+	}
+	return now
+}
+
+// advance moves the clock to at and lets it run up to limit.
+func (c *Clock) advance(at, limit time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.at, c.set, c.limit = at, c.wall(), limit
+}
+
+// Sink receives replayed candles; *candles.Service implements it.
+type Sink interface {
+	Preload(c candles.Candle)
+	Ingest(c candles.Candle)
+}
+
+// Worker consumes candle closes asynchronously, such as the alert engine
+// or the strategy runner. The player waits for workers to go idle before
+// moving the clock on.
+type Worker interface {
+	Idle() bool
+}
+
+// Options configure a Player.
+type Options struct {
+	// Exchange is the exchange the candles are delivered as.
+	Exchange string
+	// From is where the replay starts; earlier candles are preloaded.
+	From time.Time
+	// Speed is how many virtual seconds pass per real second; 0 replays
+	// as fast as the workers keep up.
+	Speed float64
+}
+
+// Player replays candle series.
+type Player struct {
+	series  []backtest.Series
+	sink    Sink
+	clock   *Clock
+	workers []Worker
+	opts    Options
+	logger  zerolog.Logger
+
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewPlayer returns a Player delivering series to sink on clock.
+func NewPlayer(series []backtest.Series, sink Sink, clock *Clock, workers []Worker, opts Options, logger zerolog.Logger) *Player {
+	return &Player{series: series, sink: sink, clock: clock, workers: workers, opts: opts, logger: logger, sleep: sleep}
+}
+
+// step is the candles of every pair closing at the same time.
+type step struct {
+	close   time.Time
+	candles []candles.Candle
+}
+
+// Run preloads the warm-up candles and then replays the rest until done or
+// ctx is cancelled.
+func (p *Player) Run(ctx context.Context) error {
+	steps := p.prepare()
+	if len(steps) == 0 {
+		p.logger.Warn().Msg("nothing to replay")
+		return nil
+	}
+	p.clock.advance(p.clock.Now(), steps[0].close)
+	p.logger.Info().Time("from", steps[0].close).Time("to", steps[len(steps)-1].close).
+		Int("steps", len(steps)).Float64("speed", p.opts.Speed).Msg("replay started")
+	started := time.Now()
+	for i, s := range steps {
+		if p.opts.Speed > 0 {
+			wait := time.Duration(float64(s.close.Sub(p.clock.Now())) / p.opts.Speed)
+			if err := p.sleep(ctx, wait); err != nil {
+				return err
+			}
+		}
+		if err := p.drain(ctx); err != nil {
+			return err
+		}
+		limit := s.close
+		if i+1 < len(steps) {
+			limit = steps[i+1].close
+		}
+		p.clock.advance(s.close, limit)
+		for _, c := range s.candles {
+			p.sink.Ingest(c)
+		}
+		if (i+1)%1000 == 0 {
+			p.logger.Debug().Int("step", i+1).Time("at", s.close).Msg("replay progress")
+		}
+	}
+	if err := p.drain(ctx); err != nil {
+		return err
+	}
+	p.logger.Info().Int("steps", len(steps)).Dur("took", time.Since(started)).Msg("replay finished")
+	return nil
+}
+
+// prepare preloads candles before From and groups the rest by close time.
+func (p *Player) prepare() []step {
+	byClose := make(map[int64][]candles.Candle)
+	for _, s := range p.series {
+		for _, c := range s.Candles {
+			c.Exchange, c.Pair, c.Closed = p.opts.Exchange, s.Pair, true
+			if c.Start.Before(p.opts.From) {
+				p.sink.Preload(c)
+				continue
+			}
+			end := c.Start
+			if d, ok := candles.IntervalDuration(c.Interval); ok {
+				end = end.Add(d)
+			}
+			byClose[end.UnixNano()] = append(byClose[end.UnixNano()], c)
+		}
+	}
+	steps := make([]step, 0, len(byClose))
+	for at, list := range byClose {
+		sort.Slice(list, func(i, j int) bool { return list[i].Pair < list[j].Pair })
+		steps = append(steps, step{close: time.Unix(0, at).UTC(), candles: list})
+	}
+	sort.Slice(steps, func(i, j int) bool { return steps[i].close.Before(steps[j].close) })
+	return steps
+}
+
+// drain waits for every worker to finish the candles delivered so far.
+func (p *Player) drain(ctx context.Context) error {
+	for _, w := range p.workers {
+		for !w.Idle() {
+			if err := p.sleep(ctx, 5*time.Millisecond); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,117 @@
+package replay
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/backtest"
+	"github.com/example/tg-crypto-trader/ta-service/internal/candles"
+)
+
+// recorder is a sink and a worker that handles each candle on a goroutine,
+// noting the virtual time it sees.
+type recorder struct {
+	clock *Clock
+
+	mu        sync.Mutex
+	preloaded []candles.Candle
+	ingested  []candles.Candle
+	seen      []time.Time
+	pending   int
+}
+
+func (r *recorder) Preload(c candles.Candle) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.preloaded = append(r.preloaded, c)
+}
+
+func (r *recorder) Ingest(c candles.Candle) {
+	r.mu.Lock()
+	r.ingested = append(r.ingested, c)
+	r.pending++
+	r.mu.Unlock()
+	go func() {
+		time.Sleep(time.Millisecond)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.seen = append(r.seen, r.clock.Now())
+		r.pending--
+	}()
+}
+
+func (r *recorder) Idle() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.pending == 0
+}
+
+func series(pair string, start time.Time, n int) backtest.Series {
+	s := backtest.Series{Pair: pair}
+	for i := 0; i < n; i++ {
+		s.Candles = append(s.Candles, candles.Candle{Exchange: "backtest", Pair: pair, Interval: "1h",
+			Start: start.Add(time.Duration(i) * time.Hour), Close: float64(100 + i)})
+	}
+	return s
+}
+
+func TestPlayer(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	from := start.Add(3 * time.Hour)
+	data := []backtest.Series{series("ETHUSDT", start, 6), series("BTCUSDT", start.Add(time.Hour), 5)}
+	clock := NewClock(from, 0)
+	rec := &recorder{clock: clock}
+	p := NewPlayer(data, rec, clock, []Worker{rec}, Options{Exchange: "binance", From: from}, zerolog.Nop())
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	if len(rec.preloaded) != 5 {
+		t.Fatalf("preloaded %d candles, want 5", len(rec.preloaded))
+	}
+	if len(rec.ingested) != 6 {
+		t.Fatalf("ingested %d candles, want 6", len(rec.ingested))
+	}
+	for i, c := range rec.ingested {
+		if c.Exchange != "binance" || !c.Closed {
+			t.Fatalf("candle %d not delivered as a closed binance candle: %+v", i, c)
+		}
+		if i > 0 && c.Start.Before(rec.ingested[i-1].Start) {
+			t.Fatalf("candles out of order at %d: %v after %v", i, c.Start, rec.ingested[i-1].Start)
+		}
+	}
+	if rec.ingested[0].Pair != "BTCUSDT" || rec.ingested[1].Pair != "ETHUSDT" {
+		t.Fatalf("same-time candles should be delivered by pair: %s, %s", rec.ingested[0].Pair, rec.ingested[1].Pair)
+	}
+	// Workers drain before the clock moves on, so each sees its own close.
+	for i, at := range rec.seen {
+		want := rec.ingested[i].Start.Add(time.Hour)
+		if !at.Equal(want) {
+			t.Fatalf("worker %d saw %v, want candle close %v", i, at, want)
+		}
+	}
+	if want := start.Add(6 * time.Hour); !clock.Now().Equal(want) {
+		t.Fatalf("clock ended at %v, want %v", clock.Now(), want)
+	}
+}
+
+func TestClockSpeed(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	wall := start
+	c := NewClock(start, 60)
+	c.wall = func() time.Time { return wall }
+	c.advance(start, start.Add(time.Hour))
+
+	wall = wall.Add(30 * time.Second)
+	if got, want := c.Now(), start.Add(30*time.Minute); !got.Equal(want) {
+		t.Fatalf("after 30s at 60x: %v, want %v", got, want)
+	}
+	wall = wall.Add(time.Hour)
+	if got, want := c.Now(), start.Add(time.Hour); !got.Equal(want) {
+		t.Fatalf("clock should stop at the next close: %v, want %v", got, want)
+	}
+}
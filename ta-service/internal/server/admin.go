@@ -0,0 +1,100 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/candles"
+	"github.com/example/tg-crypto-trader/ta-service/internal/storage"
+)
+
+// SymbolManager changes the tracked symbols at runtime.
+type SymbolManager interface {
+	Watched(ctx context.Context) ([]storage.Symbol, error)
+	Watch(ctx context.Context, pair, interval, addedBy string) (storage.Symbol, error)
+	Unwatch(ctx context.Context, pair, interval, owner string) error
+}
+
+type watchRequest struct {
+	Pair     string `json:"pair"`
+	Interval string `json:"interval"`
+	AddedBy  string `json:"added_by"`
+}
+
+// EnableAdmin mounts the symbol admin endpoints. Requests must present token
+// as a bearer token. DELETE takes an optional ?owner= that must match the
+// symbol's added_by.
+func (h *HTTPServer) EnableAdmin(manager SymbolManager, token string) {
+	h.router.Route("/v1/admin", func(r chi.Router) {
+		r.Use(requireToken(token))
+		r.Get("/symbols", func(w http.ResponseWriter, r *http.Request) {
+			list, err := manager.Watched(r.Context())
+			if err != nil {
+				h.respondErr(w, http.StatusServiceUnavailable, err)
+				return
+			}
+			h.respondJSON(w, map[string]interface{}{"symbols": list})
+		})
+		r.Post("/symbols", func(w http.ResponseWriter, r *http.Request) {
+			var req watchRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				h.respondErr(w, http.StatusBadRequest, fmt.Errorf("invalid body: %w", err))
+				return
+			}
+			sym, err := manager.Watch(r.Context(), req.Pair, req.Interval, req.AddedBy)
+			if err != nil {
+				h.respondErr(w, adminStatus(err), err)
+				return
+			}
+			h.respondStatus(w, http.StatusCreated, sym)
+		})
+		r.Delete("/symbols/{pair}/{interval}", func(w http.ResponseWriter, r *http.Request) {
+			if err := manager.Unwatch(r.Context(), chi.URLParam(r, "pair"), chi.URLParam(r, "interval"), r.URL.Query().Get("owner")); err != nil {
+				h.respondErr(w, adminStatus(err), err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	})
+}
+
+func adminStatus(err error) int {
+	switch {
+	case errors.Is(err, candles.ErrInvalidSymbol), errors.Is(err, candles.ErrInvalidInterval):
+		return http.StatusBadRequest
+	case errors.Is(err, candles.ErrNotWatched):
+		return http.StatusNotFound
+	case errors.Is(err, candles.ErrNotOwner):
+		return http.StatusForbidden
+	case errors.Is(err, candles.ErrConfiguredSymbol), errors.Is(err, candles.ErrTooManySymbols):
+		return http.StatusConflict
+	default:
+		return http.StatusServiceUnavailable
+	}
+}
+
+// requireToken rejects requests without the bearer token. An empty token
+// rejects every request rather than opening the routes.
+func requireToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Authorization")
+			presented := ""
+			if strings.HasPrefix(strings.ToLower(header), "bearer ") {
+				presented = strings.TrimSpace(header[len("bearer "):])
+			}
+			if token == "" || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/alerts"
+	"github.com/example/tg-crypto-trader/ta-service/internal/model"
+)
+
+// AlertManager manages per-chat alerts and their delivery outbox.
+type AlertManager interface {
+	Create(ctx context.Context, a model.Alert) (model.Alert, error)
+	List(ctx context.Context, chatID int64) ([]model.Alert, error)
+	Delete(ctx context.Context, chatID, id int64) error
+	PendingEvents(ctx context.Context, limit int) ([]model.AlertEvent, error)
+	AckEvents(ctx context.Context, ids []int64) error
+}
+
+// EnableAlerts mounts the alert endpoints behind the admin token.
+func (h *HTTPServer) EnableAlerts(manager AlertManager, token string) {
+	h.router.Route("/v1/alerts", func(r chi.Router) {
+		r.Use(requireToken(token))
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			chatID, err := strconv.ParseInt(r.URL.Query().Get("chat_id"), 10, 64)
+			if err != nil {
+				h.respondErr(w, http.StatusBadRequest, fmt.Errorf("invalid chat_id"))
+				return
+			}
+			list, err := manager.List(r.Context(), chatID)
+			if err != nil {
+				h.respondErr(w, http.StatusServiceUnavailable, err)
+				return
+			}
+			h.respondJSON(w, map[string]interface{}{"alerts": list})
+		})
+		r.Post("/", func(w http.ResponseWriter, r *http.Request) {
+			var req model.Alert
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				h.respondErr(w, http.StatusBadRequest, fmt.Errorf("invalid body: %w", err))
+				return
+			}
+			created, err := manager.Create(r.Context(), req)
+			if err != nil {
+				h.respondErr(w, alertStatus(err), err)
+				return
+			}
+			h.respondStatus(w, http.StatusCreated, created)
+		})
+		r.Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+			if err != nil {
+				h.respondErr(w, http.StatusBadRequest, fmt.Errorf("invalid alert id"))
+				return
+			}
+			chatID, err := strconv.ParseInt(r.URL.Query().Get("chat_id"), 10, 64)
+			if err != nil {
+				h.respondErr(w, http.StatusBadRequest, fmt.Errorf("invalid chat_id"))
+				return
+			}
+			if err := manager.Delete(r.Context(), chatID, id); err != nil {
+				h.respondErr(w, alertStatus(err), err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+		r.Get("/events", func(w http.ResponseWriter, r *http.Request) {
+			limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+			events, err := manager.PendingEvents(r.Context(), limit)
+			if err != nil {
+				h.respondErr(w, http.StatusServiceUnavailable, err)
+				return
+			}
+			h.respondJSON(w, map[string]interface{}{"events": events})
+		})
+		r.Post("/events/ack", func(w http.ResponseWriter, r *http.Request) {
+			var req struct {
+				IDs []int64 `json:"ids"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				h.respondErr(w, http.StatusBadRequest, fmt.Errorf("invalid body: %w", err))
+				return
+			}
+			if err := manager.AckEvents(r.Context(), req.IDs); err != nil {
+				h.respondErr(w, http.StatusServiceUnavailable, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	})
+}
+
+func alertStatus(err error) int {
+	switch {
+	case errors.Is(err, alerts.ErrInvalidAlert):
+		return http.StatusBadRequest
+	case errors.Is(err, alerts.ErrAlertNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, alerts.ErrTooManyAlerts):
+		return http.StatusConflict
+	default:
+		return http.StatusServiceUnavailable
+	}
+}
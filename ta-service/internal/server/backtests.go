@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/backtest"
+	"github.com/example/tg-crypto-trader/ta-service/internal/model"
+)
+
+// BacktestManager queues backtest jobs and reports on them.
+type BacktestManager interface {
+	Submit(ctx context.Context, req backtest.JobRequest) (model.BacktestJob, error)
+	Get(ctx context.Context, chatID, id int64) (model.BacktestJob, error)
+}
+
+// EnableBacktests mounts the backtest job endpoints behind the admin token.
+func (h *HTTPServer) EnableBacktests(manager BacktestManager, token string) {
+	h.router.Route("/v1/backtests", func(r chi.Router) {
+		r.Use(requireToken(token))
+		r.Post("/", func(w http.ResponseWriter, r *http.Request) {
+			var req backtest.JobRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				h.respondErr(w, http.StatusBadRequest, fmt.Errorf("invalid body: %w", err))
+				return
+			}
+			job, err := manager.Submit(r.Context(), req)
+			if err != nil {
+				h.respondErr(w, backtestStatus(err), err)
+				return
+			}
+			h.respondStatus(w, http.StatusAccepted, job)
+		})
+		r.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+			if err != nil {
+				h.respondErr(w, http.StatusBadRequest, fmt.Errorf("invalid backtest id"))
+				return
+			}
+			chatID, ok := h.chatID(w, r)
+			if !ok {
+				return
+			}
+			job, err := manager.Get(r.Context(), chatID, id)
+			if err != nil {
+				h.respondErr(w, backtestStatus(err), err)
+				return
+			}
+			h.respondJSON(w, job)
+		})
+	})
+}
+
+func backtestStatus(err error) int {
+	switch {
+	case errors.Is(err, backtest.ErrInvalidJob):
+		return http.StatusBadRequest
+	case errors.Is(err, backtest.ErrJobNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, backtest.ErrQueueFull):
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusServiceUnavailable
+	}
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/filters"
+)
+
+// FilterValidation reports whether an auto-trade filter compiles.
+type FilterValidation struct {
+	Valid     bool     `json:"valid"`
+	Canonical string   `json:"canonical,omitempty"`
+	Intervals []string `json:"intervals,omitempty"`
+	Refs      []string `json:"refs,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	Position  int      `json:"position,omitempty"`
+}
+
+// validateFilter compiles a filter expression. Invalid expressions are a
+// normal outcome and return 200 with valid=false and the error position.
+func (h *HTTPServer) validateFilter(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Expression string `json:"expression"`
+		Interval   string `json:"interval"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.respondErr(w, http.StatusBadRequest, fmt.Errorf("invalid body: %w", err))
+		return
+	}
+	if req.Interval == "" {
+		req.Interval = "1m"
+	}
+	f, err := filters.Compile(req.Expression, req.Interval)
+	if err != nil {
+		result := FilterValidation{Error: err.Error()}
+		var syntaxErr *filters.Error
+		if errors.As(err, &syntaxErr) {
+			result.Position = syntaxErr.Pos + 1
+		}
+		h.respondJSON(w, result)
+		return
+	}
+	h.respondJSON(w, FilterValidation{Valid: true, Canonical: f.String(), Intervals: f.Intervals, Refs: f.Refs})
+}
@@ -2,8 +2,12 @@ package server
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/cors"
@@ -11,13 +15,16 @@ import (
 
 	"github.com/example/tg-crypto-trader/ta-service/internal/candles"
 	"github.com/example/tg-crypto-trader/ta-service/internal/indicators"
+	"github.com/example/tg-crypto-trader/ta-service/internal/telemetry"
 )
 
 // IndicatorService defines the indicator computation contract.
 type IndicatorService interface {
 	RSI(pair, interval string) (indicators.IndicatorResult, error)
 	MACD(pair, interval string) (indicators.MACDResult, error)
+	Indicator(name, pair, interval string) (indicators.IndicatorResult, error)
 	Signals(pair, interval string) (map[string]float64, error)
+	IndicatorSeries(name, pair, interval string, query indicators.SeriesQuery) (indicators.SeriesResult, error)
 }
 
 // CandleProvider fetches candles for indicator calculations.
@@ -25,6 +32,12 @@ type CandleProvider interface {
 	Candles(exchange, pair, interval string) []candles.Candle
 }
 
+// StreamHealthReporter is implemented by providers that supervise exchange
+// streams; /readyz reports unready while any stream is unhealthy.
+type StreamHealthReporter interface {
+	StreamHealth() []candles.StreamHealth
+}
+
 // HTTPServer exposes indicator endpoints.
 type HTTPServer struct {
 	router   chi.Router
@@ -37,12 +50,18 @@ type HTTPServer struct {
 func NewHTTP(service IndicatorService, provider CandleProvider, logger zerolog.Logger) *HTTPServer {
 	r := chi.NewRouter()
 	r.Use(cors.AllowAll().Handler)
+	r.Use(telemetry.Middleware(func(r *http.Request) string {
+		return chi.RouteContext(r.Context()).RoutePattern()
+	}))
 	srv := &HTTPServer{router: r, service: service, provider: provider, logger: logger}
 	r.Get("/healthz", srv.health)
-	r.Get("/readyz", srv.health)
+	r.Get("/readyz", srv.ready)
 	r.Get("/v1/indicators/rsi/{pair}/{interval}", srv.getRSI)
 	r.Get("/v1/indicators/macd/{pair}/{interval}", srv.getMACD)
 	r.Get("/v1/indicators/signals/{pair}/{interval}", srv.getSignals)
+	r.Get("/v1/indicators/{name}/{pair}/{interval}", srv.getIndicator)
+	r.Get("/v1/indicators/{name}/{pair}/{interval}/series", srv.getSeries)
+	r.Post("/v1/filters/validate", srv.validateFilter)
 	return srv
 }
 
@@ -56,6 +75,27 @@ func (h *HTTPServer) health(w http.ResponseWriter, _ *http.Request) {
 	_, _ = w.Write([]byte("ok"))
 }
 
+func (h *HTTPServer) ready(w http.ResponseWriter, _ *http.Request) {
+	reporter, ok := h.provider.(StreamHealthReporter)
+	if !ok {
+		h.health(w, nil)
+		return
+	}
+	streams := reporter.StreamHealth()
+	status := "ok"
+	for _, s := range streams {
+		if !s.Healthy {
+			status = "degraded"
+			break
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"status": status, "streams": streams})
+}
+
 func (h *HTTPServer) getRSI(w http.ResponseWriter, r *http.Request) {
 	pair := strings.ToUpper(chi.URLParam(r, "pair"))
 	interval := chi.URLParam(r, "interval")
@@ -94,8 +134,75 @@ func (h *HTTPServer) getSignals(w http.ResponseWriter, r *http.Request) {
 	h.respondJSON(w, payload)
 }
 
+func (h *HTTPServer) getIndicator(w http.ResponseWriter, r *http.Request) {
+	name := strings.ToLower(chi.URLParam(r, "name"))
+	pair := strings.ToUpper(chi.URLParam(r, "pair"))
+	interval := chi.URLParam(r, "interval")
+	value, err := h.service.Indicator(name, pair, interval)
+	if err != nil {
+		h.respondErr(w, http.StatusBadRequest, err)
+		return
+	}
+	h.respondJSON(w, value)
+}
+
+func (h *HTTPServer) getSeries(w http.ResponseWriter, r *http.Request) {
+	name := strings.ToLower(chi.URLParam(r, "name"))
+	pair := strings.ToUpper(chi.URLParam(r, "pair"))
+	interval := chi.URLParam(r, "interval")
+	query, err := parseSeriesQuery(r.URL.Query())
+	if err != nil {
+		h.respondErr(w, http.StatusBadRequest, err)
+		return
+	}
+	value, err := h.service.IndicatorSeries(name, pair, interval, query)
+	if err != nil {
+		h.respondErr(w, http.StatusBadRequest, err)
+		return
+	}
+	h.respondJSON(w, value)
+}
+
+func parseSeriesQuery(values url.Values) (indicators.SeriesQuery, error) {
+	var query indicators.SeriesQuery
+	var err error
+	if raw := values.Get("from"); raw != "" {
+		if query.From, err = parseTime(raw); err != nil {
+			return query, fmt.Errorf("invalid from: %w", err)
+		}
+	}
+	if raw := values.Get("to"); raw != "" {
+		if query.To, err = parseTime(raw); err != nil {
+			return query, fmt.Errorf("invalid to: %w", err)
+		}
+	}
+	if raw := values.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 0 {
+			return query, fmt.Errorf("invalid limit %q", raw)
+		}
+		query.Limit = limit
+	}
+	return query, nil
+}
+
+// parseTime accepts unix seconds or RFC3339 timestamps.
+func parseTime(raw string) (time.Time, error) {
+	if secs, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.Unix(secs, 0), nil
+	}
+	return time.Parse(time.RFC3339, raw)
+}
+
 func (h *HTTPServer) respondJSON(w http.ResponseWriter, payload interface{}) {
+	h.respondStatus(w, http.StatusOK, payload)
+}
+
+// respondStatus writes payload as JSON with status; the Content-Type has to
+// be set before the header is written.
+func (h *HTTPServer) respondStatus(w http.ResponseWriter, status int, payload interface{}) {
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
 	_ = json.NewEncoder(w).Encode(payload)
 }
 
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/model"
+	"github.com/example/tg-crypto-trader/ta-service/internal/storage"
+	"github.com/example/tg-crypto-trader/ta-service/internal/strategy"
+)
+
+// StrategyManager manages per-chat strategies and auto-trade filters.
+type StrategyManager interface {
+	Create(ctx context.Context, st model.Strategy) (model.Strategy, error)
+	List(ctx context.Context, chatID int64) ([]model.Strategy, error)
+	Delete(ctx context.Context, chatID, id int64) error
+	SetMode(ctx context.Context, chatID, id int64, enabled, dryRun *bool) (model.Strategy, error)
+	Filter(ctx context.Context, chatID int64) (model.ChatFilter, bool, error)
+	SetFilter(ctx context.Context, f model.ChatFilter) (model.ChatFilter, error)
+	ClearFilter(ctx context.Context, chatID int64) error
+}
+
+// EnableStrategies mounts the strategy endpoints behind the admin token.
+func (h *HTTPServer) EnableStrategies(manager StrategyManager, token string) {
+	h.router.Route("/v1/strategies", func(r chi.Router) {
+		r.Use(requireToken(token))
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			chatID, ok := h.chatID(w, r)
+			if !ok {
+				return
+			}
+			list, err := manager.List(r.Context(), chatID)
+			if err != nil {
+				h.respondErr(w, http.StatusServiceUnavailable, err)
+				return
+			}
+			resp := map[string]interface{}{"strategies": list}
+			if f, found, err := manager.Filter(r.Context(), chatID); err == nil && found {
+				resp["filter"] = f
+			}
+			h.respondJSON(w, resp)
+		})
+		r.Post("/", func(w http.ResponseWriter, r *http.Request) {
+			var req model.Strategy
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				h.respondErr(w, http.StatusBadRequest, fmt.Errorf("invalid body: %w", err))
+				return
+			}
+			created, err := manager.Create(r.Context(), req)
+			if err != nil {
+				h.respondErr(w, strategyStatus(err), err)
+				return
+			}
+			h.respondStatus(w, http.StatusCreated, created)
+		})
+		r.Get("/filter", func(w http.ResponseWriter, r *http.Request) {
+			chatID, ok := h.chatID(w, r)
+			if !ok {
+				return
+			}
+			f, found, err := manager.Filter(r.Context(), chatID)
+			if err != nil {
+				h.respondErr(w, http.StatusServiceUnavailable, err)
+				return
+			}
+			if !found {
+				h.respondErr(w, http.StatusNotFound, errors.New("no auto-trade filter"))
+				return
+			}
+			h.respondJSON(w, f)
+		})
+		r.Put("/filter", func(w http.ResponseWriter, r *http.Request) {
+			var req model.ChatFilter
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				h.respondErr(w, http.StatusBadRequest, fmt.Errorf("invalid body: %w", err))
+				return
+			}
+			f, err := manager.SetFilter(r.Context(), req)
+			if err != nil {
+				h.respondErr(w, strategyStatus(err), err)
+				return
+			}
+			h.respondJSON(w, f)
+		})
+		r.Delete("/filter", func(w http.ResponseWriter, r *http.Request) {
+			chatID, ok := h.chatID(w, r)
+			if !ok {
+				return
+			}
+			if err := manager.ClearFilter(r.Context(), chatID); err != nil {
+				h.respondErr(w, http.StatusServiceUnavailable, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+		r.Patch("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+			if err != nil {
+				h.respondErr(w, http.StatusBadRequest, fmt.Errorf("invalid strategy id"))
+				return
+			}
+			chatID, ok := h.chatID(w, r)
+			if !ok {
+				return
+			}
+			var req struct {
+				Enabled *bool `json:"enabled"`
+				DryRun  *bool `json:"dry_run"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				h.respondErr(w, http.StatusBadRequest, fmt.Errorf("invalid body: %w", err))
+				return
+			}
+			st, err := manager.SetMode(r.Context(), chatID, id, req.Enabled, req.DryRun)
+			if err != nil {
+				h.respondErr(w, strategyStatus(err), err)
+				return
+			}
+			h.respondJSON(w, st)
+		})
+		r.Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
+			id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+			if err != nil {
+				h.respondErr(w, http.StatusBadRequest, fmt.Errorf("invalid strategy id"))
+				return
+			}
+			chatID, ok := h.chatID(w, r)
+			if !ok {
+				return
+			}
+			if err := manager.Delete(r.Context(), chatID, id); err != nil {
+				h.respondErr(w, strategyStatus(err), err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	})
+}
+
+func (h *HTTPServer) chatID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	chatID, err := strconv.ParseInt(r.URL.Query().Get("chat_id"), 10, 64)
+	if err != nil {
+		h.respondErr(w, http.StatusBadRequest, fmt.Errorf("invalid chat_id"))
+		return 0, false
+	}
+	return chatID, true
+}
+
+func strategyStatus(err error) int {
+	switch {
+	case errors.Is(err, strategy.ErrInvalidStrategy):
+		return http.StatusBadRequest
+	case errors.Is(err, strategy.ErrStrategyNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, strategy.ErrTooManyStrategies), errors.Is(err, storage.ErrDuplicateStrategy):
+		return http.StatusConflict
+	default:
+		return http.StatusServiceUnavailable
+	}
+}
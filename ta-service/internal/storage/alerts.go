@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/model"
+)
+
+const alertColumns = `id, chat_id, kind, exchange, pair, interval, indicator, direction, threshold, lookback, recurring, active, armed, last_fired, created_at`
+
+// ActiveAlerts returns every alert still being evaluated.
+func (s *Store) ActiveAlerts(ctx context.Context) ([]model.Alert, error) {
+	return s.queryAlerts(ctx, `SELECT `+alertColumns+` FROM ta_alerts WHERE active ORDER BY id`)
+}
+
+// ChatAlerts returns the alerts of one chat, including fired one-shots.
+func (s *Store) ChatAlerts(ctx context.Context, chatID int64) ([]model.Alert, error) {
+	return s.queryAlerts(ctx, `SELECT `+alertColumns+` FROM ta_alerts WHERE chat_id = $1 ORDER BY id`, chatID)
+}
+
+// CountChatAlerts returns the number of active alerts of a chat.
+func (s *Store) CountChatAlerts(ctx context.Context, chatID int64) (int, error) {
+	var n int
+	err := s.pool.QueryRow(ctx, `SELECT count(*) FROM ta_alerts WHERE chat_id = $1 AND active`, chatID).Scan(&n)
+	return n, err
+}
+
+// CreateAlert inserts a and fills in its ID and creation time.
+func (s *Store) CreateAlert(ctx context.Context, a *model.Alert) error {
+	const q = `INSERT INTO ta_alerts (chat_id, kind, exchange, pair, interval, indicator, direction, threshold, lookback, recurring, active, armed)
+               VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)
+               RETURNING id, created_at`
+	return s.pool.QueryRow(ctx, q, a.ChatID, string(a.Kind), a.Exchange, a.Pair, a.Interval, a.Indicator, a.Direction,
+		a.Threshold, a.Lookback, a.Recurring, a.Active, a.Armed).Scan(&a.ID, &a.CreatedAt)
+}
+
+// DeleteAlert removes a chat's alert and reports whether it existed.
+func (s *Store) DeleteAlert(ctx context.Context, chatID, id int64) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM ta_alerts WHERE id = $1 AND chat_id = $2`, id, chatID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// SetAlertArmed records whether a recurring alert may fire again.
+func (s *Store) SetAlertArmed(ctx context.Context, id int64, armed bool) error {
+	_, err := s.pool.Exec(ctx, `UPDATE ta_alerts SET armed = $2 WHERE id = $1`, id, armed)
+	return err
+}
+
+// RecordAlertFired updates the alert state and queues ev for delivery in one
+// transaction, filling in the event ID.
+func (s *Store) RecordAlertFired(ctx context.Context, a model.Alert, ev *model.AlertEvent) error {
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `UPDATE ta_alerts SET active = $2, armed = $3, last_fired = $4 WHERE id = $1`,
+			a.ID, a.Active, a.Armed, ev.FiredAt); err != nil {
+			return err
+		}
+		return tx.QueryRow(ctx, `INSERT INTO ta_alert_events (alert_id, chat_id, message, fired_at) VALUES ($1,$2,$3,$4) RETURNING id`,
+			ev.AlertID, ev.ChatID, ev.Message, ev.FiredAt).Scan(&ev.ID)
+	})
+}
+
+// PendingAlertEvents returns undelivered events, oldest first.
+func (s *Store) PendingAlertEvents(ctx context.Context, limit int) ([]model.AlertEvent, error) {
+	const q = `SELECT id, alert_id, chat_id, message, fired_at FROM ta_alert_events
+               WHERE delivered_at IS NULL ORDER BY id LIMIT $1`
+	rows, err := s.pool.Query(ctx, q, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []model.AlertEvent
+	for rows.Next() {
+		var ev model.AlertEvent
+		if err := rows.Scan(&ev.ID, &ev.AlertID, &ev.ChatID, &ev.Message, &ev.FiredAt); err != nil {
+			return nil, err
+		}
+		out = append(out, ev)
+	}
+	return out, rows.Err()
+}
+
+// AckAlertEvents marks events delivered; already delivered IDs are ignored.
+func (s *Store) AckAlertEvents(ctx context.Context, ids []int64) error {
+	_, err := s.pool.Exec(ctx, `UPDATE ta_alert_events SET delivered_at = $2 WHERE id = ANY($1) AND delivered_at IS NULL`, ids, time.Now().UTC())
+	return err
+}
+
+func (s *Store) queryAlerts(ctx context.Context, q string, args ...interface{}) ([]model.Alert, error) {
+	rows, err := s.pool.Query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []model.Alert
+	for rows.Next() {
+		var a model.Alert
+		var kind string
+		if err := rows.Scan(&a.ID, &a.ChatID, &kind, &a.Exchange, &a.Pair, &a.Interval, &a.Indicator, &a.Direction,
+			&a.Threshold, &a.Lookback, &a.Recurring, &a.Active, &a.Armed, &a.LastFired, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		a.Kind = model.AlertKind(kind)
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
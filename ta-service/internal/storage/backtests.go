@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/model"
+)
+
+const backtestColumns = `id, chat_id, strategy, params, pair, interval, range_from, range_to, cash, status, error, result,
+	created_at, started_at, finished_at`
+
+// CreateBacktest queues job and fills in its ID, status and creation time.
+func (s *Store) CreateBacktest(ctx context.Context, job *model.BacktestJob) error {
+	const q = `INSERT INTO ta_backtests (chat_id, strategy, params, pair, interval, range_from, range_to, cash)
+	           VALUES ($1,$2,$3,$4,$5,$6,$7,$8)
+	           RETURNING id, status, created_at`
+	return s.pool.QueryRow(ctx, q, job.ChatID, job.Strategy, job.Params, job.Pair, job.Interval, job.From, job.To, job.Cash).
+		Scan(&job.ID, &job.Status, &job.CreatedAt)
+}
+
+// Backtest returns a job, if it exists.
+func (s *Store) Backtest(ctx context.Context, id int64) (model.BacktestJob, bool, error) {
+	job, err := scanBacktest(s.pool.QueryRow(ctx, `SELECT `+backtestColumns+` FROM ta_backtests WHERE id = $1`, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return job, false, nil
+	}
+	return job, err == nil, err
+}
+
+// ClaimBacktest marks the oldest queued job running and returns it. Replicas
+// skip rows another one is claiming, so each job runs once.
+func (s *Store) ClaimBacktest(ctx context.Context) (model.BacktestJob, bool, error) {
+	const q = `UPDATE ta_backtests SET status = 'running', started_at = now()
+	           WHERE id = (SELECT id FROM ta_backtests WHERE status = 'queued' ORDER BY id FOR UPDATE SKIP LOCKED LIMIT 1)
+	           RETURNING ` + backtestColumns
+	job, err := scanBacktest(s.pool.QueryRow(ctx, q))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return job, false, nil
+	}
+	return job, err == nil, err
+}
+
+// FinishBacktest stores a job's final status, error and result. Jobs no
+// longer running, such as those already failed as stale, are left alone.
+func (s *Store) FinishBacktest(ctx context.Context, job model.BacktestJob) error {
+	var result []byte
+	if len(job.Result) > 0 {
+		result = job.Result
+	}
+	_, err := s.pool.Exec(ctx, `UPDATE ta_backtests SET status = $2, error = $3, result = $4, finished_at = now() WHERE id = $1 AND status = 'running'`,
+		job.ID, job.Status, job.Error, result)
+	return err
+}
+
+// QueuedBacktests counts jobs waiting for a worker.
+func (s *Store) QueuedBacktests(ctx context.Context) (int, error) {
+	var n int
+	err := s.pool.QueryRow(ctx, `SELECT count(*) FROM ta_backtests WHERE status = 'queued'`).Scan(&n)
+	return n, err
+}
+
+// FailStaleBacktests fails running jobs started before cutoff, such as jobs
+// whose replica stopped mid-run, and reports how many there were.
+func (s *Store) FailStaleBacktests(ctx context.Context, cutoff time.Time, reason string) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `UPDATE ta_backtests SET status = 'failed', error = $2, finished_at = now()
+		WHERE status = 'running' AND started_at < $1`, cutoff, reason)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+func scanBacktest(row pgx.Row) (model.BacktestJob, error) {
+	var (
+		job    model.BacktestJob
+		result []byte
+	)
+	err := row.Scan(&job.ID, &job.ChatID, &job.Strategy, &job.Params, &job.Pair, &job.Interval, &job.From, &job.To, &job.Cash,
+		&job.Status, &job.Error, &result, &job.CreatedAt, &job.StartedAt, &job.FinishedAt)
+	if len(result) > 0 {
+		job.Result = result
+	}
+	return job, err
+}
@@ -2,7 +2,10 @@ package storage
 
 import (
 	"context"
+	"fmt"
+	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/example/tg-crypto-trader/ta-service/internal/model"
@@ -31,12 +34,60 @@ func (s *Store) Close() {
 
 // UpsertCandle writes the candle to TimescaleDB.
 func (s *Store) UpsertCandle(ctx context.Context, c model.Candle) error {
-	const q = `INSERT INTO ta_candles (exchange, pair, interval, open, high, low, close, volume, started_at)
+	_, err := s.pool.Exec(ctx, upsertCandle, c.Exchange, c.Pair, c.Interval, c.Open, c.High, c.Low, c.Close, c.Volume, c.Start)
+	return err
+}
+
+// copyThreshold is the batch size from which UpsertCandles stages rows with
+// COPY instead of sending one upsert per row.
+const copyThreshold = 256
+
+const upsertCandle = `INSERT INTO ta_candles (exchange, pair, interval, open, high, low, close, volume, started_at)
                VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9)
                ON CONFLICT (exchange, pair, interval, started_at)
                DO UPDATE SET open = EXCLUDED.open, high = EXCLUDED.high, low = EXCLUDED.low, close = EXCLUDED.close, volume = EXCLUDED.volume`
-	_, err := s.pool.Exec(ctx, q, c.Exchange, c.Pair, c.Interval, c.Open, c.High, c.Low, c.Close, c.Volume, c.Start)
-	return err
+
+// UpsertCandles writes candles in one transaction. Candles must be unique
+// by primary key. Small batches are pipelined as upserts; large ones are
+// copied into a staging table and merged.
+func (s *Store) UpsertCandles(ctx context.Context, list []model.Candle) error {
+	if len(list) == 0 {
+		return nil
+	}
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback(ctx) }()
+	if len(list) < copyThreshold {
+		batch := &pgx.Batch{}
+		for _, c := range list {
+			batch.Queue(upsertCandle, c.Exchange, c.Pair, c.Interval, c.Open, c.High, c.Low, c.Close, c.Volume, c.Start)
+		}
+		if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+			return err
+		}
+		return tx.Commit(ctx)
+	}
+	if _, err := tx.Exec(ctx, `CREATE TEMP TABLE ta_candles_stage (LIKE ta_candles) ON COMMIT DROP`); err != nil {
+		return fmt.Errorf("create stage: %w", err)
+	}
+	columns := []string{"exchange", "pair", "interval", "open", "high", "low", "close", "volume", "started_at"}
+	rows := pgx.CopyFromSlice(len(list), func(i int) ([]interface{}, error) {
+		c := list[i]
+		return []interface{}{c.Exchange, c.Pair, c.Interval, c.Open, c.High, c.Low, c.Close, c.Volume, c.Start}, nil
+	})
+	if _, err := tx.CopyFrom(ctx, pgx.Identifier{"ta_candles_stage"}, columns, rows); err != nil {
+		return fmt.Errorf("copy candles: %w", err)
+	}
+	const merge = `INSERT INTO ta_candles (exchange, pair, interval, open, high, low, close, volume, started_at)
+               SELECT exchange, pair, interval, open, high, low, close, volume, started_at FROM ta_candles_stage
+               ON CONFLICT (exchange, pair, interval, started_at)
+               DO UPDATE SET open = EXCLUDED.open, high = EXCLUDED.high, low = EXCLUDED.low, close = EXCLUDED.close, volume = EXCLUDED.volume`
+	if _, err := tx.Exec(ctx, merge); err != nil {
+		return fmt.Errorf("merge candles: %w", err)
+	}
+	return tx.Commit(ctx)
 }
 
 // LoadCandles returns the latest candles up to limit.
@@ -64,3 +115,77 @@ func (s *Store) LoadCandles(ctx context.Context, exchange, pair, interval string
 	}
 	return out, nil
 }
+
+// LoadCandleRange returns candles starting in [from, to), oldest first. A
+// zero to loads everything from from onwards.
+func (s *Store) LoadCandleRange(ctx context.Context, exchange, pair, interval string, from, to time.Time) ([]model.Candle, error) {
+	const q = `SELECT exchange, pair, interval, open, high, low, close, volume, started_at
+               FROM ta_candles
+               WHERE exchange = $1 AND pair = $2 AND interval = $3
+                 AND started_at >= $4 AND ($5::timestamptz IS NULL OR started_at < $5)
+               ORDER BY started_at`
+	var until *time.Time
+	if !to.IsZero() {
+		until = &to
+	}
+	rows, err := s.pool.Query(ctx, q, exchange, pair, interval, from, until)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []model.Candle
+	for rows.Next() {
+		var c model.Candle
+		if err := rows.Scan(&c.Exchange, &c.Pair, &c.Interval, &c.Open, &c.High, &c.Low, &c.Close, &c.Volume, &c.Start); err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// Symbol is a tracked exchange pair and interval.
+type Symbol struct {
+	Exchange  string    `json:"exchange"`
+	Pair      string    `json:"pair"`
+	Interval  string    `json:"interval"`
+	AddedBy   string    `json:"added_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ListSymbols returns the runtime watchlist.
+func (s *Store) ListSymbols(ctx context.Context) ([]Symbol, error) {
+	const q = `SELECT exchange, pair, interval, added_by, created_at FROM ta_symbols ORDER BY exchange, pair, interval`
+	rows, err := s.pool.Query(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Symbol
+	for rows.Next() {
+		var sym Symbol
+		if err := rows.Scan(&sym.Exchange, &sym.Pair, &sym.Interval, &sym.AddedBy, &sym.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, sym)
+	}
+	return out, rows.Err()
+}
+
+// AddSymbol adds a pair/interval to the watchlist; re-adding is a no-op.
+func (s *Store) AddSymbol(ctx context.Context, sym Symbol) error {
+	const q = `INSERT INTO ta_symbols (exchange, pair, interval, added_by) VALUES ($1,$2,$3,$4)
+               ON CONFLICT (exchange, pair, interval) DO NOTHING`
+	_, err := s.pool.Exec(ctx, q, sym.Exchange, sym.Pair, sym.Interval, sym.AddedBy)
+	return err
+}
+
+// RemoveSymbol deletes a pair/interval and reports whether it existed.
+func (s *Store) RemoveSymbol(ctx context.Context, exchange, pair, interval string) (bool, error) {
+	const q = `DELETE FROM ta_symbols WHERE exchange = $1 AND pair = $2 AND interval = $3`
+	tag, err := s.pool.Exec(ctx, q, exchange, pair, interval)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
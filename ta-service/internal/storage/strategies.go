@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/model"
+)
+
+// ErrDuplicateStrategy is returned when a chat reuses a strategy name.
+var ErrDuplicateStrategy = errors.New("strategy name already in use")
+
+const strategyColumns = `id, chat_id, name, pair, interval, entry, exit, size, slippage_bps, cooldown_seconds, max_positions,
+	dry_run, paper_trading, enabled, open_positions, last_trade, created_at`
+
+// EnabledStrategies returns every strategy the runner evaluates.
+func (s *Store) EnabledStrategies(ctx context.Context) ([]model.Strategy, error) {
+	return s.queryStrategies(ctx, `SELECT `+strategyColumns+` FROM ta_strategies WHERE enabled ORDER BY id`)
+}
+
+// ChatStrategies returns the strategies of one chat.
+func (s *Store) ChatStrategies(ctx context.Context, chatID int64) ([]model.Strategy, error) {
+	return s.queryStrategies(ctx, `SELECT `+strategyColumns+` FROM ta_strategies WHERE chat_id = $1 ORDER BY id`, chatID)
+}
+
+// CreateStrategy inserts st and fills in its ID and creation time. A chat
+// reusing a strategy name gets ErrDuplicateStrategy.
+func (s *Store) CreateStrategy(ctx context.Context, st *model.Strategy) error {
+	const q = `INSERT INTO ta_strategies (chat_id, name, pair, interval, entry, exit, size, slippage_bps, cooldown_seconds,
+	               max_positions, dry_run, paper_trading, enabled)
+	           VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13)
+	           RETURNING id, created_at`
+	err := s.pool.QueryRow(ctx, q, st.ChatID, st.Name, st.Pair, st.Interval, st.Entry, st.Exit, st.Size, st.SlippageBps,
+		st.CooldownSeconds, st.MaxPositions, st.DryRun, st.PaperTrading, st.Enabled).Scan(&st.ID, &st.CreatedAt)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+		return ErrDuplicateStrategy
+	}
+	return err
+}
+
+// DeleteStrategy removes a chat's strategy and reports whether it existed.
+func (s *Store) DeleteStrategy(ctx context.Context, chatID, id int64) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM ta_strategies WHERE id = $1 AND chat_id = $2`, id, chatID)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// SetStrategyMode updates whether a chat's strategy runs and whether it only
+// notifies, reporting whether it existed.
+func (s *Store) SetStrategyMode(ctx context.Context, chatID, id int64, enabled, dryRun bool) (bool, error) {
+	tag, err := s.pool.Exec(ctx, `UPDATE ta_strategies SET enabled = $3, dry_run = $4 WHERE id = $1 AND chat_id = $2`,
+		id, chatID, enabled, dryRun)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// RecordStrategyTrade stores the position count after a trade and queues the
+// chat notification in one transaction.
+func (s *Store) RecordStrategyTrade(ctx context.Context, st model.Strategy, message string) error {
+	return pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, `UPDATE ta_strategies SET open_positions = $2, last_trade = $3 WHERE id = $1`,
+			st.ID, st.OpenPositions, st.LastTrade); err != nil {
+			return err
+		}
+		return queueNotification(ctx, tx, st.ChatID, message, time.Now().UTC())
+	})
+}
+
+// QueueNotification adds a message for a chat to the alert delivery outbox.
+func (s *Store) QueueNotification(ctx context.Context, chatID int64, message string) error {
+	return queueNotification(ctx, s.pool, chatID, message, time.Now().UTC())
+}
+
+// queueNotification inserts an outbox event that is not tied to an alert;
+// alert_id 0 marks those.
+func queueNotification(ctx context.Context, db interface {
+	Exec(context.Context, string, ...interface{}) (pgconn.CommandTag, error)
+}, chatID int64, message string, at time.Time) error {
+	_, err := db.Exec(ctx, `INSERT INTO ta_alert_events (alert_id, chat_id, message, fired_at) VALUES (0, $1, $2, $3)`,
+		chatID, message, at)
+	return err
+}
+
+// ChatFilters returns every chat's auto-trade filter.
+func (s *Store) ChatFilters(ctx context.Context) ([]model.ChatFilter, error) {
+	rows, err := s.pool.Query(ctx, `SELECT chat_id, expression, interval, updated_at FROM ta_chat_filters`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []model.ChatFilter
+	for rows.Next() {
+		var f model.ChatFilter
+		if err := rows.Scan(&f.ChatID, &f.Expression, &f.Interval, &f.UpdatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, f)
+	}
+	return out, rows.Err()
+}
+
+// ChatFilter returns a chat's auto-trade filter, if set.
+func (s *Store) ChatFilter(ctx context.Context, chatID int64) (model.ChatFilter, bool, error) {
+	f := model.ChatFilter{ChatID: chatID}
+	err := s.pool.QueryRow(ctx, `SELECT expression, interval, updated_at FROM ta_chat_filters WHERE chat_id = $1`, chatID).
+		Scan(&f.Expression, &f.Interval, &f.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return f, false, nil
+	}
+	return f, err == nil, err
+}
+
+// SetChatFilter stores or replaces a chat's auto-trade filter.
+func (s *Store) SetChatFilter(ctx context.Context, f model.ChatFilter) error {
+	const q = `INSERT INTO ta_chat_filters (chat_id, expression, interval, updated_at) VALUES ($1,$2,$3,$4)
+	           ON CONFLICT (chat_id) DO UPDATE SET expression = EXCLUDED.expression, interval = EXCLUDED.interval,
+	               updated_at = EXCLUDED.updated_at`
+	_, err := s.pool.Exec(ctx, q, f.ChatID, f.Expression, f.Interval, f.UpdatedAt)
+	return err
+}
+
+// DeleteChatFilter removes a chat's auto-trade filter.
+func (s *Store) DeleteChatFilter(ctx context.Context, chatID int64) error {
+	_, err := s.pool.Exec(ctx, `DELETE FROM ta_chat_filters WHERE chat_id = $1`, chatID)
+	return err
+}
+
+func (s *Store) queryStrategies(ctx context.Context, q string, args ...interface{}) ([]model.Strategy, error) {
+	rows, err := s.pool.Query(ctx, q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []model.Strategy
+	for rows.Next() {
+		var st model.Strategy
+		if err := rows.Scan(&st.ID, &st.ChatID, &st.Name, &st.Pair, &st.Interval, &st.Entry, &st.Exit, &st.Size,
+			&st.SlippageBps, &st.CooldownSeconds, &st.MaxPositions, &st.DryRun, &st.PaperTrading, &st.Enabled,
+			&st.OpenPositions, &st.LastTrade, &st.CreatedAt); err != nil {
+			return nil, err
+		}
+		out = append(out, st)
+	}
+	return out, rows.Err()
+}
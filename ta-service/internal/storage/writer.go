@@ -0,0 +1,228 @@
+package storage
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/model"
+	"github.com/example/tg-crypto-trader/ta-service/internal/telemetry"
+)
+
+// BatchUpserter persists a batch of candles that are unique by primary key.
+type BatchUpserter interface {
+	UpsertCandles(ctx context.Context, list []model.Candle) error
+}
+
+// WriterOptions tunes the write-behind pipeline.
+type WriterOptions struct {
+	// BatchSize triggers a flush once this many distinct candles are pending.
+	BatchSize int
+	// FlushInterval bounds how long a candle waits before being written.
+	FlushInterval time.Duration
+	// MaxPending caps buffered candles; new keys are dropped beyond it.
+	MaxPending int
+	// ShutdownTimeout bounds the final flush.
+	ShutdownTimeout time.Duration
+}
+
+type candleKey struct {
+	exchange string
+	pair     string
+	interval string
+	start    int64
+}
+
+// Writer coalesces candles by primary key in memory and persists them in
+// batches off the ingest path. Repeated updates to a forming candle between
+// flushes cost a single row write.
+type Writer struct {
+	store  BatchUpserter
+	opts   WriterOptions
+	logger zerolog.Logger
+
+	mu      sync.Mutex
+	pending map[candleKey]model.Candle
+	// inflight counts candles taken by a running flush, so the MaxPending
+	// bound also covers batches that may be requeued.
+	inflight int
+	dropped  int
+
+	kick chan struct{}
+	done chan struct{}
+}
+
+// NewWriter returns a Writer; call Start to begin flushing.
+func NewWriter(store BatchUpserter, opts WriterOptions, logger zerolog.Logger) *Writer {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 500
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	if opts.MaxPending < opts.BatchSize {
+		opts.MaxPending = 100 * opts.BatchSize
+	}
+	if opts.ShutdownTimeout <= 0 {
+		opts.ShutdownTimeout = 10 * time.Second
+	}
+	return &Writer{
+		store:   store,
+		opts:    opts,
+		logger:  logger,
+		pending: make(map[candleKey]model.Candle),
+		kick:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+}
+
+// Enqueue schedules c for persistence without blocking. A pending candle
+// with the same key is replaced.
+func (w *Writer) Enqueue(c model.Candle) {
+	key := candleKey{c.Exchange, c.Pair, c.Interval, c.Start.UnixNano()}
+	w.mu.Lock()
+	if _, ok := w.pending[key]; !ok && len(w.pending)+w.inflight >= w.opts.MaxPending {
+		w.dropped++
+		dropped := w.dropped
+		w.mu.Unlock()
+		telemetry.PersistDropped.Inc()
+		if dropped == 1 || dropped%1000 == 0 {
+			w.logger.Error().Int("dropped", dropped).Msg("candle write buffer full; dropping candles")
+		}
+		return
+	}
+	w.pending[key] = c
+	size := len(w.pending)
+	w.mu.Unlock()
+	telemetry.PersistPending.Set(float64(size))
+	if size >= w.opts.BatchSize {
+		select {
+		case w.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Start flushes in the background until ctx ends, then performs a final
+// flush bounded by ShutdownTimeout.
+func (w *Writer) Start(ctx context.Context) {
+	go w.run(ctx)
+}
+
+// Close waits for the final flush after the Start context ends.
+func (w *Writer) Close() {
+	<-w.done
+}
+
+func (w *Writer) run(ctx context.Context) {
+	defer close(w.done)
+	ticker := time.NewTicker(w.opts.FlushInterval)
+	defer ticker.Stop()
+	var retryAt time.Time
+	backoff := w.opts.FlushInterval
+	for {
+		select {
+		case <-ctx.Done():
+			w.drain()
+			return
+		case <-ticker.C:
+		case <-w.kick:
+		}
+		if time.Now().Before(retryAt) {
+			continue
+		}
+		if err := w.flush(ctx); err != nil {
+			if ctx.Err() != nil {
+				continue
+			}
+			w.logger.Warn().Err(err).Dur("retry_in", backoff).Msg("candle flush failed")
+			retryAt = time.Now().Add(backoff)
+			if backoff *= 2; backoff > 30*time.Second {
+				backoff = 30 * time.Second
+			}
+			continue
+		}
+		retryAt, backoff = time.Time{}, w.opts.FlushInterval
+	}
+}
+
+// drain retries the final flush until it succeeds or the timeout passes.
+func (w *Writer) drain() {
+	ctx, cancel := context.WithTimeout(context.Background(), w.opts.ShutdownTimeout)
+	defer cancel()
+	for {
+		err := w.flush(ctx)
+		if err == nil {
+			return
+		}
+		if ctx.Err() != nil {
+			w.mu.Lock()
+			lost := len(w.pending)
+			w.mu.Unlock()
+			w.logger.Error().Err(err).Int("candles", lost).Msg("final candle flush failed")
+			return
+		}
+		select {
+		case <-ctx.Done():
+		case <-time.After(w.opts.FlushInterval):
+		}
+	}
+}
+
+// flush writes every pending candle in batches of BatchSize. Batches that
+// fail are merged back unless a newer update for the key has arrived.
+func (w *Writer) flush(ctx context.Context) error {
+	w.mu.Lock()
+	if len(w.pending) == 0 {
+		w.mu.Unlock()
+		return nil
+	}
+	taken := w.pending
+	w.pending = make(map[candleKey]model.Candle, len(taken))
+	w.inflight = len(taken)
+	w.mu.Unlock()
+
+	list := make([]model.Candle, 0, len(taken))
+	for _, c := range taken {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Start.Before(list[j].Start) })
+
+	for len(list) > 0 {
+		n := w.opts.BatchSize
+		if n > len(list) {
+			n = len(list)
+		}
+		start := time.Now()
+		err := w.store.UpsertCandles(ctx, list[:n])
+		telemetry.DBFlushSeconds.Observe(time.Since(start).Seconds())
+		if err != nil {
+			telemetry.DBFlushErrors.Inc()
+			w.requeue(list)
+			return err
+		}
+		list = list[n:]
+	}
+	w.mu.Lock()
+	w.inflight = 0
+	size := len(w.pending)
+	w.mu.Unlock()
+	telemetry.PersistPending.Set(float64(size))
+	return nil
+}
+
+func (w *Writer) requeue(list []model.Candle) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.inflight = 0
+	for _, c := range list {
+		key := candleKey{c.Exchange, c.Pair, c.Interval, c.Start.UnixNano()}
+		if _, ok := w.pending[key]; !ok {
+			w.pending[key] = c
+		}
+	}
+	telemetry.PersistPending.Set(float64(len(w.pending)))
+}
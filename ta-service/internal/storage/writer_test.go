@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/model"
+)
+
+type fakeUpserter struct {
+	mu      sync.Mutex
+	down    bool
+	batches [][]model.Candle
+	rows    map[time.Time]model.Candle
+}
+
+func (f *fakeUpserter) UpsertCandles(_ context.Context, list []model.Candle) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		return errors.New("connection refused")
+	}
+	f.batches = append(f.batches, append([]model.Candle(nil), list...))
+	for _, c := range list {
+		f.rows[c.Start] = c
+	}
+	return nil
+}
+
+func (f *fakeUpserter) setDown(down bool) {
+	f.mu.Lock()
+	f.down = down
+	f.mu.Unlock()
+}
+
+func (f *fakeUpserter) rowCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.rows)
+}
+
+func candleAt(minute int, close float64) model.Candle {
+	return model.Candle{Exchange: "binance", Pair: "ETHUSDT", Interval: "1m", Close: close, Start: time.Unix(int64(minute*60), 0)}
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWriterCoalescesAndRetries(t *testing.T) {
+	store := &fakeUpserter{rows: make(map[time.Time]model.Candle)}
+	w := NewWriter(store, WriterOptions{BatchSize: 4, FlushInterval: 10 * time.Millisecond, MaxPending: 8}, zerolog.Nop())
+
+	// Updates to the forming candle coalesce into one row with the last value.
+	for i := 0; i < 100; i++ {
+		w.Enqueue(candleAt(0, float64(i)))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w.Start(ctx)
+	waitFor(t, "first flush", func() bool { return store.rowCount() == 1 })
+	store.mu.Lock()
+	if got := store.rows[time.Unix(0, 0)].Close; got != 99 || len(store.batches[0]) != 1 {
+		store.mu.Unlock()
+		t.Fatalf("expected one coalesced row with close 99, got %v in %d rows", got, len(store.batches[0]))
+	}
+	store.mu.Unlock()
+
+	// During an outage candles stay buffered up to MaxPending distinct keys.
+	store.setDown(true)
+	for i := 1; i <= 12; i++ {
+		w.Enqueue(candleAt(i, 1))
+	}
+	time.Sleep(50 * time.Millisecond)
+	if store.rowCount() != 1 {
+		t.Fatalf("expected no writes while the store is down")
+	}
+	store.setDown(false)
+	waitFor(t, "retry after outage", func() bool { return store.rowCount() == 9 })
+	store.mu.Lock()
+	for _, b := range store.batches {
+		if len(b) > 4 {
+			store.mu.Unlock()
+			t.Fatalf("batch of %d exceeds batch size", len(b))
+		}
+	}
+	store.mu.Unlock()
+
+	// Candles enqueued just before shutdown are flushed on Close.
+	w.Enqueue(candleAt(20, 1))
+	cancel()
+	w.Close()
+	if store.rowCount() != 10 {
+		t.Fatalf("expected shutdown flush, got %d rows", store.rowCount())
+	}
+}
@@ -0,0 +1,68 @@
+package strategy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// TradeIntent mirrors the API trade payload.
+type TradeIntent struct {
+	Mode         string  `json:"mode"`
+	Token        string  `json:"token"`
+	Size         float64 `json:"size"`
+	SlippageBps  int     `json:"slippage_bps"`
+	Side         string  `json:"side"`
+	Trigger      string  `json:"trigger"`
+	PaperTrading bool    `json:"paper_trading"`
+	Interval     string  `json:"interval,omitempty"`
+	ChatID       int64   `json:"chat_id,omitempty"`
+	Strategy     string  `json:"strategy,omitempty"`
+}
+
+// APIClient submits intents to the API service's /v1/trades.
+type APIClient struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// NewAPIClient returns a client authenticating with the API bearer token.
+func NewAPIClient(baseURL, token string) *APIClient {
+	return &APIClient{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		token:   token,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// CreateTrade implements IntentSink.
+func (c *APIClient) CreateTrade(ctx context.Context, intent TradeIntent) error {
+	body, err := json.Marshal(intent)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+"/v1/trades", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("api status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
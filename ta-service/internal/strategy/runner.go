@@ -0,0 +1,548 @@
+// Package strategy runs chats' auto-trade strategies on closed candles and
+// submits the resulting trade intents through the API service.
+package strategy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/candles"
+	"github.com/example/tg-crypto-trader/ta-service/internal/filters"
+	"github.com/example/tg-crypto-trader/ta-service/internal/model"
+	"github.com/example/tg-crypto-trader/ta-service/internal/telemetry"
+)
+
+// Strategy errors.
+var (
+	ErrInvalidStrategy   = errors.New("invalid strategy")
+	ErrTooManyStrategies = errors.New("too many strategies")
+	ErrStrategyNotFound  = errors.New("strategy not found")
+)
+
+const maxPositions = 20
+
+var namePattern = regexp.MustCompile(`^[a-z0-9_-]{1,32}$`)
+
+// Store persists strategies, chat filters and the notification outbox.
+type Store interface {
+	EnabledStrategies(ctx context.Context) ([]model.Strategy, error)
+	ChatStrategies(ctx context.Context, chatID int64) ([]model.Strategy, error)
+	CreateStrategy(ctx context.Context, st *model.Strategy) error
+	DeleteStrategy(ctx context.Context, chatID, id int64) (bool, error)
+	SetStrategyMode(ctx context.Context, chatID, id int64, enabled, dryRun bool) (bool, error)
+	RecordStrategyTrade(ctx context.Context, st model.Strategy, message string) error
+	QueueNotification(ctx context.Context, chatID int64, message string) error
+	ChatFilters(ctx context.Context) ([]model.ChatFilter, error)
+	ChatFilter(ctx context.Context, chatID int64) (model.ChatFilter, bool, error)
+	SetChatFilter(ctx context.Context, f model.ChatFilter) error
+	DeleteChatFilter(ctx context.Context, chatID int64) error
+}
+
+// IntentSink submits trade intents; *APIClient posts them to the API.
+type IntentSink interface {
+	CreateTrade(ctx context.Context, intent TradeIntent) error
+}
+
+// Options tunes the runner.
+type Options struct {
+	// MaxPerChat caps the strategies of one chat.
+	MaxPerChat int
+	// Refresh is how often strategies are reloaded so changes made on other
+	// replicas are picked up.
+	Refresh time.Duration
+	// QueueSize bounds closed candles awaiting evaluation.
+	QueueSize int
+	// Now reads the clock; replays pass their virtual clock. Defaults to
+	// time.Now.
+	Now func() time.Time
+}
+
+// Runner evaluates enabled strategies on closed candles. Entries need the
+// strategy's entry rule and, when set, the chat's auto-trade filter to hold;
+// an exit closes every open position of the strategy.
+type Runner struct {
+	store   Store
+	signals filters.SignalSource
+	candles filters.CandleSource
+	sink    IntentSink
+	opts    Options
+	logger  zerolog.Logger
+	now     func() time.Time
+
+	running atomic.Bool
+	queue   chan candles.Candle
+	// pending counts queued candles and the one being evaluated.
+	pending atomic.Int64
+
+	mu         sync.Mutex
+	strategies map[int64]*compiled
+	gates      map[int64]*filters.Filter
+}
+
+type compiled struct {
+	model.Strategy
+	entry, exit *filters.Filter
+	// failing suppresses repeated failure notices while the API is down.
+	failing bool
+}
+
+// NewRunner returns a Runner. A nil sink limits every strategy to dry runs.
+// Evaluation starts with Run.
+func NewRunner(store Store, signals filters.SignalSource, source filters.CandleSource, sink IntentSink, opts Options, logger zerolog.Logger) *Runner {
+	if opts.MaxPerChat <= 0 {
+		opts.MaxPerChat = 10
+	}
+	if opts.Refresh <= 0 {
+		opts.Refresh = 15 * time.Second
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1024
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &Runner{
+		store:      store,
+		signals:    signals,
+		candles:    source,
+		sink:       sink,
+		opts:       opts,
+		logger:     logger,
+		now:        func() time.Time { return opts.Now().UTC() },
+		queue:      make(chan candles.Candle, opts.QueueSize),
+		strategies: make(map[int64]*compiled),
+		gates:      make(map[int64]*filters.Filter),
+	}
+}
+
+// OnCandleClose queues a closed candle for evaluation without blocking the
+// ingest path.
+func (r *Runner) OnCandleClose(c candles.Candle) {
+	if !r.running.Load() {
+		return
+	}
+	r.pending.Add(1)
+	select {
+	case r.queue <- c:
+	default:
+		r.pending.Add(-1)
+		r.logger.Warn().Str("pair", c.Pair).Str("interval", c.Interval).Msg("strategy queue full; candle skipped")
+	}
+}
+
+// Idle reports whether the strategy evaluation is running with no closed
+// candles queued or being evaluated. Replays wait for it before advancing
+// their clock.
+func (r *Runner) Idle() bool {
+	return r.running.Load() && r.pending.Load() == 0
+}
+
+// Run evaluates strategies until ctx ends. In cluster mode only the elected
+// collector runs it so each signal is acted on once.
+func (r *Runner) Run(ctx context.Context) {
+	for len(r.queue) > 0 {
+		<-r.queue
+		r.pending.Add(-1)
+	}
+	r.reload(ctx)
+	r.running.Store(true)
+	defer r.running.Store(false)
+	ticker := time.NewTicker(r.opts.Refresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.reload(ctx)
+		case c := <-r.queue:
+			r.evaluate(ctx, c)
+			r.pending.Add(-1)
+		}
+	}
+}
+
+func (r *Runner) reload(ctx context.Context) {
+	list, err := r.store.EnabledStrategies(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			r.logger.Warn().Err(err).Msg("failed to load strategies")
+		}
+		return
+	}
+	chatFilters, err := r.store.ChatFilters(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			r.logger.Warn().Err(err).Msg("failed to load chat filters")
+		}
+		return
+	}
+	next := make(map[int64]*compiled, len(list))
+	for _, st := range list {
+		c, err := compile(st)
+		if err != nil {
+			r.logger.Warn().Err(err).Int64("strategy", st.ID).Msg("skipping strategy that no longer compiles")
+			continue
+		}
+		next[st.ID] = c
+	}
+	gates := make(map[int64]*filters.Filter, len(chatFilters))
+	for _, f := range chatFilters {
+		gate, err := filters.Compile(f.Expression, f.Interval)
+		if err != nil {
+			r.logger.Warn().Err(err).Int64("chat", f.ChatID).Msg("skipping chat filter that no longer compiles")
+			continue
+		}
+		gates[f.ChatID] = gate
+	}
+	r.mu.Lock()
+	for id, c := range next {
+		if prev, ok := r.strategies[id]; ok {
+			c.carryOver(prev)
+		}
+	}
+	r.strategies = next
+	r.gates = gates
+	r.mu.Unlock()
+}
+
+// carryOver keeps the in-memory state of prev when c replaces it.
+func (c *compiled) carryOver(prev *compiled) {
+	c.failing = prev.failing
+	// A trade whose store write failed is only known here; keep it so its
+	// intent is not sent again.
+	if prev.LastTrade != nil && (c.LastTrade == nil || prev.LastTrade.After(*c.LastTrade)) {
+		c.OpenPositions, c.LastTrade = prev.OpenPositions, prev.LastTrade
+	}
+}
+
+// Create validates and stores a new strategy for a chat.
+func (r *Runner) Create(ctx context.Context, st model.Strategy) (model.Strategy, error) {
+	if err := r.normalize(&st); err != nil {
+		return st, err
+	}
+	c, err := compile(st)
+	if err != nil {
+		return st, err
+	}
+	existing, err := r.store.ChatStrategies(ctx, st.ChatID)
+	if err != nil {
+		return st, err
+	}
+	if len(existing) >= r.opts.MaxPerChat {
+		return st, fmt.Errorf("%w: chat has %d strategies", ErrTooManyStrategies, len(existing))
+	}
+	st.Enabled, st.OpenPositions, st.LastTrade = true, 0, nil
+	if err := r.store.CreateStrategy(ctx, &st); err != nil {
+		return st, err
+	}
+	c.Strategy = st
+	r.mu.Lock()
+	r.strategies[st.ID] = c
+	r.mu.Unlock()
+	return st, nil
+}
+
+// List returns a chat's strategies.
+func (r *Runner) List(ctx context.Context, chatID int64) ([]model.Strategy, error) {
+	return r.store.ChatStrategies(ctx, chatID)
+}
+
+// Delete removes a chat's strategy.
+func (r *Runner) Delete(ctx context.Context, chatID, id int64) error {
+	removed, err := r.store.DeleteStrategy(ctx, chatID, id)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return fmt.Errorf("%w: #%d", ErrStrategyNotFound, id)
+	}
+	r.mu.Lock()
+	delete(r.strategies, id)
+	r.mu.Unlock()
+	return nil
+}
+
+// SetMode pauses or resumes a strategy and switches it between dry runs and
+// live intents; nil leaves a setting unchanged.
+func (r *Runner) SetMode(ctx context.Context, chatID, id int64, enabled, dryRun *bool) (model.Strategy, error) {
+	list, err := r.store.ChatStrategies(ctx, chatID)
+	if err != nil {
+		return model.Strategy{}, err
+	}
+	var st model.Strategy
+	for _, candidate := range list {
+		if candidate.ID == id {
+			st = candidate
+		}
+	}
+	if st.ID == 0 {
+		return st, fmt.Errorf("%w: #%d", ErrStrategyNotFound, id)
+	}
+	if enabled != nil {
+		st.Enabled = *enabled
+	}
+	if dryRun != nil {
+		st.DryRun = *dryRun
+	}
+	if !st.DryRun && r.sink == nil {
+		return st, fmt.Errorf("%w: live trading needs TA_SERVICE_API_URL", ErrInvalidStrategy)
+	}
+	c, err := compile(st)
+	if err != nil {
+		return st, err
+	}
+	found, err := r.store.SetStrategyMode(ctx, chatID, id, st.Enabled, st.DryRun)
+	if err != nil {
+		return st, err
+	}
+	if !found {
+		return st, fmt.Errorf("%w: #%d", ErrStrategyNotFound, id)
+	}
+	r.mu.Lock()
+	if st.Enabled {
+		if prev, ok := r.strategies[id]; ok {
+			c.carryOver(prev)
+		}
+		r.strategies[id] = c
+	} else {
+		delete(r.strategies, id)
+	}
+	r.mu.Unlock()
+	return st, nil
+}
+
+// Filter returns a chat's auto-trade filter, if set.
+func (r *Runner) Filter(ctx context.Context, chatID int64) (model.ChatFilter, bool, error) {
+	return r.store.ChatFilter(ctx, chatID)
+}
+
+// SetFilter validates and stores a chat's auto-trade filter.
+func (r *Runner) SetFilter(ctx context.Context, f model.ChatFilter) (model.ChatFilter, error) {
+	f.Expression = strings.TrimSpace(f.Expression)
+	if f.Interval == "" {
+		f.Interval = "1m"
+	}
+	if f.ChatID == 0 {
+		return f, fmt.Errorf("%w: chat_id required", ErrInvalidStrategy)
+	}
+	gate, err := filters.Compile(f.Expression, f.Interval)
+	if err != nil {
+		return f, fmt.Errorf("%w: filter %v", ErrInvalidStrategy, err)
+	}
+	f.UpdatedAt = r.now()
+	if err := r.store.SetChatFilter(ctx, f); err != nil {
+		return f, err
+	}
+	r.mu.Lock()
+	r.gates[f.ChatID] = gate
+	r.mu.Unlock()
+	return f, nil
+}
+
+// ClearFilter removes a chat's auto-trade filter.
+func (r *Runner) ClearFilter(ctx context.Context, chatID int64) error {
+	if err := r.store.DeleteChatFilter(ctx, chatID); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	delete(r.gates, chatID)
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *Runner) evaluate(ctx context.Context, c candles.Candle) {
+	if c.Exchange != "binance" {
+		return
+	}
+	r.mu.Lock()
+	var matching []compiled
+	for _, st := range r.strategies {
+		if st.Pair == c.Pair && st.Interval == c.Interval {
+			matching = append(matching, *st)
+		}
+	}
+	gates := make(map[int64]*filters.Filter, len(matching))
+	for _, st := range matching {
+		if gate, ok := r.gates[st.ChatID]; ok {
+			gates[st.ChatID] = gate
+		}
+	}
+	r.mu.Unlock()
+	if len(matching) == 0 {
+		return
+	}
+	sort.Slice(matching, func(i, j int) bool { return matching[i].ID < matching[j].ID })
+	env := filters.NewMarketEnv(r.signals, r.candles, c.Pair)
+	for _, st := range matching {
+		r.step(ctx, st, gates[st.ChatID], env, c)
+	}
+}
+
+// step acts on one strategy: exits take precedence over entries, and
+// entries respect the position cap, the cooldown and the chat filter.
+func (r *Runner) step(ctx context.Context, st compiled, gate *filters.Filter, env filters.Env, c candles.Candle) {
+	log := r.logger.With().Int64("strategy", st.ID).Logger()
+	if st.OpenPositions > 0 && st.exit != nil {
+		hit, err := st.exit.Eval(env)
+		if err != nil {
+			log.Debug().Err(err).Msg("exit rule not evaluated")
+		} else if hit {
+			r.trade(ctx, st, "sell", c)
+			return
+		}
+	}
+	if st.OpenPositions >= st.MaxPositions {
+		return
+	}
+	if st.LastTrade != nil && r.now().Sub(*st.LastTrade) < st.Cooldown() {
+		return
+	}
+	for _, rule := range []*filters.Filter{st.entry, gate} {
+		if rule == nil {
+			continue
+		}
+		hit, err := rule.Eval(env)
+		if err != nil {
+			log.Debug().Err(err).Str("rule", rule.Source).Msg("entry rule not evaluated")
+			return
+		}
+		if !hit {
+			return
+		}
+	}
+	r.trade(ctx, st, "buy", c)
+}
+
+func (r *Runner) trade(ctx context.Context, st compiled, side string, c candles.Candle) {
+	size := st.Size
+	if side == "sell" {
+		size = st.Size * float64(st.OpenPositions)
+	}
+	what := fmt.Sprintf("%s %s %s at %s close %s", side, formatNum(size), st.Pair, st.Interval, formatNum(c.Close))
+	var message string
+	if st.DryRun || r.sink == nil {
+		message = fmt.Sprintf("Strategy %s (dry run): would %s", st.Name, what)
+		telemetry.StrategySignals.WithLabelValues(side, "dry_run").Inc()
+	} else {
+		err := r.sink.CreateTrade(ctx, TradeIntent{
+			Mode:         "market",
+			Token:        st.Pair,
+			Size:         size,
+			SlippageBps:  st.SlippageBps,
+			Side:         side,
+			Trigger:      "auto",
+			PaperTrading: st.PaperTrading,
+			Interval:     st.Interval,
+			ChatID:       st.ChatID,
+			Strategy:     st.Name,
+		})
+		if err != nil {
+			telemetry.StrategySignals.WithLabelValues(side, "failed").Inc()
+			r.logger.Error().Err(err).Int64("strategy", st.ID).Str("side", side).Msg("failed to submit strategy intent")
+			if !st.failing {
+				if err := r.store.QueueNotification(ctx, st.ChatID, fmt.Sprintf("Strategy %s: could not %s: %v", st.Name, what, err)); err != nil {
+					r.logger.Warn().Err(err).Int64("chat", st.ChatID).Msg("failed to queue strategy notice")
+				}
+				r.update(st.ID, func(s *compiled) { s.failing = true })
+			}
+			return
+		}
+		message = fmt.Sprintf("Strategy %s: submitted %s", st.Name, what)
+		telemetry.StrategySignals.WithLabelValues(side, "submitted").Inc()
+	}
+	now := r.now()
+	st.LastTrade = &now
+	if side == "sell" {
+		st.OpenPositions = 0
+	} else {
+		st.OpenPositions++
+	}
+	st.failing = false
+	// Memory is updated even if the store write fails: the intent is
+	// already out and must not be repeated on the next candle.
+	r.update(st.ID, func(s *compiled) {
+		s.OpenPositions, s.LastTrade, s.failing = st.OpenPositions, st.LastTrade, false
+	})
+	if err := r.store.RecordStrategyTrade(ctx, st.Strategy, message); err != nil {
+		r.logger.Error().Err(err).Int64("strategy", st.ID).Msg("failed to record strategy trade")
+	}
+	r.logger.Info().Int64("strategy", st.ID).Int64("chat", st.ChatID).Str("side", side).Bool("dry_run", st.DryRun).Msg("strategy signal")
+}
+
+func (r *Runner) update(id int64, fn func(*compiled)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if st, ok := r.strategies[id]; ok {
+		fn(st)
+	}
+}
+
+// normalize fills defaults and validates a new strategy.
+func (r *Runner) normalize(st *model.Strategy) error {
+	st.Name = strings.ToLower(strings.TrimSpace(st.Name))
+	st.Pair = strings.ToUpper(strings.TrimSpace(st.Pair))
+	st.Entry = strings.TrimSpace(st.Entry)
+	st.Exit = strings.TrimSpace(st.Exit)
+	if st.ChatID == 0 {
+		return fmt.Errorf("%w: chat_id required", ErrInvalidStrategy)
+	}
+	if !namePattern.MatchString(st.Name) {
+		return fmt.Errorf("%w: name must be 1-32 letters, digits, _ or -", ErrInvalidStrategy)
+	}
+	if st.Pair == "" {
+		return fmt.Errorf("%w: pair required", ErrInvalidStrategy)
+	}
+	if !candles.ValidInterval(st.Interval) {
+		return fmt.Errorf("%w: unknown interval %q", ErrInvalidStrategy, st.Interval)
+	}
+	if st.Size <= 0 {
+		return fmt.Errorf("%w: size must be positive", ErrInvalidStrategy)
+	}
+	if st.SlippageBps == 0 {
+		st.SlippageBps = 50
+	}
+	if st.SlippageBps < 0 || st.SlippageBps > 5000 {
+		return fmt.Errorf("%w: slippage must be 1-5000 bps", ErrInvalidStrategy)
+	}
+	if st.CooldownSeconds < 0 {
+		return fmt.Errorf("%w: cooldown must not be negative", ErrInvalidStrategy)
+	}
+	if st.MaxPositions == 0 {
+		st.MaxPositions = 1
+	}
+	if st.MaxPositions < 0 || st.MaxPositions > maxPositions {
+		return fmt.Errorf("%w: max positions must be 1-%d", ErrInvalidStrategy, maxPositions)
+	}
+	if !st.DryRun && r.sink == nil {
+		return fmt.Errorf("%w: live trading needs TA_SERVICE_API_URL", ErrInvalidStrategy)
+	}
+	return nil
+}
+
+func compile(st model.Strategy) (*compiled, error) {
+	entry, err := filters.Compile(st.Entry, st.Interval)
+	if err != nil {
+		return nil, fmt.Errorf("%w: entry %v", ErrInvalidStrategy, err)
+	}
+	c := &compiled{Strategy: st, entry: entry}
+	if st.Exit != "" {
+		if c.exit, err = filters.Compile(st.Exit, st.Interval); err != nil {
+			return nil, fmt.Errorf("%w: exit %v", ErrInvalidStrategy, err)
+		}
+	}
+	return c, nil
+}
+
+func formatNum(v float64) string {
+	return strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.8f", v), "0"), ".")
+}
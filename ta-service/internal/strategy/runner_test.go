@@ -0,0 +1,316 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/candles"
+	"github.com/example/tg-crypto-trader/ta-service/internal/model"
+)
+
+type memStore struct {
+	mu         sync.Mutex
+	strategies map[int64]model.Strategy
+	filters    map[int64]model.ChatFilter
+	notices    []string
+	nextID     int64
+	// recordErr fails RecordStrategyTrade while set.
+	recordErr error
+}
+
+func newMemStore() *memStore {
+	return &memStore{strategies: make(map[int64]model.Strategy), filters: make(map[int64]model.ChatFilter)}
+}
+
+func (m *memStore) EnabledStrategies(context.Context) ([]model.Strategy, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []model.Strategy
+	for _, st := range m.strategies {
+		if st.Enabled {
+			out = append(out, st)
+		}
+	}
+	return out, nil
+}
+
+func (m *memStore) ChatStrategies(_ context.Context, chatID int64) ([]model.Strategy, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []model.Strategy
+	for _, st := range m.strategies {
+		if st.ChatID == chatID {
+			out = append(out, st)
+		}
+	}
+	return out, nil
+}
+
+func (m *memStore) CreateStrategy(_ context.Context, st *model.Strategy) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID++
+	st.ID = m.nextID
+	m.strategies[st.ID] = *st
+	return nil
+}
+
+func (m *memStore) DeleteStrategy(_ context.Context, chatID, id int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if st, ok := m.strategies[id]; !ok || st.ChatID != chatID {
+		return false, nil
+	}
+	delete(m.strategies, id)
+	return true, nil
+}
+
+func (m *memStore) SetStrategyMode(_ context.Context, chatID, id int64, enabled, dryRun bool) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st, ok := m.strategies[id]
+	if !ok || st.ChatID != chatID {
+		return false, nil
+	}
+	st.Enabled, st.DryRun = enabled, dryRun
+	m.strategies[id] = st
+	return true, nil
+}
+
+func (m *memStore) RecordStrategyTrade(_ context.Context, st model.Strategy, message string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.recordErr != nil {
+		return m.recordErr
+	}
+	m.strategies[st.ID] = st
+	m.notices = append(m.notices, message)
+	return nil
+}
+
+func (m *memStore) QueueNotification(_ context.Context, _ int64, message string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.notices = append(m.notices, message)
+	return nil
+}
+
+func (m *memStore) ChatFilters(context.Context) ([]model.ChatFilter, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []model.ChatFilter
+	for _, f := range m.filters {
+		out = append(out, f)
+	}
+	return out, nil
+}
+
+func (m *memStore) ChatFilter(_ context.Context, chatID int64) (model.ChatFilter, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.filters[chatID]
+	return f, ok, nil
+}
+
+func (m *memStore) SetChatFilter(_ context.Context, f model.ChatFilter) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.filters[f.ChatID] = f
+	return nil
+}
+
+func (m *memStore) DeleteChatFilter(_ context.Context, chatID int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.filters, chatID)
+	return nil
+}
+
+// fakeMarket serves closed 1h candles and per-bar signals, latest last.
+type fakeMarket struct {
+	history []candles.Candle
+	signals []map[string]float64
+}
+
+func (f *fakeMarket) Candles(string, string, string) []candles.Candle { return f.history }
+
+func (f *fakeMarket) SignalsAt(_, _ string, back int) (map[string]float64, error) {
+	if back >= len(f.signals) {
+		return nil, errors.New("not enough data")
+	}
+	return f.signals[len(f.signals)-1-back], nil
+}
+
+func (f *fakeMarket) close(price, rsi float64) candles.Candle {
+	c := candles.Candle{Exchange: "binance", Pair: "ETHUSDT", Interval: "1h", Close: price, Closed: true,
+		Start: time.Unix(int64(len(f.history))*3600, 0)}
+	f.history = append(f.history, c)
+	f.signals = append(f.signals, map[string]float64{"rsi": rsi})
+	return c
+}
+
+type recordingSink struct {
+	intents []TradeIntent
+	err     error
+}
+
+func (s *recordingSink) CreateTrade(_ context.Context, intent TradeIntent) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.intents = append(s.intents, intent)
+	return nil
+}
+
+func TestRunnerEntriesExitsAndLimits(t *testing.T) {
+	store := newMemStore()
+	market := &fakeMarket{}
+	sink := &recordingSink{}
+	runner := NewRunner(store, market, market, sink, Options{MaxPerChat: 2}, zerolog.Nop())
+	clock := time.Unix(0, 0)
+	runner.now = func() time.Time { return clock }
+	ctx := context.Background()
+
+	live, err := runner.Create(ctx, model.Strategy{ChatID: 7, Name: "Dip", Pair: "ethusdt", Interval: "1h",
+		Entry: "rsi < 30", Exit: "rsi crosses_above 60", Size: 0.5, CooldownSeconds: 7200, MaxPositions: 2})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	dry, err := runner.Create(ctx, model.Strategy{ChatID: 7, Name: "watch", Pair: "ETHUSDT", Interval: "1h",
+		Entry: "close > 2000", Size: 1, DryRun: true})
+	if err != nil {
+		t.Fatalf("create dry run: %v", err)
+	}
+	if _, err := runner.Create(ctx, model.Strategy{ChatID: 7, Name: "third", Pair: "ETHUSDT", Interval: "1h", Entry: "rsi < 1", Size: 1}); !errors.Is(err, ErrTooManyStrategies) {
+		t.Fatalf("expected per-chat limit, got %v", err)
+	}
+	if _, err := runner.Create(ctx, model.Strategy{ChatID: 8, Name: "bad", Pair: "ETHUSDT", Interval: "1h", Entry: "rsi <", Size: 1}); !errors.Is(err, ErrInvalidStrategy) {
+		t.Fatalf("expected invalid entry rule to be rejected, got %v", err)
+	}
+
+	step := func(price, rsi float64) {
+		clock = clock.Add(time.Hour)
+		runner.evaluate(ctx, market.close(price, rsi))
+	}
+	step(1900, 25) // live buys; dry run stays quiet below 2000
+	step(1950, 20) // cooldown blocks a second entry
+	step(2100, 22) // cooldown over: second position; dry run would buy once
+	step(2150, 24) // max positions reached
+	step(2200, 65) // rsi crosses above 60: exit both positions
+
+	if len(sink.intents) != 3 {
+		t.Fatalf("expected 3 intents, got %+v", sink.intents)
+	}
+	exit := sink.intents[2]
+	if exit.Side != "sell" || exit.Size != 1 || exit.Trigger != "auto" || exit.ChatID != 7 || exit.Strategy != "dip" || exit.Token != "ETHUSDT" {
+		t.Fatalf("unexpected exit intent %+v", exit)
+	}
+	if st := store.strategies[live.ID]; st.OpenPositions != 0 || st.LastTrade == nil {
+		t.Fatalf("positions not closed: %+v", st)
+	}
+	if st := store.strategies[dry.ID]; st.OpenPositions != 1 {
+		t.Fatalf("dry run should track simulated positions: %+v", st)
+	}
+	wantNotice := "Strategy watch (dry run): would buy 1 ETHUSDT at 1h close 2100"
+	if len(store.notices) != 4 || store.notices[2] != wantNotice {
+		t.Fatalf("unexpected notices %q", store.notices)
+	}
+
+	// A chat filter gates entries; API failures are reported once.
+	if _, err := runner.SetFilter(ctx, model.ChatFilter{ChatID: 7, Expression: "close < 1000", Interval: "1h"}); err != nil {
+		t.Fatalf("set filter: %v", err)
+	}
+	clock = clock.Add(3 * time.Hour)
+	step(1800, 10)
+	if len(sink.intents) != 3 {
+		t.Fatalf("chat filter should block the entry, got %+v", sink.intents)
+	}
+	if err := runner.ClearFilter(ctx, 7); err != nil {
+		t.Fatalf("clear filter: %v", err)
+	}
+	sink.err = errors.New("api down")
+	notices := len(store.notices)
+	step(1800, 10)
+	step(1790, 10)
+	if got := store.notices[notices:]; len(got) != 1 || !strings.Contains(got[0], "could not buy") {
+		t.Fatalf("expected a single failure notice, got %q", got)
+	}
+
+	paused := false
+	if _, err := runner.SetMode(ctx, 7, live.ID, &paused, nil); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	sink.err = nil
+	step(1780, 10)
+	if len(sink.intents) != 3 {
+		t.Fatalf("paused strategy traded: %+v", sink.intents)
+	}
+	if err := runner.Delete(ctx, 8, dry.ID); !errors.Is(err, ErrStrategyNotFound) {
+		t.Fatalf("expected other chats not to delete the strategy, got %v", err)
+	}
+}
+
+func TestRunnerKeepsUnrecordedTradesAcrossReload(t *testing.T) {
+	store := newMemStore()
+	market := &fakeMarket{}
+	sink := &recordingSink{}
+	runner := NewRunner(store, market, market, sink, Options{}, zerolog.Nop())
+	clock := time.Unix(0, 0)
+	runner.now = func() time.Time { return clock }
+	ctx := context.Background()
+	st, err := runner.Create(ctx, model.Strategy{ChatID: 7, Name: "dip", Pair: "ETHUSDT", Interval: "1h",
+		Entry: "rsi < 30", Size: 0.5, CooldownSeconds: 7200})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	store.recordErr = errors.New("connection refused")
+	clock = clock.Add(time.Hour)
+	runner.evaluate(ctx, market.close(1900, 25))
+	if len(sink.intents) != 1 {
+		t.Fatalf("expected the entry, got %+v", sink.intents)
+	}
+	// The store still shows no position; a reload or mode change must not
+	// forget the buy.
+	runner.reload(ctx)
+	clock = clock.Add(time.Hour)
+	runner.evaluate(ctx, market.close(1890, 20))
+	if len(sink.intents) != 1 {
+		t.Fatalf("buy repeated after reload: %+v", sink.intents)
+	}
+	enabled := true
+	if _, err := runner.SetMode(ctx, 7, st.ID, &enabled, nil); err != nil {
+		t.Fatalf("set mode: %v", err)
+	}
+	clock = clock.Add(time.Hour)
+	runner.evaluate(ctx, market.close(1885, 20))
+	if len(sink.intents) != 1 {
+		t.Fatalf("buy repeated after mode change: %+v", sink.intents)
+	}
+
+	// Once the store catches up its values are used again.
+	store.recordErr = nil
+	clock = clock.Add(2 * time.Hour)
+	runner.evaluate(ctx, market.close(1880, 20))
+	runner.reload(ctx)
+	runner.mu.Lock()
+	got := runner.strategies[st.ID].Strategy
+	runner.mu.Unlock()
+	if len(sink.intents) != 1 || got.OpenPositions != 1 || got.LastTrade == nil || !got.LastTrade.Equal(clock.Add(-4*time.Hour)) {
+		t.Fatalf("unexpected state after reload: %+v (intents %+v)", got, sink.intents)
+	}
+}
+
+func TestRunnerWithoutSinkIsDryRunOnly(t *testing.T) {
+	runner := NewRunner(newMemStore(), &fakeMarket{}, &fakeMarket{}, nil, Options{}, zerolog.Nop())
+	_, err := runner.Create(context.Background(), model.Strategy{ChatID: 1, Name: "x", Pair: "ETHUSDT", Interval: "1h", Entry: "rsi < 30", Size: 1})
+	if !errors.Is(err, ErrInvalidStrategy) {
+		t.Fatalf("expected live strategy to be rejected without an API, got %v", err)
+	}
+}
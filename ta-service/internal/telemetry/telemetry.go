@@ -0,0 +1,201 @@
+// Package telemetry defines the TA service's Prometheus metrics and serves
+// them on the metrics address.
+package telemetry
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog"
+)
+
+var (
+	// StreamConnected is 1 while an exchange stream is connected.
+	StreamConnected = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ta_stream_connected",
+		Help: "Whether the exchange stream for a symbol is connected.",
+	}, []string{"exchange", "symbol", "interval"})
+	// StreamReconnects counts stream reconnect attempts after a drop or dial failure.
+	StreamReconnects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ta_stream_reconnects_total",
+		Help: "Exchange stream reconnect attempts.",
+	}, []string{"exchange", "symbol", "interval"})
+	// StreamLastEvent records the unix time of the last stream event.
+	StreamLastEvent = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "ta_stream_last_event_timestamp_seconds",
+		Help: "Unix time of the last event received on the exchange stream.",
+	}, []string{"exchange", "symbol", "interval"})
+	// CandleGaps counts detected runs of missing candles.
+	CandleGaps = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ta_candle_gaps_total",
+		Help: "Detected gaps in candle start times.",
+	}, []string{"exchange", "symbol", "interval"})
+	// GapFillCandles counts candles recovered from REST backfill.
+	GapFillCandles = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ta_gap_fill_candles_total",
+		Help: "Closed candles recovered through REST gap-fill.",
+	}, []string{"exchange", "symbol", "interval"})
+	// PersistPending is the number of candles awaiting a database flush.
+	PersistPending = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "ta_persist_pending_candles",
+		Help: "Candles buffered for write-behind persistence.",
+	})
+	// PersistDropped counts candles dropped because the write buffer was full.
+	PersistDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ta_persist_dropped_total",
+		Help: "Candles dropped because the write-behind buffer was full.",
+	})
+	// DBFlushSeconds observes the latency of each candle batch upsert.
+	DBFlushSeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "ta_db_upsert_seconds",
+		Help:    "Latency of candle batch upserts.",
+		Buckets: prometheus.DefBuckets,
+	})
+	// DBFlushErrors counts failed candle batch upserts.
+	DBFlushErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ta_db_upsert_errors_total",
+		Help: "Failed candle batch upserts.",
+	})
+)
+
+var (
+	// CandlesIngested counts candle updates accepted per exchange and symbol.
+	CandlesIngested = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ta_candles_ingested_total",
+		Help: "Candle updates ingested, including forming candles.",
+	}, []string{"exchange", "symbol", "closed"})
+	// IndicatorSeconds observes indicator computation. Mode is "stream" for
+	// incremental lookups, "batch" for full recomputation and "update" for
+	// applying a closed candle to every streaming indicator.
+	IndicatorSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ta_indicator_compute_seconds",
+		Help:    "Indicator computation latency.",
+		Buckets: prometheus.ExponentialBuckets(1e-6, 4, 10),
+	}, []string{"indicator", "mode"})
+	// HTTPRequestSeconds observes REST request latency by route.
+	HTTPRequestSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "ta_http_request_duration_seconds",
+		Help:    "HTTP request latency by route and status.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+	// WSClients is the number of connected WebSocket clients.
+	WSClients = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "ta_ws_clients",
+		Help: "Connected WebSocket clients.",
+	})
+	// WSDropped counts messages dropped for slow WebSocket consumers.
+	WSDropped = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "ta_ws_dropped_messages_total",
+		Help: "WebSocket messages dropped or clients evicted by the slow-consumer policy.",
+	})
+	// AlertsFired counts alerts queued for delivery.
+	AlertsFired = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ta_alerts_fired_total",
+		Help: "Alerts that fired and were queued for Telegram delivery.",
+	}, []string{"kind"})
+	// StrategySignals counts strategy entries and exits by outcome.
+	StrategySignals = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "ta_strategy_signals_total",
+		Help: "Strategy entry and exit signals, by side and outcome (dry_run, submitted, failed).",
+	}, []string{"side", "outcome"})
+)
+
+var lastCandles = &candleAges{last: make(map[[2]string]time.Time)}
+
+func init() {
+	prometheus.MustRegister(lastCandles)
+}
+
+// ObserveCandle records an ingested candle for the ingest rate and the
+// last-candle age.
+func ObserveCandle(exchange, symbol string, closed bool) {
+	label := "false"
+	if closed {
+		label = "true"
+	}
+	CandlesIngested.WithLabelValues(exchange, symbol, label).Inc()
+	lastCandles.mu.Lock()
+	lastCandles.last[[2]string{exchange, symbol}] = time.Now()
+	lastCandles.mu.Unlock()
+}
+
+// candleAges reports the time since the last candle per exchange and symbol,
+// computed at scrape time so a silent feed shows a growing age.
+type candleAges struct {
+	mu   sync.Mutex
+	last map[[2]string]time.Time
+}
+
+var candleAgeDesc = prometheus.NewDesc(
+	"ta_last_candle_age_seconds",
+	"Seconds since the last candle update was ingested.",
+	[]string{"exchange", "symbol"}, nil,
+)
+
+func (c *candleAges) Describe(ch chan<- *prometheus.Desc) {
+	ch <- candleAgeDesc
+}
+
+func (c *candleAges) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for key, at := range c.last {
+		ch <- prometheus.MustNewConstMetric(candleAgeDesc, prometheus.GaugeValue, now.Sub(at).Seconds(), key[0], key[1])
+	}
+}
+
+// Middleware records request latency labelled with the route pattern from
+// route(r), so path parameters do not multiply series.
+func Middleware(route func(r *http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+			pattern := route(r)
+			if pattern == "" {
+				pattern = "unmatched"
+			}
+			HTTPRequestSeconds.WithLabelValues(r.Method, pattern, strconv.Itoa(rec.status)).Observe(time.Since(start).Seconds())
+		})
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// StartMetricsServer exposes Prometheus metrics at /metrics.
+func StartMetricsServer(ctx context.Context, addr string, logger zerolog.Logger) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = srv.Shutdown(context.Background())
+	}()
+
+	go func() {
+		logger.Info().Str("addr", addr).Msg("starting metrics server")
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error().Err(err).Msg("metrics server failed")
+		}
+	}()
+}
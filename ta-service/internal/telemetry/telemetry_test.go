@@ -0,0 +1,33 @@
+package telemetry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddlewareLabelsByRoute(t *testing.T) {
+	handler := Middleware(func(*http.Request) string { return "/v1/indicators/{name}" })(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	for _, path := range []string{"/v1/indicators/rsi", "/v1/indicators/macd"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+	if got := testutil.CollectAndCount(HTTPRequestSeconds); got != 1 {
+		t.Fatalf("expected one series for both paths, got %d", got)
+	}
+}
+
+func TestObserveCandleReportsAge(t *testing.T) {
+	ObserveCandle("binance", "ETHUSDT", true)
+	ObserveCandle("binance", "ETHUSDT", false)
+	ObserveCandle("uniswap", "ETHUSDC", true)
+	if got := testutil.CollectAndCount(lastCandles); got != 2 {
+		t.Fatalf("expected an age per exchange/symbol, got %d", got)
+	}
+	if got := testutil.ToFloat64(CandlesIngested.WithLabelValues("binance", "ETHUSDT", "true")); got != 1 {
+		t.Fatalf("expected one closed binance candle, got %v", got)
+	}
+}
@@ -0,0 +1,284 @@
+package ws
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/telemetry"
+)
+
+// Policy decides what happens when a client's outbound queue is full.
+type Policy string
+
+const (
+	// PolicyDisconnect evicts the slow client.
+	PolicyDisconnect Policy = "disconnect"
+	// PolicyDropOldest discards the oldest queued message to make room.
+	PolicyDropOldest Policy = "drop_oldest"
+	// PolicyDropNewest discards the message being sent.
+	PolicyDropNewest Policy = "drop_newest"
+)
+
+// client owns a connection. Only writeLoop writes to conn and only readLoop
+// reads from it, as gorilla/websocket requires.
+type client struct {
+	hub       *Hub
+	conn      *websocket.Conn
+	remote    string
+	send      chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu      sync.Mutex
+	dropped int
+	subs    map[topic]map[string]bool
+}
+
+func newClient(h *Hub, conn *websocket.Conn) *client {
+	c := &client{
+		hub:  h,
+		conn: conn,
+		send: make(chan []byte, h.opts.SendBuffer),
+		done: make(chan struct{}),
+		subs: make(map[topic]map[string]bool),
+	}
+	if conn != nil {
+		c.remote = conn.RemoteAddr().String()
+	}
+	return c
+}
+
+// enqueue hands data to the writer without blocking, applying the hub's
+// slow-consumer policy when the queue is full.
+func (c *client) enqueue(data []byte) {
+	select {
+	case <-c.done:
+		return
+	case c.send <- data:
+		return
+	default:
+	}
+	switch c.hub.opts.Policy {
+	case PolicyDropNewest:
+		c.recordDrop()
+	case PolicyDropOldest:
+		for {
+			select {
+			case <-c.done:
+				return
+			case c.send <- data:
+				return
+			default:
+			}
+			select {
+			case <-c.send:
+				c.recordDrop()
+			default:
+			}
+		}
+	default:
+		telemetry.WSDropped.Inc()
+		c.hub.logger.Warn().Str("remote", c.remote).Msg("evicting slow ws consumer")
+		c.close()
+	}
+}
+
+func (c *client) recordDrop() {
+	telemetry.WSDropped.Inc()
+	c.mu.Lock()
+	c.dropped++
+	dropped := c.dropped
+	c.mu.Unlock()
+	if dropped == 1 || dropped%100 == 0 {
+		c.hub.logger.Warn().Str("remote", c.remote).Int("dropped", dropped).Msg("dropping ws messages for slow consumer")
+	}
+}
+
+// close stops the writer and removes the client from the hub. The writer
+// sends a close frame and closes the connection, which unblocks the reader.
+func (c *client) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.hub.remove(c)
+	})
+}
+
+func (c *client) readLoop() {
+	defer c.close()
+	c.conn.SetReadLimit(maxMessageSize)
+	_ = c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	for {
+		_, payload, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				c.hub.logger.Warn().Err(err).Str("remote", c.remote).Msg("ws read failed")
+			}
+			return
+		}
+		var req Request
+		if err := json.Unmarshal(payload, &req); err != nil {
+			c.reply(Message{Type: "error", Error: "invalid message"})
+			continue
+		}
+		c.handle(req)
+	}
+}
+
+func (c *client) writeLoop() {
+	ticker := time.NewTicker(pingPeriod)
+	defer func() {
+		ticker.Stop()
+		_ = c.conn.Close()
+	}()
+	for {
+		select {
+		case <-c.done:
+			c.flush()
+			return
+		case data := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				c.hub.logger.Warn().Err(err).Str("remote", c.remote).Msg("ws write failed")
+				c.close()
+				return
+			}
+		case <-ticker.C:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.close()
+				return
+			}
+		}
+	}
+}
+
+// flush writes whatever is still queued, then a close frame.
+func (c *client) flush() {
+	_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	for {
+		select {
+		case data := <-c.send:
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		default:
+			_ = c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			return
+		}
+	}
+}
+
+func (c *client) handle(req Request) {
+	switch req.Type {
+	case "ping":
+		c.reply(Message{Type: "pong"})
+	case "subscribe", "unsubscribe":
+		t, channels, errMsg := parseSubscription(req)
+		if errMsg != "" {
+			c.reply(Message{Type: "error", Error: errMsg})
+			return
+		}
+		if req.Type == "subscribe" {
+			c.subscribe(t, channels)
+			c.reply(Message{Type: "subscribed", Exchange: t.exchange, Pair: t.pair, Interval: t.interval, Channels: channels})
+			c.sendSnapshot(t, channels)
+			return
+		}
+		c.unsubscribe(t, channels)
+		c.reply(Message{Type: "unsubscribed", Exchange: t.exchange, Pair: t.pair, Interval: t.interval, Channels: channels})
+	default:
+		c.reply(Message{Type: "error", Error: "unknown message type"})
+	}
+}
+
+func parseSubscription(req Request) (topic, []string, string) {
+	t := topic{
+		exchange: strings.ToLower(req.Exchange),
+		pair:     strings.ToUpper(req.Pair),
+		interval: req.Interval,
+	}
+	if t.exchange == "" {
+		t.exchange = "binance"
+	}
+	if t.pair == "" || t.interval == "" {
+		return topic{}, nil, "pair and interval are required"
+	}
+	channels := req.Channels
+	if len(channels) == 0 {
+		channels = []string{ChannelCandles, ChannelIndicators, ChannelAlerts}
+	}
+	for _, ch := range channels {
+		switch ch {
+		case ChannelCandles, ChannelIndicators, ChannelAlerts:
+		default:
+			return topic{}, nil, "unknown channel " + ch
+		}
+	}
+	return t, channels, ""
+}
+
+func (c *client) subscribe(t topic, channels []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	set, ok := c.subs[t]
+	if !ok {
+		set = make(map[string]bool)
+		c.subs[t] = set
+	}
+	for _, ch := range channels {
+		set[ch] = true
+	}
+}
+
+func (c *client) unsubscribe(t topic, channels []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	set, ok := c.subs[t]
+	if !ok {
+		return
+	}
+	for _, ch := range channels {
+		delete(set, ch)
+	}
+	if len(set) == 0 {
+		delete(c.subs, t)
+	}
+}
+
+func (c *client) subscribed(t topic, channel string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.subs[t][channel]
+}
+
+// sendSnapshot pushes the current state so clients need not wait for a close.
+func (c *client) sendSnapshot(t topic, channels []string) {
+	for _, ch := range channels {
+		switch ch {
+		case ChannelCandles:
+			list := c.hub.provider.Candles(t.exchange, t.pair, t.interval)
+			if len(list) > 0 {
+				c.reply(Message{Type: "candle", Exchange: t.exchange, Pair: t.pair, Interval: t.interval, Data: list[len(list)-1]})
+			}
+		case ChannelIndicators:
+			if signals, err := c.hub.service.ExchangeSignals(t.exchange, t.pair, t.interval); err == nil {
+				c.reply(Message{Type: "indicators", Exchange: t.exchange, Pair: t.pair, Interval: t.interval, Data: signals})
+			}
+		}
+	}
+}
+
+func (c *client) reply(msg Message) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+	c.enqueue(data)
+}
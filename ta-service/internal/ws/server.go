@@ -1,75 +1,157 @@
+// Package ws serves the TA WebSocket feed.
+//
+// Clients subscribe per exchange/pair/interval and channel:
+//
+//	{"type":"subscribe","exchange":"binance","pair":"ETHUSDT","interval":"1m","channels":["candles","indicators"]}
+//	{"type":"unsubscribe","exchange":"binance","pair":"ETHUSDT","interval":"1m","channels":["indicators"]}
+//	{"type":"ping"}
+//
+// The hub pushes "candle" and "indicators" messages when a candle closes and
+// "alert" messages when an alert on the topic fires, and acknowledges
+// requests with "subscribed", "unsubscribed", "pong" or "error" messages.
 package ws
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/rs/zerolog"
 
+	"github.com/example/tg-crypto-trader/ta-service/internal/alerts"
 	"github.com/example/tg-crypto-trader/ta-service/internal/candles"
-	"github.com/example/tg-crypto-trader/ta-service/internal/indicators"
+	"github.com/example/tg-crypto-trader/ta-service/internal/telemetry"
 )
 
-// Hub broadcasts indicator snapshots to clients.
+const (
+	writeWait      = 10 * time.Second
+	pongWait       = 60 * time.Second
+	pingPeriod     = (pongWait * 9) / 10
+	maxMessageSize = 4096
+)
+
+// Channel names accepted in subscriptions.
+const (
+	ChannelCandles    = "candles"
+	ChannelIndicators = "indicators"
+	ChannelAlerts     = "alerts"
+)
+
+// Request is a message sent by a client.
+type Request struct {
+	Type     string   `json:"type"`
+	Exchange string   `json:"exchange,omitempty"`
+	Pair     string   `json:"pair,omitempty"`
+	Interval string   `json:"interval,omitempty"`
+	Channels []string `json:"channels,omitempty"`
+}
+
+// Message is a payload pushed to clients.
+type Message struct {
+	Type     string      `json:"type"`
+	Exchange string      `json:"exchange,omitempty"`
+	Pair     string      `json:"pair,omitempty"`
+	Interval string      `json:"interval,omitempty"`
+	Channels []string    `json:"channels,omitempty"`
+	Data     interface{} `json:"data,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+type topic struct {
+	exchange string
+	pair     string
+	interval string
+}
+
+// SignalSource computes the indicator snapshot pushed on the indicators channel.
+type SignalSource interface {
+	ExchangeSignals(exchange, pair, interval string) (map[string]float64, error)
+}
+
+// CandleFeed provides buffered candles and candle-close notifications.
+type CandleFeed interface {
+	Candles(exchange, pair, interval string) []candles.Candle
+	OnCandleClose(fn func(candles.Candle))
+}
+
+// Options tunes authentication and per-client backpressure.
+type Options struct {
+	// Token, when set, must be presented as a bearer token or token query parameter.
+	Token string
+	// SendBuffer bounds each client's outbound queue.
+	SendBuffer int
+	// Policy applies when a client's queue is full.
+	Policy Policy
+}
+
+// Hub pushes candles, indicators and alerts to subscribed clients.
 type Hub struct {
 	upgrader websocket.Upgrader
-	clients  map[*websocket.Conn]struct{}
+	clients  map[*client]struct{}
 	mu       sync.Mutex
-	service  *indicators.Service
-	provider *candles.Service
+	service  SignalSource
+	provider CandleFeed
+	opts     Options
 	logger   zerolog.Logger
 }
 
-// NewHub builds a WebSocket hub.
-func NewHub(service *indicators.Service, provider *candles.Service, logger zerolog.Logger) *Hub {
-	return &Hub{
+// NewHub builds a WebSocket hub. It registers for candle closes, so it must
+// be created after the indicator service for pushed indicators to include
+// the closing candle.
+func NewHub(service SignalSource, provider CandleFeed, opts Options, logger zerolog.Logger) *Hub {
+	if opts.SendBuffer <= 0 {
+		opts.SendBuffer = 64
+	}
+	switch opts.Policy {
+	case PolicyDisconnect, PolicyDropOldest, PolicyDropNewest:
+	default:
+		opts.Policy = PolicyDisconnect
+	}
+	h := &Hub{
 		upgrader: websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }},
-		clients:  make(map[*websocket.Conn]struct{}),
+		clients:  make(map[*client]struct{}),
 		service:  service,
 		provider: provider,
+		opts:     opts,
 		logger:   logger,
 	}
+	provider.OnCandleClose(h.onCandleClose)
+	return h
 }
 
 // Handle upgrades HTTP connections.
 func (h *Hub) Handle(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
 	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("ws upgrade failed")
 		return
 	}
+	c := newClient(h, conn)
 	h.mu.Lock()
-	h.clients[conn] = struct{}{}
+	h.clients[c] = struct{}{}
+	telemetry.WSClients.Set(float64(len(h.clients)))
 	h.mu.Unlock()
-	go h.writer(conn)
-}
-
-func (h *Hub) writer(conn *websocket.Conn) {
-	ticker := time.NewTicker(5 * time.Second)
-	defer func() {
-		ticker.Stop()
-		conn.Close()
-		h.mu.Lock()
-		delete(h.clients, conn)
-		h.mu.Unlock()
-	}()
-	for range ticker.C {
-		// send aggregated snapshot for default symbol set
-		snapshot := make(map[string]interface{})
-		for _, pair := range []string{"ETHUSDT", "BTCUSDT"} {
-			if value, err := h.service.Signals(pair, "1m"); err == nil {
-				snapshot[pair] = value
-			}
-		}
-		payload, _ := json.Marshal(snapshot)
-		if err := conn.WriteMessage(websocket.TextMessage, payload); err != nil {
-			h.logger.Warn().Err(err).Msg("ws write failed")
-			return
-		}
+	go c.writeLoop()
+	go c.readLoop()
+}
+
+func (h *Hub) authorized(r *http.Request) bool {
+	if h.opts.Token == "" {
+		return true
 	}
+	token := r.URL.Query().Get("token")
+	if header := r.Header.Get("Authorization"); strings.HasPrefix(strings.ToLower(header), "bearer ") {
+		token = strings.TrimSpace(header[len("bearer "):])
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(h.opts.Token)) == 1
 }
 
 // Broadcast sends the payload to all clients.
@@ -78,13 +160,85 @@ func (h *Hub) Broadcast(payload interface{}) {
 	if err != nil {
 		return
 	}
+	for _, c := range h.snapshot() {
+		c.enqueue(data)
+	}
+}
+
+// PublishAlert pushes a fired alert to clients subscribed to the alerts
+// channel of its topic.
+func (h *Hub) PublishAlert(alert alerts.Fired) {
+	t := topic{exchange: alert.Exchange, pair: alert.Pair, interval: alert.Interval}
+	h.publish(t, ChannelAlerts, Message{Type: "alert", Exchange: t.exchange, Pair: t.pair, Interval: t.interval, Data: alert})
+}
+
+func (h *Hub) onCandleClose(candle candles.Candle) {
+	t := topic{exchange: candle.Exchange, pair: candle.Pair, interval: candle.Interval}
+	h.publish(t, ChannelCandles, Message{Type: "candle", Exchange: t.exchange, Pair: t.pair, Interval: t.interval, Data: candle})
+	if !h.hasSubscribers(t, ChannelIndicators) {
+		return
+	}
+	signals, err := h.service.ExchangeSignals(t.exchange, t.pair, t.interval)
+	if err != nil {
+		h.logger.Debug().Err(err).Str("exchange", t.exchange).Str("pair", t.pair).Msg("signals unavailable for push")
+		return
+	}
+	h.publish(t, ChannelIndicators, Message{Type: "indicators", Exchange: t.exchange, Pair: t.pair, Interval: t.interval, Data: signals})
+}
+
+func (h *Hub) publish(t topic, channel string, msg Message) {
+	var data []byte
+	for _, c := range h.snapshot() {
+		if !c.subscribed(t, channel) {
+			continue
+		}
+		if data == nil {
+			var err error
+			if data, err = json.Marshal(msg); err != nil {
+				h.logger.Error().Err(err).Msg("ws marshal failed")
+				return
+			}
+		}
+		c.enqueue(data)
+	}
+}
+
+func (h *Hub) hasSubscribers(t topic, channel string) bool {
+	for _, c := range h.snapshot() {
+		if c.subscribed(t, channel) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Hub) snapshot() []*client {
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	for conn := range h.clients {
-		if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
-			h.logger.Warn().Err(err).Msg("broadcast failed")
-			conn.Close()
-			delete(h.clients, conn)
-		}
+	out := make([]*client, 0, len(h.clients))
+	for c := range h.clients {
+		out = append(out, c)
 	}
+	return out
+}
+
+// Close disconnects every client with a normal close frame.
+func (h *Hub) Close() {
+	for _, c := range h.snapshot() {
+		c.close()
+	}
+}
+
+// Clients returns the number of connected clients.
+func (h *Hub) Clients() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients)
+}
+
+func (h *Hub) remove(c *client) {
+	h.mu.Lock()
+	delete(h.clients, c)
+	telemetry.WSClients.Set(float64(len(h.clients)))
+	h.mu.Unlock()
 }
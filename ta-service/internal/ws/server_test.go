@@ -0,0 +1,281 @@
+package ws
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog"
+
+	"github.com/example/tg-crypto-trader/ta-service/internal/alerts"
+	"github.com/example/tg-crypto-trader/ta-service/internal/candles"
+)
+
+type fakeFeed struct {
+	mu        sync.Mutex
+	listeners []func(candles.Candle)
+}
+
+func (f *fakeFeed) Candles(exchange, pair, interval string) []candles.Candle { return nil }
+
+func (f *fakeFeed) OnCandleClose(fn func(candles.Candle)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.listeners = append(f.listeners, fn)
+}
+
+func (f *fakeFeed) close(c candles.Candle) {
+	f.mu.Lock()
+	listeners := append([]func(candles.Candle){}, f.listeners...)
+	f.mu.Unlock()
+	for _, fn := range listeners {
+		fn(c)
+	}
+}
+
+type fakeSignals struct{}
+
+// ExchangeSignals reports a different RSI per exchange so tests can tell
+// which exchange's indicators were pushed.
+func (fakeSignals) ExchangeSignals(exchange, pair, interval string) (map[string]float64, error) {
+	if exchange == "uniswap" {
+		return map[string]float64{"rsi": 58}, nil
+	}
+	return map[string]float64{"rsi": 42}, nil
+}
+
+func newTestHub(t *testing.T, opts Options) (*Hub, *fakeFeed, *httptest.Server) {
+	t.Helper()
+	feed := &fakeFeed{}
+	hub := NewHub(fakeSignals{}, feed, opts, zerolog.Nop())
+	srv := httptest.NewServer(http.HandlerFunc(hub.Handle))
+	t.Cleanup(func() {
+		hub.Close()
+		srv.Close()
+	})
+	return hub, feed, srv
+}
+
+func dial(t *testing.T, srv *httptest.Server, query string) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + query
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) Message {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg Message
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return msg
+}
+
+func subscribe(t *testing.T, conn *websocket.Conn, channels ...string) {
+	t.Helper()
+	req := Request{Type: "subscribe", Exchange: "binance", Pair: "ethusdt", Interval: "1m", Channels: channels}
+	if err := conn.WriteJSON(req); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	for {
+		msg := readMessage(t, conn)
+		if msg.Type == "subscribed" {
+			return
+		}
+		if msg.Type == "error" {
+			t.Fatalf("subscribe rejected: %s", msg.Error)
+		}
+	}
+}
+
+func TestHubManyClientsConcurrentPublish(t *testing.T) {
+	const (
+		clients = 40
+		closes  = 50
+		writers = 8
+	)
+	hub, feed, srv := newTestHub(t, Options{SendBuffer: 4 * closes})
+	conns := make([]*websocket.Conn, clients)
+	for i := range conns {
+		conns[i] = dial(t, srv, "")
+		subscribe(t, conns[i], ChannelCandles, ChannelIndicators)
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < closes; i += writers {
+				feed.close(candles.Candle{
+					Exchange: "binance",
+					Pair:     "ETHUSDT",
+					Interval: "1m",
+					Close:    float64(i),
+					Start:    time.Unix(int64(i*60), 0),
+					Closed:   true,
+				})
+				hub.Broadcast(map[string]int{"tick": i})
+			}
+		}(w)
+	}
+
+	errs := make(chan string, clients)
+	var readers sync.WaitGroup
+	for _, conn := range conns {
+		readers.Add(1)
+		go func(conn *websocket.Conn) {
+			defer readers.Done()
+			counts := map[string]int{}
+			_ = conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+			for counts["candle"] < closes || counts["indicators"] < closes {
+				_, payload, err := conn.ReadMessage()
+				if err != nil {
+					errs <- err.Error()
+					return
+				}
+				var msg Message
+				if err := json.Unmarshal(payload, &msg); err != nil {
+					errs <- err.Error()
+					return
+				}
+				counts[msg.Type]++
+			}
+		}(conn)
+	}
+	wg.Wait()
+	readers.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("client failed: %s", err)
+	}
+	if got := hub.Clients(); got != clients {
+		t.Fatalf("expected %d clients, got %d", clients, got)
+	}
+}
+
+func TestHubUnsubscribeStopsPush(t *testing.T) {
+	_, feed, srv := newTestHub(t, Options{})
+	conn := dial(t, srv, "")
+	subscribe(t, conn, ChannelCandles)
+	if err := conn.WriteJSON(Request{Type: "unsubscribe", Pair: "ETHUSDT", Interval: "1m", Channels: []string{ChannelCandles}}); err != nil {
+		t.Fatalf("unsubscribe: %v", err)
+	}
+	if msg := readMessage(t, conn); msg.Type != "unsubscribed" {
+		t.Fatalf("expected unsubscribed, got %s", msg.Type)
+	}
+	feed.close(candles.Candle{Exchange: "binance", Pair: "ETHUSDT", Interval: "1m", Closed: true})
+	if err := conn.WriteJSON(Request{Type: "ping"}); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+	if msg := readMessage(t, conn); msg.Type != "pong" {
+		t.Fatalf("expected pong before any candle, got %s", msg.Type)
+	}
+}
+
+func TestHubPushesExchangeIndicators(t *testing.T) {
+	_, feed, srv := newTestHub(t, Options{})
+	conn := dial(t, srv, "")
+	if err := conn.WriteJSON(Request{Type: "subscribe", Exchange: "uniswap", Pair: "WETH/USDC", Interval: "1m", Channels: []string{ChannelIndicators}}); err != nil {
+		t.Fatalf("subscribe: %v", err)
+	}
+	for {
+		if msg := readMessage(t, conn); msg.Type == "subscribed" {
+			break
+		}
+	}
+	feed.close(candles.Candle{Exchange: "uniswap", Pair: "WETH/USDC", Interval: "1m", Closed: true})
+	msg := readMessage(t, conn)
+	if data, _ := msg.Data.(map[string]interface{}); msg.Type != "indicators" || msg.Exchange != "uniswap" || data["rsi"] != float64(58) {
+		t.Fatalf("expected uniswap indicators, got %+v", msg)
+	}
+}
+
+func TestHubPushesAlerts(t *testing.T) {
+	hub, _, srv := newTestHub(t, Options{})
+	conn := dial(t, srv, "")
+	subscribe(t, conn, ChannelAlerts)
+	hub.PublishAlert(alerts.Fired{AlertID: 1, Exchange: "binance", Pair: "BTCUSDT", Interval: "1m", Message: "other pair"})
+	hub.PublishAlert(alerts.Fired{AlertID: 2, Exchange: "binance", Pair: "ETHUSDT", Interval: "1m", Message: "Alert #2: ETHUSDT 1m closed at 4010, above 4000"})
+	msg := readMessage(t, conn)
+	if msg.Type != "alert" || msg.Pair != "ETHUSDT" {
+		t.Fatalf("expected the ETHUSDT alert, got %+v", msg)
+	}
+	if data, _ := msg.Data.(map[string]interface{}); data["alert_id"] != float64(2) || data["message"] != "Alert #2: ETHUSDT 1m closed at 4010, above 4000" {
+		t.Fatalf("unexpected alert payload %+v", msg.Data)
+	}
+}
+
+func TestHubRequiresToken(t *testing.T) {
+	_, _, srv := newTestHub(t, Options{Token: "secret"})
+	url := "ws" + strings.TrimPrefix(srv.URL, "http")
+	if _, resp, err := websocket.DefaultDialer.Dial(url, nil); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected unauthorized dial, got %v", err)
+	}
+	conn := dial(t, srv, "?token=secret")
+	if err := conn.WriteJSON(Request{Type: "ping"}); err != nil {
+		t.Fatalf("ping: %v", err)
+	}
+	if msg := readMessage(t, conn); msg.Type != "pong" {
+		t.Fatalf("expected pong, got %s", msg.Type)
+	}
+}
+
+func attachIdleClient(h *Hub) *client {
+	c := newClient(h, nil)
+	h.mu.Lock()
+	h.clients[c] = struct{}{}
+	h.mu.Unlock()
+	return c
+}
+
+func TestSlowConsumerDisconnected(t *testing.T) {
+	hub := NewHub(fakeSignals{}, &fakeFeed{}, Options{SendBuffer: 2, Policy: PolicyDisconnect}, zerolog.Nop())
+	c := attachIdleClient(hub)
+	for i := 0; i < 3; i++ {
+		c.enqueue([]byte("x"))
+	}
+	select {
+	case <-c.done:
+	default:
+		t.Fatalf("expected slow client to be evicted")
+	}
+	if hub.Clients() != 0 {
+		t.Fatalf("expected evicted client to be removed")
+	}
+}
+
+func TestSlowConsumerDropPolicies(t *testing.T) {
+	for _, tc := range []struct {
+		policy Policy
+		want   []string
+	}{
+		{PolicyDropOldest, []string{"3", "4"}},
+		{PolicyDropNewest, []string{"0", "1"}},
+	} {
+		hub := NewHub(fakeSignals{}, &fakeFeed{}, Options{SendBuffer: 2, Policy: tc.policy}, zerolog.Nop())
+		c := attachIdleClient(hub)
+		for i := 0; i < 5; i++ {
+			c.enqueue([]byte{byte('0' + i)})
+		}
+		if hub.Clients() != 1 {
+			t.Fatalf("%s: client should stay connected", tc.policy)
+		}
+		for _, want := range tc.want {
+			if got := string(<-c.send); got != want {
+				t.Fatalf("%s: expected %s, got %s", tc.policy, want, got)
+			}
+		}
+	}
+}